@@ -5,9 +5,12 @@
 package rpc
 
 import (
+	"context"
 	"crypto/tls"
 	"github.com/rpccloud/rpc/internal/base"
 	"github.com/rpccloud/rpc/internal/client"
+	"github.com/rpccloud/rpc/internal/gateway"
+	"github.com/rpccloud/rpc/internal/router"
 	"github.com/rpccloud/rpc/internal/rpc"
 	"github.com/rpccloud/rpc/internal/server"
 )
@@ -30,6 +33,12 @@ type String = rpc.String
 // Bytes ...
 type Bytes = rpc.Bytes
 
+// Time ...
+type Time = rpc.Time
+
+// Duration ...
+type Duration = rpc.Duration
+
 // Array common Array type
 type Array = rpc.Array
 
@@ -66,11 +75,70 @@ type ActionCacheFunc = rpc.ActionCacheFunc
 // Service ...
 type Service = rpc.Service
 
+// Error ...
+type Error = base.Error
+
+// ActionInterceptor ...
+type ActionInterceptor = rpc.ActionInterceptor
+
+// ActionContext ...
+type ActionContext = rpc.ActionContext
+
+// DeferredReply ...
+type DeferredReply = rpc.DeferredReply
+
+// Future ...
+type Future = rpc.Future
+
+// Principal ...
+type Principal = rpc.Principal
+
+// Authenticator ...
+type Authenticator = gateway.Authenticator
+
+// AuthenticatorFunc ...
+type AuthenticatorFunc = gateway.AuthenticatorFunc
+
+// SessionStore ...
+type SessionStore = gateway.SessionStore
+
+// NewPrincipal ...
+func NewPrincipal(name string, roles ...string) *Principal {
+	return rpc.NewPrincipal(name, roles...)
+}
+
+// WaitAll ...
+func WaitAll(futures ...*Future) []RTValue {
+	return rpc.WaitAll(futures...)
+}
+
+// WaitAny ...
+func WaitAny(futures ...*Future) (int, RTValue) {
+	return rpc.WaitAny(futures...)
+}
+
 // NewService ...
 func NewService() *Service {
 	return rpc.NewService()
 }
 
+// Marshaler is implemented by the types that write themselves to the stream
+// and read themselves back
+type Marshaler = rpc.Marshaler
+
+// DecodeValue converts the value that a struct, a slice or a map is written as,
+// back to the value that out points to
+func DecodeValue(value Any, out interface{}) *Error {
+	return rpc.DecodeValue(value, out)
+}
+
+const (
+	// CompressionFlate ...
+	CompressionFlate = rpc.CompressionFlate
+	// CompressionGzip ...
+	CompressionGzip = rpc.CompressionGzip
+)
+
 // Server ...
 type Server = server.Server
 
@@ -79,9 +147,73 @@ func NewServer() *Server {
 	return server.NewServer()
 }
 
+// RemoteHub ...
+type RemoteHub = router.RemoteHub
+
+// NewRemoteHub ...
+func NewRemoteHub() *RemoteHub {
+	return router.NewRemoteHub()
+}
+
+// Registry ...
+type Registry = router.IRegistry
+
+// RegistryNode ...
+type RegistryNode = router.RegistryNode
+
+// FileRegistry ...
+type FileRegistry = router.FileRegistry
+
+// NewFileRegistry ...
+func NewFileRegistry(path string) *FileRegistry {
+	return router.NewFileRegistry(path)
+}
+
+// Router ...
+type Router = router.Router
+
+// NewRouter ...
+func NewRouter() *Router {
+	return router.NewRouter()
+}
+
+// GatewayNode ...
+type GatewayNode = router.GatewayNode
+
+// NewGatewayNode ...
+func NewGatewayNode(
+	id uint64,
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+) *GatewayNode {
+	return router.NewGatewayNode(id, network, addr, tlsConfig)
+}
+
+// WorkerNode ...
+type WorkerNode = router.WorkerNode
+
+// NewWorkerNode ...
+func NewWorkerNode(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+) *WorkerNode {
+	return router.NewWorkerNode(network, addr, tlsConfig)
+}
+
 // Client ...
 type Client = client.Client
 
+// Invocation ...
+type Invocation = client.Invocation
+
+// Invoker ...
+type Invoker = client.Invoker
+
+// ClientInterceptor ...
+type ClientInterceptor = client.Interceptor
+
 // Dial ...
 func Dial(network string, addr string) *Client {
 	return client.Dial(network, addr)
@@ -92,6 +224,26 @@ func DialTLS(network string, addr string, tlsConfig *tls.Config) *Client {
 	return client.DialTLS(network, addr, tlsConfig)
 }
 
+// DialWithCredentials ...
+func DialWithCredentials(network string, addr string, credentials Map) *Client {
+	return client.DialWithCredentials(network, addr, credentials)
+}
+
+// DialTLSWithCredentials ...
+func DialTLSWithCredentials(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+	credentials Map,
+) *Client {
+	return client.DialTLSWithCredentials(network, addr, tlsConfig, credentials)
+}
+
+// WithMetadata ...
+func WithMetadata(ctx context.Context, key string, value string) context.Context {
+	return client.WithMetadata(ctx, key, value)
+}
+
 // GetTLSServerConfig ...
 func GetTLSServerConfig(certFile string, keyFile string) (*tls.Config, error) {
 	return base.GetTLSServerConfig(certFile, keyFile)
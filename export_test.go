@@ -1,10 +1,35 @@
 package rpc
 
 import (
+	"context"
 	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/client"
+	"github.com/rpccloud/rpc/internal/rpc"
 	"testing"
 )
 
+func TestNewPrincipal(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewPrincipal("kitty", "admin")).
+			Equal(rpc.NewPrincipal("kitty", "admin"))
+	})
+}
+
+func TestWaitAll(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WaitAll()).Equal([]RTValue{})
+	})
+}
+
+func TestWaitAny(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WaitAny()).Equal(-1, RTValue{})
+	})
+}
+
 func TestNewService(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -12,6 +37,23 @@ func TestNewService(t *testing.T) {
 	})
 }
 
+func TestDecodeValue(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := struct{ Name string }{}
+		assert(DecodeValue(Map{"Name": "kitty"}, &v)).IsNil()
+		assert(v.Name).Equal("kitty")
+	})
+}
+
+func TestCompressions(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(CompressionFlate).Equal(rpc.CompressionFlate)
+		assert(CompressionGzip).Equal(rpc.CompressionGzip)
+	})
+}
+
 func TestNewServer(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -19,6 +61,41 @@ func TestNewServer(t *testing.T) {
 	})
 }
 
+func TestNewRemoteHub(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewRemoteHub()).IsNotNil()
+	})
+}
+
+func TestNewFileRegistry(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewFileRegistry("registry.json")).IsNotNil()
+	})
+}
+
+func TestNewRouter(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewRouter()).IsNotNil()
+	})
+}
+
+func TestNewGatewayNode(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewGatewayNode(1, "tcp", "127.0.0.1:8784", nil)).IsNotNil()
+	})
+}
+
+func TestNewWorkerNode(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewWorkerNode("tcp", "127.0.0.1:8784", nil)).IsNotNil()
+	})
+}
+
 func TestDialTLS(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -38,6 +115,33 @@ func TestDial(t *testing.T) {
 	})
 }
 
+func TestDialTLSWithCredentials(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		cert, _ := GetTLSClientConfig(true, nil)
+		v := DialTLSWithCredentials("ws", "127.0.0.1", cert, Map{"token": "abc"})
+		defer v.Close()
+		assert(v).IsNotNil()
+	})
+}
+
+func TestDialWithCredentials(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := DialWithCredentials("ws", "127.0.0.1", Map{"token": "abc"})
+		defer v.Close()
+		assert(v).IsNotNil()
+	})
+}
+
+func TestWithMetadata(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WithMetadata(context.Background(), "traceID", "1234")).
+			Equal(client.WithMetadata(context.Background(), "traceID", "1234"))
+	})
+}
+
 func TestGetTLSServerConfig(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -164,6 +164,12 @@ func (p *SyncConn) OnFillWrite(_ []byte) int {
 const streamConnStatusRunning = int32(1)
 const streamConnStatusClosed = int32(0)
 
+type streamConnCompression struct {
+	compression string
+	threshold   int
+	limit       int
+}
+
 // StreamConn ...
 type StreamConn struct {
 	isDebug      bool
@@ -177,6 +183,7 @@ type StreamConn struct {
 	writeStream  *rpc.Stream
 	writePos     int
 	activeTimeNS int64
+	compression  atomic.Value
 }
 
 // NewStreamConn ...
@@ -201,6 +208,25 @@ func (p *StreamConn) SetReceiver(receiver IReceiver) {
 	p.receiver = receiver
 }
 
+// SetCompression sets the codec that the connection negotiated. The streams
+// whose bodies are longer than threshold are compressed when they are written,
+// and the compressed streams that are read can not be longer than limit
+func (p *StreamConn) SetCompression(compression string, threshold int, limit int) {
+	p.compression.Store(streamConnCompression{
+		compression: compression,
+		threshold:   threshold,
+		limit:       limit,
+	})
+}
+
+func (p *StreamConn) getCompression() streamConnCompression {
+	if v, ok := p.compression.Load().(streamConnCompression); ok {
+		return v
+	}
+
+	return streamConnCompression{}
+}
+
 // OnOpen ...
 func (p *StreamConn) OnOpen() {
 	p.receiver.OnConnOpen(p)
@@ -242,6 +268,16 @@ func (p *StreamConn) OnReadBytes(b []byte) {
 			p.readStream.PutBytes(writeBuf)
 			if p.readStream.GetWritePos() == streamLength {
 				if p.readStream.CheckStream() {
+					if p.readStream.HasStatusBitCompressed() {
+						c := p.getCompression()
+						stream, err := p.readStream.Decompress(c.compression, c.limit)
+						p.readStream.Release()
+						p.readStream = stream
+						if err != nil {
+							p.receiver.OnConnError(p, err)
+							return
+						}
+					}
 					atomic.StoreInt64(&p.activeTimeNS, base.TimeNow().UnixNano())
 					if p.isDebug {
 						p.readStream.SetStatusBitDebug()
@@ -330,6 +366,14 @@ func (p *StreamConn) WriteStreamAndRelease(stream *rpc.Stream) {
 			_ = recover()
 		}()
 
+		if c := p.getCompression(); c.compression != "" &&
+			stream.GetWritePos()-rpc.StreamHeadSize > c.threshold {
+			if compressed := stream.Compress(c.compression); compressed != nil {
+				stream.Release()
+				stream = compressed
+			}
+		}
+
 		stream.BuildStreamCheck()
 		p.writeCH <- stream
 	}()
@@ -5,6 +5,7 @@ import (
 	"github.com/rpccloud/rpc/internal/rpc"
 	"math/rand"
 	"net"
+	"strings"
 	"testing"
 	"time"
 )
@@ -365,6 +366,20 @@ func TestStreamConn_SetReceiver(t *testing.T) {
 	})
 }
 
+func TestStreamConn_SetCompression(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStreamConn(false, nil, newTestSingleReceiver())
+		assert(v.getCompression()).Equal(streamConnCompression{})
+		v.SetCompression(rpc.CompressionGzip, 1024, 4096)
+		assert(v.getCompression()).Equal(streamConnCompression{
+			compression: rpc.CompressionGzip,
+			threshold:   1024,
+			limit:       4096,
+		})
+	})
+}
+
 func TestStreamConn_OnOpen(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -431,6 +446,38 @@ func TestStreamConn_OnReadBytes(t *testing.T) {
 		assert(receiver.GetError()).Equal(base.ErrStream)
 	})
 
+	t.Run("decompress error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		receiver := newTestSingleReceiver()
+		streamConn := NewStreamConn(false, nil, receiver)
+		streamConn.OnOpen()
+		stream := rpc.NewStream()
+		stream.PutBytes([]byte{12})
+		stream.SetStatusBitCompressed()
+		stream.BuildStreamCheck()
+		streamConn.OnReadBytes(stream.GetBuffer())
+		assert(receiver.GetOnErrorCount()).Equal(1)
+		assert(receiver.GetError()).Equal(base.ErrStream)
+	})
+
+	t.Run("test compressed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		receiver := newTestSingleReceiver()
+		streamConn := NewStreamConn(false, nil, receiver)
+		streamConn.SetCompression(rpc.CompressionFlate, 0, 8192)
+		streamConn.OnOpen()
+		stream := rpc.NewStream()
+		stream.WriteString(strings.Repeat("a", 1024))
+		compressed := stream.Compress(rpc.CompressionFlate)
+		compressed.BuildStreamCheck()
+		streamConn.OnReadBytes(compressed.GetBuffer())
+		assert(receiver.GetOnErrorCount()).Equal(0)
+		assert(receiver.GetOnStreamCount()).Equal(1)
+		ret := receiver.GetStream()
+		assert(ret.HasStatusBitCompressed()).IsFalse()
+		assert(ret.ReadString()).Equal(strings.Repeat("a", 1024), nil)
+	})
+
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		for streams := 1; streams < 5; streams++ {
@@ -622,6 +669,32 @@ func TestStreamConn_WriteStreamAndRelease(t *testing.T) {
 			Equal(retStream.GetBuffer())
 	})
 
+	t.Run("write compressed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		conn := newTestNetConn(nil, 10, 4096)
+		netConn := NewServerSyncConn(conn, 1024, 1024)
+		v := NewStreamConn(false, netConn, newTestSingleReceiver())
+		v.SetCompression(rpc.CompressionGzip, 1024, 8192)
+		netConn.SetNext(v)
+		v.OnOpen()
+
+		// the body is not longer than the threshold
+		small := rpc.NewStream()
+		small.WriteString(strings.Repeat("a", 512))
+		small.BuildStreamCheck()
+		expectBuffer := small.GetBuffer()
+		v.WriteStreamAndRelease(small.Clone())
+
+		large := rpc.NewStream()
+		large.WriteString(strings.Repeat("a", 2048))
+		compressed := large.Compress(rpc.CompressionGzip)
+		compressed.BuildStreamCheck()
+		expectBuffer = append(expectBuffer, compressed.GetBuffer()...)
+		v.WriteStreamAndRelease(large)
+
+		assert(conn.writeBuf[:conn.writePos]).Equal(expectBuffer)
+	})
+
 	t.Run("write error", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		conn := newTestNetConn(nil, 10, 10)
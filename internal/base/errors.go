@@ -170,6 +170,48 @@ var (
 		ErrorLevelFatal,
 		"write to file error",
 	)
+
+	// ErrRuntimeReplyChunkNotAvailable ...
+	ErrRuntimeReplyChunkNotAvailable = DefineDevelopError(
+		generalErrorSeg|25,
+		ErrorLevelError,
+		"Runtime.ReplyChunk is only available in the outermost action",
+	)
+
+	// ErrCallDeadlineExceeded ...
+	ErrCallDeadlineExceeded = DefineNetError(
+		generalErrorSeg|26,
+		ErrorLevelWarn,
+		"",
+	)
+
+	// ErrActionForbidden ...
+	ErrActionForbidden = DefineSecurityError(
+		generalErrorSeg|27,
+		ErrorLevelWarn,
+		"",
+	)
+
+	// ErrRuntimeSessionNotAvailable ...
+	ErrRuntimeSessionNotAvailable = DefineDevelopError(
+		generalErrorSeg|28,
+		ErrorLevelError,
+		"the call does not come from a session",
+	)
+
+	// ErrRuntimeDeferNotAvailable ...
+	ErrRuntimeDeferNotAvailable = DefineDevelopError(
+		generalErrorSeg|29,
+		ErrorLevelError,
+		"Runtime.Defer is only available in the outermost action",
+	)
+
+	// ErrDeferredReplyTimeout ...
+	ErrDeferredReplyTimeout = DefineNetError(
+		generalErrorSeg|30,
+		ErrorLevelWarn,
+		"",
+	)
 )
 
 const coreErrorSeg = 1 << 8
@@ -223,6 +265,20 @@ var (
 		ErrorLevelFatal,
 		"processor is not running",
 	)
+
+	// ErrClientStubDuplicateName ... *
+	ErrClientStubDuplicateName = DefineKernelError(
+		coreErrorSeg|9,
+		ErrorLevelFatal,
+		"",
+	)
+
+	// ErrProcessorHookQueueIsFull ...
+	ErrProcessorHookQueueIsFull = DefineKernelError(
+		coreErrorSeg|10,
+		ErrorLevelError,
+		"session hook queue is full",
+	)
 )
 
 const gatewayErrorSeg = 2 << 8
@@ -255,6 +311,27 @@ var (
 		ErrorLevelWarn,
 		"gateway seed overflows",
 	)
+
+	// ErrGateWayUnauthorized ...
+	ErrGateWayUnauthorized = DefineSecurityError(
+		gatewayErrorSeg|5,
+		ErrorLevelWarn,
+		"unauthorized",
+	)
+
+	// ErrGateWayCompressionNotSupported ...
+	ErrGateWayCompressionNotSupported = DefineConfigError(
+		gatewayErrorSeg|6,
+		ErrorLevelFatal,
+		"compression is not supported",
+	)
+
+	// ErrGateWayChunksDropped ...
+	ErrGateWayChunksDropped = DefineNetError(
+		gatewayErrorSeg|7,
+		ErrorLevelWarn,
+		"the chunks of the reply have been dropped, they can not be replayed",
+	)
 )
 
 const serverErrorSeg = 3 << 8
@@ -291,6 +368,94 @@ var (
 		ErrorLevelWarn,
 		"client config error",
 	)
+
+	// ErrClientCanceled ...
+	ErrClientCanceled = DefineNetError(
+		clientErrorSeg|3,
+		ErrorLevelWarn,
+		"canceled",
+	)
+
+	// ErrClientClosed ...
+	ErrClientClosed = DefineNetError(
+		clientErrorSeg|4,
+		ErrorLevelWarn,
+		"client is closed",
+	)
+)
+
+const routerErrorSeg = 5 << 8
+
+var (
+	// ErrRouterAlreadyRunning ...
+	ErrRouterAlreadyRunning = DefineConfigError(
+		routerErrorSeg|1,
+		ErrorLevelFatal,
+		"it is already running",
+	)
+
+	// ErrRouterNoAvailableAdapter ...
+	ErrRouterNoAvailableAdapter = DefineConfigError(
+		routerErrorSeg|2,
+		ErrorLevelFatal,
+		"no listener is set on the router",
+	)
+
+	// ErrRouterGatewayConflict ...
+	ErrRouterGatewayConflict = DefineConfigError(
+		routerErrorSeg|3,
+		ErrorLevelError,
+		"gateway id is already connected to the router",
+	)
+
+	// ErrRouterNoProcessor ...
+	ErrRouterNoProcessor = DefineNetError(
+		routerErrorSeg|4,
+		ErrorLevelWarn,
+		"no processor is connected to the router",
+	)
+
+	// ErrRouterRegistryRead ...
+	ErrRouterRegistryRead = DefineConfigError(
+		routerErrorSeg|5,
+		ErrorLevelError,
+		"registry file read error",
+	)
+
+	// ErrRouterRegistryFormat ...
+	ErrRouterRegistryFormat = DefineConfigError(
+		routerErrorSeg|6,
+		ErrorLevelError,
+		"registry file format error",
+	)
+
+	// ErrRouterRegistryOpen ...
+	ErrRouterRegistryOpen = DefineConfigError(
+		routerErrorSeg|7,
+		ErrorLevelError,
+		"registry can not be opened",
+	)
+
+	// ErrRouterNoSecret ...
+	ErrRouterNoSecret = DefineConfigError(
+		routerErrorSeg|8,
+		ErrorLevelFatal,
+		"no secret is set on the router",
+	)
+
+	// ErrRouterSecretNotMatch ...
+	ErrRouterSecretNotMatch = DefineSecurityError(
+		routerErrorSeg|9,
+		ErrorLevelWarn,
+		"secret does not match",
+	)
+
+	// ErrRouterProcessorLost ...
+	ErrRouterProcessorLost = DefineNetError(
+		routerErrorSeg|10,
+		ErrorLevelWarn,
+		"processor is disconnected from the router",
+	)
 )
 
 const goAdapterErrorSeg = 101 << 8
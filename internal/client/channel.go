@@ -34,6 +34,26 @@ func (p *Channel) Free(stream *rpc.Stream) bool {
 	return false
 }
 
+// Cancel ...
+func (p *Channel) Cancel(item *SendItem) bool {
+	if item != nil && p.item == item {
+		p.item = nil
+		item.isRunning = false
+		return true
+	}
+
+	return false
+}
+
+// Chunk ...
+func (p *Channel) Chunk(stream *rpc.Stream) bool {
+	if item := p.item; item != nil {
+		return item.Chunk(stream)
+	}
+
+	return false
+}
+
 // CheckTime ...
 func (p *Channel) CheckTime(nowNS int64) bool {
 	if p.item != nil && p.item.CheckTime(nowNS) {
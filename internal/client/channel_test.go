@@ -48,6 +48,55 @@ func TestChannel_Free(t *testing.T) {
 	})
 }
 
+func TestChannel_Cancel(t *testing.T) {
+	t.Run("item is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 642, item: nil}
+		assert(v.Cancel(nil)).IsFalse()
+	})
+
+	t.Run("item is not in the channel", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		v := &Channel{sequence: 642, item: NewSendItem(0)}
+		assert(v.Cancel(item)).IsFalse()
+		assert(item.isRunning).IsTrue()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		v := &Channel{sequence: 642, item: item}
+		assert(v.Cancel(item)).IsTrue()
+		assert(v.item).IsNil()
+		assert(item.isRunning).IsFalse()
+	})
+}
+
+func TestChannel_Chunk(t *testing.T) {
+	t.Run("p.item == nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 642, item: nil}
+		assert(v.Chunk(rpc.NewStream())).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		chunk := rpc.Any(nil)
+		item.onChunk = func(value rpc.Any) {
+			chunk = value
+		}
+		v := &Channel{sequence: 642, item: item}
+		stream := rpc.NewStream()
+		stream.WriteUint64(0)
+		stream.WriteString("hello")
+		assert(v.Chunk(stream)).IsTrue()
+		assert(v.item).Equal(item)
+		assert(chunk).Equal("hello")
+	})
+}
+
 func TestChannel_CheckTime(t *testing.T) {
 	t.Run("p.item == nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -2,7 +2,9 @@
 package client
 
 import (
+	"context"
 	"crypto/tls"
+	"math"
 	"sync"
 	"time"
 
@@ -13,12 +15,32 @@ import (
 
 // Dial ...
 func Dial(network string, addr string) *Client {
-	return newClient(network, addr, nil, 1500, 1500)
+	return newClient(network, addr, nil, nil, 1500, 1500)
 }
 
 // DialTLS ...
 func DialTLS(network string, addr string, tlsConfig *tls.Config) *Client {
-	return newClient(network, addr, tlsConfig, 1500, 1500)
+	return newClient(network, addr, tlsConfig, nil, 1500, 1500)
+}
+
+// DialWithCredentials dials the server, and sends the credentials to the
+// authenticator of the server when connecting
+func DialWithCredentials(
+	network string,
+	addr string,
+	credentials rpc.Map,
+) *Client {
+	return newClient(network, addr, nil, credentials, 1500, 1500)
+}
+
+// DialTLSWithCredentials ...
+func DialTLSWithCredentials(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+	credentials rpc.Map,
+) *Client {
+	return newClient(network, addr, tlsConfig, credentials, 1500, 1500)
 }
 
 // Config ...
@@ -50,15 +72,22 @@ func (p *Subscription) Close() {
 type Client struct {
 	config          *Config
 	sessionString   string
+	credentials     rpc.Map
 	adapter         *adapter.Adapter
 	conn            *adapter.StreamConn
 	preSendHead     *SendItem
 	preSendTail     *SendItem
 	channels        []Channel
 	lastPingTimeNS  int64
+	lastPongTimeNS  int64
+	boardCastSeq    uint64
 	orcManager      *base.ORCManager
 	errorHub        rpc.IStreamHub
 	subscriptionMap map[string][]*Subscription
+	topicMap        map[string][]*Subscription
+	interceptors    []Interceptor
+	compressions    []string
+	isClosed        bool
 	sync.Mutex
 }
 
@@ -66,21 +95,29 @@ func newClient(
 	network string,
 	addr string,
 	tlsConfig *tls.Config,
+	credentials rpc.Map,
 	rBufSize int,
 	wBufSize int,
 ) *Client {
 	ret := &Client{
 		config:          &Config{},
 		sessionString:   "",
+		credentials:     credentials,
 		adapter:         nil,
 		conn:            nil,
 		preSendHead:     nil,
 		preSendTail:     nil,
 		channels:        nil,
 		lastPingTimeNS:  0,
+		lastPongTimeNS:  0,
+		boardCastSeq:    0,
 		orcManager:      base.NewORCManager(),
 		subscriptionMap: make(map[string][]*Subscription),
+		topicMap:        make(map[string][]*Subscription),
+		interceptors:    make([]Interceptor, 0),
+		compressions:    rpc.GetCompressions(),
 		errorHub:        rpc.NewLogToScreenErrorStreamHub("Client"),
+		isClosed:        false,
 	}
 
 	// init adapter
@@ -116,6 +153,17 @@ func newClient(
 	return ret
 }
 
+// SetCompressions sets the codecs that the client offers to the server, and
+// the compression is disabled if none is set. They are sent when the client
+// connects, so they take effect on the next connection if the client has
+// already connected
+func (p *Client) SetCompressions(compressions ...string) *Client {
+	p.Lock()
+	defer p.Unlock()
+	p.compressions = compressions
+	return p
+}
+
 // SetErrorHub ...
 func (p *Client) SetErrorHub(errorHub rpc.IStreamHub) {
 	p.Lock()
@@ -123,6 +171,27 @@ func (p *Client) SetErrorHub(errorHub rpc.IStreamHub) {
 	p.errorHub = errorHub
 }
 
+// AddInterceptor adds an interceptor to the client. The interceptors are
+// called in the order they are added
+func (p *Client) AddInterceptor(interceptor Interceptor) *Client {
+	p.Lock()
+	defer p.Unlock()
+
+	if interceptor != nil {
+		interceptors := make([]Interceptor, 0, len(p.interceptors)+1)
+		interceptors = append(interceptors, p.interceptors...)
+		p.interceptors = append(interceptors, interceptor)
+	}
+
+	return p
+}
+
+func (p *Client) getInterceptors() []Interceptor {
+	p.Lock()
+	defer p.Unlock()
+	return p.interceptors
+}
+
 func (p *Client) initChannel(size int) {
 	p.channels = make([]Channel, size)
 	for i := 0; i < len(p.channels); i++ {
@@ -132,7 +201,11 @@ func (p *Client) initChannel(size int) {
 }
 
 func (p *Client) initConn(stream *rpc.Stream) {
-	if kind := stream.GetKind(); kind != rpc.StreamKindConnectResponse {
+	if kind := stream.GetKind(); kind == rpc.StreamKindSystemErrorReport {
+		// the connection is rejected by the server
+		_, err := rpc.ParseResponseStream(stream)
+		p.OnConnError(p.conn, err)
+	} else if kind != rpc.StreamKindConnectResponse {
 		p.OnConnError(p.conn, base.ErrStream)
 	} else if sessionString, err := stream.ReadString(); err != nil {
 		p.OnConnError(p.conn, err)
@@ -152,6 +225,8 @@ func (p *Client) initConn(stream *rpc.Stream) {
 		p.OnConnError(p.conn, err)
 	} else if heartbeatTimeout <= 0 {
 		p.OnConnError(p.conn, base.ErrClientConfig)
+	} else if err := p.initCompression(stream, int(transLimit)); err != nil {
+		p.OnConnError(p.conn, err)
 	} else if !stream.IsReadFinish() {
 		p.OnConnError(p.conn, base.ErrStream)
 	} else if sessionString != p.sessionString {
@@ -166,6 +241,9 @@ func (p *Client) initConn(stream *rpc.Stream) {
 
 		// init channel
 		p.initChannel(p.config.numOfChannels)
+
+		// the sequence of the messages restarts with the session
+		p.boardCastSeq = 0
 	} else {
 		// try to resend channel message
 		for i := 0; i < len(p.channels); i++ {
@@ -175,7 +253,36 @@ func (p *Client) initConn(stream *rpc.Stream) {
 		}
 	}
 
+	// the server might lose the topics, so subscribe them again
+	for topic := range p.topicMap {
+		p.tryToSendTopic(rpc.StreamKindTopicSubscribe, topic)
+	}
+
 	p.lastPingTimeNS = base.TimeNow().UnixNano()
+	// the connect response also proves that the server is alive
+	p.lastPongTimeNS = p.lastPingTimeNS
+}
+
+// initCompression reads the compression that the server chooses, it is
+// omitted if the streams are not compressed
+func (p *Client) initCompression(
+	stream *rpc.Stream,
+	transLimit int,
+) *base.Error {
+	if stream.IsReadFinish() {
+		return nil
+	} else if compression, err := stream.ReadString(); err != nil {
+		return err
+	} else if !rpc.IsCompressionSupported(compression) {
+		return base.ErrClientConfig
+	} else if threshold, err := stream.ReadInt64(); err != nil {
+		return err
+	} else if threshold < 0 {
+		return base.ErrClientConfig
+	} else {
+		p.conn.SetCompression(compression, int(threshold), transLimit)
+		return nil
+	}
 }
 
 func (p *Client) tryToSendPing(nowNS int64) {
@@ -191,6 +298,48 @@ func (p *Client) tryToSendPing(nowNS int64) {
 	p.conn.WriteStreamAndRelease(stream)
 }
 
+func (p *Client) tryToSendCancel(callbackID uint64) {
+	if p.conn == nil {
+		return
+	}
+
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindRPCCancel)
+	stream.SetCallbackID(callbackID)
+	p.conn.WriteStreamAndRelease(stream)
+}
+
+func (p *Client) onBoardCast(actionPath string, value rpc.Any) {
+	if list, ok := p.subscriptionMap[actionPath]; ok {
+		for i := 0; i < len(list); i++ {
+			list[i].onMessage(value)
+		}
+	}
+}
+
+func (p *Client) tryToSendBoardCastAck(seq uint64) {
+	if p.conn == nil {
+		return
+	}
+
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindRPCBoardCastAck)
+	stream.SetCallbackID(seq)
+	p.conn.WriteStreamAndRelease(stream)
+}
+
+func (p *Client) tryToSendTopic(kind uint8, topic string) {
+	if p.conn == nil {
+		return
+	}
+
+	stream := rpc.NewStream()
+	stream.SetKind(kind)
+	stream.SetCallbackID(0)
+	stream.WriteString(topic)
+	p.conn.WriteStreamAndRelease(stream)
+}
+
 func (p *Client) tryToTimeout(nowNS int64) {
 	// sweep pre send list
 	preValidItem := (*SendItem)(nil)
@@ -217,9 +366,11 @@ func (p *Client) tryToTimeout(nowNS int64) {
 		}
 	}
 
-	// sweep the channels
+	// sweep the channels, and tell the server to cancel the timeout calls
 	for i := 0; i < len(p.channels); i++ {
-		(&p.channels[i]).CheckTime(nowNS)
+		if channel := &p.channels[i]; channel.CheckTime(nowNS) {
+			p.tryToSendCancel(channel.sequence)
+		}
 	}
 
 	// check conn timeout
@@ -286,11 +437,50 @@ func (p *Client) Subscribe(
 	return ret
 }
 
+// SubscribeTopic subscribes the topic on the server, fn is called when a
+// handler publishes a value to the topic by Runtime.Publish. The topic is
+// subscribed again when the client reconnects
+func (p *Client) SubscribeTopic(
+	topic string,
+	fn func(value rpc.Any),
+) *Subscription {
+	p.Lock()
+	defer p.Unlock()
+
+	ret := &Subscription{
+		id:        base.GetSeed(),
+		client:    p,
+		onMessage: fn,
+	}
+	list, ok := p.topicMap[topic]
+	if !ok {
+		list = make([]*Subscription, 0)
+		p.tryToSendTopic(rpc.StreamKindTopicSubscribe, topic)
+	}
+	p.topicMap[topic] = append(list, ret)
+	return ret
+}
+
 func (p *Client) unsubscribe(id int64) {
 	p.Lock()
 	defer p.Unlock()
 
-	for key, list := range p.subscriptionMap {
+	removeSubscription(p.subscriptionMap, id)
+
+	for _, topic := range removeSubscription(p.topicMap, id) {
+		p.tryToSendTopic(rpc.StreamKindTopicUnsubscribe, topic)
+	}
+}
+
+// removeSubscription removes the subscription from subscriptionMap, and
+// returns the keys that have no subscription any more
+func removeSubscription(
+	subscriptionMap map[string][]*Subscription,
+	id int64,
+) []string {
+	ret := make([]string, 0)
+
+	for key, list := range subscriptionMap {
 		pos := -1
 		for i := 0; i < len(list); i++ {
 			if list[i].id == id {
@@ -304,21 +494,24 @@ func (p *Client) unsubscribe(id int64) {
 		}
 
 		if len(list) > 0 {
-			p.subscriptionMap[key] = list
+			subscriptionMap[key] = list
 		} else {
-			delete(p.subscriptionMap, key)
+			delete(subscriptionMap, key)
+			ret = append(ret, key)
 		}
 	}
+
+	return ret
 }
 
-// Send ...
-func (p *Client) Send(
-	timeout time.Duration,
+func (p *Client) makeSendItem(
+	timeoutNS int64,
+	deadlineNS int64,
+	metadata map[string]string,
 	target string,
 	args ...interface{},
-) (interface{}, *base.Error) {
-	item := NewSendItem(int64(timeout))
-	defer item.Release()
+) (*SendItem, *base.Error) {
+	item := NewSendItem(timeoutNS)
 
 	item.sendStream.SetKind(rpc.StreamKindRPCRequest)
 	// set depth
@@ -327,15 +520,37 @@ func (p *Client) Send(
 	item.sendStream.WriteString(target)
 	// write from
 	item.sendStream.WriteString("@")
+	// write deadline
+	if deadlineNS > 0 {
+		item.sendStream.SetStatusBitDeadline()
+		item.sendStream.WriteInt64(deadlineNS)
+	}
+	// write metadata
+	if len(metadata) > 0 {
+		item.sendStream.SetStatusBitMetadata()
+		item.sendStream.WriteMetadata(metadata)
+	}
 	// write args
 	for i := 0; i < len(args); i++ {
 		if eStr := item.sendStream.Write(args[i]); eStr != rpc.StreamWriteOK {
+			item.Release()
 			return nil, base.ErrUnsupportedValue.AddDebug(eStr)
 		}
 	}
 
+	return item, nil
+}
+
+func (p *Client) sendItem(item *SendItem) {
 	// add item to the list tail
 	p.Lock()
+	defer p.Unlock()
+
+	if p.isClosed {
+		item.abort(base.ErrClientClosed)
+		return
+	}
+
 	if p.preSendTail == nil {
 		p.preSendHead = item
 		p.preSendTail = item
@@ -344,7 +559,79 @@ func (p *Client) Send(
 		p.preSendTail = item
 	}
 	p.tryToDeliverPreSendMessages()
-	p.Unlock()
+}
+
+func (p *Client) cancelItem(item *SendItem) {
+	p.Lock()
+	defer p.Unlock()
+
+	// remove item from the pre send list
+	preItem := (*SendItem)(nil)
+	for it := p.preSendHead; it != nil; preItem, it = it, it.next {
+		if it == item {
+			if preItem == nil {
+				p.preSendHead = item.next
+			} else {
+				preItem.next = item.next
+			}
+
+			if item == p.preSendTail {
+				p.preSendTail = preItem
+			}
+
+			item.next = nil
+			break
+		}
+	}
+
+	// free the channel, and tell the server to cancel the call
+	if len(p.channels) > 0 {
+		callbackID := item.sendStream.GetCallbackID()
+		channel := &p.channels[callbackID%uint64(len(p.channels))]
+		if channel.Cancel(item) {
+			p.tryToSendCancel(callbackID)
+			p.tryToDeliverPreSendMessages()
+		}
+	}
+
+	item.isRunning = false
+
+	// the response might be put into returnCH before the lock
+	select {
+	case stream := <-item.returnCH:
+		stream.Release()
+	default:
+	}
+}
+
+// Send ...
+func (p *Client) Send(
+	timeout time.Duration,
+	target string,
+	args ...interface{},
+) (interface{}, *base.Error) {
+	return intercept(
+		p.getInterceptors(),
+		newInvocation(target, args, nil),
+		func(inv *Invocation) (interface{}, *base.Error) {
+			return p.send(timeout, inv.metadata, inv.target, inv.args...)
+		},
+	)
+}
+
+func (p *Client) send(
+	timeout time.Duration,
+	metadata map[string]string,
+	target string,
+	args ...interface{},
+) (interface{}, *base.Error) {
+	item, err := p.makeSendItem(int64(timeout), 0, metadata, target, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer item.Release()
+
+	p.sendItem(item)
 
 	// wait for response
 	backStream := <-item.returnCH
@@ -353,13 +640,201 @@ func (p *Client) Send(
 	return rpc.ParseResponseStream(backStream)
 }
 
-// Close ...
+// SendContext sends the request, and waits for the response until ctx is
+// done. The deadline of ctx is sent to the server, and it can be read by
+// Runtime.GetDeadline
+func (p *Client) SendContext(
+	ctx context.Context,
+	target string,
+	args ...interface{},
+) (interface{}, *base.Error) {
+	return intercept(
+		p.getInterceptors(),
+		newInvocation(target, args, getContextMetadata(ctx)),
+		func(inv *Invocation) (interface{}, *base.Error) {
+			return p.sendContext(ctx, inv.metadata, inv.target, inv.args...)
+		},
+	)
+}
+
+func (p *Client) sendContext(
+	ctx context.Context,
+	metadata map[string]string,
+	target string,
+	args ...interface{},
+) (interface{}, *base.Error) {
+	timeoutNS, deadlineNS := int64(math.MaxInt64), int64(0)
+	if deadline, ok := ctx.Deadline(); ok {
+		deadlineNS = deadline.UnixNano()
+		timeoutNS = deadlineNS - base.TimeNow().UnixNano()
+	}
+
+	item, err := p.makeSendItem(
+		timeoutNS,
+		deadlineNS,
+		metadata,
+		target,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer item.Release()
+
+	p.sendItem(item)
+
+	// wait for response
+	select {
+	case backStream := <-item.returnCH:
+		defer backStream.Release()
+		return rpc.ParseResponseStream(backStream)
+	case <-ctx.Done():
+		p.cancelItem(item)
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, base.ErrClientTimeout
+		}
+		return nil, base.ErrClientCanceled
+	}
+}
+
+// SendStream calls an action that replies with Runtime.ReplyChunk. The
+// chunks are read through the returned ReplyStream. The timeout is restarted
+// every time a chunk arrives. The call passes through the interceptors, and
+// the next Invoker returns the *ReplyStream at once, without waiting for the
+// reply
+func (p *Client) SendStream(
+	timeout time.Duration,
+	target string,
+	args ...interface{},
+) *ReplyStream {
+	streams := make([]*ReplyStream, 0, 1)
+	ret, err := intercept(
+		p.getInterceptors(),
+		newInvocation(target, args, nil),
+		func(inv *Invocation) (interface{}, *base.Error) {
+			stream, err := p.sendStream(
+				timeout,
+				inv.metadata,
+				inv.target,
+				inv.args...,
+			)
+			if err != nil {
+				return nil, err
+			}
+			streams = append(streams, stream)
+			return stream, nil
+		},
+	)
+
+	result, ok := ret.(*ReplyStream)
+	if !ok || err != nil {
+		// the interceptors short-circuit the call, or it fails
+		result = newFinishedReplyStream(ret, err)
+	}
+
+	// the streams that are dropped by the interceptors are cancelled
+	for _, stream := range streams {
+		if stream != result {
+			stream.Close()
+		}
+	}
+
+	return result
+}
+
+func (p *Client) sendStream(
+	timeout time.Duration,
+	metadata map[string]string,
+	target string,
+	args ...interface{},
+) (*ReplyStream, *base.Error) {
+	item, err := p.makeSendItem(int64(timeout), 0, metadata, target, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := newReplyStream(p, item)
+	p.sendItem(item)
+	return ret, nil
+}
+
+// Forward sends the request stream that is made by another node, and waits
+// for the response stream. The header and the body of the stream are sent as
+// they are, except the callback id. When cancelCH is closed, the call is
+// cancelled on the server, and it is finished with ErrClientCanceled. The
+// caller is responsible for releasing the returned stream
+func (p *Client) Forward(
+	timeout time.Duration,
+	stream *rpc.Stream,
+	cancelCH <-chan bool,
+) *rpc.Stream {
+	item := NewSendItem(int64(timeout))
+	defer item.Release()
+	item.sendStream.PutBytesTo(stream.GetBuffer(), 0)
+
+	p.sendItem(item)
+
+	// wait for response
+	select {
+	case backStream := <-item.returnCH:
+		return backStream
+	case <-cancelCH:
+		p.cancelItem(item)
+		ret := rpc.NewStream()
+		ret.SetKind(rpc.StreamKindRPCResponseError)
+		ret.WriteUint64(uint64(base.ErrClientCanceled.GetCode()))
+		ret.WriteString(base.ErrClientCanceled.GetMessage())
+		return ret
+	}
+}
+
+// IsHealthy reports whether the client is connected, and the server has
+// answered the Ping with a Pong within the heartbeat timeout
+func (p *Client) IsHealthy() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.conn != nil &&
+		base.TimeNow().UnixNano()-p.lastPongTimeNS <=
+			int64(p.config.heartbeatTimeout)
+}
+
+// Close closes the client. The calls that are waiting for the responses are
+// finished with ErrClientClosed, and so are the calls that are sent later
 func (p *Client) Close() bool {
-	return p.orcManager.Close(func() bool {
+	ret := p.orcManager.Close(func() bool {
 		return p.adapter.Close()
 	}, func() {
 		p.adapter = nil
 	})
+
+	p.abortItems(base.ErrClientClosed)
+	return ret
+}
+
+// abortItems finishes all the calls that are waiting for the responses with
+// err, and the calls that are sent later are finished at once
+func (p *Client) abortItems(err *base.Error) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.isClosed = true
+
+	for item := p.preSendHead; item != nil; {
+		next := item.next
+		item.next = nil
+		item.abort(err)
+		item = next
+	}
+	p.preSendHead = nil
+	p.preSendTail = nil
+
+	for i := 0; i < len(p.channels); i++ {
+		if channel := &p.channels[i]; channel.item != nil {
+			channel.item.abort(err)
+			channel.item = nil
+		}
+	}
 }
 
 // OnConnOpen ...
@@ -371,6 +846,17 @@ func (p *Client) OnConnOpen(streamConn *adapter.StreamConn) {
 	stream.SetKind(rpc.StreamKindConnectRequest)
 	stream.SetCallbackID(0)
 	stream.WriteString(p.sessionString)
+	if p.credentials == nil {
+		stream.WriteNil()
+	} else if reason := stream.Write(p.credentials); reason != rpc.StreamWriteOK {
+		stream.Release()
+		p.OnConnError(streamConn, base.ErrUnsupportedValue.AddDebug(reason))
+		return
+	}
+	// the server chooses one of them, or none
+	if len(p.compressions) > 0 {
+		stream.WriteStringArray(p.compressions)
+	}
 	streamConn.WriteStreamAndRelease(stream)
 }
 
@@ -406,6 +892,12 @@ func (p *Client) OnConnReadStream(
 			} else {
 				stream.Release()
 			}
+		case rpc.StreamKindRPCResponseChunk:
+			channel := &p.channels[callbackID%uint64(len(p.channels))]
+			if channel.sequence == callbackID {
+				channel.Chunk(stream)
+			}
+			stream.Release()
 		case rpc.StreamKindRPCBoardCast:
 			if actionPath, err := stream.ReadString(); err != nil {
 				p.OnConnError(streamConn, err)
@@ -413,17 +905,37 @@ func (p *Client) OnConnReadStream(
 				p.OnConnError(streamConn, err)
 			} else if !stream.IsReadFinish() {
 				p.OnConnError(streamConn, base.ErrStream)
+			} else if callbackID == 0 {
+				// the message is not sequenced, so it is not acknowledged
+				p.onBoardCast(actionPath, value)
+			} else {
+				// the server replays the messages that are not acknowledged,
+				// so the message might have been received already
+				if callbackID > p.boardCastSeq {
+					p.boardCastSeq = callbackID
+					p.onBoardCast(actionPath, value)
+				}
+				p.tryToSendBoardCastAck(callbackID)
+			}
+			stream.Release()
+		case rpc.StreamKindTopicPublish:
+			if topic, err := stream.ReadString(); err != nil {
+				p.OnConnError(streamConn, err)
+			} else if value, err := stream.Read(); err != nil {
+				p.OnConnError(streamConn, err)
+			} else if !stream.IsReadFinish() {
+				p.OnConnError(streamConn, base.ErrStream)
 			} else {
-				if list, ok := p.subscriptionMap[actionPath]; ok {
-					for i := 0; i < len(list); i++ {
-						list[i].onMessage(value)
-					}
+				for _, subscription := range p.topicMap[topic] {
+					subscription.onMessage(value)
 				}
 			}
 			stream.Release()
 		case rpc.StreamKindPong:
 			if !stream.IsReadFinish() {
 				p.OnConnError(streamConn, base.ErrStream)
+			} else {
+				p.lastPongTimeNS = base.TimeNow().UnixNano()
 			}
 			stream.Release()
 		default:
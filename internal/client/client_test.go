@@ -1,12 +1,15 @@
 package client
 
 import (
+	"context"
 	"crypto/tls"
 	"github.com/rpccloud/rpc/internal/adapter"
 	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/gateway"
 	"github.com/rpccloud/rpc/internal/rpc"
 	"math/rand"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -73,12 +76,39 @@ func getTestServer() *server.Server {
 			time.Sleep(time.Duration(timeNS))
 			return rt.Reply(nil)
 		}).
+		On("GetDeadline", func(rt rpc.Runtime) rpc.Return {
+			if deadline, ok := rt.GetDeadline(); ok {
+				return rt.Reply(deadline.UnixNano())
+			}
+			return rt.Reply(nil)
+		}).
+		On("GetMetadata", func(rt rpc.Runtime, key rpc.String) rpc.Return {
+			if value, ok := rt.GetMetadata(key); ok {
+				return rt.Reply(value)
+			}
+			return rt.Reply(nil)
+		}).
+		On("Count", func(rt rpc.Runtime, n int64) rpc.Return {
+			for i := int64(0); i < n; i++ {
+				if err := rt.ReplyChunk(i); err != nil {
+					return rt.Reply(err)
+				}
+			}
+			return rt.Reply(n)
+		}).
 		On("PostMessage", func(rt rpc.Runtime, timeNS int64) rpc.Return {
 			return rt.Reply(
 				rt.Post(rt.GetPostEndPoint(), "@Post", rpc.Array{true, timeNS}),
 			)
 		})
 
+	userService.On(
+		"Publish",
+		func(rt rpc.Runtime, topic string, value string) rpc.Return {
+			return rt.Reply(rt.Publish(topic, value))
+		},
+	)
+
 	rpcServer := server.NewServer().ListenWithDebug("ws", "0.0.0.0:8765", nil)
 	rpcServer.AddService("user", userService, nil)
 
@@ -153,6 +183,37 @@ func TestDialTLS(t *testing.T) {
 	})
 }
 
+func TestDialWithCredentials(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := DialWithCredentials("ws", "localhost", rpc.Map{"token": "abc"})
+		testAdapter := (*TestAdapter)(unsafe.Pointer(v.adapter))
+		assert(v.credentials).Equal(rpc.Map{"token": "abc"})
+		assert(testAdapter.network).Equal("ws")
+		assert(testAdapter.addr).Equal("localhost")
+		assert(testAdapter.tlsConfig).Equal(nil)
+		assert(testAdapter.rBufSize).Equal(1500)
+		assert(testAdapter.wBufSize).Equal(1500)
+	})
+}
+
+func TestDialTLSWithCredentials(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		tlsConfig := &tls.Config{}
+		v := DialTLSWithCredentials(
+			"ws", "localhost", tlsConfig, rpc.Map{"token": "abc"},
+		)
+		testAdapter := (*TestAdapter)(unsafe.Pointer(v.adapter))
+		assert(v.credentials).Equal(rpc.Map{"token": "abc"})
+		assert(testAdapter.network).Equal("ws")
+		assert(testAdapter.addr).Equal("localhost")
+		assert(testAdapter.tlsConfig).Equal(tlsConfig)
+		assert(testAdapter.rBufSize).Equal(1500)
+		assert(testAdapter.wBufSize).Equal(1500)
+	})
+}
+
 func TestSubscription_Close(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -187,7 +248,7 @@ func TestNewClient(t *testing.T) {
 		defer testServer.Close()
 
 		assert := base.NewAssert(t)
-		v := newClient("ws", "127.0.0.1:8765", nil, 1024, 2048)
+		v := newClient("ws", "127.0.0.1:8765", nil, nil, 1024, 2048)
 
 		for {
 			v.Lock()
@@ -231,11 +292,14 @@ func TestNewClient(t *testing.T) {
 		assert(v.preSendTail).IsNil()
 		assert(len(v.channels)).Equal(32)
 		assert(v.lastPingTimeNS > 0).IsTrue()
+		assert(v.boardCastSeq).Equal(uint64(0))
 		// orcStatusReady | orcLockBit = 1 | 1 << 2 = 5
 		assert(atomic.LoadUint64(
 			&(*TestORCManager)(unsafe.Pointer(v.orcManager)).sequence,
 		) % 8).Equal(uint64(5))
 		assert(v.errorHub).IsNotNil()
+		assert(v.topicMap).Equal(map[string][]*Subscription{})
+		assert(v.compressions).Equal(rpc.GetCompressions())
 
 		// check tryLoop
 		_, err := v.Send(
@@ -258,6 +322,30 @@ func TestClient_SetErrorHub(t *testing.T) {
 	})
 }
 
+func TestClient_AddInterceptor(t *testing.T) {
+	t.Run("interceptor is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{interceptors: make([]Interceptor, 0)}
+		assert(v.AddInterceptor(nil)).Equal(v)
+		assert(len(v.interceptors)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fn := func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+			return next(inv)
+		}
+		v := &Client{interceptors: make([]Interceptor, 0)}
+		assert(v.AddInterceptor(fn)).Equal(v)
+		interceptors := v.getInterceptors()
+		assert(len(interceptors)).Equal(1)
+		assert(v.AddInterceptor(fn)).Equal(v)
+		assert(len(v.getInterceptors())).Equal(2)
+		// the previous slice is not changed
+		assert(len(interceptors)).Equal(1)
+	})
+}
+
 func TestClient_tryToSendPing(t *testing.T) {
 	t.Run("p.conn == nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -305,6 +393,80 @@ func TestClient_tryToSendPing(t *testing.T) {
 	})
 }
 
+func TestClient_onBoardCast(t *testing.T) {
+	t.Run("not subscribed", func(t *testing.T) {
+		v := &Client{subscriptionMap: map[string][]*Subscription{}}
+		v.onBoardCast("#.test%Message", "Hello")
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{subscriptionMap: map[string][]*Subscription{}}
+		ret := make([]rpc.Any, 0)
+		v.Subscribe("#.test", "Message", func(value rpc.Any) {
+			ret = append(ret, value)
+		})
+		v.Subscribe("#.test", "Message", func(value rpc.Any) {
+			ret = append(ret, value)
+		})
+		v.onBoardCast("#.test%Message", "Hello")
+		assert(ret).Equal([]rpc.Any{"Hello", "Hello"})
+	})
+}
+
+func TestClient_tryToSendBoardCastAck(t *testing.T) {
+	t.Run("p.conn == nil", func(t *testing.T) {
+		v := &Client{}
+		v.tryToSendBoardCastAck(3)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		v.tryToSendBoardCastAck(3)
+
+		stream := rpc.NewStream()
+		stream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindRPCBoardCastAck))
+		assert(stream.GetCallbackID()).Equal(uint64(3))
+		assert(stream.IsReadFinish()).IsTrue()
+		assert(stream.CheckStream()).IsTrue()
+	})
+}
+
+func TestClient_tryToSendTopic(t *testing.T) {
+	t.Run("p.conn == nil", func(t *testing.T) {
+		v := &Client{}
+		v.tryToSendTopic(rpc.StreamKindTopicSubscribe, "news")
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		v.tryToSendTopic(rpc.StreamKindTopicUnsubscribe, "news")
+
+		stream := rpc.NewStream()
+		stream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindTopicUnsubscribe))
+		assert(stream.GetCallbackID()).Equal(uint64(0))
+		assert(stream.ReadString()).Equal("news", nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		assert(stream.CheckStream()).IsTrue()
+	})
+}
+
 func TestClient_tryToTimeout(t *testing.T) {
 	fnTest := func(totalItems int, timeoutItems int) bool {
 		v := &Client{
@@ -368,6 +530,35 @@ func TestClient_tryToTimeout(t *testing.T) {
 		assert(v.channels[0].item).IsNil()
 	})
 
+	t.Run("cancel the timeout channels", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{
+			lastPingTimeNS: 10000,
+			config:         &Config{heartbeatTimeout: time.Minute},
+			channels:       make([]Channel, 1),
+		}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		item := NewSendItem(int64(5 * time.Millisecond))
+		v.channels[0].Use(item, 1)
+
+		v.tryToTimeout(item.sendTimeNS + int64(4*time.Millisecond))
+		assert(len(netConn.writeCH)).Equal(0)
+
+		v.tryToTimeout(item.sendTimeNS + int64(10*time.Millisecond))
+		assert(v.channels[0].item).IsNil()
+		assert(len(netConn.writeCH)).Equal(1)
+		stream := rpc.NewStream()
+		stream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindRPCCancel))
+		assert(stream.GetCallbackID()).Equal(uint64(1))
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+
 	t.Run("check if the conn has been swept", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		v := &Client{
@@ -503,7 +694,7 @@ func TestClient_tryToDeliverPreSendMessages(t *testing.T) {
 func TestClient_Subscribe(t *testing.T) {
 	t.Run("test basic", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		v := newClient("ws", "127.0.0.1:8080", nil, 1200, 1200)
+		v := newClient("ws", "127.0.0.1:8080", nil, nil, 1200, 1200)
 		defer v.Close()
 
 		sub1 := v.Subscribe("#.test", "Message01", func(value rpc.Any) {})
@@ -523,7 +714,7 @@ func TestClient_Subscribe(t *testing.T) {
 		rpcServer := getTestServer()
 		defer rpcServer.Close()
 
-		rpcClient := newClient("ws", "0.0.0.0:8765", nil, 1200, 1200)
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
 		defer rpcClient.Close()
 
 		waitCH := make(chan rpc.Any, 1)
@@ -536,9 +727,125 @@ func TestClient_Subscribe(t *testing.T) {
 	})
 }
 
+func TestClient_SubscribeTopic(t *testing.T) {
+	t.Run("test basic", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{topicMap: map[string][]*Subscription{}}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		sub1 := v.SubscribeTopic("news", func(value rpc.Any) {})
+		sub2 := v.SubscribeTopic("news", func(value rpc.Any) {})
+		sub3 := v.SubscribeTopic("sports", func(value rpc.Any) {})
+
+		assert(sub1, sub2, sub3).IsNotNil()
+		assert(v.topicMap).Equal(map[string][]*Subscription{
+			"news":   {sub1, sub2},
+			"sports": {sub3},
+		})
+
+		// the topic is subscribed only once
+		assert(len(netConn.writeCH)).Equal(2)
+		for _, topic := range []string{"news", "sports"} {
+			stream := rpc.NewStream()
+			stream.PutBytesTo(<-netConn.writeCH, 0)
+			assert(stream.GetKind()).Equal(uint8(rpc.StreamKindTopicSubscribe))
+			assert(stream.ReadString()).Equal(topic, nil)
+		}
+	})
+
+	t.Run("test message", func(t *testing.T) {
+		assert := base.NewAssert(t)
+
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		client1 := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer client1.Close()
+		client2 := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer client2.Close()
+
+		waitCH := make(chan rpc.Any, 4)
+		client1.SubscribeTopic("news", func(value rpc.Any) {
+			waitCH <- value
+		})
+		sub := client2.SubscribeTopic("news", func(value rpc.Any) {
+			waitCH <- value
+		})
+		client2.SubscribeTopic("sports", func(value rpc.Any) {
+			waitCH <- value
+		})
+
+		// wait for the subscriptions
+		assert(client1.Send(3*time.Second, "#.user:SayHello", "kitty")).
+			Equal("hello kitty", nil)
+		assert(client2.Send(3*time.Second, "#.user:SayHello", "kitty")).
+			Equal("hello kitty", nil)
+
+		assert(client1.Send(3*time.Second, "#.user:Publish", "news", "hi")).
+			Equal(nil, nil)
+		assert(<-waitCH).Equal("hi")
+		assert(<-waitCH).Equal("hi")
+
+		sub.Close()
+		assert(client2.Send(3*time.Second, "#.user:SayHello", "kitty")).
+			Equal("hello kitty", nil)
+		assert(client1.Send(3*time.Second, "#.user:Publish", "news", "bye")).
+			Equal(nil, nil)
+		assert(<-waitCH).Equal("bye")
+		time.Sleep(100 * time.Millisecond)
+		assert(len(waitCH)).Equal(0)
+	})
+}
+
+func TestRemoveSubscription(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sub1 := &Subscription{id: 1}
+		sub2 := &Subscription{id: 2}
+		sub3 := &Subscription{id: 3}
+		subscriptionMap := map[string][]*Subscription{
+			"news":   {sub1, sub2},
+			"sports": {sub3},
+		}
+		assert(removeSubscription(subscriptionMap, 4)).Equal([]string{})
+		assert(removeSubscription(subscriptionMap, 1)).Equal([]string{})
+		assert(removeSubscription(subscriptionMap, 3)).Equal([]string{"sports"})
+		assert(subscriptionMap).Equal(map[string][]*Subscription{
+			"news": {sub2},
+		})
+	})
+}
+
 func TestClient_unsubscribe(t *testing.T) {
+	t.Run("topic", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{topicMap: map[string][]*Subscription{}}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+
+		sub1 := v.SubscribeTopic("news", func(value rpc.Any) {})
+		sub2 := v.SubscribeTopic("news", func(value rpc.Any) {})
+		v.conn = streamConn
+
+		v.unsubscribe(sub1.id)
+		assert(len(netConn.writeCH)).Equal(0)
+		v.unsubscribe(sub2.id)
+		assert(v.topicMap).Equal(map[string][]*Subscription{})
+
+		stream := rpc.NewStream()
+		stream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindTopicUnsubscribe))
+		assert(stream.ReadString()).Equal("news", nil)
+	})
+
 	assert := base.NewAssert(t)
-	v := newClient("ws", "127.0.0.1:8080", nil, 1200, 1200)
+	v := newClient("ws", "127.0.0.1:8080", nil, nil, 1200, 1200)
 	defer v.Close()
 
 	sub1 := v.Subscribe("#.test", "Message01", func(value rpc.Any) {})
@@ -580,7 +887,7 @@ func TestClient_Send(t *testing.T) {
 		rpcServer := getTestServer()
 		defer rpcServer.Close()
 
-		rpcClient := newClient("ws", "0.0.0.0:8765", nil, 1200, 1200)
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
 		defer rpcClient.Close()
 
 		waitCH := make(chan []interface{})
@@ -599,64 +906,711 @@ func TestClient_Send(t *testing.T) {
 			assert(<-waitCH...).Equal("hello kitty", nil)
 		}
 	})
-}
 
-func TestClient_Close(t *testing.T) {
-	t.Run("test", func(t *testing.T) {
+	t.Run("with credentials", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		v := newClient("ws", "127.0.0.1:1234", nil, 1200, 1200)
-		assert(v.adapter).IsNotNil()
-		assert(v.Close()).IsTrue()
-		assert(v.adapter).IsNil()
+		rpcServer := server.NewServer().
+			ListenWithDebug("ws", "0.0.0.0:8765", nil).
+			SetAuthenticator(gateway.AuthenticatorFunc(
+				func(credentials rpc.Map) (*rpc.Principal, bool) {
+					if credentials["token"] == "abc" {
+						return rpc.NewPrincipal("kitty"), true
+					}
+					return nil, false
+				},
+			)).
+			AddService("user", rpc.NewService().
+				On("WhoAmI", func(rt rpc.Runtime) rpc.Return {
+					return rt.Reply(rt.GetPrincipal().GetName())
+				}), nil)
+		go func() {
+			rpcServer.SetNumOfThreads(1024).Open()
+		}()
+		defer rpcServer.Close()
+		time.Sleep(100 * time.Millisecond)
+
+		client1 := newClient(
+			"ws", "0.0.0.0:8765", nil, rpc.Map{"token": "abc"}, 1200, 1200,
+		)
+		defer client1.Close()
+		assert(client1.Send(3*time.Second, "#.user:WhoAmI")).
+			Equal("kitty", nil)
+
+		errorHub := rpc.NewTestStreamHub()
+		client2 := newClient(
+			"ws", "0.0.0.0:8765", nil, rpc.Map{"token": "123"}, 1200, 1200,
+		)
+		client2.SetErrorHub(errorHub)
+		defer client2.Close()
+		assert(client2.Send(time.Second, "#.user:WhoAmI")).
+			Equal(nil, base.ErrClientTimeout)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrGateWayUnauthorized)
+	})
+
+	t.Run("with compression", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := server.NewServer().
+			ListenWithDebug("ws", "0.0.0.0:8765", nil).
+			SetCompression(rpc.CompressionGzip, 64).
+			AddService("user", rpc.NewService().
+				On("Echo", func(rt rpc.Runtime, v rpc.Map) rpc.Return {
+					return rt.Reply(v)
+				}), nil)
+		go func() {
+			rpcServer.SetNumOfThreads(1024).Open()
+		}()
+		defer rpcServer.Close()
+		time.Sleep(100 * time.Millisecond)
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+		v := rpc.Map{"name": strings.Repeat("kitty", 4096)}
+		assert(rpcClient.Send(3*time.Second, "#.user:Echo", v)).Equal(v, nil)
+		assert(rpcClient.Send(3*time.Second, "#.user:Echo", rpc.Map{})).
+			Equal(rpc.Map{}, nil)
+	})
+
+	t.Run("with session attributes", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := server.NewServer().
+			ListenWithDebug("ws", "0.0.0.0:8765", nil).
+			AddService("user", rpc.NewService().
+				On("Login", func(rt rpc.Runtime, name string) rpc.Return {
+					return rt.Reply(rt.SessionSet("name", name))
+				}).
+				On("WhoAmI", func(rt rpc.Runtime) rpc.Return {
+					name, _ := rt.SessionGet("name")
+					return rt.Reply(name)
+				}), nil)
+		go func() {
+			rpcServer.SetNumOfThreads(1024).Open()
+		}()
+		defer rpcServer.Close()
+		time.Sleep(100 * time.Millisecond)
+
+		client1 := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer client1.Close()
+		client2 := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer client2.Close()
+
+		assert(client1.Send(3*time.Second, "#.user:Login", "kitty")).
+			Equal(nil, nil)
+		assert(client1.Send(3*time.Second, "#.user:WhoAmI")).
+			Equal("kitty", nil)
+		assert(client2.Send(3*time.Second, "#.user:WhoAmI")).
+			Equal(nil, nil)
+	})
+
+	t.Run("with interceptors", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		result := interface{}(nil)
+		rpcClient.AddInterceptor(
+			func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+				inv.SetTarget("#.user:SayHello")
+				inv.SetArgs("doggy")
+				ret, err := next(inv)
+				result = ret
+				return ret, err
+			},
+		)
+
+		assert(rpcClient.Send(6*time.Second, "#.user:NotExist", "kitty")).
+			Equal("hello doggy", nil)
+		assert(result).Equal("hello doggy")
 	})
 }
 
-func TestClient_OnConnOpen(t *testing.T) {
-	t.Run("test", func(t *testing.T) {
+func TestClient_cancelItem(t *testing.T) {
+	t.Run("item is in the pre send list", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		v := &Client{sessionString: "123456"}
+		v := &Client{channels: make([]Channel, 0)}
+		items := []*SendItem{NewSendItem(0), NewSendItem(0), NewSendItem(0)}
+		for _, item := range items {
+			v.sendItem(item)
+		}
+		v.cancelItem(items[1])
+		assert(checkClientPreSendList(v, []*SendItem{items[0], items[2]})).
+			IsTrue()
+		v.cancelItem(items[2])
+		assert(checkClientPreSendList(v, []*SendItem{items[0]})).IsTrue()
+		v.cancelItem(items[0])
+		assert(checkClientPreSendList(v, []*SendItem{})).IsTrue()
+		assert(items[0].isRunning).IsFalse()
+	})
+
+	t.Run("item is in the channel", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{
+			lastPingTimeNS: 10000,
+			config:         &Config{heartbeatTimeout: time.Minute},
+			channels:       make([]Channel, 1),
+		}
 		netConn := newTestNetConn()
 		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
 		streamConn := adapter.NewStreamConn(false, syncConn, v)
 		syncConn.SetNext(streamConn)
 		v.conn = streamConn
 
-		v.OnConnOpen(streamConn)
+		item := NewSendItem(int64(time.Minute))
+		nextItem := NewSendItem(int64(time.Minute))
+		v.sendItem(item)
+		v.sendItem(nextItem)
+		<-netConn.writeCH
+
+		// the response has arrived before cancel
+		item.returnCH <- rpc.NewStream()
+		v.cancelItem(item)
+		assert(len(item.returnCH)).Equal(0)
+		assert(item.isRunning).IsFalse()
+		assert(len(netConn.writeCH)).Equal(2)
 
 		stream := rpc.NewStream()
 		stream.PutBytesTo(<-netConn.writeCH, 0)
-		assert(stream.GetKind()).
-			Equal(uint8(rpc.StreamKindConnectRequest))
-		assert(stream.ReadString()).Equal("123456", nil)
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindRPCCancel))
+		assert(stream.GetCallbackID()).Equal(uint64(1))
+
+		// the free channel is used by the next item
+		assert(v.channels[0].item).Equal(nextItem)
+		assert(checkClientPreSendList(v, []*SendItem{})).IsTrue()
 	})
 }
 
-func TestClient_OnConnReadStream(t *testing.T) {
-	fnTestClient := func() (*Client, *adapter.StreamConn, *testNetConn) {
+func TestClient_SendContext(t *testing.T) {
+	t.Run("args error", func(t *testing.T) {
+		assert := base.NewAssert(t)
 		v := &Client{
-			config:          &Config{},
-			subscriptionMap: map[string][]*Subscription{},
+			channels: make([]Channel, 0),
 		}
-		netConn := newTestNetConn()
-		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
-		streamConn := adapter.NewStreamConn(false, syncConn, v)
-		syncConn.SetNext(streamConn)
-		return v, streamConn, netConn
-	}
 
-	t.Run("conn == nil, stream.callbackID != 0", func(t *testing.T) {
-		assert := base.NewAssert(t)
-		stream := rpc.NewStream()
-		stream.SetCallbackID(12)
-		v, streamConn, _ := fnTestClient()
-		errorHub := rpc.NewTestStreamHub()
-		v.errorHub = errorHub
-		v.OnConnReadStream(streamConn, stream)
-		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
-			Equal(nil, base.ErrStream)
+		assert(v.SendContext(
+			context.Background(),
+			"#.user:SayHello",
+			make(chan bool),
+		)).Equal(nil, base.ErrUnsupportedValue.AddDebug(
+			"value type(chan bool) is not supported",
+		))
 	})
 
-	t.Run("conn == nil, kind != StreamKindConnectResponse", func(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		assert(rpcClient.SendContext(
+			context.Background(),
+			"#.user:SayHello",
+			"kitty",
+		)).Equal("hello kitty", nil)
+
+		assert(rpcClient.SendContext(
+			context.Background(),
+			"#.user:GetDeadline",
+		)).Equal(nil, nil)
+
+		deadline := base.TimeNow().Add(5 * time.Second)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		assert(rpcClient.SendContext(ctx, "#.user:GetDeadline")).
+			Equal(deadline.UnixNano(), nil)
+	})
+
+	t.Run("with interceptors", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		rpcClient.AddInterceptor(
+			func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+				inv.SetArgs(append(inv.GetArgs(), "kitty")...)
+				return next(inv)
+			},
+		)
+
+		assert(rpcClient.SendContext(context.Background(), "#.user:SayHello")).
+			Equal("hello kitty", nil)
+	})
+
+	t.Run("with metadata", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		rpcClient.AddInterceptor(
+			func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+				inv.SetMetadata("token", "abc")
+				return next(inv)
+			},
+		)
+
+		ctx := WithMetadata(context.Background(), "traceID", "1234")
+		assert(rpcClient.SendContext(ctx, "#.user:GetMetadata", "traceID")).
+			Equal("1234", nil)
+		assert(rpcClient.SendContext(ctx, "#.user:GetMetadata", "token")).
+			Equal("abc", nil)
+		assert(rpcClient.Send(6*time.Second, "#.user:GetMetadata", "token")).
+			Equal("abc", nil)
+		assert(rpcClient.Send(6*time.Second, "#.user:GetMetadata", "traceID")).
+			Equal(nil, nil)
+	})
+
+	t.Run("context is canceled", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			cancel()
+		}()
+		assert(rpcClient.SendContext(
+			ctx,
+			"#.user:Sleep",
+			int64(2*time.Second),
+		)).Equal(nil, base.ErrClientCanceled)
+
+		// all channels are free
+		rpcClient.Lock()
+		for _, channel := range rpcClient.channels {
+			assert(channel.item).IsNil()
+		}
+		rpcClient.Unlock()
+	})
+
+	t.Run("context deadline exceeded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			200*time.Millisecond,
+		)
+		defer cancel()
+		assert(rpcClient.SendContext(
+			ctx,
+			"#.user:Sleep",
+			int64(2*time.Second),
+		)).Equal(nil, base.ErrClientTimeout)
+	})
+}
+
+func TestClient_SendStream(t *testing.T) {
+	t.Run("args error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{
+			channels: make([]Channel, 0),
+		}
+
+		replyStream := v.SendStream(time.Second, "#.user:Count", make(chan bool))
+		assert(replyStream.Next()).Equal(nil, false)
+		assert(replyStream.Result()).Equal(nil, base.ErrUnsupportedValue.AddDebug(
+			"value type(chan bool) is not supported",
+		))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		waitCH := make(chan []interface{})
+		for i := 0; i < 100; i++ {
+			go func() {
+				replyStream := rpcClient.SendStream(6*time.Second, "#.user:Count", 5)
+				chunks := make([]rpc.Any, 0)
+				for v, ok := replyStream.Next(); ok; v, ok = replyStream.Next() {
+					chunks = append(chunks, v)
+				}
+				v, err := replyStream.Result()
+				waitCH <- []interface{}{chunks, v, err}
+			}()
+		}
+
+		for i := 0; i < 100; i++ {
+			assert(<-waitCH...).Equal(
+				[]rpc.Any{int64(0), int64(1), int64(2), int64(3), int64(4)},
+				int64(5),
+				nil,
+			)
+		}
+	})
+
+	t.Run("Result drops the unread chunks", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		replyStream := rpcClient.SendStream(6*time.Second, "#.user:Count", 3)
+		assert(replyStream.Next()).Equal(int64(0), true)
+		assert(replyStream.Result()).Equal(int64(3), nil)
+		assert(replyStream.Next()).Equal(nil, false)
+	})
+
+	t.Run("with interceptors", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		rpcClient.AddInterceptor(
+			func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+				inv.SetTarget("#.user:GetMetadata")
+				inv.SetArgs("token")
+				inv.SetMetadata("token", "abc")
+				// the first stream is dropped, so it is cancelled
+				_, _ = next(inv)
+				return next(inv)
+			},
+		)
+
+		replyStream := rpcClient.SendStream(6*time.Second, "#.user:NotExist")
+		assert(replyStream.Result()).Equal("abc", nil)
+	})
+
+	t.Run("interceptor short-circuits the call", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{channels: make([]Channel, 0)}
+		v.AddInterceptor(
+			func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+				return "cached", nil
+			},
+		)
+		replyStream := v.SendStream(time.Second, "#.user:Count", 3)
+		assert(replyStream.Next()).Equal(nil, false)
+		assert(replyStream.Result()).Equal("cached", nil)
+	})
+
+	t.Run("Close cancels the call", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		replyStream := rpcClient.SendStream(6*time.Second, "#.user:Count", 3)
+		assert(replyStream.Next()).Equal(int64(0), true)
+		replyStream.Close()
+		assert(replyStream.Result()).Equal(nil, base.ErrClientCanceled)
+
+		// the channel is free, so the next call works
+		assert(rpcClient.Send(3*time.Second, "#.user:SayHello", "kitty")).
+			Equal("hello kitty", nil)
+	})
+}
+
+func TestClient_Forward(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		stream, _ := rpc.MakeInternalRequestStream(
+			true, 3, "#.user:SayHello", "#.user:Forward", "kitty",
+		)
+		defer stream.Release()
+
+		backStream := rpcClient.Forward(3*time.Second, stream, nil)
+		defer backStream.Release()
+		assert(rpc.ParseResponseStream(backStream)).Equal("hello kitty", nil)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		stream, _ := rpc.MakeInternalRequestStream(
+			true, 0, "#.user:Sleep", "", int64(2*time.Second),
+		)
+		defer stream.Release()
+
+		backStream := rpcClient.Forward(500*time.Millisecond, stream, nil)
+		defer backStream.Release()
+		assert(rpc.ParseResponseStream(backStream)).
+			Equal(nil, base.ErrClientTimeout)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		stream, _ := rpc.MakeInternalRequestStream(
+			true, 0, "#.user:Sleep", "", int64(2*time.Second),
+		)
+		defer stream.Release()
+
+		cancelCH := make(chan bool)
+		time.AfterFunc(200*time.Millisecond, func() {
+			close(cancelCH)
+		})
+
+		start := time.Now()
+		backStream := rpcClient.Forward(3*time.Second, stream, cancelCH)
+		defer backStream.Release()
+		assert(rpc.ParseResponseStream(backStream)).
+			Equal(nil, base.ErrClientCanceled)
+		assert(time.Since(start) < time.Second).IsTrue()
+
+		// the channel is free, so the next call works
+		assert(rpcClient.Send(3*time.Second, "#.user:SayHello", "kitty")).
+			Equal("hello kitty", nil)
+	})
+}
+
+func TestClient_IsHealthy(t *testing.T) {
+	t.Run("no connection", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{config: &Config{heartbeatTimeout: time.Second}}
+		assert(v.IsHealthy()).IsFalse()
+	})
+
+	t.Run("connection is not active", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{config: &Config{heartbeatTimeout: 0}}
+		syncConn := adapter.NewClientSyncConn(newTestNetConn(), 1200, 1200)
+		v.conn = adapter.NewStreamConn(false, syncConn, v)
+		assert(v.IsHealthy()).IsFalse()
+	})
+
+	t.Run("pong is timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{config: &Config{heartbeatTimeout: time.Second}}
+		syncConn := adapter.NewClientSyncConn(newTestNetConn(), 1200, 1200)
+		v.conn = adapter.NewStreamConn(false, syncConn, v)
+		v.lastPongTimeNS = base.TimeNow().Add(-2 * time.Second).UnixNano()
+		assert(v.IsHealthy()).IsFalse()
+		v.lastPongTimeNS = base.TimeNow().UnixNano()
+		assert(v.IsHealthy()).IsTrue()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rpcServer := getTestServer()
+		defer rpcServer.Close()
+
+		rpcClient := newClient("ws", "0.0.0.0:8765", nil, nil, 1200, 1200)
+		defer rpcClient.Close()
+
+		assert(rpcClient.Send(3*time.Second, "#.user:SayHello", "kitty")).
+			Equal("hello kitty", nil)
+		assert(rpcClient.IsHealthy()).IsTrue()
+	})
+}
+
+func TestClient_Close(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newClient("ws", "127.0.0.1:1234", nil, nil, 1200, 1200)
+		assert(v.adapter).IsNotNil()
+		assert(v.Close()).IsTrue()
+		assert(v.adapter).IsNil()
+	})
+
+	t.Run("waiting calls are finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newClient("ws", "127.0.0.1:1234", nil, nil, 1200, 1200)
+		waitCH := make(chan *base.Error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := v.Send(10*time.Second, "#.user:SayHello", "kitty")
+				waitCH <- err
+			}()
+		}
+		for {
+			v.Lock()
+			item := v.preSendHead
+			ok := item != nil && item.next != nil
+			v.Unlock()
+			if ok {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert(v.Close()).IsTrue()
+		assert(<-waitCH).Equal(base.ErrClientClosed)
+		assert(<-waitCH).Equal(base.ErrClientClosed)
+
+		// the calls after the client is closed are finished at once
+		assert(v.Send(10*time.Second, "#.user:SayHello", "kitty")).
+			Equal(nil, base.ErrClientClosed)
+	})
+}
+
+func TestClient_abortItems(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item1 := NewSendItem(0)
+		item2 := NewSendItem(0)
+		item3 := NewSendItem(0)
+		v := &Client{
+			preSendHead: item1,
+			preSendTail: item2,
+			channels:    make([]Channel, 4),
+		}
+		item1.next = item2
+		(&v.channels[1]).Use(item3, 4)
+		v.abortItems(base.ErrClientClosed)
+		assert(v.isClosed).IsTrue()
+		assert(v.preSendHead, v.preSendTail).IsNil()
+		assert(v.channels[1].item).IsNil()
+		for _, item := range []*SendItem{item1, item2, item3} {
+			assert(item.isRunning).IsFalse()
+			assert(item.next).IsNil()
+			assert(rpc.ParseResponseStream(<-item.returnCH)).
+				Equal(nil, base.ErrClientClosed)
+		}
+	})
+}
+
+func TestClient_SetCompressions(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{compressions: rpc.GetCompressions()}
+		assert(v.SetCompressions(rpc.CompressionGzip)).Equal(v)
+		assert(v.compressions).Equal([]string{rpc.CompressionGzip})
+		assert(v.SetCompressions()).Equal(v)
+		assert(len(v.compressions)).Equal(0)
+	})
+}
+
+func TestClient_OnConnOpen(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{sessionString: "123456"}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		v.OnConnOpen(streamConn)
+
+		stream := rpc.NewStream()
+		stream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(stream.GetKind()).
+			Equal(uint8(rpc.StreamKindConnectRequest))
+		assert(stream.ReadString()).Equal("123456", nil)
+		assert(stream.ReadNil()).Equal(nil, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+
+	t.Run("with credentials", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Client{
+			sessionString: "123456",
+			credentials:   rpc.Map{"token": "abc"},
+			compressions:  rpc.GetCompressions(),
+		}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		v.OnConnOpen(streamConn)
+
+		stream := rpc.NewStream()
+		stream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(stream.GetKind()).
+			Equal(uint8(rpc.StreamKindConnectRequest))
+		assert(stream.ReadString()).Equal("123456", nil)
+		assert(stream.ReadMap()).Equal(rpc.Map{"token": "abc"}, nil)
+		assert(stream.ReadStringArray()).Equal(rpc.GetCompressions(), nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+
+	t.Run("credentials is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := &Client{
+			sessionString: "123456",
+			credentials:   rpc.Map{"token": make(chan bool)},
+			errorHub:      errorHub,
+		}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		v.conn = streamConn
+
+		v.OnConnOpen(streamConn)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).Equal(
+			nil,
+			base.ErrUnsupportedValue.AddDebug(
+				"value[\"token\"] type(chan bool) is not supported",
+			).Standardize(),
+		)
+	})
+}
+
+func TestClient_OnConnReadStream(t *testing.T) {
+	fnTestClient := func() (*Client, *adapter.StreamConn, *testNetConn) {
+		v := &Client{
+			config:          &Config{},
+			subscriptionMap: map[string][]*Subscription{},
+		}
+		netConn := newTestNetConn()
+		syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, v)
+		syncConn.SetNext(streamConn)
+		return v, streamConn, netConn
+	}
+
+	t.Run("conn == nil, stream.callbackID != 0", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(12)
+		v, streamConn, _ := fnTestClient()
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		v.OnConnReadStream(streamConn, stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("conn == nil, kind != StreamKindConnectResponse", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := rpc.NewStream()
 		stream.SetCallbackID(0)
@@ -668,6 +1622,17 @@ func TestClient_OnConnReadStream(t *testing.T) {
 			Equal(nil, base.ErrStream)
 	})
 
+	t.Run("conn == nil, connection is rejected", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.MakeSystemErrorStream(base.ErrGateWayUnauthorized)
+		v, streamConn, _ := fnTestClient()
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		v.OnConnReadStream(streamConn, stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrGateWayUnauthorized)
+	})
+
 	t.Run("conn == nil, read sessionString error", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := rpc.NewStream()
@@ -828,6 +1793,60 @@ func TestClient_OnConnReadStream(t *testing.T) {
 			Equal(nil, base.ErrStream)
 	})
 
+	t.Run("conn == nil, compression error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(compression rpc.Any, threshold rpc.Any) *base.Error {
+			stream := rpc.NewStream()
+			stream.SetCallbackID(0)
+			stream.SetKind(rpc.StreamKindConnectResponse)
+			stream.WriteString("12-87654321876543218765432187654321")
+			stream.WriteInt64(32)
+			stream.WriteInt64(4 * 1024 * 1024)
+			stream.WriteInt64(int64(time.Second / time.Millisecond))
+			stream.WriteInt64(int64(2 * time.Second / time.Millisecond))
+			stream.Write(compression)
+			if threshold != nil {
+				stream.Write(threshold)
+			}
+			v, streamConn, _ := fnTestClient()
+			errorHub := rpc.NewTestStreamHub()
+			v.errorHub = errorHub
+			v.OnConnReadStream(streamConn, stream)
+			_, err := rpc.ParseResponseStream(errorHub.WaitStream())
+			return err
+		}
+		assert(fnTest(true, int64(1024))).Equal(base.ErrStream)
+		assert(fnTest("br", int64(1024))).Equal(base.ErrClientConfig)
+		assert(fnTest(rpc.CompressionGzip, nil)).Equal(base.ErrStream)
+		assert(fnTest(rpc.CompressionGzip, int64(-1))).Equal(base.ErrClientConfig)
+	})
+
+	t.Run("conn == nil, compression ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(0)
+		stream.SetKind(rpc.StreamKindConnectResponse)
+		stream.WriteString("12-87654321876543218765432187654321")
+		stream.WriteInt64(32)
+		stream.WriteInt64(4 * 1024 * 1024)
+		stream.WriteInt64(int64(time.Second / time.Millisecond))
+		stream.WriteInt64(int64(2 * time.Second / time.Millisecond))
+		stream.WriteString(rpc.CompressionGzip)
+		stream.WriteInt64(1024)
+		v, streamConn, netConn := fnTestClient()
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		v.OnConnReadStream(streamConn, stream)
+		assert(errorHub.GetStream()).IsNil()
+
+		sendStream := rpc.NewStream()
+		sendStream.WriteString(strings.Repeat("a", 2048))
+		v.conn.WriteStreamAndRelease(sendStream)
+		writeStream := rpc.NewStream()
+		writeStream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(writeStream.HasStatusBitCompressed()).IsTrue()
+	})
+
 	t.Run("conn == nil, sessionString != p.sessionString", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := rpc.NewStream()
@@ -839,11 +1858,13 @@ func TestClient_OnConnReadStream(t *testing.T) {
 		stream.WriteInt64(int64(time.Second / time.Millisecond))
 		stream.WriteInt64(int64(2 * time.Second / time.Millisecond))
 		v, streamConn, _ := fnTestClient()
+		v.boardCastSeq = 9
 		errorHub := rpc.NewTestStreamHub()
 		v.errorHub = errorHub
 		v.OnConnReadStream(streamConn, stream)
 		assert(errorHub.GetStream()).IsNil()
 		assert(v.sessionString).Equal("12-87654321876543218765432187654321")
+		assert(v.boardCastSeq).Equal(uint64(0))
 
 		assert(v.config.numOfChannels).Equal(32)
 		assert(v.config.transLimit).Equal(4 * 1024 * 1024)
@@ -879,6 +1900,26 @@ func TestClient_OnConnReadStream(t *testing.T) {
 		assert(v.lastPingTimeNS > 0).IsTrue()
 	})
 
+	t.Run("conn == nil, subscribe the topics again", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(0)
+		stream.SetKind(rpc.StreamKindConnectResponse)
+		stream.WriteString("12-87654321876543218765432187654321")
+		stream.WriteInt64(32)
+		stream.WriteInt64(4 * 1024 * 1024)
+		stream.WriteInt64(int64(time.Second / time.Millisecond))
+		stream.WriteInt64(int64(2 * time.Second / time.Millisecond))
+		v, streamConn, netConn := fnTestClient()
+		v.topicMap = map[string][]*Subscription{"news": {&Subscription{}}}
+		v.OnConnReadStream(streamConn, stream)
+
+		topicStream := rpc.NewStream()
+		topicStream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(topicStream.GetKind()).Equal(uint8(rpc.StreamKindTopicSubscribe))
+		assert(topicStream.ReadString()).Equal("news", nil)
+	})
+
 	t.Run("p.conn != nil, StreamKindPong error", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := rpc.NewStream()
@@ -903,6 +1944,8 @@ func TestClient_OnConnReadStream(t *testing.T) {
 		v.errorHub = errorHub
 		v.OnConnReadStream(streamConn, stream)
 		assert(errorHub.GetStream()).IsNil()
+		assert(base.TimeNow().UnixNano()-v.lastPongTimeNS < int64(time.Second)).
+			IsTrue()
 	})
 
 	t.Run("p.conn != nil, StreamKindRPCResponseOK ok", func(t *testing.T) {
@@ -919,6 +1962,50 @@ func TestClient_OnConnReadStream(t *testing.T) {
 		assert(v.channels[17].item).IsNil()
 	})
 
+	t.Run("p.conn != nil, StreamKindRPCResponseChunk ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(17 + 32)
+		stream.SetKind(rpc.StreamKindRPCResponseChunk)
+		stream.WriteUint64(0)
+		stream.WriteString("hello")
+		v, streamConn, _ := fnTestClient()
+		v.conn = streamConn
+		v.channels = make([]Channel, 32)
+		(&v.channels[17]).sequence = 17
+		item := NewSendItem(0)
+		chunk := rpc.Any(nil)
+		item.onChunk = func(value rpc.Any) {
+			chunk = value
+		}
+		(&v.channels[17]).Use(item, 32)
+		v.OnConnReadStream(streamConn, stream)
+		assert(v.channels[17].item).Equal(item)
+		assert(chunk).Equal("hello")
+	})
+
+	t.Run("p.conn != nil, StreamKindRPCResponseChunk error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(17)
+		stream.SetKind(rpc.StreamKindRPCResponseChunk)
+		stream.WriteUint64(0)
+		stream.WriteString("hello")
+		v, streamConn, _ := fnTestClient()
+		v.conn = streamConn
+		v.channels = make([]Channel, 32)
+		(&v.channels[17]).sequence = 17
+		item := NewSendItem(0)
+		chunk := rpc.Any(nil)
+		item.onChunk = func(value rpc.Any) {
+			chunk = value
+		}
+		(&v.channels[17]).Use(item, 32)
+		v.OnConnReadStream(streamConn, stream)
+		assert(item.numOfChunks).Equal(uint64(0))
+		assert(chunk).IsNil()
+	})
+
 	t.Run("p.conn != nil, StreamKindRPCResponseOK error", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := rpc.NewStream()
@@ -1018,13 +2105,37 @@ func TestClient_OnConnReadStream(t *testing.T) {
 			Equal(nil, base.ErrStream)
 	})
 
+	t.Run("StreamKindRPCBoardCast sequence is zero", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCBoardCast)
+		stream.WriteString("#.test%Message")
+		stream.WriteString("Hello")
+		v, streamConn, netConn := fnTestClient()
+		v.conn = streamConn
+		v.boardCastSeq = 5
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		var ret rpc.Any
+		v.Subscribe("#.test", "Message", func(value rpc.Any) {
+			ret = value
+		})
+		v.OnConnReadStream(streamConn, stream)
+		assert(ret).Equal("Hello")
+		assert(v.boardCastSeq).Equal(uint64(5))
+		// the message is not acknowledged
+		assert(len(netConn.writeCH)).Equal(0)
+		assert(errorHub.GetStream()).IsNil()
+	})
+
 	t.Run("StreamKindRPCBoardCast ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := rpc.NewStream()
 		stream.SetKind(rpc.StreamKindRPCBoardCast)
+		stream.SetCallbackID(1)
 		stream.WriteString("#.test%Message")
 		stream.WriteString("Hello")
-		v, streamConn, _ := fnTestClient()
+		v, streamConn, netConn := fnTestClient()
 		v.conn = streamConn
 		var ret rpc.Any
 		v.Subscribe("#.test", "Message", func(value rpc.Any) {
@@ -1032,6 +2143,97 @@ func TestClient_OnConnReadStream(t *testing.T) {
 		})
 		v.OnConnReadStream(streamConn, stream)
 		assert(ret).Equal("Hello")
+		assert(v.boardCastSeq).Equal(uint64(1))
+
+		ackStream := rpc.NewStream()
+		ackStream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(ackStream.GetKind()).Equal(uint8(rpc.StreamKindRPCBoardCastAck))
+		assert(ackStream.GetCallbackID()).Equal(uint64(1))
+	})
+
+	t.Run("StreamKindRPCBoardCast is replayed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCBoardCast)
+		stream.SetCallbackID(3)
+		stream.WriteString("#.test%Message")
+		stream.WriteString("Hello")
+		v, streamConn, netConn := fnTestClient()
+		v.conn = streamConn
+		v.boardCastSeq = 3
+		times := 0
+		v.Subscribe("#.test", "Message", func(value rpc.Any) {
+			times++
+		})
+		v.OnConnReadStream(streamConn, stream)
+		assert(times).Equal(0)
+		assert(v.boardCastSeq).Equal(uint64(3))
+
+		// the replayed message is acknowledged again
+		ackStream := rpc.NewStream()
+		ackStream.PutBytesTo(<-netConn.writeCH, 0)
+		assert(ackStream.GetKind()).Equal(uint8(rpc.StreamKindRPCBoardCastAck))
+		assert(ackStream.GetCallbackID()).Equal(uint64(3))
+	})
+
+	t.Run("StreamKindTopicPublish Read topic error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		v, streamConn, _ := fnTestClient()
+		v.conn = streamConn
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		v.OnConnReadStream(streamConn, stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("StreamKindTopicPublish Read value error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		v, streamConn, _ := fnTestClient()
+		v.conn = streamConn
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		v.OnConnReadStream(streamConn, stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("StreamKindTopicPublish Read is not finish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		stream.WriteBool(true)
+		stream.WriteString("error")
+		v, streamConn, _ := fnTestClient()
+		v.conn = streamConn
+		errorHub := rpc.NewTestStreamHub()
+		v.errorHub = errorHub
+		v.OnConnReadStream(streamConn, stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("StreamKindTopicPublish ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		stream.WriteString("Hello")
+		v, streamConn, _ := fnTestClient()
+		v.topicMap = map[string][]*Subscription{}
+		var ret rpc.Any
+		v.SubscribeTopic("news", func(value rpc.Any) {
+			ret = value
+		})
+		v.conn = streamConn
+		v.OnConnReadStream(streamConn, stream)
+		assert(ret).Equal("Hello")
 	})
 }
 
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+// Invocation is an outgoing call that passes through the interceptors
+type Invocation struct {
+	target   string
+	args     []interface{}
+	metadata map[string]string
+}
+
+func newInvocation(
+	target string,
+	args []interface{},
+	metadata map[string]string,
+) *Invocation {
+	ret := &Invocation{
+		target:   target,
+		args:     args,
+		metadata: nil,
+	}
+
+	// copy the metadata, so the interceptors can not change the source
+	for key, value := range metadata {
+		ret.SetMetadata(key, value)
+	}
+
+	return ret
+}
+
+// GetTarget ...
+func (p *Invocation) GetTarget() string {
+	return p.target
+}
+
+// SetTarget ...
+func (p *Invocation) SetTarget(target string) {
+	p.target = target
+}
+
+// GetArgs ...
+func (p *Invocation) GetArgs() []interface{} {
+	return p.args
+}
+
+// SetArgs ...
+func (p *Invocation) SetArgs(args ...interface{}) {
+	p.args = args
+}
+
+// GetMetadata ...
+func (p *Invocation) GetMetadata(key string) (string, bool) {
+	ret, ok := p.metadata[key]
+	return ret, ok
+}
+
+// SetMetadata sets the metadata that is sent with the call
+func (p *Invocation) SetMetadata(key string, value string) {
+	if p.metadata == nil {
+		p.metadata = make(map[string]string)
+	}
+	p.metadata[key] = value
+}
+
+// Invoker sends the invocation and returns the parsed response
+type Invoker func(inv *Invocation) (interface{}, *base.Error)
+
+// Interceptor wraps the outgoing calls of Client.Send, Client.SendContext and
+// Client.SendStream. It may change the target and the args of inv before
+// calling next, and inspect the result that next returns. Returning without
+// calling next short-circuits the call, and calling next more than once
+// retries it
+type Interceptor func(inv *Invocation, next Invoker) (interface{}, *base.Error)
+
+func intercept(
+	interceptors []Interceptor,
+	inv *Invocation,
+	invoker Invoker,
+) (interface{}, *base.Error) {
+	next := invoker
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, fn := interceptors[i], next
+		next = func(inv *Invocation) (interface{}, *base.Error) {
+			return interceptor(inv, fn)
+		}
+	}
+	return next(inv)
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+func TestNewInvocation(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", []interface{}{"kitty"}, nil)
+		assert(v.target).Equal("#.user:SayHello")
+		assert(v.args).Equal([]interface{}{"kitty"})
+		assert(v.metadata == nil).IsTrue()
+	})
+
+	t.Run("metadata is copied", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		metadata := map[string]string{"traceID": "1234"}
+		v := newInvocation("#.user:SayHello", nil, metadata)
+		v.SetMetadata("locale", "en")
+		assert(v.metadata).Equal(map[string]string{
+			"traceID": "1234",
+			"locale":  "en",
+		})
+		assert(metadata).Equal(map[string]string{"traceID": "1234"})
+	})
+}
+
+func TestInvocation_GetTarget(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", nil, nil)
+		assert(v.GetTarget()).Equal("#.user:SayHello")
+	})
+}
+
+func TestInvocation_SetTarget(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", nil, nil)
+		v.SetTarget("#.user:Sleep")
+		assert(v.target).Equal("#.user:Sleep")
+	})
+}
+
+func TestInvocation_GetArgs(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", []interface{}{"kitty", 3}, nil)
+		assert(v.GetArgs()).Equal([]interface{}{"kitty", 3})
+	})
+}
+
+func TestInvocation_SetArgs(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", []interface{}{"kitty"}, nil)
+		v.SetArgs("doggy", 3)
+		assert(v.args).Equal([]interface{}{"doggy", 3})
+		v.SetArgs()
+		assert(len(v.args)).Equal(0)
+	})
+}
+
+func TestInvocation_GetMetadata(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", nil, nil)
+		assert(v.GetMetadata("traceID")).Equal("", false)
+		v.SetMetadata("traceID", "1234")
+		assert(v.GetMetadata("traceID")).Equal("1234", true)
+	})
+}
+
+func TestInvocation_SetMetadata(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newInvocation("#.user:SayHello", nil, nil)
+		v.SetMetadata("traceID", "1234")
+		v.SetMetadata("traceID", "5678")
+		assert(v.metadata).Equal(map[string]string{"traceID": "5678"})
+	})
+}
+
+func TestIntercept(t *testing.T) {
+	t.Run("no interceptors", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(intercept(
+			nil,
+			newInvocation("#.user:SayHello", []interface{}{"kitty"}, nil),
+			func(inv *Invocation) (interface{}, *base.Error) {
+				return inv.GetTarget(), nil
+			},
+		)).Equal("#.user:SayHello", nil)
+	})
+
+	t.Run("interceptors are called in order", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls := make([]string, 0)
+		fnNew := func(name string) Interceptor {
+			return func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+				calls = append(calls, "before"+name)
+				inv.SetArgs(append(inv.GetArgs(), name)...)
+				ret, err := next(inv)
+				calls = append(calls, "after"+name)
+				return ret, err
+			}
+		}
+		assert(intercept(
+			[]Interceptor{fnNew("1"), fnNew("2")},
+			newInvocation("#.user:SayHello", []interface{}{"0"}, nil),
+			func(inv *Invocation) (interface{}, *base.Error) {
+				calls = append(calls, "invoke")
+				return inv.GetArgs(), nil
+			},
+		)).Equal([]interface{}{"0", "1", "2"}, nil)
+		assert(calls).Equal([]string{
+			"before1", "before2", "invoke", "after2", "after1",
+		})
+	})
+
+	t.Run("short-circuit", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		invoked := false
+		assert(intercept(
+			[]Interceptor{
+				func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+					return nil, base.ErrClientCanceled
+				},
+			},
+			newInvocation("#.user:SayHello", nil, nil),
+			func(inv *Invocation) (interface{}, *base.Error) {
+				invoked = true
+				return nil, nil
+			},
+		)).Equal(nil, base.ErrClientCanceled)
+		assert(invoked).IsFalse()
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		times := 0
+		assert(intercept(
+			[]Interceptor{
+				func(inv *Invocation, next Invoker) (interface{}, *base.Error) {
+					ret, err := next(inv)
+					for i := 0; i < 3 && err != nil; i++ {
+						ret, err = next(inv)
+					}
+					return ret, err
+				},
+			},
+			newInvocation("#.user:SayHello", nil, nil),
+			func(inv *Invocation) (interface{}, *base.Error) {
+				if times++; times < 3 {
+					return nil, base.ErrClientTimeout
+				}
+				return times, nil
+			},
+		)).Equal(3, nil)
+	})
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+)
+
+type metadataContextKey struct{}
+
+// WithMetadata returns a copy of ctx that carries the metadata key-value.
+// The metadata of ctx is sent with the calls of Client.SendContext, and it
+// can be read by Runtime.GetMetadata on the server
+func WithMetadata(ctx context.Context, key string, value string) context.Context {
+	metadata := getContextMetadata(ctx)
+	ret := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		ret[k] = v
+	}
+	ret[key] = value
+	return context.WithValue(ctx, metadataContextKey{}, ret)
+}
+
+func getContextMetadata(ctx context.Context) map[string]string {
+	if ret, ok := ctx.Value(metadataContextKey{}).(map[string]string); ok {
+		return ret
+	}
+	return nil
+}
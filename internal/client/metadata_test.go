@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+func TestWithMetadata(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ctx1 := WithMetadata(context.Background(), "traceID", "1234")
+		ctx2 := WithMetadata(ctx1, "locale", "en")
+		assert(getContextMetadata(ctx1)).Equal(map[string]string{
+			"traceID": "1234",
+		})
+		assert(getContextMetadata(ctx2)).Equal(map[string]string{
+			"traceID": "1234",
+			"locale":  "en",
+		})
+	})
+}
+
+func TestGetContextMetadata(t *testing.T) {
+	t.Run("metadata is not set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getContextMetadata(context.Background()) == nil).IsTrue()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ctx := WithMetadata(context.Background(), "traceID", "1234")
+		assert(getContextMetadata(ctx)).Equal(map[string]string{
+			"traceID": "1234",
+		})
+	})
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+// ReplyStream ...
+type ReplyStream struct {
+	client     *Client
+	item       *SendItem
+	chunks     []rpc.Any
+	notifyCH   chan bool
+	isDone     bool
+	isWaiting  bool
+	closedItem *SendItem
+	ret        rpc.Any
+	err        *base.Error
+	sync.Mutex
+}
+
+func newReplyStream(client *Client, item *SendItem) *ReplyStream {
+	ret := &ReplyStream{
+		client:     client,
+		item:       item,
+		chunks:     make([]rpc.Any, 0),
+		notifyCH:   make(chan bool, 1),
+		isDone:     false,
+		isWaiting:  false,
+		closedItem: nil,
+		ret:        nil,
+		err:        nil,
+	}
+	item.onChunk = ret.onChunk
+	return ret
+}
+
+func newFinishedReplyStream(ret rpc.Any, err *base.Error) *ReplyStream {
+	return &ReplyStream{
+		client:     nil,
+		item:       nil,
+		chunks:     make([]rpc.Any, 0),
+		notifyCH:   make(chan bool, 1),
+		isDone:     true,
+		isWaiting:  false,
+		closedItem: nil,
+		ret:        ret,
+		err:        err,
+	}
+}
+
+func (p *ReplyStream) onChunk(value rpc.Any) {
+	p.Lock()
+	p.chunks = append(p.chunks, value)
+	p.Unlock()
+
+	select {
+	case p.notifyCH <- true:
+	default:
+	}
+}
+
+// Next blocks until the next chunk arrives. It returns false when the stream
+// is finished, and the final reply can be got by Result. Next and Result
+// should be called by one goroutine, Close can be called by any goroutine
+func (p *ReplyStream) Next() (rpc.Any, bool) {
+	for {
+		p.Lock()
+		if len(p.chunks) > 0 {
+			ret := p.chunks[0]
+			p.chunks[0] = nil
+			p.chunks = p.chunks[1:]
+			p.Unlock()
+			return ret, true
+		} else if p.isDone {
+			p.Unlock()
+			return nil, false
+		}
+		item := p.item
+		p.isWaiting = true
+		p.Unlock()
+
+		select {
+		case <-p.notifyCH:
+			p.releaseClosedItem(nil)
+		case backStream := <-item.returnCH:
+			ret, err := rpc.ParseResponseStream(backStream)
+			backStream.Release()
+
+			p.Lock()
+			if p.item == item {
+				p.item = nil
+				p.ret, p.err = ret, err
+				p.isDone = true
+			} else {
+				// Close took the item, and it releases the item
+				item = nil
+			}
+			p.Unlock()
+
+			p.releaseClosedItem(item)
+		}
+	}
+}
+
+// releaseClosedItem is called by Next when it stops waiting. It releases the
+// finished item, and the item that Close left to it
+func (p *ReplyStream) releaseClosedItem(item *SendItem) {
+	p.Lock()
+	p.isWaiting = false
+	closedItem := p.closedItem
+	p.closedItem = nil
+	p.Unlock()
+
+	if item != nil {
+		item.Release()
+	}
+
+	if closedItem != nil {
+		closedItem.Release()
+	}
+}
+
+// Result blocks until the stream is finished, and returns the final reply.
+// The chunks that have not been read by Next are dropped
+func (p *ReplyStream) Result() (rpc.Any, *base.Error) {
+	for _, ok := p.Next(); ok; _, ok = p.Next() {
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	return p.ret, p.err
+}
+
+// Close stops reading the stream. If the call has not finished, it is
+// cancelled, and Result returns ErrClientCanceled. The SendItem of the stream
+// is only released when the stream is finished or closed, so Close should be
+// called if the stream is not read to the end
+func (p *ReplyStream) Close() {
+	p.Lock()
+	item := p.item
+	p.item = nil
+	p.chunks = make([]rpc.Any, 0)
+	if !p.isDone {
+		p.isDone = true
+		p.ret, p.err = nil, base.ErrClientCanceled
+	}
+	p.Unlock()
+
+	if item != nil {
+		if p.client != nil {
+			p.client.cancelItem(item)
+		}
+
+		// Next might be waiting for the item, then it wakes up and releases
+		// the item, so the item is not reused while it is waited
+		p.Lock()
+		if p.isWaiting {
+			p.closedItem, item = item, nil
+		}
+		p.Unlock()
+
+		if item != nil {
+			item.Release()
+		} else {
+			select {
+			case p.notifyCH <- true:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+func TestNewReplyStream(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		client := &Client{}
+		v := newReplyStream(client, item)
+		assert(v.client).Equal(client)
+		assert(v.item).Equal(item)
+		assert(v.chunks).Equal(make([]rpc.Any, 0))
+		assert(cap(v.notifyCH)).Equal(1)
+		assert(v.isDone, v.ret, v.err).Equal(false, nil, nil)
+		assert(v.isWaiting, v.closedItem).Equal(false, nil)
+		assert(item.onChunk != nil).IsTrue()
+	})
+}
+
+func TestNewFinishedReplyStream(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFinishedReplyStream(nil, base.ErrStream)
+		assert(v.client, v.item).Equal(nil, nil)
+		assert(v.isWaiting, v.closedItem).Equal(false, nil)
+		assert(v.isDone, v.ret, v.err).Equal(true, nil, base.ErrStream)
+		v = newFinishedReplyStream("ok", nil)
+		assert(v.isDone, v.ret, v.err).Equal(true, "ok", nil)
+	})
+}
+
+func TestReplyStream_onChunk(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newReplyStream(nil, NewSendItem(0))
+		v.onChunk("a")
+		v.onChunk("b")
+		assert(v.chunks).Equal([]rpc.Any{"a", "b"})
+		assert(len(v.notifyCH)).Equal(1)
+	})
+}
+
+func TestReplyStream_Next(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		v := newReplyStream(nil, item)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			item.onChunk("a")
+			item.onChunk("b")
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindRPCResponseOK)
+			stream.Write("done")
+			item.Back(stream)
+		}()
+
+		assert(v.Next()).Equal("a", true)
+		assert(v.Next()).Equal("b", true)
+		assert(v.Next()).Equal(nil, false)
+		assert(v.Next()).Equal(nil, false)
+		assert(v.item).IsNil()
+		assert(v.ret, v.err).Equal("done", nil)
+	})
+}
+
+func TestReplyStream_releaseClosedItem(t *testing.T) {
+	t.Run("nothing to release", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newReplyStream(nil, NewSendItem(0))
+		v.isWaiting = true
+		v.releaseClosedItem(nil)
+		assert(v.isWaiting, v.closedItem).Equal(false, nil)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		v := newReplyStream(nil, item)
+		v.isWaiting = true
+		v.closedItem = NewSendItem(0)
+		v.releaseClosedItem(item)
+		assert(v.isWaiting, v.closedItem).Equal(false, nil)
+	})
+}
+
+func TestReplyStream_Result(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		v := newReplyStream(nil, item)
+		item.onChunk("a")
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseError)
+		stream.WriteUint64(uint64(base.ErrStream.GetCode()))
+		stream.WriteString(base.ErrStream.GetMessage())
+		item.Back(stream)
+		assert(v.Result()).Equal(nil, base.ErrStream)
+		assert(len(v.chunks)).Equal(0)
+	})
+}
+
+func TestReplyStream_Close(t *testing.T) {
+	t.Run("stream is not finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newReplyStream(nil, NewSendItem(0))
+		v.onChunk("a")
+		v.Close()
+		assert(v.item).IsNil()
+		assert(v.Next()).Equal(nil, false)
+		assert(v.Result()).Equal(nil, base.ErrClientCanceled)
+		// close again
+		v.Close()
+		assert(v.Result()).Equal(nil, base.ErrClientCanceled)
+	})
+
+	t.Run("stream is finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		item := NewSendItem(0)
+		v := newReplyStream(nil, item)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseOK)
+		stream.Write("done")
+		item.Back(stream)
+		assert(v.Result()).Equal("done", nil)
+		v.Close()
+		assert(v.Result()).Equal("done", nil)
+	})
+
+	t.Run("Next is waiting", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newReplyStream(nil, NewSendItem(0))
+		waitCH := make(chan bool)
+		go func() {
+			_, ok := v.Next()
+			waitCH <- ok
+		}()
+
+		for isWaiting := false; !isWaiting; {
+			time.Sleep(10 * time.Millisecond)
+			v.Lock()
+			isWaiting = v.isWaiting
+			v.Unlock()
+		}
+
+		v.Close()
+		assert(<-waitCH).IsFalse()
+		assert(v.Result()).Equal(nil, base.ErrClientCanceled)
+		assert(v.item, v.closedItem).Equal(nil, nil)
+		assert(v.isWaiting).IsFalse()
+	})
+}
@@ -22,6 +22,8 @@ type SendItem struct {
 	startTimeNS int64
 	sendTimeNS  int64
 	timeoutNS   int64
+	numOfChunks uint64
+	onChunk     func(value rpc.Any)
 	returnCH    chan *rpc.Stream
 	sendStream  *rpc.Stream
 	next        *SendItem
@@ -34,6 +36,8 @@ func NewSendItem(timeoutNS int64) *SendItem {
 	ret.startTimeNS = base.TimeNow().UnixNano()
 	ret.sendTimeNS = 0
 	ret.timeoutNS = timeoutNS
+	ret.numOfChunks = 0
+	ret.onChunk = nil
 	ret.next = nil
 	return ret
 }
@@ -48,26 +52,56 @@ func (p *SendItem) Back(stream *rpc.Stream) bool {
 	return true
 }
 
-// CheckTime ...
-func (p *SendItem) CheckTime(nowNS int64) bool {
-	if nowNS-p.startTimeNS > p.timeoutNS && p.isRunning {
-		p.isRunning = false
+// Chunk ...
+func (p *SendItem) Chunk(stream *rpc.Stream) bool {
+	if stream == nil || !p.isRunning || p.onChunk == nil {
+		return false
+	}
 
-		// return timeout stream
-		stream := rpc.NewStream()
-		stream.SetKind(rpc.StreamKindRPCResponseError)
-		stream.SetCallbackID(p.sendStream.GetCallbackID())
-		stream.WriteUint64(uint64(base.ErrClientTimeout.GetCode()))
-		stream.WriteString(base.ErrClientTimeout.GetMessage())
-		p.returnCH <- stream
+	if index, err := stream.ReadUint64(); err != nil || index != p.numOfChunks {
+		// the chunk has already been received
+		return false
+	} else if value, err := stream.Read(); err != nil || !stream.IsReadFinish() {
+		return false
+	} else {
+		// every chunk restarts the timeout
+		p.numOfChunks++
+		p.startTimeNS = base.TimeNow().UnixNano()
+		p.onChunk(value)
 		return true
 	}
+}
+
+// CheckTime ...
+func (p *SendItem) CheckTime(nowNS int64) bool {
+	if nowNS-p.startTimeNS > p.timeoutNS {
+		return p.abort(base.ErrClientTimeout)
+	}
 
 	return false
 }
 
+// abort finishes the item with err if it is still running
+func (p *SendItem) abort(err *base.Error) bool {
+	if !p.isRunning {
+		return false
+	}
+
+	p.isRunning = false
+
+	// return error stream
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindRPCResponseError)
+	stream.SetCallbackID(p.sendStream.GetCallbackID())
+	stream.WriteUint64(uint64(err.GetCode()))
+	stream.WriteString(err.GetMessage())
+	p.returnCH <- stream
+	return true
+}
+
 // Release ...
 func (p *SendItem) Release() {
+	p.onChunk = nil
 	p.sendStream.Reset()
 	sendItemCache.Put(p)
 }
@@ -18,6 +18,8 @@ func TestSendItem_NewSendItem(t *testing.T) {
 		assert(base.TimeNow().UnixNano()-v.startTimeNS >= 0).IsTrue()
 		assert(v.sendTimeNS).Equal(int64(0))
 		assert(v.timeoutNS).Equal(int64(5432))
+		assert(v.numOfChunks).Equal(uint64(0))
+		assert(v.onChunk == nil).IsTrue()
 		assert(len(v.returnCH)).Equal(0)
 		assert(cap(v.returnCH)).Equal(1)
 		assert(v.sendStream).IsNotNil()
@@ -51,6 +53,72 @@ func TestSendItem_Back(t *testing.T) {
 	})
 }
 
+func TestSendItem_Chunk(t *testing.T) {
+	fnMakeChunk := func(index uint64, value interface{}) *rpc.Stream {
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseChunk)
+		stream.WriteUint64(index)
+		stream.Write(value)
+		return stream
+	}
+
+	t.Run("stream is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.onChunk = func(value rpc.Any) {}
+		assert(v.Chunk(nil)).IsFalse()
+	})
+
+	t.Run("item is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.onChunk = func(value rpc.Any) {}
+		v.isRunning = false
+		assert(v.Chunk(fnMakeChunk(0, true))).IsFalse()
+	})
+
+	t.Run("onChunk is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		assert(v.Chunk(fnMakeChunk(0, true))).IsFalse()
+	})
+
+	t.Run("index is wrong", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.onChunk = func(value rpc.Any) {}
+		v.numOfChunks = 1
+		assert(v.Chunk(fnMakeChunk(0, true))).IsFalse()
+		assert(v.Chunk(fnMakeChunk(2, true))).IsFalse()
+		assert(v.numOfChunks).Equal(uint64(1))
+	})
+
+	t.Run("stream is broken", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.onChunk = func(value rpc.Any) {}
+		stream := fnMakeChunk(0, true)
+		stream.WriteBool(true)
+		assert(v.Chunk(stream)).IsFalse()
+		assert(v.numOfChunks).Equal(uint64(0))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.startTimeNS = 0
+		values := make([]rpc.Any, 0)
+		v.onChunk = func(value rpc.Any) {
+			values = append(values, value)
+		}
+		assert(v.Chunk(fnMakeChunk(0, "a"))).IsTrue()
+		assert(v.Chunk(fnMakeChunk(1, "b"))).IsTrue()
+		assert(v.numOfChunks).Equal(uint64(2))
+		assert(v.startTimeNS > 0).IsTrue()
+		assert(values).Equal([]rpc.Any{"a", "b"})
+	})
+}
+
 func TestSendItem_CheckTime(t *testing.T) {
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -91,6 +159,28 @@ func TestSendItem_CheckTime(t *testing.T) {
 	})
 }
 
+func TestSendItem_abort(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.sendStream.SetCallbackID(15)
+		assert(v.abort(base.ErrClientClosed)).IsTrue()
+		assert(v.isRunning).IsFalse()
+		stream := <-v.returnCH
+		assert(stream.GetCallbackID()).Equal(uint64(15))
+		assert(rpc.ParseResponseStream(stream)).
+			Equal(nil, base.ErrClientClosed)
+	})
+
+	t.Run("it is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewSendItem(0)
+		v.isRunning = false
+		assert(v.abort(base.ErrClientClosed)).IsFalse()
+		assert(len(v.returnCH)).Equal(0)
+	})
+}
+
 func TestSendItem_Release(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
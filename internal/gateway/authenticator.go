@@ -0,0 +1,22 @@
+package gateway
+
+import (
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+// Authenticator checks the credentials that the client sends in the connect
+// request. It returns the principal that is attached to the session, or
+// false to reject the connection
+type Authenticator interface {
+	Authenticate(credentials rpc.Map) (*rpc.Principal, bool)
+}
+
+// AuthenticatorFunc is an adapter to use a function as an Authenticator
+type AuthenticatorFunc func(credentials rpc.Map) (*rpc.Principal, bool)
+
+// Authenticate ...
+func (p AuthenticatorFunc) Authenticate(
+	credentials rpc.Map,
+) (*rpc.Principal, bool) {
+	return p(credentials)
+}
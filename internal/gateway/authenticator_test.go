@@ -0,0 +1,20 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+func TestAuthenticatorFunc_Authenticate(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		principal := rpc.NewPrincipal("kitty")
+		v := AuthenticatorFunc(func(credentials rpc.Map) (*rpc.Principal, bool) {
+			return principal, credentials["token"] == "abc"
+		})
+		assert(v.Authenticate(rpc.Map{"token": "abc"})).Equal(principal, true)
+		assert(v.Authenticate(rpc.Map{"token": "123"})).Equal(principal, false)
+	})
+}
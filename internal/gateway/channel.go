@@ -10,6 +10,8 @@ type Channel struct {
 	sequence   uint64
 	backTimeNS int64
 	backStream *rpc.Stream
+	chunks     []*rpc.Stream
+	isDropped  bool
 }
 
 // In ...
@@ -43,6 +45,38 @@ func (p *Channel) Out(stream *rpc.Stream) (canOut bool) {
 	}
 }
 
+// OutChunk records the chunk, so it can be replayed if the request is sent
+// again. Only the last maxChunks chunks are kept
+func (p *Channel) OutChunk(stream *rpc.Stream, maxChunks int) (canOut bool) {
+	if stream.GetCallbackID() == p.sequence && p.backTimeNS == 0 {
+		p.chunks = append(p.chunks, stream)
+		if len(p.chunks) > maxChunks {
+			p.chunks[0].Release()
+			p.chunks[0] = nil
+			p.chunks = p.chunks[1:]
+			p.isDropped = true
+		}
+		return true
+	}
+
+	return false
+}
+
+// Chunks returns the recorded chunks of the call. It returns false if some of
+// the chunks have been dropped, and the call can not be replayed
+func (p *Channel) Chunks(id uint64) ([]*rpc.Stream, bool) {
+	if id == p.sequence {
+		return p.chunks, !p.isDropped
+	}
+
+	return nil, true
+}
+
+// IsRunning ...
+func (p *Channel) IsRunning(id uint64) bool {
+	return id == p.sequence && p.backTimeNS == 0
+}
+
 // IsTimeout ...
 func (p *Channel) IsTimeout(nowNS int64, timeout int64) bool {
 	return p.backTimeNS > 0 && nowNS-p.backTimeNS > timeout
@@ -55,4 +89,9 @@ func (p *Channel) Clean() {
 		p.backStream.Release()
 		p.backStream = nil
 	}
+	for _, chunk := range p.chunks {
+		chunk.Release()
+	}
+	p.chunks = nil
+	p.isDropped = false
 }
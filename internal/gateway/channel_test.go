@@ -76,6 +76,103 @@ func TestChannel_Out(t *testing.T) {
 	})
 }
 
+func TestChannel_OutChunk(t *testing.T) {
+	t.Run("id equals sequence", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10}
+		stream1 := rpc.NewStream()
+		stream1.SetCallbackID(10)
+		stream2 := rpc.NewStream()
+		stream2.SetCallbackID(10)
+		assert(v.OutChunk(stream1, 8)).Equal(true)
+		assert(v.OutChunk(stream2, 8)).Equal(true)
+		assert(len(v.chunks)).Equal(2)
+		assert(v.chunks[0] == stream1, v.chunks[1] == stream2).Equal(true, true)
+		assert(v.backTimeNS, v.backStream).Equal(int64(0), nil)
+	})
+
+	t.Run("chunks overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10}
+		streams := make([]*rpc.Stream, 3)
+		for i := 0; i < len(streams); i++ {
+			streams[i] = rpc.NewStream()
+			streams[i].SetCallbackID(10)
+			assert(v.OutChunk(streams[i], 2)).Equal(true)
+		}
+		assert(len(v.chunks)).Equal(2)
+		assert(v.chunks[0] == streams[1], v.chunks[1] == streams[2]).
+			Equal(true, true)
+		assert(v.isDropped).IsTrue()
+		v.Clean()
+		assert(v.isDropped).IsFalse()
+	})
+
+	t.Run("id equals sequence, but reply has been out", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10, backTimeNS: 10}
+		stream := rpc.NewStream()
+		stream.SetCallbackID(10)
+		assert(v.OutChunk(stream, 8)).Equal(false)
+		assert(len(v.chunks)).Equal(0)
+	})
+
+	t.Run("id is wrong", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10}
+		stream := rpc.NewStream()
+		stream.SetCallbackID(9)
+		assert(v.OutChunk(stream, 8)).Equal(false)
+		stream.SetCallbackID(11)
+		assert(v.OutChunk(stream, 8)).Equal(false)
+		assert(len(v.chunks)).Equal(0)
+	})
+}
+
+func TestChannel_Chunks(t *testing.T) {
+	t.Run("id equals sequence", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		v := &Channel{sequence: 10, chunks: []*rpc.Stream{stream}}
+		assert(v.Chunks(10)).Equal([]*rpc.Stream{stream}, true)
+	})
+
+	t.Run("chunks have been dropped", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		v := &Channel{sequence: 10, chunks: []*rpc.Stream{stream}, isDropped: true}
+		assert(v.Chunks(10)).Equal([]*rpc.Stream{stream}, false)
+	})
+
+	t.Run("id is wrong", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10, chunks: []*rpc.Stream{rpc.NewStream()}}
+		assert(v.Chunks(9)).Equal([]*rpc.Stream(nil), true)
+		assert(v.Chunks(11)).Equal([]*rpc.Stream(nil), true)
+	})
+}
+
+func TestChannel_IsRunning(t *testing.T) {
+	t.Run("id is wrong", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10}
+		assert(v.IsRunning(9)).IsFalse()
+		assert(v.IsRunning(11)).IsFalse()
+	})
+
+	t.Run("reply has been out", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10, backTimeNS: 10}
+		assert(v.IsRunning(10)).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{sequence: 10}
+		assert(v.IsRunning(10)).IsTrue()
+	})
+}
+
 func TestChannel_IsTimeout(t *testing.T) {
 	t.Run("backTimeNS is zero", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -123,4 +220,18 @@ func TestChannel_Clean(t *testing.T) {
 		assert(v.sequence, v.backTimeNS, v.backStream).
 			Equal(uint64(10), int64(0), nil)
 	})
+
+	t.Run("chunks is not empty", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &Channel{
+			sequence:   10,
+			backTimeNS: 1,
+			backStream: rpc.NewStream(),
+			chunks:     []*rpc.Stream{rpc.NewStream(), rpc.NewStream()},
+		}
+		v.Clean()
+		assert(v.sequence, v.backTimeNS, v.backStream).
+			Equal(uint64(10), int64(0), nil)
+		assert(v.chunks == nil).IsTrue()
+	})
 }
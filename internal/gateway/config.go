@@ -11,11 +11,19 @@ type Config struct {
 	heartbeat        time.Duration
 	heartbeatTimeout time.Duration
 
+	// the streams whose bodies are longer than compressThreshold are
+	// compressed, if the client supports the compression
+	compression       string
+	compressThreshold int
+
 	serverMaxSessions     int
 	serverSessionTimeout  time.Duration
 	serverReadBufferSize  int
 	serverWriteBufferSize int
 	serverCacheTimeout    time.Duration
+	serverMaxOutboxSize   int
+	// the chunks of a streaming reply that are kept for the replay
+	serverMaxChunkCacheSize int
 }
 
 // GetDefaultConfig ...
@@ -26,10 +34,16 @@ func GetDefaultConfig() *Config {
 		heartbeat:        4 * time.Second,
 		heartbeatTimeout: 8 * time.Second,
 
+		compression:       "",
+		compressThreshold: 1024,
+
 		serverMaxSessions:     10240000,
 		serverSessionTimeout:  120 * time.Second,
 		serverReadBufferSize:  1200,
 		serverWriteBufferSize: 1200,
 		serverCacheTimeout:    10 * time.Second,
+		serverMaxOutboxSize:   1024,
+
+		serverMaxChunkCacheSize: 1024,
 	}
 }
@@ -14,10 +14,14 @@ func TestGetDefaultConfig(t *testing.T) {
 		assert(cfg.transLimit).Equal(4 * 1024 * 1024)
 		assert(cfg.heartbeat).Equal(4 * time.Second)
 		assert(cfg.heartbeatTimeout).Equal(8 * time.Second)
+		assert(cfg.compression).Equal("")
+		assert(cfg.compressThreshold).Equal(1024)
 		assert(cfg.serverMaxSessions).Equal(10240000)
 		assert(cfg.serverSessionTimeout).Equal(120 * time.Second)
 		assert(cfg.serverReadBufferSize).Equal(1200)
 		assert(cfg.serverWriteBufferSize).Equal(1200)
 		assert(cfg.serverCacheTimeout).Equal(10 * time.Second)
+		assert(cfg.serverMaxOutboxSize).Equal(1024)
+		assert(cfg.serverMaxChunkCacheSize).Equal(1024)
 	})
 }
@@ -3,6 +3,7 @@ package gateway
 
 import (
 	"crypto/tls"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,6 +27,11 @@ type GateWay struct {
 	streamHub      rpc.IStreamHub
 	closeCH        chan bool
 	config         *Config
+	authenticator  Authenticator
+	sessionStore   SessionStore
+	forwardCaller  bool
+	topicMap       map[string]map[uint64]bool
+	topicLock      sync.Mutex
 	adapters       []*adapter.Adapter
 	orcManager     *base.ORCManager
 	sync.Mutex
@@ -50,6 +56,10 @@ func NewGateWay(
 		streamHub:      streamHub,
 		closeCH:        make(chan bool, 1),
 		config:         config,
+		authenticator:  nil,
+		sessionStore:   nil,
+		forwardCaller:  false,
+		topicMap:       make(map[string]map[uint64]bool),
 		adapters:       make([]*adapter.Adapter, 0),
 		orcManager:     base.NewORCManager(),
 	}
@@ -76,6 +86,173 @@ func (p *GateWay) GetSession(id uint64) (*Session, bool) {
 	return p.sessionMapList[id%sessionManagerVectorSize].Get(id)
 }
 
+// GetPrincipal returns the principal of the session
+func (p *GateWay) GetPrincipal(sessionID uint64) *rpc.Principal {
+	if session, ok := p.GetSession(sessionID); ok {
+		return session.GetPrincipal()
+	}
+
+	return nil
+}
+
+// GetSessionAttribute returns the attribute of the session by key
+func (p *GateWay) GetSessionAttribute(
+	sessionID uint64,
+	key string,
+) (rpc.Any, bool) {
+	if session, ok := p.GetSession(sessionID); ok {
+		return session.GetAttribute(key)
+	}
+
+	return nil, false
+}
+
+// SetSessionAttribute sets the attribute of the session by key. It returns
+// false if the session is not found
+func (p *GateWay) SetSessionAttribute(
+	sessionID uint64,
+	key string,
+	value rpc.Any,
+) bool {
+	if session, ok := p.GetSession(sessionID); ok {
+		session.SetAttribute(key, value)
+		return true
+	}
+
+	return false
+}
+
+func (p *GateWay) getSessionStore() SessionStore {
+	p.Lock()
+	defer p.Unlock()
+	return p.sessionStore
+}
+
+// loadSession loads the attributes of the session that is not in the
+// gateway from the session store. Like resuming a live session, the session
+// can only be loaded by the connection of the same principal
+func (p *GateWay) loadSession(
+	id uint64,
+	security string,
+	principal *rpc.Principal,
+) (rpc.Map, bool) {
+	store := p.getSessionStore()
+	if store == nil {
+		return nil, false
+	} else if _, ok := p.GetSession(id); ok {
+		return nil, false
+	} else if name, attributes, ok := store.Load(
+		fmt.Sprintf("%d-%s", id, security),
+	); !ok {
+		return nil, false
+	} else if name != getPrincipalName(principal) {
+		return nil, false
+	} else {
+		// the new sessions must not take the id
+		for {
+			seed := atomic.LoadUint64(&p.sessionSeed)
+			if seed >= id ||
+				atomic.CompareAndSwapUint64(&p.sessionSeed, seed, id) {
+				return attributes, true
+			}
+		}
+	}
+}
+
+// onSessionEvent reports the session event to the stream hub, so the session
+// hooks of the services are invoked
+func (p *GateWay) onSessionEvent(sessionID uint64, event string) {
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindSessionEvent)
+	stream.SetGatewayID(p.id)
+	stream.SetSessionID(sessionID)
+	stream.WriteString(event)
+	p.streamHub.OnReceiveStream(stream)
+}
+
+// onSessionClose reports the close event with the snapshot of the principal
+// and the attributes, because the session is removed before the hooks run
+func (p *GateWay) onSessionClose(session *Session) {
+	principal, attributes := session.getSnapshot()
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindSessionEvent)
+	stream.SetGatewayID(p.id)
+	stream.SetSessionID(session.id)
+	stream.WriteString(rpc.SessionEventClose)
+	if principal == nil {
+		stream.WriteNil()
+	} else {
+		roles := make(rpc.Array, 0)
+		for _, role := range principal.GetRoles() {
+			roles = append(roles, role)
+		}
+		stream.WriteString(principal.GetName())
+		stream.Write(roles)
+	}
+	if attributes == nil {
+		stream.WriteNil()
+	} else {
+		stream.Write(attributes)
+	}
+	p.streamHub.OnReceiveStream(stream)
+}
+
+func (p *GateWay) subscribe(topic string, sessionID uint64) {
+	p.topicLock.Lock()
+	defer p.topicLock.Unlock()
+
+	sessions, ok := p.topicMap[topic]
+	if !ok {
+		sessions = make(map[uint64]bool)
+		p.topicMap[topic] = sessions
+	}
+	sessions[sessionID] = true
+}
+
+func (p *GateWay) unsubscribe(topic string, sessionID uint64) {
+	p.topicLock.Lock()
+	defer p.topicLock.Unlock()
+
+	if sessions, ok := p.topicMap[topic]; ok {
+		delete(sessions, sessionID)
+		if len(sessions) == 0 {
+			delete(p.topicMap, topic)
+		}
+	}
+}
+
+// Publish sends the stream to all the sessions that subscribe its topic
+func (p *GateWay) Publish(stream *rpc.Stream) {
+	topic, err := stream.ReadString()
+	if err != nil {
+		p.streamHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+		stream.Release()
+		return
+	}
+
+	sessionIDs := func() []uint64 {
+		p.topicLock.Lock()
+		defer p.topicLock.Unlock()
+
+		ret := make([]uint64, 0, len(p.topicMap[topic]))
+		for id := range p.topicMap[topic] {
+			ret = append(ret, id)
+		}
+		return ret
+	}()
+
+	stream.SetReadPosToBodyStart()
+	stream.SetGatewayID(p.id)
+	for _, id := range sessionIDs {
+		if session, ok := p.GetSession(id); ok {
+			item := stream.Clone()
+			item.SetSessionID(id)
+			session.OutStream(item)
+		}
+	}
+	stream.Release()
+}
+
 // CreateSessionID ...
 func (p *GateWay) CreateSessionID() uint64 {
 	return atomic.AddUint64(&p.sessionSeed, 1)
@@ -116,6 +293,80 @@ func (p *GateWay) Listen(
 	return p
 }
 
+// SetCompression sets the codec that the streams are compressed with, if the
+// client supports it. The streams whose bodies are not longer than threshold
+// are not compressed, and the compression is disabled if it is ""
+func (p *GateWay) SetCompression(compression string, threshold int) *GateWay {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.streamHub.OnReceiveStream(
+			rpc.MakeSystemErrorStream(base.ErrGatewayAlreadyRunning),
+		)
+	} else if compression != "" && !rpc.IsCompressionSupported(compression) {
+		p.streamHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrGateWayCompressionNotSupported.AddDebug(compression),
+		))
+	} else {
+		p.config.compression = compression
+		p.config.compressThreshold = base.MaxInt(threshold, 0)
+	}
+
+	return p
+}
+
+// SetAuthenticator sets the authenticator that checks the connect requests.
+// All the connections are accepted if it is not set
+func (p *GateWay) SetAuthenticator(authenticator Authenticator) *GateWay {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isRunning {
+		p.authenticator = authenticator
+	} else {
+		p.streamHub.OnReceiveStream(
+			rpc.MakeSystemErrorStream(base.ErrGatewayAlreadyRunning),
+		)
+	}
+
+	return p
+}
+
+// SetSessionStore sets the store that keeps the session attributes, so they
+// survive a gateway restart
+func (p *GateWay) SetSessionStore(store SessionStore) *GateWay {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isRunning {
+		p.sessionStore = store
+	} else {
+		p.streamHub.OnReceiveStream(
+			rpc.MakeSystemErrorStream(base.ErrGatewayAlreadyRunning),
+		)
+	}
+
+	return p
+}
+
+// SetForwardCaller makes the requests carry the principal and the session of
+// the caller, when they are evaluated by another node
+func (p *GateWay) SetForwardCaller(forward bool) *GateWay {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isRunning {
+		p.forwardCaller = forward
+	} else {
+		p.streamHub.OnReceiveStream(
+			rpc.MakeSystemErrorStream(base.ErrGatewayAlreadyRunning),
+		)
+	}
+
+	return p
+}
+
 // ListenWithDebug ...
 func (p *GateWay) ListenWithDebug(
 	network string,
@@ -229,7 +480,7 @@ func (p *GateWay) OutStream(stream *rpc.Stream) {
 // OnConnOpen ...
 func (p *GateWay) OnConnOpen(_ *adapter.StreamConn) {
 	// ignore
-	// we will add some security checks here
+	// the connection is authenticated by the connect request in InitSession
 }
 
 // OnConnReadStream ...
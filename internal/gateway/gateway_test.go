@@ -39,6 +39,9 @@ func TestNewGateWay(t *testing.T) {
 		assert(len(v.closeCH)).Equal(0)
 		assert(cap(v.closeCH)).Equal(1)
 		assert(v.config).Equal(GetDefaultConfig())
+		assert(v.authenticator).IsNil()
+		assert(v.sessionStore).IsNil()
+		assert(v.topicMap).Equal(map[string]map[uint64]bool{})
 		assert(len(v.adapters)).Equal(0)
 		assert(cap(v.adapters)).Equal(0)
 		assert(v.orcManager).IsNotNil()
@@ -99,6 +102,262 @@ func TestGateWay_GetSession(t *testing.T) {
 	})
 }
 
+func TestGateWay_GetPrincipal(t *testing.T) {
+	t.Run("session is not found", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.GetPrincipal(1)).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		principal := rpc.NewPrincipal("kitty")
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		session := newSession(1, v)
+		session.principal = principal
+		v.AddSession(session)
+		assert(v.GetPrincipal(1)).Equal(principal)
+	})
+}
+
+func TestGateWay_GetSessionAttribute(t *testing.T) {
+	t.Run("session is not found", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.GetSessionAttribute(1, "name")).Equal(nil, false)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		session := newSession(1, v)
+		session.attributes = rpc.Map{"name": "kitty"}
+		v.AddSession(session)
+		assert(v.GetSessionAttribute(1, "name")).Equal("kitty", true)
+	})
+}
+
+func TestGateWay_SetSessionAttribute(t *testing.T) {
+	t.Run("session is not found", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.SetSessionAttribute(1, "name", "kitty")).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		session := newSession(1, v)
+		v.AddSession(session)
+		assert(v.SetSessionAttribute(1, "name", "kitty")).IsTrue()
+		assert(session.attributes).Equal(rpc.Map{"name": "kitty"})
+	})
+}
+
+func TestGateWay_getSessionStore(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.getSessionStore()).IsNil()
+		v.sessionStore = store
+		assert(v.getSessionStore()).Equal(store)
+	})
+}
+
+func TestGateWay_loadSession(t *testing.T) {
+	security := "12345678123456781234567812345678"
+
+	t.Run("store is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.loadSession(1, security, nil)).Equal(nil, false)
+	})
+
+	t.Run("session is in the gateway", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		store.Save("1-"+security, "", rpc.Map{"name": "kitty"})
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		v.SetSessionStore(store)
+		v.AddSession(newSession(1, v))
+		assert(v.loadSession(1, security, nil)).Equal(nil, false)
+	})
+
+	t.Run("session is not in the store", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		v.SetSessionStore(newTestSessionStore())
+		assert(v.loadSession(1, security, nil)).Equal(nil, false)
+	})
+
+	t.Run("principal does not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		store.Save("1-"+security, "kitty", rpc.Map{"name": "kitty"})
+		store.Save("2-"+security, "", rpc.Map{"name": "kitty"})
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		v.SetSessionStore(store)
+		assert(v.loadSession(1, security, nil)).Equal(nil, false)
+		assert(v.loadSession(1, security, rpc.NewPrincipal("doggy"))).
+			Equal(nil, false)
+		assert(v.loadSession(2, security, rpc.NewPrincipal("doggy"))).
+			Equal(nil, false)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		store.Save("5-"+security, "", rpc.Map{"name": "kitty"})
+		store.Save("3-"+security, "doggy", rpc.Map{"name": "doggy"})
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		v.SetSessionStore(store)
+		assert(v.loadSession(5, security, nil)).
+			Equal(rpc.Map{"name": "kitty"}, true)
+		assert(v.sessionSeed).Equal(uint64(5))
+		assert(v.loadSession(3, security, rpc.NewPrincipal("doggy"))).
+			Equal(rpc.Map{"name": "doggy"}, true)
+		assert(v.sessionSeed).Equal(uint64(5))
+	})
+}
+
+func TestGateWay_onSessionEvent(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		v.onSessionEvent(11, rpc.SessionEventOpen)
+		stream := streamHub.GetStream()
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindSessionEvent))
+		assert(stream.GetGatewayID()).Equal(uint64(132))
+		assert(stream.GetSessionID()).Equal(uint64(11))
+		assert(stream.ReadString()).Equal(rpc.SessionEventOpen, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+}
+
+func TestGateWay_onSessionClose(t *testing.T) {
+	t.Run("principal and attributes are nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		v.onSessionClose(newSession(11, v))
+		stream := streamHub.GetStream()
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindSessionEvent))
+		assert(stream.GetGatewayID()).Equal(uint64(132))
+		assert(stream.GetSessionID()).Equal(uint64(11))
+		assert(stream.ReadString()).Equal(rpc.SessionEventClose, nil)
+		assert(stream.ReadNil()).Equal(nil, nil)
+		assert(stream.ReadNil()).Equal(nil, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		session := newSession(11, v)
+		session.principal = rpc.NewPrincipal("kitty", "admin")
+		session.attributes = rpc.Map{"name": "doggy"}
+		v.onSessionClose(session)
+		stream := streamHub.GetStream()
+		assert(stream.ReadString()).Equal(rpc.SessionEventClose, nil)
+		assert(stream.ReadString()).Equal("kitty", nil)
+		assert(stream.ReadArray()).Equal(rpc.Array{"admin"}, nil)
+		assert(stream.ReadMap()).Equal(rpc.Map{"name": "doggy"}, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+}
+
+func TestGateWay_subscribe(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		v.subscribe("news", 11)
+		v.subscribe("news", 12)
+		v.subscribe("news", 12)
+		v.subscribe("sport", 11)
+		assert(v.topicMap).Equal(map[string]map[uint64]bool{
+			"news":  {11: true, 12: true},
+			"sport": {11: true},
+		})
+	})
+}
+
+func TestGateWay_unsubscribe(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		v.subscribe("news", 11)
+		v.subscribe("news", 12)
+		v.unsubscribe("sport", 11)
+		v.unsubscribe("news", 11)
+		assert(v.topicMap).Equal(map[string]map[uint64]bool{
+			"news": {12: true},
+		})
+		v.unsubscribe("news", 12)
+		assert(v.topicMap).Equal(map[string]map[uint64]bool{})
+	})
+}
+
+func TestGateWay_Publish(t *testing.T) {
+	t.Run("read topic error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteBool(true)
+		v.Publish(stream)
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		s1 := newSession(11, v)
+		s2 := newSession(12, v)
+		s3 := newSession(13, v)
+		v.AddSession(s1)
+		v.AddSession(s2)
+		v.AddSession(s3)
+		v.subscribe("news", 11)
+		v.subscribe("news", 12)
+		// the session 14 does not exist
+		v.subscribe("news", 14)
+
+		netConns := make([]*testNetConn, 0)
+		for _, session := range []*Session{s1, s2, s3} {
+			netConn := newTestNetConn()
+			syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+			streamConn := adapter.NewStreamConn(false, syncConn, session)
+			syncConn.SetNext(streamConn)
+			session.conn = streamConn
+			netConns = append(netConns, netConn)
+		}
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		stream.WriteString("HI")
+		v.Publish(stream)
+
+		for i, netConn := range netConns[:2] {
+			outStream := rpc.NewStream()
+			outStream.PutBytesTo(netConn.writeBuffer, 0)
+			assert(outStream.GetKind()).Equal(uint8(rpc.StreamKindTopicPublish))
+			assert(outStream.GetGatewayID()).Equal(uint64(132))
+			assert(outStream.GetSessionID()).Equal(uint64(11 + i))
+			assert(outStream.ReadString()).Equal("news", nil)
+			assert(outStream.ReadString()).Equal("HI", nil)
+			assert(outStream.IsReadFinish()).IsTrue()
+		}
+		assert(len(netConns[2].writeBuffer)).Equal(0)
+	})
+}
+
 func TestGateWay_CreateSessionID(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -153,6 +412,107 @@ func TestGateWay_Listen(t *testing.T) {
 	})
 }
 
+func TestGateWay_SetAuthenticator(t *testing.T) {
+	t.Run("gateway is running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		v.isRunning = true
+		assert(v.SetAuthenticator(AuthenticatorFunc(nil))).Equal(v)
+		assert(v.authenticator).IsNil()
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrGatewayAlreadyRunning)
+	})
+
+	t.Run("gateway is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		authenticator := AuthenticatorFunc(
+			func(credentials rpc.Map) (*rpc.Principal, bool) {
+				return nil, true
+			},
+		)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.SetAuthenticator(authenticator)).Equal(v)
+		assert(v.authenticator).IsNotNil()
+	})
+}
+
+func TestGateWay_SetCompression(t *testing.T) {
+	t.Run("gateway is running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		v.isRunning = true
+		assert(v.SetCompression(rpc.CompressionGzip, 64)).Equal(v)
+		assert(v.config.compression).Equal("")
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrGatewayAlreadyRunning)
+	})
+
+	t.Run("compression is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		assert(v.SetCompression("br", 64)).Equal(v)
+		assert(v.config.compression).Equal("")
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrGateWayCompressionNotSupported.AddDebug("br").
+				Standardize())
+	})
+
+	t.Run("gateway is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.SetCompression(rpc.CompressionGzip, 64)).Equal(v)
+		assert(v.config.compression).Equal(rpc.CompressionGzip)
+		assert(v.config.compressThreshold).Equal(64)
+		assert(v.SetCompression("", -1)).Equal(v)
+		assert(v.config.compression).Equal("")
+		assert(v.config.compressThreshold).Equal(0)
+	})
+}
+
+func TestGateWay_SetSessionStore(t *testing.T) {
+	t.Run("gateway is running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		v.isRunning = true
+		assert(v.SetSessionStore(newTestSessionStore())).Equal(v)
+		assert(v.sessionStore).IsNil()
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrGatewayAlreadyRunning)
+	})
+
+	t.Run("gateway is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.SetSessionStore(store)).Equal(v)
+		assert(v.sessionStore).Equal(store)
+	})
+}
+
+func TestGateWay_SetForwardCaller(t *testing.T) {
+	t.Run("gateway is running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		v := NewGateWay(132, GetDefaultConfig(), streamHub)
+		v.isRunning = true
+		assert(v.SetForwardCaller(true)).Equal(v)
+		assert(v.forwardCaller).IsFalse()
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrGatewayAlreadyRunning)
+	})
+
+	t.Run("gateway is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		assert(v.SetForwardCaller(true)).Equal(v)
+		assert(v.forwardCaller).IsTrue()
+	})
+}
+
 func TestGateWay_ListenWithDebug(t *testing.T) {
 	t.Run("gateway is running", func(t *testing.T) {
 		assert := base.NewAssert(t)
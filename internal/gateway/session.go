@@ -19,6 +19,14 @@ type Session struct {
 	id           uint64
 	gateway      *GateWay
 	security     string
+	principal    *rpc.Principal
+	attributes   rpc.Map
+	attributeSeq uint64
+	savedSeq     uint64
+	saveLock     sync.Mutex
+	topics       map[string]bool
+	outbox       []*rpc.Stream
+	outboxSeq    uint64
 	conn         *adapter.StreamConn
 	channels     []Channel
 	activeTimeNS int64
@@ -42,18 +50,45 @@ func InitSession(
 	} else if sessionString, err := stream.ReadString(); err != nil {
 		stream.Release()
 		gw.OnConnError(streamConn, base.ErrStream)
+	} else if credentials, err := readCredentials(stream); err != nil {
+		stream.Release()
+		gw.OnConnError(streamConn, base.ErrStream)
+	} else if compressions, err := readCompressions(stream); err != nil {
+		stream.Release()
+		gw.OnConnError(streamConn, base.ErrStream)
 	} else if !stream.IsReadFinish() {
 		stream.Release()
 		gw.OnConnError(streamConn, base.ErrStream)
 	} else {
 		session := (*Session)(nil)
+		principal := (*rpc.Principal)(nil)
 		config := gw.config
+		event := rpc.SessionEventReconnect
+
+		// authenticate the connection
+		if gw.authenticator != nil {
+			if v, ok := gw.authenticator.Authenticate(credentials); ok {
+				principal = v
+			} else {
+				// tell the client why the connection is closed
+				stream.Release()
+				streamConn.WriteStreamAndRelease(
+					rpc.MakeSystemErrorStream(base.ErrGateWayUnauthorized),
+				)
+				gw.OnConnError(streamConn, base.ErrGateWayUnauthorized)
+				return
+			}
+		}
 
 		// try to find session by session string
+		id, security, isValid := uint64(0), "", false
 		strArray := strings.Split(sessionString, "-")
 		if len(strArray) == 2 && len(strArray[1]) == 32 {
-			if id, err := strconv.ParseUint(strArray[0], 10, 64); err == nil {
-				if s, ok := gw.GetSession(id); ok && s.security == strArray[1] {
+			if v, err := strconv.ParseUint(strArray[0], 10, 64); err == nil {
+				id, security, isValid = v, strArray[1], true
+				if s, ok := gw.GetSession(id); ok &&
+					s.security == security &&
+					s.isPrincipalMatch(principal) {
 					session = s
 				}
 			}
@@ -67,10 +102,21 @@ func InitSession(
 				return
 			}
 
+			attributes, ok := rpc.Map(nil), false
+			if isValid {
+				attributes, ok = gw.loadSession(id, security, principal)
+			}
+
+			if !ok {
+				id, security = gw.CreateSessionID(), base.GetRandString(32)
+			}
+
 			session = &Session{
-				id:           gw.CreateSessionID(),
+				id:           id,
 				gateway:      gw,
-				security:     base.GetRandString(32),
+				security:     security,
+				principal:    principal,
+				attributes:   attributes,
 				conn:         nil,
 				channels:     make([]Channel, config.numOfChannels),
 				activeTimeNS: base.TimeNow().UnixNano(),
@@ -78,22 +124,217 @@ func InitSession(
 				next:         nil,
 			}
 
-			gw.AddSession(session)
+			// another connection might revive the same session at the same
+			// time, then this connection starts a new session
+			for !gw.AddSession(session) {
+				session.id = gw.CreateSessionID()
+				session.security = base.GetRandString(32)
+				session.attributes = nil
+			}
+			event = rpc.SessionEventOpen
+		} else {
+			// the roles of the principal might be changed
+			session.setPrincipal(principal)
 		}
 
 		streamConn.SetReceiver(session)
 
 		stream.SetWritePosToBodyStart()
 		stream.SetKind(rpc.StreamKindConnectResponse)
-		stream.WriteString(fmt.Sprintf("%d-%s", session.id, session.security))
+		stream.WriteString(session.getSessionString())
 		stream.WriteInt64(int64(config.numOfChannels))
 		stream.WriteInt64(int64(config.transLimit))
 		stream.WriteInt64(int64(config.heartbeat / time.Millisecond))
 		stream.WriteInt64(int64(config.heartbeatTimeout / time.Millisecond))
+		compression := negotiateCompression(config.compression, compressions)
+		if compression != "" {
+			// the clients that do not send the compressions never read
+			// the fields below
+			stream.WriteString(compression)
+			stream.WriteInt64(int64(config.compressThreshold))
+		}
 		streamConn.WriteStreamAndRelease(stream)
 
+		// the connect response itself is not compressed
+		if compression != "" {
+			streamConn.SetCompression(
+				compression,
+				config.compressThreshold,
+				config.transLimit,
+			)
+		}
+
 		session.OnConnOpen(streamConn)
+		gw.onSessionEvent(session.id, event)
+	}
+}
+
+func readCredentials(stream *rpc.Stream) (rpc.Map, *base.Error) {
+	// the credentials are optional, and they are nil if the client only sends
+	// the compressions
+	if stream.IsReadFinish() {
+		return nil, nil
+	} else if _, err := stream.ReadNil(); err == nil {
+		return nil, nil
+	}
+
+	return stream.ReadMap()
+}
+
+// readCompressions reads the codecs that the client supports, they are
+// optional
+func readCompressions(stream *rpc.Stream) ([]string, *base.Error) {
+	if stream.IsReadFinish() {
+		return nil, nil
+	}
+
+	return stream.ReadStringArray()
+}
+
+// negotiateCompression returns the compression if the client supports it,
+// otherwise it returns ""
+func negotiateCompression(compression string, compressions []string) string {
+	for _, v := range compressions {
+		if v == compression {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// GetPrincipal ...
+func (p *Session) GetPrincipal() *rpc.Principal {
+	p.Lock()
+	defer p.Unlock()
+	return p.principal
+}
+
+func (p *Session) setPrincipal(principal *rpc.Principal) {
+	p.Lock()
+	defer p.Unlock()
+	p.principal = principal
+}
+
+func getPrincipalName(principal *rpc.Principal) string {
+	if principal == nil {
+		return ""
+	}
+
+	return principal.GetName()
+}
+
+// isPrincipalMatch checks whether the session can be resumed by the
+// connection of principal
+func (p *Session) isPrincipalMatch(principal *rpc.Principal) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.principal == nil || principal == nil {
+		return p.principal == principal
+	}
+
+	return p.principal.GetName() == principal.GetName()
+}
+
+// GetAttribute returns the session attribute by key
+func (p *Session) GetAttribute(key string) (rpc.Any, bool) {
+	p.Lock()
+	defer p.Unlock()
+	ret, ok := p.attributes[key]
+	return ret, ok
+}
+
+// SetAttribute sets the session attribute by key. The attribute is removed if
+// value is nil
+func (p *Session) SetAttribute(key string, value rpc.Any) {
+	p.Lock()
+	if value == nil {
+		delete(p.attributes, key)
+	} else {
+		if p.attributes == nil {
+			p.attributes = rpc.Map{}
+		}
+		p.attributes[key] = value
+	}
+	p.attributeSeq++
+	seq := p.attributeSeq
+	sessionString := p.getSessionString()
+	principalName := getPrincipalName(p.principal)
+	// the store gets a snapshot, so it can keep the map
+	attributes := make(rpc.Map, len(p.attributes))
+	for k, v := range p.attributes {
+		attributes[k] = v
+	}
+	p.Unlock()
+
+	// the store is saved out of the session lock, so the session is not
+	// blocked by the store. The older snapshots are dropped, so the writes
+	// are persisted in order
+	if store := p.gateway.getSessionStore(); store != nil {
+		p.saveLock.Lock()
+		defer p.saveLock.Unlock()
+
+		if seq > p.savedSeq {
+			p.savedSeq = seq
+			store.Save(sessionString, principalName, attributes)
+		}
+	}
+}
+
+func (p *Session) getTopics() []string {
+	p.Lock()
+	defer p.Unlock()
+
+	ret := make([]string, 0, len(p.topics))
+	for topic := range p.topics {
+		ret = append(ret, topic)
+	}
+	return ret
+}
+
+// getSnapshot returns the principal and a copy of the attributes
+func (p *Session) getSnapshot() (*rpc.Principal, rpc.Map) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.attributes == nil {
+		return p.principal, nil
+	}
+
+	attributes := make(rpc.Map, len(p.attributes))
+	for k, v := range p.attributes {
+		attributes[k] = v
 	}
+	return p.principal, attributes
+}
+
+// releaseOutbox drops the messages that the client has not acknowledged
+func (p *Session) releaseOutbox() {
+	p.Lock()
+	defer p.Unlock()
+
+	for i := 0; i < len(p.outbox); i++ {
+		p.outbox[i].Release()
+		p.outbox[i] = nil
+	}
+	p.outbox = nil
+}
+
+// forwardCaller writes the principal and the session of the caller into the
+// request, if the gateway forwards them. It is called with the lock held
+func (p *Session) forwardCaller(stream *rpc.Stream) *base.Error {
+	// the option does not change while the gateway is running
+	if !p.gateway.forwardCaller {
+		return nil
+	}
+
+	endpoint, _ := base.EncryptSessionEndpoint(p.gateway.id, p.id)
+	return rpc.ForwardRequestStream(stream, p.principal, endpoint)
+}
+
+func (p *Session) getSessionString() string {
+	return fmt.Sprintf("%d-%s", p.id, p.security)
 }
 
 // TimeCheck ...
@@ -143,8 +384,38 @@ func (p *Session) OutStream(stream *rpc.Stream) {
 			} else {
 				stream.Release()
 			}
+		case rpc.StreamKindRPCResponseChunk:
+			// record stream, it is replayed if the request is sent again
+			channel := &p.channels[stream.GetCallbackID()%uint64(len(p.channels))]
+			if !channel.OutChunk(
+				stream,
+				p.gateway.config.serverMaxChunkCacheSize,
+			) {
+				stream.Release()
+			} else if p.conn != nil {
+				p.conn.WriteStreamAndRelease(stream.Clone())
+			}
 		case rpc.StreamKindRPCBoardCast:
-			p.conn.WriteStreamAndRelease(stream)
+			// record stream, it is replayed until the client acknowledges it
+			p.outboxSeq++
+			stream.SetCallbackID(p.outboxSeq)
+			if p.conn != nil {
+				p.conn.WriteStreamAndRelease(stream.Clone())
+			}
+			p.outbox = append(p.outbox, stream)
+			// the outbox is full, drop the oldest message
+			if len(p.outbox) > p.gateway.config.serverMaxOutboxSize {
+				p.outbox[0].Release()
+				p.outbox[0] = nil
+				p.outbox = p.outbox[1:]
+			}
+		case rpc.StreamKindTopicPublish:
+			// the messages of the topic are not cached
+			if p.conn != nil {
+				p.conn.WriteStreamAndRelease(stream)
+			} else {
+				stream.Release()
+			}
 		default:
 			stream.Release()
 		}
@@ -156,6 +427,11 @@ func (p *Session) OnConnOpen(streamConn *adapter.StreamConn) {
 	p.Lock()
 	defer p.Unlock()
 	p.conn = streamConn
+
+	// replay the messages that the client has not acknowledged
+	for _, stream := range p.outbox {
+		streamConn.WriteStreamAndRelease(stream.Clone())
+	}
 }
 
 // OnConnReadStream ...
@@ -182,20 +458,93 @@ func (p *Session) OnConnReadStream(
 			if accepted, backStream := channel.In(cbID); accepted {
 				stream.SetGatewayID(p.gateway.id)
 				stream.SetSessionID(p.id)
-				// who receives the stream is responsible for releasing it
-				p.gateway.streamHub.OnReceiveStream(stream)
-			} else if backStream != nil {
-				// do not release the backStream, so we need to clone it
-				streamConn.WriteStreamAndRelease(backStream.Clone())
+				if err := p.forwardCaller(stream); err != nil {
+					// the call is finished with the error
+					errStream := rpc.NewStream()
+					errStream.SetKind(rpc.StreamKindRPCResponseError)
+					errStream.SetCallbackID(cbID)
+					errStream.WriteUint64(uint64(err.GetCode()))
+					errStream.WriteString(err.GetMessage())
+					if channel.Out(errStream) {
+						streamConn.WriteStreamAndRelease(errStream.Clone())
+					} else {
+						errStream.Release()
+					}
+					stream.Release()
+				} else {
+					// who receives the stream is responsible for releasing it
+					p.gateway.streamHub.OnReceiveStream(stream)
+				}
+			} else if chunks, ok := channel.Chunks(cbID); !ok {
+				// the call can not be resumed, because the chunks are lost
+				errStream := rpc.NewStream()
+				errStream.SetKind(rpc.StreamKindRPCResponseError)
+				errStream.SetCallbackID(cbID)
+				errStream.WriteUint64(uint64(base.ErrGateWayChunksDropped.GetCode()))
+				errStream.WriteString(base.ErrGateWayChunksDropped.GetMessage())
+				streamConn.WriteStreamAndRelease(errStream)
 				stream.Release()
 			} else {
-				// ignore the stream
+				// do not release the cached streams, so we need to clone them
+				for _, chunk := range chunks {
+					streamConn.WriteStreamAndRelease(chunk.Clone())
+				}
+				if backStream != nil {
+					streamConn.WriteStreamAndRelease(backStream.Clone())
+				}
 				stream.Release()
 			}
 		} else {
 			p.OnConnError(streamConn, base.ErrStream)
 			stream.Release()
 		}
+	case rpc.StreamKindRPCCancel:
+		if cbID := stream.GetCallbackID(); cbID > 0 && stream.IsReadFinish() {
+			channel := &p.channels[cbID%uint64(len(p.channels))]
+			if channel.IsRunning(cbID) {
+				stream.SetGatewayID(p.gateway.id)
+				stream.SetSessionID(p.id)
+				p.gateway.streamHub.OnReceiveStream(stream)
+			} else {
+				// the call has finished, ignore the stream
+				stream.Release()
+			}
+		} else {
+			p.OnConnError(streamConn, base.ErrStream)
+			stream.Release()
+		}
+	case rpc.StreamKindRPCBoardCastAck:
+		// the client acknowledges all the messages up to the sequence
+		if seq := stream.GetCallbackID(); seq > 0 && stream.IsReadFinish() {
+			pos := 0
+			for pos < len(p.outbox) && p.outbox[pos].GetCallbackID() <= seq {
+				p.outbox[pos].Release()
+				p.outbox[pos] = nil
+				pos++
+			}
+			p.outbox = p.outbox[pos:]
+		} else {
+			p.OnConnError(streamConn, base.ErrStream)
+		}
+		stream.Release()
+	case rpc.StreamKindTopicSubscribe:
+		fallthrough
+	case rpc.StreamKindTopicUnsubscribe:
+		if topic, err := stream.ReadString(); err != nil ||
+			topic == "" ||
+			!stream.IsReadFinish() {
+			p.OnConnError(streamConn, base.ErrStream)
+		} else if stream.GetKind() == rpc.StreamKindTopicSubscribe {
+			if p.topics == nil {
+				p.topics = make(map[string]bool)
+			}
+			p.topics[topic] = true
+			p.gateway.subscribe(topic, p.id)
+		} else {
+			delete(p.topics, topic)
+			p.gateway.unsubscribe(topic, p.id)
+		}
+		stream.Release()
 	default:
 		p.OnConnError(streamConn, base.ErrStream)
 		stream.Release()
@@ -276,9 +625,30 @@ func (p *SessionPool) Add(session *Session) bool {
 
 // TimeCheck ...
 func (p *SessionPool) TimeCheck(nowNS int64) {
+	closedSessions := p.timeCheck(nowNS)
+
+	// the session is closed out of the lock, so the hooks can visit the pool
+	for _, session := range closedSessions {
+		// the hooks get the snapshot of the session
+		p.gateway.onSessionClose(session)
+
+		if store := p.gateway.getSessionStore(); store != nil {
+			store.Delete(session.getSessionString())
+		}
+
+		for _, topic := range session.getTopics() {
+			p.gateway.unsubscribe(topic, session.id)
+		}
+
+		session.releaseOutbox()
+	}
+}
+
+func (p *SessionPool) timeCheck(nowNS int64) []*Session {
 	p.Lock()
 	defer p.Unlock()
 
+	ret := ([]*Session)(nil)
 	node := p.head
 	for node != nil {
 		node.TimeCheck(nowNS)
@@ -286,6 +656,7 @@ func (p *SessionPool) TimeCheck(nowNS int64) {
 		// remove it from the list
 		if node.activeTimeNS == 0 {
 			delete(p.idMap, node.id)
+			ret = append(ret, node)
 
 			if node.prev != nil {
 				node.prev.next = node.next
@@ -304,4 +675,6 @@ func (p *SessionPool) TimeCheck(nowNS int64) {
 
 		node = node.next
 	}
+
+	return ret
 }
@@ -0,0 +1,15 @@
+package gateway
+
+import (
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+// SessionStore keeps the session attributes out of the gateway, so they
+// survive a gateway restart. The session is the session string that the
+// client uses to resume the session, and the principal is the name of the
+// principal that owns the session, or "" if the session is anonymous
+type SessionStore interface {
+	Load(session string) (principal string, attributes rpc.Map, ok bool)
+	Save(session string, principal string, attributes rpc.Map)
+	Delete(session string)
+}
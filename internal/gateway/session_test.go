@@ -6,6 +6,7 @@ import (
 	"github.com/rpccloud/rpc/internal/base"
 	"github.com/rpccloud/rpc/internal/rpc"
 	"net"
+	"sync"
 	"testing"
 	"time"
 )
@@ -23,6 +24,68 @@ func newSession(id uint64, gateway *GateWay) *Session {
 	}
 }
 
+type testSessionStore struct {
+	sessions   map[string]rpc.Map
+	principals map[string]string
+	sync.Mutex
+}
+
+func newTestSessionStore() *testSessionStore {
+	return &testSessionStore{
+		sessions:   map[string]rpc.Map{},
+		principals: map[string]string{},
+	}
+}
+
+func (p *testSessionStore) Load(session string) (string, rpc.Map, bool) {
+	p.Lock()
+	defer p.Unlock()
+	ret, ok := p.sessions[session]
+	return p.principals[session], ret, ok
+}
+
+func (p *testSessionStore) Save(
+	session string,
+	principal string,
+	attributes rpc.Map,
+) {
+	p.Lock()
+	defer p.Unlock()
+	p.sessions[session] = attributes
+	p.principals[session] = principal
+}
+
+func (p *testSessionStore) Delete(session string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.sessions, session)
+	delete(p.principals, session)
+}
+
+type testHookSessionStore struct {
+	*testSessionStore
+	onLoad func(session string)
+	onSave func(session string)
+}
+
+func (p *testHookSessionStore) Load(session string) (string, rpc.Map, bool) {
+	if p.onLoad != nil {
+		p.onLoad(session)
+	}
+	return p.testSessionStore.Load(session)
+}
+
+func (p *testHookSessionStore) Save(
+	session string,
+	principal string,
+	attributes rpc.Map,
+) {
+	if p.onSave != nil {
+		p.onSave(session)
+	}
+	p.testSessionStore.Save(session, principal, attributes)
+}
+
 type testNetConn struct {
 	writeBuffer []byte
 	isRunning   bool
@@ -117,7 +180,10 @@ func checkSessionList(head *Session) bool {
 
 func testTimeCheck(pos int) bool {
 	nowNS := base.TimeNow().UnixNano()
-	v := NewSessionPool(&GateWay{config: GetDefaultConfig()})
+	v := NewSessionPool(&GateWay{
+		config:    GetDefaultConfig(),
+		streamHub: rpc.NewTestStreamHub(),
+	})
 
 	s1 := &Session{id: 1, activeTimeNS: nowNS}
 	s2 := &Session{id: 2, activeTimeNS: nowNS}
@@ -240,6 +306,337 @@ func TestInitSession(t *testing.T) {
 			Equal(nil, base.ErrStream)
 	})
 
+	t.Run("read stream is not finish after credentials", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		netConn := newTestNetConn()
+		streamHub := rpc.NewTestStreamHub()
+		gw := NewGateWay(132, GetDefaultConfig(), streamHub)
+
+		streamConn := adapter.NewStreamConn(
+			false,
+			adapter.NewServerSyncConn(netConn, 1200, 1200),
+			gw,
+		)
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteString("")
+		stream.Write(rpc.Map{"token": "abc"})
+		stream.WriteBool(false)
+		stream.BuildStreamCheck()
+		streamConn.OnReadBytes(stream.GetBuffer())
+		assert(netConn.isRunning).IsFalse()
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("read compressions error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		netConn := newTestNetConn()
+		streamHub := rpc.NewTestStreamHub()
+		gw := NewGateWay(132, GetDefaultConfig(), streamHub)
+
+		streamConn := adapter.NewStreamConn(
+			false,
+			adapter.NewServerSyncConn(netConn, 1200, 1200),
+			gw,
+		)
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteString("")
+		stream.WriteNil()
+		stream.Write(rpc.Array{true})
+		stream.BuildStreamCheck()
+		streamConn.OnReadBytes(stream.GetBuffer())
+		assert(netConn.isRunning).IsFalse()
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("negotiate compression", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(compression string, compressions rpc.Any) *rpc.Stream {
+			gw := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+			gw.SetCompression(compression, 64)
+
+			netConn := newTestNetConn()
+			syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+			streamConn := adapter.NewStreamConn(false, syncConn, gw)
+			syncConn.SetNext(streamConn)
+
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindConnectRequest)
+			stream.WriteString("")
+			stream.WriteNil()
+			if compressions != nil {
+				stream.Write(compressions)
+			}
+			stream.BuildStreamCheck()
+			streamConn.OnReadBytes(stream.GetBuffer())
+			assert(netConn.isRunning).IsTrue()
+
+			rs := rpc.NewStream()
+			rs.PutBytesTo(netConn.writeBuffer, 0)
+			assert(rs.GetKind()).Equal(uint8(rpc.StreamKindConnectResponse))
+			_, _ = rs.ReadString()
+			for i := 0; i < 4; i++ {
+				_, _ = rs.ReadInt64()
+			}
+			return rs
+		}
+
+		// the client does not send the compressions
+		rs := fnTest(rpc.CompressionGzip, nil)
+		assert(rs.IsReadFinish()).IsTrue()
+
+		// the compression is not enabled
+		rs = fnTest("", rpc.GetCompressions())
+		assert(rs.IsReadFinish()).IsTrue()
+
+		// the client does not support the compression
+		rs = fnTest(rpc.CompressionGzip, []string{rpc.CompressionFlate})
+		assert(rs.IsReadFinish()).IsTrue()
+
+		rs = fnTest(rpc.CompressionGzip, rpc.GetCompressions())
+		assert(rs.ReadString()).Equal(rpc.CompressionGzip, nil)
+		assert(rs.ReadInt64()).Equal(int64(64), nil)
+		assert(rs.IsReadFinish()).IsTrue()
+	})
+
+	t.Run("authenticate failed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		netConn := newTestNetConn()
+		streamHub := rpc.NewTestStreamHub()
+		gw := NewGateWay(132, GetDefaultConfig(), streamHub)
+		credentialsCH := make(chan rpc.Map, 1)
+		gw.SetAuthenticator(AuthenticatorFunc(
+			func(credentials rpc.Map) (*rpc.Principal, bool) {
+				credentialsCH <- credentials
+				return nil, false
+			},
+		))
+
+		syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, gw)
+		syncConn.SetNext(streamConn)
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteString("")
+		stream.Write(rpc.Map{"token": "abc"})
+		stream.BuildStreamCheck()
+		streamConn.OnReadBytes(stream.GetBuffer())
+
+		assert(<-credentialsCH).Equal(rpc.Map{"token": "abc"})
+		assert(netConn.isRunning).IsFalse()
+		assert(gw.totalSessions).Equal(int64(0))
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrGateWayUnauthorized)
+
+		rs := rpc.NewStream()
+		rs.PutBytesTo(netConn.writeBuffer, 0)
+		assert(rs.GetKind()).Equal(uint8(rpc.StreamKindSystemErrorReport))
+		assert(rpc.ParseResponseStream(rs)).
+			Equal(nil, base.ErrGateWayUnauthorized)
+	})
+
+	t.Run("authenticate ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		principal := rpc.NewPrincipal("kitty", "admin")
+		gw := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		gw.SetAuthenticator(AuthenticatorFunc(
+			func(credentials rpc.Map) (*rpc.Principal, bool) {
+				return principal, credentials == nil
+			},
+		))
+
+		netConn := newTestNetConn()
+		syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, gw)
+		syncConn.SetNext(streamConn)
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteString("")
+		stream.BuildStreamCheck()
+		streamConn.OnReadBytes(stream.GetBuffer())
+
+		assert(netConn.isRunning).IsTrue()
+		assert(gw.totalSessions).Equal(int64(1))
+		assert(gw.GetPrincipal(1)).Equal(principal)
+	})
+
+	t.Run("resume session with principal", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		security := "12345678123456781234567812345678"
+		fnTest := func(
+			sessionPrincipal *rpc.Principal,
+			connPrincipal *rpc.Principal,
+		) *GateWay {
+			gw := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+			gw.SetAuthenticator(AuthenticatorFunc(
+				func(credentials rpc.Map) (*rpc.Principal, bool) {
+					return connPrincipal, true
+				},
+			))
+			gw.AddSession(&Session{
+				id:        234,
+				security:  security,
+				principal: sessionPrincipal,
+				gateway:   gw,
+			})
+
+			netConn := newTestNetConn()
+			syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+			streamConn := adapter.NewStreamConn(false, syncConn, gw)
+			syncConn.SetNext(streamConn)
+
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindConnectRequest)
+			stream.WriteString("234-" + security)
+			stream.BuildStreamCheck()
+			streamConn.OnReadBytes(stream.GetBuffer())
+			return gw
+		}
+
+		p1 := rpc.NewPrincipal("kitty")
+		p2 := rpc.NewPrincipal("kitty", "admin")
+		p3 := rpc.NewPrincipal("doggy")
+
+		gw := fnTest(p1, p2)
+		assert(gw.totalSessions).Equal(int64(1))
+		assert(gw.GetPrincipal(234)).Equal(p2)
+
+		gw = fnTest(p1, p3)
+		assert(gw.totalSessions).Equal(int64(2))
+		assert(gw.GetPrincipal(234)).Equal(p1)
+		assert(gw.GetPrincipal(1)).Equal(p3)
+
+		gw = fnTest(nil, p3)
+		assert(gw.totalSessions).Equal(int64(2))
+
+		gw = fnTest(p1, nil)
+		assert(gw.totalSessions).Equal(int64(2))
+
+		gw = fnTest(nil, nil)
+		assert(gw.totalSessions).Equal(int64(1))
+	})
+
+	t.Run("report session events", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		security := "12345678123456781234567812345678"
+		streamHub := rpc.NewTestStreamHub()
+		gw := NewGateWay(132, GetDefaultConfig(), streamHub)
+		gw.AddSession(&Session{
+			id:       234,
+			security: security,
+			gateway:  gw,
+			channels: make([]Channel, gw.config.numOfChannels),
+		})
+
+		fnConnect := func(sessionString string) *rpc.Stream {
+			netConn := newTestNetConn()
+			syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+			streamConn := adapter.NewStreamConn(false, syncConn, gw)
+			syncConn.SetNext(streamConn)
+
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindConnectRequest)
+			stream.WriteString(sessionString)
+			stream.BuildStreamCheck()
+			streamConn.OnReadBytes(stream.GetBuffer())
+			return streamHub.GetStream()
+		}
+
+		for _, it := range []struct {
+			sessionString string
+			sessionID     uint64
+			event         string
+		}{
+			{"", 1, rpc.SessionEventOpen},
+			{"234-" + security, 234, rpc.SessionEventReconnect},
+		} {
+			stream := fnConnect(it.sessionString)
+			assert(stream.GetKind()).Equal(uint8(rpc.StreamKindSessionEvent))
+			assert(stream.GetGatewayID()).Equal(uint64(132))
+			assert(stream.GetSessionID()).Equal(it.sessionID)
+			assert(stream.ReadString()).Equal(it.event, nil)
+			assert(stream.IsReadFinish()).IsTrue()
+		}
+	})
+
+	t.Run("restore session from store", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		security := "12345678123456781234567812345678"
+		fnTest := func(sessionString string) *GateWay {
+			store := newTestSessionStore()
+			store.Save("234-"+security, "", rpc.Map{"name": "kitty"})
+			gw := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+			gw.SetSessionStore(store)
+
+			netConn := newTestNetConn()
+			syncConn := adapter.NewServerSyncConn(netConn, 1200, 1200)
+			streamConn := adapter.NewStreamConn(false, syncConn, gw)
+			syncConn.SetNext(streamConn)
+
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindConnectRequest)
+			stream.WriteString(sessionString)
+			stream.BuildStreamCheck()
+			streamConn.OnReadBytes(stream.GetBuffer())
+			return gw
+		}
+
+		gw := fnTest("234-" + security)
+		assert(gw.totalSessions).Equal(int64(1))
+		session, ok := gw.GetSession(234)
+		assert(ok).IsTrue()
+		assert(session.security).Equal(security)
+		assert(session.GetAttribute("name")).Equal("kitty", true)
+		assert(gw.CreateSessionID()).Equal(uint64(235))
+
+		gw = fnTest("234-87654321876543218765432187654321")
+		assert(gw.totalSessions).Equal(int64(1))
+		_, ok = gw.GetSession(234)
+		assert(ok).IsFalse()
+		session, ok = gw.GetSession(1)
+		assert(ok).IsTrue()
+		assert(session.attributes).IsNil()
+	})
+
+	t.Run("stored session is taken by another connection", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		security := "12345678123456781234567812345678"
+		other := &Session{id: 234, security: security}
+		store := &testHookSessionStore{testSessionStore: newTestSessionStore()}
+		store.Save("234-"+security, "", rpc.Map{"name": "kitty"})
+		gw := NewGateWay(132, GetDefaultConfig(), rpc.NewTestStreamHub())
+		gw.SetSessionStore(store)
+		// the other connection revives the session after the check
+		store.onLoad = func(_ string) {
+			gw.AddSession(other)
+		}
+
+		syncConn := adapter.NewServerSyncConn(newTestNetConn(), 1200, 1200)
+		streamConn := adapter.NewStreamConn(false, syncConn, gw)
+		syncConn.SetNext(streamConn)
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteString("234-" + security)
+		stream.BuildStreamCheck()
+		streamConn.OnReadBytes(stream.GetBuffer())
+
+		session, ok := gw.GetSession(234)
+		assert(ok).IsTrue()
+		assert(session == other).IsTrue()
+		session, ok = gw.GetSession(235)
+		assert(ok).IsTrue()
+		assert(session.security == security).IsFalse()
+		assert(session.attributes).IsNil()
+	})
+
 	t.Run("max sessions limit", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		streamHub := rpc.NewTestStreamHub()
@@ -373,69 +770,397 @@ func TestNewSession(t *testing.T) {
 	})
 }
 
-func TestSession_TimeCheck(t *testing.T) {
-	t.Run("p.conn is active", func(t *testing.T) {
+func TestReadCredentials(t *testing.T) {
+	t.Run("credentials is not set", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		session, syncConn, netConn := prepareTestSession()
-		session.gateway.config.heartbeatTimeout = 100 * time.Millisecond
-		syncConn.OnOpen()
-		session.TimeCheck(base.TimeNow().UnixNano())
-		assert(netConn.isRunning).IsTrue()
-		assert(session.conn).IsNotNil()
+		stream := rpc.NewStream()
+		assert(readCredentials(stream)).Equal(nil, nil)
 	})
 
-	t.Run("p.conn is not active", func(t *testing.T) {
+	t.Run("credentials is nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		session, syncConn, netConn := prepareTestSession()
-		session.gateway.config.heartbeatTimeout = 1 * time.Millisecond
-		syncConn.OnOpen()
-		time.Sleep(30 * time.Millisecond)
-		session.TimeCheck(base.TimeNow().UnixNano())
-		assert(netConn.isRunning).IsFalse()
+		stream := rpc.NewStream()
+		stream.WriteNil()
+		assert(readCredentials(stream)).Equal(nil, nil)
+		assert(stream.IsReadFinish()).IsTrue()
 	})
 
-	t.Run("p.conn is nil, session is not timeout", func(t *testing.T) {
+	t.Run("credentials format error", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		session, _, _ := prepareTestSession()
-		session.gateway.config.serverSessionTimeout = time.Second
-		session.gateway.TimeCheck(base.TimeNow().UnixNano())
-		assert(session.gateway.TotalSessions()).Equal(int64(1))
+		stream := rpc.NewStream()
+		stream.WriteBool(true)
+		_, err := readCredentials(stream)
+		assert(err).Equal(base.ErrStream)
 	})
 
-	t.Run("p.conn is nil, session is timeout", func(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		session, _, _ := prepareTestSession()
-		gw := session.gateway
-		gw.config.serverSessionTimeout = 1 * time.Millisecond
-		time.Sleep(30 * time.Millisecond)
-		gw.TimeCheck(base.TimeNow().UnixNano())
-		assert(gw.TotalSessions()).Equal(int64(0))
+		stream := rpc.NewStream()
+		stream.Write(rpc.Map{"token": "abc"})
+		assert(readCredentials(stream)).Equal(rpc.Map{"token": "abc"}, nil)
 	})
+}
 
-	t.Run("p.channels is not timeout", func(t *testing.T) {
+func TestReadCompressions(t *testing.T) {
+	t.Run("compressions is not set", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		session, _, _ := prepareTestSession()
+		stream := rpc.NewStream()
+		assert(readCompressions(stream)).Equal([]string(nil), nil)
+	})
 
-		// fill the channels
-		for i := 0; i < session.gateway.config.numOfChannels; i++ {
-			stream := rpc.NewStream()
-			stream.SetCallbackID(uint64(i) + 1)
-			session.channels[i].In(stream.GetCallbackID())
-			session.channels[i].Out(stream)
-			assert(session.channels[i].backTimeNS > 0).IsTrue()
-			assert(session.channels[i].backStream).IsNotNil()
-		}
+	t.Run("compressions format error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.WriteBool(true)
+		assert(readCompressions(stream)).Equal([]string(nil), base.ErrStream)
+	})
 
-		session.gateway.config.serverCacheTimeout = 10 * time.Millisecond
-		session.TimeCheck(base.TimeNow().UnixNano())
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.Write(rpc.GetCompressions())
+		assert(readCompressions(stream)).Equal(rpc.GetCompressions(), nil)
+	})
+}
 
-		for i := 0; i < session.gateway.config.numOfChannels; i++ {
-			assert(session.channels[i].backTimeNS > 0).IsTrue()
-			assert(session.channels[i].backStream).IsNotNil()
-		}
+func TestNegotiateCompression(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(negotiateCompression("", nil)).Equal("")
+		assert(negotiateCompression("", []string{"", "gzip"})).Equal("")
+		assert(negotiateCompression("gzip", nil)).Equal("")
+		assert(negotiateCompression("gzip", []string{"flate"})).Equal("")
+		assert(negotiateCompression("gzip", []string{"flate", "gzip"})).
+			Equal("gzip")
 	})
+}
 
-	t.Run("p.channels is timeout", func(t *testing.T) {
+func TestSession_GetPrincipal(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		principal := rpc.NewPrincipal("kitty")
+		session, _, _ := prepareTestSession()
+		assert(session.GetPrincipal()).IsNil()
+		session.principal = principal
+		assert(session.GetPrincipal()).Equal(principal)
+	})
+}
+
+func TestSession_setPrincipal(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		principal := rpc.NewPrincipal("kitty")
+		session, _, _ := prepareTestSession()
+		session.setPrincipal(principal)
+		assert(session.principal).Equal(principal)
+	})
+}
+
+func TestSession_isPrincipalMatch(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		p1 := rpc.NewPrincipal("kitty")
+		p2 := rpc.NewPrincipal("kitty", "admin")
+		p3 := rpc.NewPrincipal("doggy")
+		assert((&Session{principal: nil}).isPrincipalMatch(nil)).IsTrue()
+		assert((&Session{principal: nil}).isPrincipalMatch(p1)).IsFalse()
+		assert((&Session{principal: p1}).isPrincipalMatch(nil)).IsFalse()
+		assert((&Session{principal: p1}).isPrincipalMatch(p2)).IsTrue()
+		assert((&Session{principal: p1}).isPrincipalMatch(p3)).IsFalse()
+	})
+}
+
+func TestSession_GetAttribute(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		assert(session.GetAttribute("name")).Equal(nil, false)
+		session.attributes = rpc.Map{"name": "kitty"}
+		assert(session.GetAttribute("name")).Equal("kitty", true)
+	})
+}
+
+func TestSession_SetAttribute(t *testing.T) {
+	t.Run("without store", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		session.SetAttribute("name", "kitty")
+		session.SetAttribute("age", int64(18))
+		assert(session.attributes).Equal(rpc.Map{"name": "kitty", "age": int64(18)})
+		session.SetAttribute("age", nil)
+		assert(session.attributes).Equal(rpc.Map{"name": "kitty"})
+	})
+
+	t.Run("with store", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		session, _, _ := prepareTestSession()
+		session.gateway.SetSessionStore(store)
+		session.SetAttribute("name", "kitty")
+		assert(store.Load(session.getSessionString())).
+			Equal("", rpc.Map{"name": "kitty"}, true)
+		session.SetAttribute("name", nil)
+		assert(store.Load(session.getSessionString())).
+			Equal("", rpc.Map{}, true)
+	})
+
+	t.Run("with store and principal", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		session, _, _ := prepareTestSession()
+		session.gateway.SetSessionStore(store)
+		session.principal = rpc.NewPrincipal("kitty")
+		session.SetAttribute("name", "doggy")
+		assert(store.Load(session.getSessionString())).
+			Equal("kitty", rpc.Map{"name": "doggy"}, true)
+	})
+
+	t.Run("store is saved out of the session lock", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		store := &testHookSessionStore{testSessionStore: newTestSessionStore()}
+		// the session lock is held by the caller, so this would dead lock
+		store.onSave = func(_ string) {
+			session.GetAttribute("name")
+		}
+		session.gateway.SetSessionStore(store)
+		session.SetAttribute("name", "kitty")
+		assert(store.Load(session.getSessionString())).
+			Equal("", rpc.Map{"name": "kitty"}, true)
+	})
+
+	t.Run("older snapshot is not saved", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		session, _, _ := prepareTestSession()
+		session.gateway.SetSessionStore(store)
+		session.SetAttribute("name", "kitty")
+		// a newer snapshot is saved by another goroutine
+		session.savedSeq = 100
+		session.SetAttribute("name", "doggy")
+		assert(store.Load(session.getSessionString())).
+			Equal("", rpc.Map{"name": "kitty"}, true)
+	})
+}
+
+func TestSession_getTopics(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		assert(session.getTopics()).Equal([]string{})
+		session.topics = map[string]bool{"news": true}
+		assert(session.getTopics()).Equal([]string{"news"})
+	})
+}
+
+func TestGetPrincipalName(t *testing.T) {
+	t.Run("principal is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getPrincipalName(nil)).Equal("")
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getPrincipalName(rpc.NewPrincipal("kitty"))).Equal("kitty")
+	})
+}
+
+func TestSession_getSnapshot(t *testing.T) {
+	t.Run("attributes is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		assert(session.getSnapshot()).Equal(nil, nil)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		principal := rpc.NewPrincipal("kitty")
+		session.principal = principal
+		session.attributes = rpc.Map{"name": "doggy"}
+		p, attributes := session.getSnapshot()
+		assert(p).Equal(principal)
+		assert(attributes).Equal(rpc.Map{"name": "doggy"})
+		attributes["age"] = int64(3)
+		assert(session.attributes).Equal(rpc.Map{"name": "doggy"})
+	})
+}
+
+func TestSession_releaseOutbox(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		session.outbox = []*rpc.Stream{rpc.NewStream(), rpc.NewStream()}
+		session.releaseOutbox()
+		assert(session.outbox).IsNil()
+	})
+}
+
+func TestSession_getSessionString(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		assert(session.getSessionString()).
+			Equal(fmt.Sprintf("11-%s", session.security))
+	})
+}
+
+func TestSession_forwardCaller(t *testing.T) {
+	t.Run("caller is not forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		stream := rpc.NewStream()
+		defer stream.Release()
+		assert(session.forwardCaller(stream)).IsNil()
+		assert(stream.GetWritePos()).Equal(rpc.StreamHeadSize)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		session.gateway.forwardCaller = true
+		stream := rpc.NewStream()
+		defer stream.Release()
+		assert(session.forwardCaller(stream)).Equal(base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		session.gateway.forwardCaller = true
+		session.principal = rpc.NewPrincipal("kitty", "admin")
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.user:Get", "")
+		defer stream.Release()
+		assert(session.forwardCaller(stream)).IsNil()
+		assert(stream.ReadString()).Equal("#.user:Get", nil)
+		assert(stream.ReadString()).Equal("", nil)
+		metadata, err := stream.ReadMetadata()
+		assert(err).IsNil()
+		endpoint, _ := base.EncryptSessionEndpoint(3, 11)
+		assert(metadata[rpc.MetadataKeySession]).Equal(endpoint)
+	})
+}
+
+func TestSession_TimeCheck(t *testing.T) {
+	t.Run("p.conn is active", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		session.gateway.config.heartbeatTimeout = 100 * time.Millisecond
+		syncConn.OnOpen()
+		session.TimeCheck(base.TimeNow().UnixNano())
+		assert(netConn.isRunning).IsTrue()
+		assert(session.conn).IsNotNil()
+	})
+
+	t.Run("p.conn is not active", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		session.gateway.config.heartbeatTimeout = 1 * time.Millisecond
+		syncConn.OnOpen()
+		time.Sleep(30 * time.Millisecond)
+		session.TimeCheck(base.TimeNow().UnixNano())
+		assert(netConn.isRunning).IsFalse()
+	})
+
+	t.Run("p.conn is nil, session is not timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		session.gateway.config.serverSessionTimeout = time.Second
+		session.gateway.TimeCheck(base.TimeNow().UnixNano())
+		assert(session.gateway.TotalSessions()).Equal(int64(1))
+	})
+
+	t.Run("p.conn is nil, session is timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		gw := session.gateway
+		gw.config.serverSessionTimeout = 1 * time.Millisecond
+		time.Sleep(30 * time.Millisecond)
+		gw.TimeCheck(base.TimeNow().UnixNano())
+		assert(gw.TotalSessions()).Equal(int64(0))
+	})
+
+	t.Run("session is timeout, release the outbox", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		gw := session.gateway
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCBoardCast)
+		session.OutStream(stream)
+		assert(len(session.outbox)).Equal(1)
+		gw.config.serverSessionTimeout = 1 * time.Millisecond
+		time.Sleep(30 * time.Millisecond)
+		gw.TimeCheck(base.TimeNow().UnixNano())
+		assert(gw.TotalSessions()).Equal(int64(0))
+		assert(session.outbox).IsNil()
+	})
+
+	t.Run("session is timeout, report the close event", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		gw := session.gateway
+		gw.config.serverSessionTimeout = 1 * time.Millisecond
+		time.Sleep(30 * time.Millisecond)
+		gw.TimeCheck(base.TimeNow().UnixNano())
+		stream := gw.streamHub.(*rpc.TestStreamHub).GetStream()
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindSessionEvent))
+		assert(stream.GetSessionID()).Equal(uint64(11))
+		assert(stream.ReadString()).Equal(rpc.SessionEventClose, nil)
+		assert(stream.ReadNil()).Equal(nil, nil)
+		assert(stream.ReadNil()).Equal(nil, nil)
+	})
+
+	t.Run("session is timeout, remove it from store", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		store := newTestSessionStore()
+		session, _, _ := prepareTestSession()
+		gw := session.gateway
+		gw.SetSessionStore(store)
+		session.SetAttribute("name", "kitty")
+		gw.config.serverSessionTimeout = 1 * time.Millisecond
+		time.Sleep(30 * time.Millisecond)
+		gw.TimeCheck(base.TimeNow().UnixNano())
+		assert(gw.TotalSessions()).Equal(int64(0))
+		assert(len(store.sessions)).Equal(0)
+	})
+
+	t.Run("session is timeout, unsubscribe the topics", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		gw := session.gateway
+		session.topics = map[string]bool{"news": true}
+		gw.subscribe("news", session.id)
+		gw.config.serverSessionTimeout = 1 * time.Millisecond
+		time.Sleep(30 * time.Millisecond)
+		gw.TimeCheck(base.TimeNow().UnixNano())
+		assert(gw.TotalSessions()).Equal(int64(0))
+		assert(len(gw.topicMap)).Equal(0)
+	})
+
+	t.Run("p.channels is not timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+
+		// fill the channels
+		for i := 0; i < session.gateway.config.numOfChannels; i++ {
+			stream := rpc.NewStream()
+			stream.SetCallbackID(uint64(i) + 1)
+			session.channels[i].In(stream.GetCallbackID())
+			session.channels[i].Out(stream)
+			assert(session.channels[i].backTimeNS > 0).IsTrue()
+			assert(session.channels[i].backStream).IsNotNil()
+		}
+
+		session.gateway.config.serverCacheTimeout = 10 * time.Millisecond
+		session.TimeCheck(base.TimeNow().UnixNano())
+
+		for i := 0; i < session.gateway.config.numOfChannels; i++ {
+			assert(session.channels[i].backTimeNS > 0).IsTrue()
+			assert(session.channels[i].backStream).IsNotNil()
+		}
+	})
+
+	t.Run("p.channels is timeout", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		session, _, _ := prepareTestSession()
 
@@ -542,6 +1267,48 @@ func TestSession_OutStream(t *testing.T) {
 		assert(netConn.writeBuffer).Equal(exceptBuffer)
 	})
 
+	t.Run("stream is StreamKindRPCResponseChunk", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		syncConn.OnOpen()
+		// ignore the init stream
+		netConn.writeBuffer = make([]byte, 0)
+
+		exceptBuffer := make([]byte, 0)
+		(&session.channels[1]).In(1)
+
+		for i := 0; i < 3; i++ {
+			stream := rpc.NewStream()
+			stream.SetCallbackID(1)
+			stream.SetKind(rpc.StreamKindRPCResponseChunk)
+			stream.WriteUint64(uint64(i))
+			stream.BuildStreamCheck()
+			exceptBuffer = append(exceptBuffer, stream.GetBuffer()...)
+			session.OutStream(stream)
+		}
+
+		// the chunk does not belong to the current call
+		stream := rpc.NewStream()
+		stream.SetCallbackID(2)
+		stream.SetKind(rpc.StreamKindRPCResponseChunk)
+		session.OutStream(stream)
+
+		assert(netConn.writeBuffer).Equal(exceptBuffer)
+		assert(len(session.channels[1].chunks)).Equal(3)
+	})
+
+	t.Run("stream is StreamKindRPCResponseChunk, p.conn is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, netConn := prepareTestSession()
+		(&session.channels[1]).In(1)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(1)
+		stream.SetKind(rpc.StreamKindRPCResponseChunk)
+		session.OutStream(stream)
+		assert(len(netConn.writeBuffer)).Equal(0)
+		assert(session.channels[1].chunks).Equal([]*rpc.Stream{stream})
+	})
+
 	t.Run("stream is StreamKindRPCBoardCast", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		session, syncConn, netConn := prepareTestSession()
@@ -561,12 +1328,72 @@ func TestSession_OutStream(t *testing.T) {
 			stream.SetKind(rpc.StreamKindRPCBoardCast)
 			stream.WriteString("#.test%Msg")
 			stream.WriteString("HI")
-			stream.BuildStreamCheck()
-			exceptBuffer = append(exceptBuffer, stream.GetBuffer()...)
 			session.OutStream(stream)
+
+			// the sequence of the message is the callbackID
+			exceptStream := stream.Clone()
+			exceptStream.SetCallbackID(uint64(i))
+			exceptStream.BuildStreamCheck()
+			exceptBuffer = append(exceptBuffer, exceptStream.GetBuffer()...)
 		}
 
 		assert(netConn.writeBuffer).Equal(exceptBuffer)
+		assert(len(session.outbox)).Equal(len(session.channels))
+		assert(session.outboxSeq).Equal(uint64(len(session.channels)))
+	})
+
+	t.Run("stream is StreamKindRPCBoardCast, p.conn is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, netConn := prepareTestSession()
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCBoardCast)
+		stream.WriteString("#.test%Msg")
+		stream.WriteString("HI")
+		session.OutStream(stream)
+		assert(len(netConn.writeBuffer)).Equal(0)
+		assert(session.outbox).Equal([]*rpc.Stream{stream})
+		assert(stream.GetCallbackID()).Equal(uint64(1))
+	})
+
+	t.Run("stream is StreamKindRPCBoardCast, outbox is full", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, _ := prepareTestSession()
+		session.gateway.config.serverMaxOutboxSize = 2
+		for i := 0; i < 3; i++ {
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindRPCBoardCast)
+			session.OutStream(stream)
+		}
+		assert(len(session.outbox)).Equal(2)
+		assert(session.outbox[0].GetCallbackID()).Equal(uint64(2))
+		assert(session.outbox[1].GetCallbackID()).Equal(uint64(3))
+	})
+
+	t.Run("stream is StreamKindTopicPublish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		syncConn.OnOpen()
+		// ignore the init stream
+		netConn.writeBuffer = make([]byte, 0)
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		stream.WriteString("HI")
+		stream.BuildStreamCheck()
+		exceptBuffer := append([]byte(nil), stream.GetBuffer()...)
+		session.OutStream(stream)
+		assert(netConn.writeBuffer).Equal(exceptBuffer)
+	})
+
+	t.Run("stream is StreamKindTopicPublish, p.conn is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _, netConn := prepareTestSession()
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		session.OutStream(stream)
+		assert(len(netConn.writeBuffer)).Equal(0)
 	})
 }
 
@@ -579,6 +1406,32 @@ func TestSession_OnConnOpen(t *testing.T) {
 		session.OnConnOpen(streamConn)
 		assert(session.conn).Equal(streamConn)
 	})
+
+	t.Run("replay the outbox", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		syncConn.SetNext(streamConn)
+
+		exceptBuffer := make([]byte, 0)
+		for i := 0; i < 3; i++ {
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindRPCBoardCast)
+			stream.WriteString("#.test%Msg")
+			stream.WriteInt64(int64(i))
+			session.OutStream(stream)
+			exceptStream := stream.Clone()
+			exceptStream.BuildStreamCheck()
+			exceptBuffer = append(exceptBuffer, exceptStream.GetBuffer()...)
+		}
+
+		syncConn.OnOpen()
+		// ignore the init stream
+		netConn.writeBuffer = make([]byte, 0)
+		session.OnConnOpen(streamConn)
+		assert(netConn.writeBuffer).Equal(exceptBuffer)
+		assert(len(session.outbox)).Equal(3)
+	})
 }
 
 func TestSession_OnConnReadStream(t *testing.T) {
@@ -634,6 +1487,27 @@ func TestSession_OnConnReadStream(t *testing.T) {
 		assert(backStream.GetSessionID()).Equal(uint64(11))
 	})
 
+	t.Run("cbID > 0, accept = true, forward caller error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		session.gateway.forwardCaller = true
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		syncConn.SetNext(streamConn)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(10)
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		session.OnConnOpen(streamConn)
+		netConn.writeBuffer = make([]byte, 0)
+		session.OnConnReadStream(streamConn, stream)
+
+		backStream := rpc.NewStream()
+		backStream.PutBytesTo(netConn.writeBuffer, 0)
+		assert(backStream.GetCallbackID()).Equal(uint64(10))
+		assert(rpc.ParseResponseStream(backStream)).Equal(nil, base.ErrStream)
+		assert((&session.channels[10%len(session.channels)]).IsRunning(10)).
+			IsFalse()
+	})
+
 	t.Run("cbID > 0, accept = false, backStream != nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		session, syncConn, netConn := prepareTestSession()
@@ -657,6 +1531,83 @@ func TestSession_OnConnReadStream(t *testing.T) {
 		assert(netConn.writeBuffer).Equal(cacheStream.GetBuffer())
 	})
 
+	t.Run("cbID > 0, accept = false, chunks are cached", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		syncConn.SetNext(streamConn)
+
+		channel := &session.channels[10%len(session.channels)]
+		channel.In(10)
+		exceptBuffer := make([]byte, 0)
+		for i := 0; i < 2; i++ {
+			chunkStream := rpc.NewStream()
+			chunkStream.SetCallbackID(10)
+			chunkStream.SetKind(rpc.StreamKindRPCResponseChunk)
+			chunkStream.WriteUint64(uint64(i))
+			chunkStream.BuildStreamCheck()
+			exceptBuffer = append(exceptBuffer, chunkStream.GetBuffer()...)
+			channel.OutChunk(chunkStream, 8)
+		}
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetCallbackID(10)
+		session.OnConnOpen(streamConn)
+		netConn.writeBuffer = make([]byte, 0)
+		session.OnConnReadStream(streamConn, stream)
+		assert(netConn.writeBuffer).Equal(exceptBuffer)
+
+		// the final reply is replayed after the chunks
+		cacheStream := rpc.NewStream()
+		cacheStream.SetCallbackID(10)
+		cacheStream.BuildStreamCheck()
+		exceptBuffer = append(exceptBuffer, cacheStream.GetBuffer()...)
+		channel.Out(cacheStream)
+
+		stream = rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetCallbackID(10)
+		netConn.writeBuffer = make([]byte, 0)
+		session.OnConnReadStream(streamConn, stream)
+		assert(netConn.writeBuffer).Equal(exceptBuffer)
+	})
+
+	t.Run("cbID > 0, accept = false, chunks are dropped", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, netConn := prepareTestSession()
+		session.gateway.config.serverMaxChunkCacheSize = 1
+
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		syncConn.SetNext(streamConn)
+
+		channel := &session.channels[10%len(session.channels)]
+		channel.In(10)
+		for i := 0; i < 2; i++ {
+			chunkStream := rpc.NewStream()
+			chunkStream.SetCallbackID(10)
+			chunkStream.SetKind(rpc.StreamKindRPCResponseChunk)
+			chunkStream.WriteUint64(uint64(i))
+			session.OutStream(chunkStream)
+		}
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetCallbackID(10)
+		session.OnConnOpen(streamConn)
+		netConn.writeBuffer = make([]byte, 0)
+		session.OnConnReadStream(streamConn, stream)
+
+		errStream := rpc.NewStream()
+		errStream.SetKind(rpc.StreamKindRPCResponseError)
+		errStream.SetCallbackID(10)
+		errStream.WriteUint64(uint64(base.ErrGateWayChunksDropped.GetCode()))
+		errStream.WriteString(base.ErrGateWayChunksDropped.GetMessage())
+		errStream.BuildStreamCheck()
+		assert(netConn.writeBuffer).Equal(errStream.GetBuffer())
+	})
+
 	t.Run("cbID > 0, accept = false, backStream == nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		session, syncConn, netConn := prepareTestSession()
@@ -674,6 +1625,56 @@ func TestSession_OnConnReadStream(t *testing.T) {
 		assert(len(netConn.writeBuffer)).Equal(0)
 	})
 
+	t.Run("StreamKindRPCCancel, call is running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		session, syncConn, _ := prepareTestSession()
+		session.gateway.streamHub = streamHub
+		(&session.channels[10%len(session.channels)]).In(10)
+
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(10)
+		stream.SetKind(rpc.StreamKindRPCCancel)
+		session.OnConnReadStream(streamConn, stream)
+
+		backStream := streamHub.GetStream()
+		assert(backStream.GetKind()).Equal(uint8(rpc.StreamKindRPCCancel))
+		assert(backStream.GetGatewayID()).Equal(uint64(3))
+		assert(backStream.GetSessionID()).Equal(uint64(11))
+		assert(backStream.GetCallbackID()).Equal(uint64(10))
+	})
+
+	t.Run("StreamKindRPCCancel, call is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		session, syncConn, _ := prepareTestSession()
+		session.gateway.streamHub = streamHub
+		(&session.channels[10%len(session.channels)]).In(10)
+
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(9)
+		stream.SetKind(rpc.StreamKindRPCCancel)
+		session.OnConnReadStream(streamConn, stream)
+		assert(streamHub.GetStream()).IsNil()
+	})
+
+	t.Run("StreamKindRPCCancel, stream error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := rpc.NewTestStreamHub()
+		session, syncConn, _ := prepareTestSession()
+		session.gateway.streamHub = streamHub
+
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(0)
+		stream.SetKind(rpc.StreamKindRPCCancel)
+		session.OnConnReadStream(streamConn, stream)
+		assert(rpc.ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
 	t.Run("cbID == 0, accept = true, backStream = nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		session, syncConn, _ := prepareTestSession()
@@ -690,6 +1691,105 @@ func TestSession_OnConnReadStream(t *testing.T) {
 			Equal(nil, base.ErrStream)
 	})
 
+	t.Run("StreamKindRPCBoardCastAck", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, _ := prepareTestSession()
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		for i := 0; i < 3; i++ {
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindRPCBoardCast)
+			session.OutStream(stream)
+		}
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCBoardCastAck)
+		stream.SetCallbackID(2)
+		session.OnConnReadStream(streamConn, stream)
+		assert(len(session.outbox)).Equal(1)
+		assert(session.outbox[0].GetCallbackID()).Equal(uint64(3))
+
+		stream = rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCBoardCastAck)
+		stream.SetCallbackID(5)
+		session.OnConnReadStream(streamConn, stream)
+		assert(len(session.outbox)).Equal(0)
+	})
+
+	t.Run("StreamKindRPCBoardCastAck, stream error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for _, fnWrite := range []func(stream *rpc.Stream){
+			func(stream *rpc.Stream) { stream.SetCallbackID(0) },
+			func(stream *rpc.Stream) {
+				stream.SetCallbackID(1)
+				stream.WriteBool(true)
+			},
+		} {
+			session, syncConn, _ := prepareTestSession()
+			streamConn := adapter.NewStreamConn(false, syncConn, session)
+			streamHub := rpc.NewTestStreamHub()
+			session.gateway.streamHub = streamHub
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindRPCBoardCastAck)
+			fnWrite(stream)
+			session.OnConnReadStream(streamConn, stream)
+			assert(rpc.ParseResponseStream(streamHub.GetStream())).
+				Equal(nil, base.ErrStream)
+		}
+	})
+
+	t.Run("StreamKindTopicSubscribe", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, _ := prepareTestSession()
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicSubscribe)
+		stream.WriteString("news")
+		session.OnConnReadStream(streamConn, stream)
+		assert(session.topics).Equal(map[string]bool{"news": true})
+		assert(session.gateway.topicMap).Equal(map[string]map[uint64]bool{
+			"news": {11: true},
+		})
+	})
+
+	t.Run("StreamKindTopicUnsubscribe", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, syncConn, _ := prepareTestSession()
+		streamConn := adapter.NewStreamConn(false, syncConn, session)
+		session.topics = map[string]bool{"news": true}
+		session.gateway.subscribe("news", session.id)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicUnsubscribe)
+		stream.WriteString("news")
+		session.OnConnReadStream(streamConn, stream)
+		assert(session.topics).Equal(map[string]bool{})
+		assert(session.gateway.topicMap).Equal(map[string]map[uint64]bool{})
+	})
+
+	t.Run("StreamKindTopicSubscribe, stream error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for _, fnWrite := range []func(stream *rpc.Stream){
+			func(stream *rpc.Stream) { stream.WriteBool(true) },
+			func(stream *rpc.Stream) { stream.WriteString("") },
+			func(stream *rpc.Stream) {
+				stream.WriteString("news")
+				stream.WriteBool(true)
+			},
+		} {
+			session, syncConn, _ := prepareTestSession()
+			streamConn := adapter.NewStreamConn(false, syncConn, session)
+			streamHub := rpc.NewTestStreamHub()
+			session.gateway.streamHub = streamHub
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindTopicSubscribe)
+			fnWrite(stream)
+			session.OnConnReadStream(streamConn, stream)
+			assert(rpc.ParseResponseStream(streamHub.GetStream())).
+				Equal(nil, base.ErrStream)
+			assert(len(session.topics)).Equal(0)
+			assert(len(session.gateway.topicMap)).Equal(0)
+		}
+	})
+
 	t.Run("cbID == 0, kind err", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		session, syncConn, _ := prepareTestSession()
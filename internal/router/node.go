@@ -0,0 +1,603 @@
+package router
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/gateway"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+const (
+	defaultWorkerNumOfThreads = 16384
+	defaultWorkerCloseTimeout = 5 * time.Second
+	defaultWorkerMaxNodeDepth = 128
+	defaultWorkerMaxCallDepth = 128
+	defaultWorkerBufferSize   = 2048
+	defaultWorkerQueryTimeout = 3 * time.Second
+)
+
+// GatewayNode accepts the clients, and sends their requests to the worker
+// nodes through the router. The id should be unique among the gateway nodes
+// of the router, because the replies find their way back by it
+type GatewayNode struct {
+	gateway *gateway.GateWay
+	slot    *slot
+	logHub  rpc.IStreamHub
+}
+
+// NewGatewayNode ...
+func NewGatewayNode(
+	id uint64,
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+) *GatewayNode {
+	ret := &GatewayNode{
+		gateway: nil,
+		slot:    nil,
+		logHub:  rpc.NewLogToScreenErrorStreamHub("GatewayNode"),
+	}
+
+	ret.gateway = gateway.NewGateWay(id, gateway.GetDefaultConfig(), ret)
+	ret.slot = newSlot(
+		true,
+		id,
+		network,
+		addr,
+		tlsConfig,
+		ret.onRouterStream,
+		ret.logHub,
+	)
+
+	return ret
+}
+
+// SetSecret sets the secret that is checked by the router
+func (p *GatewayNode) SetSecret(secret string) *GatewayNode {
+	p.slot.setSecret(secret)
+	return p
+}
+
+// SetAuthenticator sets the authenticator that checks the credentials of the
+// clients when they connect
+func (p *GatewayNode) SetAuthenticator(
+	authenticator gateway.Authenticator,
+) *GatewayNode {
+	p.gateway.SetAuthenticator(authenticator)
+	return p
+}
+
+// SetSessionStore ...
+func (p *GatewayNode) SetSessionStore(store gateway.SessionStore) *GatewayNode {
+	p.gateway.SetSessionStore(store)
+	return p
+}
+
+// Listen ...
+func (p *GatewayNode) Listen(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+) *GatewayNode {
+	p.gateway.Listen(network, addr, tlsConfig)
+	return p
+}
+
+// Open runs the gateway until it is closed
+func (p *GatewayNode) Open() {
+	p.slot.open()
+	defer p.slot.close()
+	p.gateway.Open()
+}
+
+// Close ...
+func (p *GatewayNode) Close() {
+	p.gateway.Close()
+}
+
+// OnReceiveStream ...
+func (p *GatewayNode) OnReceiveStream(stream *rpc.Stream) {
+	if stream.GetKind() == rpc.StreamKindSystemErrorReport {
+		p.logHub.OnReceiveStream(stream)
+	} else {
+		p.slot.send(stream)
+	}
+}
+
+func (p *GatewayNode) onRouterStream(stream *rpc.Stream) {
+	switch stream.GetKind() {
+	case rpc.StreamKindRPCResponseOK:
+		fallthrough
+	case rpc.StreamKindRPCResponseError:
+		fallthrough
+	case rpc.StreamKindRPCResponseChunk:
+		fallthrough
+	case rpc.StreamKindRPCBoardCast:
+		p.gateway.OutStream(stream)
+	case rpc.StreamKindTopicPublish:
+		p.gateway.Publish(stream)
+	case rpc.StreamKindSessionAttribute:
+		p.onSessionAttribute(stream)
+	case rpc.StreamKindSessionPrincipal:
+		p.onSessionPrincipal(stream)
+	default:
+		stream.Release()
+	}
+}
+
+// onSessionPrincipal answers the query of the session principal that comes
+// from a worker. The answer is false if the session has no principal
+func (p *GatewayNode) onSessionPrincipal(stream *rpc.Stream) {
+	defer stream.Release()
+
+	if !stream.IsReadFinish() {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(base.ErrStream))
+		return
+	}
+
+	ret := rpc.NewStream()
+	ret.SetKind(rpc.StreamKindSessionPrincipal)
+	ret.SetGatewayID(stream.GetGatewayID())
+	ret.SetSessionID(stream.GetSessionID())
+	ret.SetCallbackID(stream.GetCallbackID())
+
+	principal := p.gateway.GetPrincipal(stream.GetSessionID())
+	if principal != nil {
+		roles := principal.GetRoles()
+		ret.WriteBool(true)
+		ret.WriteString(principal.GetName())
+		ret.WriteUint64(uint64(len(roles)))
+		for _, role := range roles {
+			ret.WriteString(role)
+		}
+	} else {
+		ret.WriteBool(false)
+	}
+
+	p.slot.send(ret)
+}
+
+// onSessionAttribute answers the query of the session attribute that comes
+// from a worker. The stream that is not well formed is not answered, and the
+// worker gives up the query when it times out
+func (p *GatewayNode) onSessionAttribute(stream *rpc.Stream) {
+	defer stream.Release()
+
+	sessionID := stream.GetSessionID()
+	value, ok := rpc.Any(nil), false
+
+	if key, err := stream.ReadString(); err != nil {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+		return
+	} else if isSet, err := stream.ReadBool(); err != nil {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+		return
+	} else if !isSet {
+		value, ok = p.gateway.GetSessionAttribute(sessionID, key)
+	} else if setValue, err := stream.Read(); err != nil {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+		return
+	} else {
+		ok = p.gateway.SetSessionAttribute(sessionID, key, setValue)
+	}
+
+	if !stream.IsReadFinish() {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(base.ErrStream))
+		return
+	}
+
+	ret := rpc.NewStream()
+	ret.SetKind(rpc.StreamKindSessionAttribute)
+	ret.SetGatewayID(stream.GetGatewayID())
+	ret.SetSessionID(sessionID)
+	ret.SetCallbackID(stream.GetCallbackID())
+	ret.Write(value)
+	ret.WriteBool(ok)
+	p.slot.send(ret)
+}
+
+// WorkerNode runs the services, and evaluates the requests that come from the
+// gateway nodes through the router
+type WorkerNode struct {
+	isRunning     bool
+	processor     *rpc.Processor
+	numOfThreads  int
+	actionCache   rpc.ActionCache
+	mountServices []*rpc.ServiceMeta
+	interceptors  []rpc.ActionInterceptor
+	slot          *slot
+	querySeed     uint64
+	queryMap      map[uint64]chan *rpc.Stream
+	queryLock     sync.Mutex
+	logHub        rpc.IStreamHub
+	orcManager    *base.ORCManager
+	sync.Mutex
+}
+
+// NewWorkerNode ...
+func NewWorkerNode(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+) *WorkerNode {
+	ret := &WorkerNode{
+		isRunning:     false,
+		processor:     nil,
+		numOfThreads:  defaultWorkerNumOfThreads,
+		actionCache:   nil,
+		mountServices: make([]*rpc.ServiceMeta, 0),
+		interceptors:  make([]rpc.ActionInterceptor, 0),
+		slot:          nil,
+		querySeed:     0,
+		queryMap:      make(map[uint64]chan *rpc.Stream),
+		logHub:        rpc.NewLogToScreenErrorStreamHub("WorkerNode"),
+		orcManager:    base.NewORCManager(),
+	}
+
+	ret.slot = newSlot(
+		false,
+		0,
+		network,
+		addr,
+		tlsConfig,
+		ret.onRouterStream,
+		ret.logHub,
+	)
+
+	return ret
+}
+
+// SetNumOfThreads ...
+func (p *WorkerNode) SetNumOfThreads(numOfThreads int) *WorkerNode {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else if numOfThreads <= 0 {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrNumOfThreadsIsWrong.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.numOfThreads = numOfThreads
+	}
+
+	return p
+}
+
+// SetSecret sets the secret that is checked by the router
+func (p *WorkerNode) SetSecret(secret string) *WorkerNode {
+	p.slot.setSecret(secret)
+	return p
+}
+
+// SetActionCache ...
+func (p *WorkerNode) SetActionCache(actionCache rpc.ActionCache) *WorkerNode {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.actionCache = actionCache
+	}
+
+	return p
+}
+
+// AddInterceptor adds an interceptor that runs around all the actions of
+// the worker
+func (p *WorkerNode) AddInterceptor(
+	interceptor rpc.ActionInterceptor,
+) *WorkerNode {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else if interceptor != nil {
+		p.interceptors = append(p.interceptors, interceptor)
+	}
+
+	return p
+}
+
+// AddService ...
+func (p *WorkerNode) AddService(
+	name string,
+	service *rpc.Service,
+	data rpc.Map,
+) *WorkerNode {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.mountServices = append(p.mountServices, rpc.NewServiceMeta(
+			name,
+			service,
+			base.GetFileLine(1),
+			data,
+		))
+	}
+
+	return p
+}
+
+// Open runs the worker until it is closed
+func (p *WorkerNode) Open() bool {
+	source := base.GetFileLine(1)
+
+	if !p.orcManager.Open(func() bool {
+		p.Lock()
+		defer p.Unlock()
+
+		if p.isRunning {
+			p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+				base.ErrRouterAlreadyRunning.AddDebug(source),
+			))
+			return false
+		} else if processor := rpc.NewProcessor(
+			p.numOfThreads,
+			defaultWorkerMaxNodeDepth,
+			defaultWorkerMaxCallDepth,
+			defaultWorkerBufferSize,
+			p.actionCache,
+			defaultWorkerCloseTimeout,
+			p.mountServices,
+			p.interceptors,
+			p,
+		); processor == nil {
+			return false
+		} else {
+			p.isRunning = true
+			p.processor = processor
+			p.slot.open()
+			return true
+		}
+	}) {
+		return false
+	}
+
+	return p.orcManager.Run(func(isRunning func() bool) bool {
+		for isRunning() {
+			startNS := base.TimeNow().UnixNano()
+			base.WaitAtLeastDurationWhenRunning(startNS, isRunning, time.Second)
+		}
+		return true
+	})
+}
+
+// IsRunning ...
+func (p *WorkerNode) IsRunning() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.isRunning
+}
+
+// Close ...
+func (p *WorkerNode) Close() bool {
+	return p.orcManager.Close(func() bool {
+		p.slot.close()
+		return true
+	}, func() {
+		p.Lock()
+		defer p.Unlock()
+		p.processor.Close()
+		p.processor = nil
+		p.isRunning = false
+	})
+}
+
+// GetPrincipal asks the gateway of the session for its principal. It returns
+// nil if the session has no principal or the gateway does not answer
+func (p *WorkerNode) GetPrincipal(
+	gatewayID uint64,
+	sessionID uint64,
+) *rpc.Principal {
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindSessionPrincipal)
+	stream.SetGatewayID(gatewayID)
+	stream.SetSessionID(sessionID)
+
+	if ret := p.querySession(stream); ret != nil {
+		defer ret.Release()
+		return readSessionPrincipal(ret)
+	}
+
+	return nil
+}
+
+// readSessionPrincipal reads the principal in the answer of the gateway
+func readSessionPrincipal(stream *rpc.Stream) *rpc.Principal {
+	if ok, err := stream.ReadBool(); err != nil || !ok {
+		return nil
+	} else if name, err := stream.ReadString(); err != nil {
+		return nil
+	} else if numOfRoles, err := stream.ReadUint64(); err != nil {
+		return nil
+	} else {
+		roles := make([]string, 0)
+		for i := uint64(0); i < numOfRoles; i++ {
+			if role, err := stream.ReadString(); err != nil {
+				return nil
+			} else {
+				roles = append(roles, role)
+			}
+		}
+
+		if !stream.IsReadFinish() {
+			return nil
+		}
+
+		return rpc.NewPrincipal(name, roles...)
+	}
+}
+
+// GetSessionAttribute asks the gateway of the session for the attribute
+func (p *WorkerNode) GetSessionAttribute(
+	gatewayID uint64,
+	sessionID uint64,
+	key string,
+) (rpc.Any, bool) {
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindSessionAttribute)
+	stream.SetGatewayID(gatewayID)
+	stream.SetSessionID(sessionID)
+	stream.WriteString(key)
+	stream.WriteBool(false)
+
+	if ret := p.querySession(stream); ret != nil {
+		defer ret.Release()
+		if value, err := ret.Read(); err != nil {
+			return nil, false
+		} else if ok, err := ret.ReadBool(); err != nil || !ret.IsReadFinish() {
+			return nil, false
+		} else {
+			return value, ok
+		}
+	}
+
+	return nil, false
+}
+
+// SetSessionAttribute asks the gateway of the session to set the attribute
+func (p *WorkerNode) SetSessionAttribute(
+	gatewayID uint64,
+	sessionID uint64,
+	key string,
+	value rpc.Any,
+) bool {
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindSessionAttribute)
+	stream.SetGatewayID(gatewayID)
+	stream.SetSessionID(sessionID)
+	stream.WriteString(key)
+	stream.WriteBool(true)
+	if reason := stream.Write(value); reason != rpc.StreamWriteOK {
+		stream.Release()
+		return false
+	}
+
+	if ret := p.querySession(stream); ret != nil {
+		defer ret.Release()
+		if _, err := ret.Read(); err != nil {
+			return false
+		} else if ok, err := ret.ReadBool(); err != nil || !ret.IsReadFinish() {
+			return false
+		} else {
+			return ok
+		}
+	}
+
+	return false
+}
+
+// querySession sends the query to the gateway of the session through the
+// router, and waits for the answer. It returns nil if the answer does not
+// arrive in time
+func (p *WorkerNode) querySession(stream *rpc.Stream) *rpc.Stream {
+	answerCH := make(chan *rpc.Stream, 1)
+
+	p.queryLock.Lock()
+	p.querySeed++
+	queryID := p.querySeed
+	p.queryMap[queryID] = answerCH
+	p.queryLock.Unlock()
+
+	defer func() {
+		p.queryLock.Lock()
+		delete(p.queryMap, queryID)
+		p.queryLock.Unlock()
+
+		// the answer might arrive after the timeout
+		select {
+		case ret := <-answerCH:
+			ret.Release()
+		default:
+		}
+	}()
+
+	stream.SetCallbackID(queryID)
+	p.slot.send(stream)
+
+	timer := time.NewTimer(defaultWorkerQueryTimeout)
+	defer timer.Stop()
+
+	select {
+	case ret := <-answerCH:
+		return ret
+	case <-timer.C:
+		return nil
+	}
+}
+
+// onSessionAnswer delivers the answer of the session query
+func (p *WorkerNode) onSessionAnswer(stream *rpc.Stream) {
+	p.queryLock.Lock()
+	answerCH, ok := p.queryMap[stream.GetCallbackID()]
+	p.queryLock.Unlock()
+
+	if ok {
+		select {
+		case answerCH <- stream:
+		default:
+			stream.Release()
+		}
+	} else {
+		stream.Release()
+	}
+}
+
+// OnReceiveStream ...
+func (p *WorkerNode) OnReceiveStream(stream *rpc.Stream) {
+	if stream.GetKind() == rpc.StreamKindSystemErrorReport {
+		p.logHub.OnReceiveStream(stream)
+	} else {
+		p.slot.send(stream)
+	}
+}
+
+func (p *WorkerNode) onRouterStream(stream *rpc.Stream) {
+	p.Lock()
+	processor := p.processor
+	p.Unlock()
+
+	if processor == nil {
+		stream.Release()
+		return
+	}
+
+	switch stream.GetKind() {
+	case rpc.StreamKindRPCRequest:
+		processor.PutStream(stream)
+	case rpc.StreamKindRPCCancel:
+		processor.Cancel(
+			stream.GetGatewayID(),
+			stream.GetSessionID(),
+			stream.GetCallbackID(),
+		)
+		stream.Release()
+	case rpc.StreamKindSessionEvent:
+		processor.OnSessionEvent(stream)
+	case rpc.StreamKindSessionAttribute:
+		fallthrough
+	case rpc.StreamKindSessionPrincipal:
+		p.onSessionAnswer(stream)
+	default:
+		stream.Release()
+	}
+}
@@ -0,0 +1,997 @@
+package router
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/client"
+	"github.com/rpccloud/rpc/internal/gateway"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+func waitTestSlotConnected(s *slot) {
+	for {
+		s.Lock()
+		conn := s.conn
+		s.Unlock()
+
+		if conn != nil {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitTestRouterConnected(r *Router, numOfGateways int, numOfWorkers int) {
+	for {
+		r.Lock()
+		ok := len(r.gatewayMap) == numOfGateways &&
+			len(r.workerConns) == numOfWorkers
+		r.Unlock()
+
+		if ok {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type testActionCache struct{}
+
+func (p *testActionCache) Get(_ string) rpc.ActionCacheFunc {
+	return nil
+}
+
+type testActionInterceptor struct {
+	numOfCalls int64
+}
+
+func (p *testActionInterceptor) Before(
+	_ rpc.Runtime,
+	_ *rpc.ActionContext,
+) *base.Error {
+	atomic.AddInt64(&p.numOfCalls, 1)
+	return nil
+}
+
+func (p *testActionInterceptor) After(_ *rpc.ActionContext) {}
+
+type testSessionStore struct{}
+
+func (p *testSessionStore) Load(_ string) (string, rpc.Map, bool) {
+	return "", nil, false
+}
+
+func (p *testSessionStore) Save(_ string, _ string, _ rpc.Map) {}
+
+func (p *testSessionStore) Delete(_ string) {}
+
+func makeTestSessionAttributeStream(
+	key string,
+	isSet bool,
+	value rpc.Any,
+) *rpc.Stream {
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindSessionAttribute)
+	stream.SetGatewayID(3)
+	stream.SetSessionID(10)
+	stream.SetCallbackID(15)
+	stream.WriteString(key)
+	stream.WriteBool(isSet)
+	if isSet {
+		stream.Write(value)
+	}
+	return stream
+}
+
+func TestNodeBasic(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(defaultWorkerNumOfThreads).Equal(16384)
+		assert(defaultWorkerCloseTimeout).Equal(5 * time.Second)
+		assert(defaultWorkerMaxNodeDepth).Equal(128)
+		assert(defaultWorkerMaxCallDepth).Equal(128)
+		assert(defaultWorkerBufferSize).Equal(2048)
+		assert(defaultWorkerQueryTimeout).Equal(3 * time.Second)
+	})
+}
+
+func TestNewGatewayNode(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		assert(v.gateway).IsNotNil()
+		assert(v.slot.isGateway).IsTrue()
+		assert(v.slot.gatewayID).Equal(uint64(3))
+		assert(v.slot.errorHub).Equal(v.logHub)
+		assert(v.logHub).IsNotNil()
+	})
+}
+
+func TestGatewayNode_SetSecret(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		assert(v.SetSecret("secret")).Equal(v)
+		assert(v.slot.secret).Equal("secret")
+	})
+}
+
+func TestGatewayNode_SetAuthenticator(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		assert(v.SetAuthenticator(gateway.AuthenticatorFunc(
+			func(_ rpc.Map) (*rpc.Principal, bool) {
+				return nil, true
+			},
+		))).Equal(v)
+	})
+}
+
+func TestGatewayNode_SetSessionStore(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		assert(v.SetSessionStore(&testSessionStore{})).Equal(v)
+	})
+}
+
+func TestGatewayNode_Listen(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		assert(v.Listen("ws", "127.0.0.1:8783", nil)).Equal(v)
+	})
+}
+
+func TestGatewayNode_OnReceiveStream(t *testing.T) {
+	t.Run("system error report", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.OnReceiveStream(rpc.MakeSystemErrorStream(base.ErrStream))
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("send to the router", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetSessionID(10)
+		v.OnReceiveStream(stream)
+		backStream := readTestStream(netConn)
+		assert(backStream.GetKind()).Equal(uint8(rpc.StreamKindRPCRequest))
+		assert(backStream.GetSessionID()).Equal(uint64(10))
+	})
+}
+
+func TestGatewayNode_onRouterStream(t *testing.T) {
+	t.Run("send to the session", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+
+		for _, kind := range []uint8{
+			rpc.StreamKindRPCResponseOK,
+			rpc.StreamKindRPCResponseError,
+			rpc.StreamKindRPCResponseChunk,
+			rpc.StreamKindRPCBoardCast,
+		} {
+			stream := rpc.NewStream()
+			stream.SetKind(kind)
+			stream.SetSessionID(10)
+			v.onRouterStream(stream)
+			// the session does not exist
+			assert(rpc.ParseResponseStream(logHub.GetStream())).
+				Equal(nil, base.ErrGateWaySessionNotFound)
+		}
+	})
+
+	t.Run("topic publish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		v.onRouterStream(stream)
+		assert(logHub.GetStream()).IsNil()
+	})
+
+	t.Run("session attribute", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		v.onRouterStream(makeTestSessionAttributeStream("name", false, nil))
+		backStream := readTestStream(netConn)
+		assert(backStream.GetKind()).
+			Equal(uint8(rpc.StreamKindSessionAttribute))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+	})
+
+	t.Run("session principal", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionPrincipal)
+		stream.SetCallbackID(15)
+		v.onRouterStream(stream)
+		backStream := readTestStream(netConn)
+		assert(backStream.GetKind()).
+			Equal(uint8(rpc.StreamKindSessionPrincipal))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+	})
+
+	t.Run("kind error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		v.onRouterStream(stream)
+		assert(logHub.GetStream()).IsNil()
+	})
+}
+
+func TestGatewayNode_onSessionAttribute(t *testing.T) {
+	t.Run("read key error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionAttribute)
+		v.onSessionAttribute(stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("read isSet error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionAttribute)
+		stream.WriteString("name")
+		v.onSessionAttribute(stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("read value error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionAttribute)
+		stream.WriteString("name")
+		stream.WriteBool(true)
+		v.onSessionAttribute(stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("stream is not finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := makeTestSessionAttributeStream("name", false, nil)
+		stream.WriteBool(true)
+		v.onSessionAttribute(stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		v.onSessionAttribute(makeTestSessionAttributeStream("name", false, nil))
+		backStream := readTestStream(netConn)
+		assert(backStream.GetKind()).
+			Equal(uint8(rpc.StreamKindSessionAttribute))
+		assert(backStream.GetGatewayID()).Equal(uint64(3))
+		assert(backStream.GetSessionID()).Equal(uint64(10))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+		// the session does not exist
+		assert(backStream.Read()).Equal(nil, nil)
+		assert(backStream.ReadBool()).Equal(false, nil)
+		assert(backStream.IsReadFinish()).IsTrue()
+	})
+
+	t.Run("set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		v.onSessionAttribute(
+			makeTestSessionAttributeStream("name", true, "kitty"),
+		)
+		backStream := readTestStream(netConn)
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+		// the session does not exist
+		assert(backStream.Read()).Equal(nil, nil)
+		assert(backStream.ReadBool()).Equal(false, nil)
+		assert(backStream.IsReadFinish()).IsTrue()
+	})
+}
+
+func TestGatewayNode_onSessionPrincipal(t *testing.T) {
+	t.Run("stream is not finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionPrincipal)
+		stream.WriteBool(true)
+		v.onSessionPrincipal(stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("session is not found", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewGatewayNode(3, "tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionPrincipal)
+		stream.SetGatewayID(3)
+		stream.SetSessionID(10)
+		stream.SetCallbackID(15)
+		v.onSessionPrincipal(stream)
+		backStream := readTestStream(netConn)
+		assert(backStream.GetGatewayID()).Equal(uint64(3))
+		assert(backStream.GetSessionID()).Equal(uint64(10))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+		assert(readSessionPrincipal(backStream)).IsNil()
+	})
+}
+
+func TestNewWorkerNode(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		assert(v.isRunning).IsFalse()
+		assert(v.processor).IsNil()
+		assert(v.numOfThreads).Equal(defaultWorkerNumOfThreads)
+		assert(v.actionCache).IsNil()
+		assert(v.mountServices).Equal(make([]*rpc.ServiceMeta, 0))
+		assert(v.interceptors).Equal(make([]rpc.ActionInterceptor, 0))
+		assert(v.slot.isGateway).IsFalse()
+		assert(v.slot.gatewayID).Equal(uint64(0))
+		assert(v.slot.errorHub).Equal(v.logHub)
+		assert(v.querySeed).Equal(uint64(0))
+		assert(v.queryMap).Equal(make(map[uint64]chan *rpc.Stream))
+		assert(v.logHub).IsNotNil()
+		assert(v.orcManager).IsNotNil()
+	})
+}
+
+func TestWorkerNode_SetNumOfThreads(t *testing.T) {
+	t.Run("worker is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.isRunning = true
+		_, source := v.SetNumOfThreads(1024), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(v.numOfThreads).Equal(defaultWorkerNumOfThreads)
+	})
+
+	t.Run("numOfThreads is wrong", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		_, source := v.SetNumOfThreads(0), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrNumOfThreadsIsWrong.AddDebug(source).Standardize(),
+		)
+		assert(v.numOfThreads).Equal(defaultWorkerNumOfThreads)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		assert(v.SetNumOfThreads(1024)).Equal(v)
+		assert(v.numOfThreads).Equal(1024)
+	})
+}
+
+func TestWorkerNode_SetSecret(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		assert(v.SetSecret("secret")).Equal(v)
+		assert(v.slot.secret).Equal("secret")
+	})
+}
+
+func TestWorkerNode_SetActionCache(t *testing.T) {
+	t.Run("worker is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.isRunning = true
+		_, source := v.SetActionCache(&testActionCache{}), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(v.actionCache).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		actionCache := &testActionCache{}
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		assert(v.SetActionCache(actionCache)).Equal(v)
+		assert(v.actionCache).Equal(actionCache)
+	})
+}
+
+func TestWorkerNode_AddInterceptor(t *testing.T) {
+	t.Run("worker is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.isRunning = true
+		interceptor := &testActionInterceptor{}
+		_, source := v.AddInterceptor(interceptor), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(len(v.interceptors)).Equal(0)
+	})
+
+	t.Run("interceptor is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		assert(v.AddInterceptor(nil)).Equal(v)
+		assert(len(v.interceptors)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		interceptor := &testActionInterceptor{}
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		assert(v.AddInterceptor(interceptor)).Equal(v)
+		assert(v.interceptors).Equal([]rpc.ActionInterceptor{interceptor})
+	})
+}
+
+func TestWorkerNode_AddService(t *testing.T) {
+	t.Run("worker is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.isRunning = true
+		_, source := v.AddService("t", rpc.NewService(), nil), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(len(v.mountServices)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		service := rpc.NewService()
+		_, source := v.AddService("t", service, nil), base.GetFileLine(0)
+		assert(v.mountServices).Equal([]*rpc.ServiceMeta{
+			rpc.NewServiceMeta("t", service, source, nil),
+		})
+	})
+}
+
+func TestWorkerNode_Open(t *testing.T) {
+	t.Run("worker is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.isRunning = true
+		ret, source := v.Open(), base.GetFileLine(0)
+		assert(ret).IsFalse()
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("processor create error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.numOfThreads = 0
+		assert(v.Open()).IsFalse()
+		assert(v.IsRunning()).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.numOfThreads = 1024
+
+		go func() {
+			for !v.IsRunning() {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			v.Close()
+		}()
+
+		assert(v.Open()).IsTrue()
+	})
+}
+
+func TestWorkerNode_GetPrincipal(t *testing.T) {
+	t.Run("no principal", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		answerTestSessionQuery(v, netConn, func(_, answer *rpc.Stream) {
+			answer.WriteBool(false)
+		})
+		assert(v.GetPrincipal(3, 10)).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		answerTestSessionQuery(v, netConn, func(query, answer *rpc.Stream) {
+			assert(query.GetKind()).
+				Equal(uint8(rpc.StreamKindSessionPrincipal))
+			assert(query.GetGatewayID()).Equal(uint64(3))
+			assert(query.GetSessionID()).Equal(uint64(10))
+			assert(query.IsReadFinish()).IsTrue()
+			answer.WriteBool(true)
+			answer.WriteString("kitty")
+			answer.WriteUint64(2)
+			answer.WriteString("admin")
+			answer.WriteString("user")
+		})
+		principal := v.GetPrincipal(3, 10)
+		assert(principal.GetName()).Equal("kitty")
+		assert(principal.GetRoles()).Equal([]string{"admin", "user"})
+		// the principal of the router is never returned
+		assert(principal.HasRole(rpc.RoleNode)).IsFalse()
+	})
+}
+
+func TestReadSessionPrincipal(t *testing.T) {
+	t.Run("answer error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for _, fn := range []func(stream *rpc.Stream){
+			func(stream *rpc.Stream) {},
+			func(stream *rpc.Stream) {
+				stream.WriteBool(true)
+			},
+			func(stream *rpc.Stream) {
+				stream.WriteBool(true)
+				stream.WriteString("kitty")
+			},
+			func(stream *rpc.Stream) {
+				stream.WriteBool(true)
+				stream.WriteString("kitty")
+				stream.WriteUint64(1)
+			},
+			func(stream *rpc.Stream) {
+				stream.WriteBool(true)
+				stream.WriteString("kitty")
+				stream.WriteUint64(0)
+				stream.WriteBool(true)
+			},
+		} {
+			stream := rpc.NewStream()
+			fn(stream)
+			assert(readSessionPrincipal(stream)).IsNil()
+		}
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := rpc.NewStream()
+		stream.WriteBool(true)
+		stream.WriteString("kitty")
+		stream.WriteUint64(0)
+		assert(readSessionPrincipal(stream)).Equal(rpc.NewPrincipal("kitty"))
+	})
+}
+
+// answerTestSessionQuery answers the next session query of the worker with
+// the answer that is made by fn
+func answerTestSessionQuery(
+	v *WorkerNode,
+	netConn *testNetConn,
+	fn func(query *rpc.Stream, answer *rpc.Stream),
+) {
+	go func() {
+		query := readTestStream(netConn)
+		defer query.Release()
+		answer := rpc.NewStream()
+		answer.SetKind(rpc.StreamKindSessionAttribute)
+		answer.SetCallbackID(query.GetCallbackID())
+		fn(query, answer)
+		v.onSessionAnswer(answer)
+	}()
+}
+
+func TestWorkerNode_GetSessionAttribute(t *testing.T) {
+	t.Run("answer error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+
+		answerTestSessionQuery(v, netConn, func(_, _ *rpc.Stream) {})
+		assert(v.GetSessionAttribute(3, 10, "name")).Equal(nil, false)
+
+		answerTestSessionQuery(v, netConn, func(_, answer *rpc.Stream) {
+			answer.Write("kitty")
+		})
+		assert(v.GetSessionAttribute(3, 10, "name")).Equal(nil, false)
+
+		answerTestSessionQuery(v, netConn, func(_, answer *rpc.Stream) {
+			answer.Write("kitty")
+			answer.WriteBool(true)
+			answer.WriteBool(true)
+		})
+		assert(v.GetSessionAttribute(3, 10, "name")).Equal(nil, false)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		answerTestSessionQuery(v, netConn, func(query, answer *rpc.Stream) {
+			assert(query.GetKind()).
+				Equal(uint8(rpc.StreamKindSessionAttribute))
+			assert(query.GetGatewayID()).Equal(uint64(3))
+			assert(query.GetSessionID()).Equal(uint64(10))
+			assert(query.ReadString()).Equal("name", nil)
+			assert(query.ReadBool()).Equal(false, nil)
+			assert(query.IsReadFinish()).IsTrue()
+			answer.Write("kitty")
+			answer.WriteBool(true)
+		})
+		assert(v.GetSessionAttribute(3, 10, "name")).Equal("kitty", true)
+	})
+}
+
+func TestWorkerNode_SetSessionAttribute(t *testing.T) {
+	t.Run("value is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		assert(v.SetSessionAttribute(3, 10, "name", make(chan bool))).
+			IsFalse()
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("answer error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+
+		answerTestSessionQuery(v, netConn, func(_, _ *rpc.Stream) {})
+		assert(v.SetSessionAttribute(3, 10, "name", "kitty")).IsFalse()
+
+		answerTestSessionQuery(v, netConn, func(_, answer *rpc.Stream) {
+			answer.Write(nil)
+		})
+		assert(v.SetSessionAttribute(3, 10, "name", "kitty")).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		answerTestSessionQuery(v, netConn, func(query, answer *rpc.Stream) {
+			assert(query.ReadString()).Equal("name", nil)
+			assert(query.ReadBool()).Equal(true, nil)
+			assert(query.Read()).Equal("kitty", nil)
+			assert(query.IsReadFinish()).IsTrue()
+			answer.Write(nil)
+			answer.WriteBool(true)
+		})
+		assert(v.SetSessionAttribute(3, 10, "name", "kitty")).IsTrue()
+	})
+}
+
+func TestWorkerNode_querySession(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		startTime := base.TimeNow()
+		assert(v.querySession(rpc.NewStream())).IsNil()
+		assert(base.TimeNow().Sub(startTime) >= defaultWorkerQueryTimeout).
+			IsTrue()
+		assert(len(v.queryMap)).Equal(0)
+	})
+}
+
+func TestWorkerNode_onSessionAnswer(t *testing.T) {
+	t.Run("query is not found", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		stream := rpc.NewStream()
+		stream.SetCallbackID(15)
+		v.onSessionAnswer(stream)
+		assert(len(v.queryMap)).Equal(0)
+	})
+
+	t.Run("query is answered", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		answerCH := make(chan *rpc.Stream, 1)
+		v.queryMap[15] = answerCH
+		stream1 := rpc.NewStream()
+		stream1.SetCallbackID(15)
+		stream2 := rpc.NewStream()
+		stream2.SetCallbackID(15)
+		v.onSessionAnswer(stream1)
+		// the second answer is dropped
+		v.onSessionAnswer(stream2)
+		assert(<-answerCH).Equal(stream1)
+		assert(len(answerCH)).Equal(0)
+	})
+}
+
+func TestWorkerNode_OnReceiveStream(t *testing.T) {
+	t.Run("system error report", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		v.logHub = logHub
+		v.OnReceiveStream(rpc.MakeSystemErrorStream(base.ErrStream))
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("send to the router", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseOK)
+		stream.SetGatewayID(3)
+		v.OnReceiveStream(stream)
+		backStream := readTestStream(netConn)
+		assert(backStream.GetKind()).Equal(uint8(rpc.StreamKindRPCResponseOK))
+		assert(backStream.GetGatewayID()).Equal(uint64(3))
+	})
+}
+
+func TestWorkerNode_onRouterStream(t *testing.T) {
+	t.Run("worker is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil)
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.conn = streamConn
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.test:Eval", "")
+		v.onRouterStream(stream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewWorkerNode("tcp", "127.0.0.1:8782", nil).
+			SetNumOfThreads(1024).
+			AddService("test", rpc.NewService().
+				On("Eval", func(rt rpc.Runtime) rpc.Return {
+					return rt.Reply("hello")
+				}), nil)
+
+		go func() {
+			v.Open()
+		}()
+		defer v.Close()
+
+		for !v.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		streamConn, netConn := newTestStreamConn(v.slot)
+		v.slot.Lock()
+		v.slot.conn = streamConn
+		v.slot.Unlock()
+
+		// request
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.test:Eval", "")
+		stream.SetGatewayID(3)
+		stream.SetCallbackID(15)
+		v.onRouterStream(stream)
+		backStream := readTestStream(netConn)
+		assert(backStream.GetGatewayID()).Equal(uint64(3))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+		assert(rpc.ParseResponseStream(backStream)).Equal("hello", nil)
+
+		// cancel, session event and the other kinds are consumed
+		for _, kind := range []uint8{
+			rpc.StreamKindRPCCancel,
+			rpc.StreamKindSessionEvent,
+			rpc.StreamKindSessionAttribute,
+			rpc.StreamKindSessionPrincipal,
+			rpc.StreamKindPing,
+		} {
+			stream := rpc.NewStream()
+			stream.SetKind(kind)
+			stream.WriteString(rpc.SessionEventOpen)
+			v.onRouterStream(stream)
+		}
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+}
+
+func TestRouter_Integration(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		router := NewRouter().
+			SetSecret("secret").
+			Listen("tcp", "127.0.0.1:8784", nil)
+		go func() {
+			router.Open()
+		}()
+		defer router.Close()
+
+		for !router.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		openCH := make(chan rpc.Any, 2)
+		service := rpc.NewService().
+			On("$onSessionOpen", func(rt rpc.Runtime) rpc.Return {
+				if principal := rt.GetPrincipal(); principal != nil {
+					openCH <- principal.GetName()
+				} else {
+					openCH <- nil
+				}
+				return rt.Reply(nil)
+			}).
+			On("SayHello", func(rt rpc.Runtime, name string) rpc.Return {
+				return rt.Reply("hello " + name)
+			}).
+			On("PostMessage", func(rt rpc.Runtime, name string) rpc.Return {
+				return rt.Reply(rt.Post(rt.GetPostEndPoint(), "@Post", name))
+			}).
+			On("GetName", func(rt rpc.Runtime) rpc.Return {
+				return rt.Reply(rt.GetPrincipal().GetName())
+			}, "admin").
+			On("SetColor", func(rt rpc.Runtime, color string) rpc.Return {
+				return rt.Reply(rt.SessionSet("color", color) == nil)
+			}).
+			On("GetColor", func(rt rpc.Runtime) rpc.Return {
+				color, _ := rt.SessionGet("color")
+				return rt.Reply(color)
+			}, "admin")
+		interceptor := &testActionInterceptor{}
+
+		workers := make([]*WorkerNode, 0)
+		for i := 0; i < 2; i++ {
+			worker := NewWorkerNode("tcp", "127.0.0.1:8784", nil).
+				SetSecret("secret").
+				SetNumOfThreads(1024).
+				AddInterceptor(interceptor).
+				AddService("user", service, nil)
+			go func() {
+				worker.Open()
+			}()
+			defer worker.Close()
+			workers = append(workers, worker)
+		}
+
+		gatewayNode := NewGatewayNode(1, "tcp", "127.0.0.1:8784", nil).
+			SetSecret("secret").
+			SetAuthenticator(gateway.AuthenticatorFunc(
+				func(credentials rpc.Map) (*rpc.Principal, bool) {
+					if credentials["token"] == "kitty" {
+						return rpc.NewPrincipal("kitty", "admin"), true
+					}
+					return nil, true
+				},
+			)).
+			Listen("ws", "127.0.0.1:8785", nil)
+		go func() {
+			gatewayNode.Open()
+		}()
+		defer gatewayNode.Close()
+
+		waitTestSlotConnected(gatewayNode.slot)
+		for _, worker := range workers {
+			waitTestSlotConnected(worker.slot)
+		}
+		waitTestRouterConnected(router, 1, 2)
+
+		rpcClient := client.Dial("ws", "127.0.0.1:8785")
+		defer rpcClient.Close()
+
+		waitCH := make(chan rpc.Any, 1)
+		rpcClient.Subscribe("#.user", "@Post", func(value rpc.Any) {
+			waitCH <- value
+		})
+
+		for _, name := range []string{"kitty", "doggy", "bunny"} {
+			assert(rpcClient.Send(3*time.Second, "#.user:SayHello", name)).
+				Equal("hello "+name, nil)
+			assert(rpcClient.Send(3*time.Second, "#.user:PostMessage", name)).
+				Equal(nil, nil)
+			assert(<-waitCH).Equal(name)
+		}
+
+		// the hooks run as the principal of the session
+		assert(<-openCH).Equal(nil)
+
+		// the role is checked against the principal of the caller
+		_, err := rpcClient.Send(3*time.Second, "#.user:GetName")
+		assert(err.GetCode()).Equal(base.ErrActionForbidden.GetCode())
+
+		kittyClient := client.DialWithCredentials(
+			"ws", "127.0.0.1:8785", rpc.Map{"token": "kitty"},
+		)
+		defer kittyClient.Close()
+		assert(kittyClient.Send(3*time.Second, "#.user:GetName")).
+			Equal("kitty", nil)
+		assert(<-openCH).Equal("kitty")
+
+		// the session attributes are kept by the gateway
+		assert(kittyClient.Send(3*time.Second, "#.user:SetColor", "red")).
+			Equal(true, nil)
+		for i := 0; i < 4; i++ {
+			assert(kittyClient.Send(3*time.Second, "#.user:GetColor")).
+				Equal("red", nil)
+		}
+
+		// the interceptors of the workers run around the actions
+		assert(atomic.LoadInt64(&interceptor.numOfCalls) > 0).IsTrue()
+	})
+}
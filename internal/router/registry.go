@@ -0,0 +1,144 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+const defaultFileRegistryInterval = time.Second
+
+// RegistryNode is a server node of the cluster, and the absolute paths of the
+// services that it mounts, like "#.user"
+type RegistryNode struct {
+	Network  string   `json:"network"`
+	Addr     string   `json:"addr"`
+	Services []string `json:"services"`
+}
+
+// IRegistry provides the server nodes of the cluster to the RemoteHub.
+// onChange is called with all the nodes when the registry is opened, and
+// called again whenever the nodes change
+type IRegistry interface {
+	Open(onChange func(nodes []*RegistryNode)) bool
+	Close() bool
+}
+
+// FileRegistry reads the server nodes from a local json file, and reloads them
+// when the file changes. The file looks like:
+//
+//	{
+//	  "nodes": [
+//	    {"network": "ws", "addr": "127.0.0.1:8080", "services": ["#.user"]}
+//	  ]
+//	}
+type FileRegistry struct {
+	path       string
+	interval   time.Duration
+	content    []byte
+	logHub     rpc.IStreamHub
+	orcManager *base.ORCManager
+	sync.Mutex
+}
+
+// NewFileRegistry ...
+func NewFileRegistry(path string) *FileRegistry {
+	return &FileRegistry{
+		path:       path,
+		interval:   defaultFileRegistryInterval,
+		content:    nil,
+		logHub:     rpc.NewLogToScreenErrorStreamHub("FileRegistry"),
+		orcManager: base.NewORCManager(),
+	}
+}
+
+// load reads the file, and returns the nodes if the content has changed since
+// the last load
+func (p *FileRegistry) load() ([]*RegistryNode, bool, *base.Error) {
+	p.Lock()
+	defer p.Unlock()
+
+	content, e := ioutil.ReadFile(p.path)
+	if e != nil {
+		return nil, false, base.ErrRouterRegistryRead.AddDebug(e.Error())
+	}
+
+	if p.content != nil && bytes.Equal(content, p.content) {
+		return nil, false, nil
+	}
+
+	config := struct {
+		Nodes []*RegistryNode `json:"nodes"`
+	}{}
+
+	if e := json.Unmarshal(content, &config); e != nil {
+		return nil, false, base.ErrRouterRegistryFormat.AddDebug(e.Error())
+	}
+
+	for _, node := range config.Nodes {
+		if node == nil || node.Network == "" || node.Addr == "" {
+			return nil, false, base.ErrRouterRegistryFormat.
+				AddDebug("network and addr of the node must not be empty")
+		}
+	}
+
+	p.content = content
+	return config.Nodes, true, nil
+}
+
+// Open loads the nodes from the file, and watches the file until the registry
+// is closed
+func (p *FileRegistry) Open(onChange func(nodes []*RegistryNode)) bool {
+	if !p.orcManager.Open(func() bool {
+		nodes, _, err := p.load()
+		if err != nil {
+			p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+			return false
+		}
+
+		onChange(nodes)
+		return true
+	}) {
+		return false
+	}
+
+	go func() {
+		p.orcManager.Run(func(isRunning func() bool) bool {
+			for isRunning() {
+				startNS := base.TimeNow().UnixNano()
+				base.WaitAtLeastDurationWhenRunning(startNS, isRunning, p.interval)
+
+				if !isRunning() {
+					break
+				}
+
+				// keep the nodes of the last load if the file is broken
+				if nodes, changed, err := p.load(); err != nil {
+					p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+				} else if changed {
+					onChange(nodes)
+				}
+			}
+
+			return true
+		})
+	}()
+
+	return true
+}
+
+// Close stops watching the file
+func (p *FileRegistry) Close() bool {
+	return p.orcManager.Close(func() bool {
+		return true
+	}, func() {
+		p.Lock()
+		defer p.Unlock()
+		p.content = nil
+	})
+}
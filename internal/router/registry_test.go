@@ -0,0 +1,175 @@
+package router
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+func writeTestRegistryFile(filePath string, content string) {
+	if e := ioutil.WriteFile(filePath, []byte(content), 0644); e != nil {
+		panic(e)
+	}
+}
+
+func makeTestRegistryFile(content string) (string, func()) {
+	dir, e := ioutil.TempDir("", "rpc-registry-")
+	if e != nil {
+		panic(e)
+	}
+
+	filePath := path.Join(dir, "registry.json")
+	writeTestRegistryFile(filePath, content)
+	return filePath, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestRegistryBasic(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(defaultFileRegistryInterval).Equal(time.Second)
+	})
+}
+
+func TestNewFileRegistry(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewFileRegistry("registry.json")
+		assert(v.path).Equal("registry.json")
+		assert(v.interval).Equal(defaultFileRegistryInterval)
+		assert(v.content).IsNil()
+		assert(v.logHub).IsNotNil()
+		assert(v.orcManager).IsNotNil()
+	})
+}
+
+func TestFileRegistry_load(t *testing.T) {
+	t.Run("read error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewFileRegistry("/not/exist/registry.json")
+		nodes, changed, err := v.load()
+		assert(nodes, changed).Equal(nil, false)
+		assert(err.GetCode()).Equal(base.ErrRouterRegistryRead.GetCode())
+	})
+
+	t.Run("json error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath, clean := makeTestRegistryFile("{")
+		defer clean()
+		v := NewFileRegistry(filePath)
+		nodes, changed, err := v.load()
+		assert(nodes, changed).Equal(nil, false)
+		assert(err.GetCode()).Equal(base.ErrRouterRegistryFormat.GetCode())
+		assert(v.content).IsNil()
+	})
+
+	t.Run("node error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath, clean := makeTestRegistryFile(
+			`{"nodes": [{"network": "ws", "services": ["#.user"]}]}`,
+		)
+		defer clean()
+		v := NewFileRegistry(filePath)
+		assert(v.load()).Equal(nil, false, base.ErrRouterRegistryFormat.
+			AddDebug("network and addr of the node must not be empty"))
+		assert(v.content).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		content := `{"nodes": [` +
+			`{"network": "ws", "addr": "127.0.0.1:8766", "services": ["#.user"]}` +
+			`]}`
+		filePath, clean := makeTestRegistryFile(content)
+		defer clean()
+		v := NewFileRegistry(filePath)
+		assert(v.load()).Equal([]*RegistryNode{{
+			Network:  "ws",
+			Addr:     "127.0.0.1:8766",
+			Services: []string{"#.user"},
+		}}, true, nil)
+		assert(v.content).Equal([]byte(content))
+
+		// not changed
+		assert(v.load()).Equal(nil, false, nil)
+	})
+}
+
+func TestFileRegistry_Open(t *testing.T) {
+	t.Run("load error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewFileRegistry("/not/exist/registry.json")
+		v.logHub = logHub
+		assert(v.Open(func(nodes []*RegistryNode) {})).IsFalse()
+		_, err := rpc.ParseResponseStream(logHub.GetStream())
+		assert(err.GetCode()).Equal(base.ErrRouterRegistryRead.GetCode())
+	})
+
+	t.Run("registry is already opened", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath, clean := makeTestRegistryFile(`{"nodes": []}`)
+		defer clean()
+		v := NewFileRegistry(filePath)
+		defer v.Close()
+		assert(v.Open(func(nodes []*RegistryNode) {})).IsTrue()
+		assert(v.Open(func(nodes []*RegistryNode) {})).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		filePath, clean := makeTestRegistryFile(`{"nodes": []}`)
+		defer clean()
+		v := NewFileRegistry(filePath)
+		v.interval = 100 * time.Millisecond
+		v.logHub = logHub
+		defer v.Close()
+
+		nodesCH := make(chan []*RegistryNode, 10)
+		assert(v.Open(func(nodes []*RegistryNode) {
+			nodesCH <- nodes
+		})).IsTrue()
+		assert(<-nodesCH).Equal([]*RegistryNode{})
+
+		// reload when the file changes
+		writeTestRegistryFile(
+			filePath,
+			`{"nodes": [{"network": "ws", "addr": "127.0.0.1:8766"}]}`,
+		)
+		assert(<-nodesCH).Equal([]*RegistryNode{{
+			Network: "ws",
+			Addr:    "127.0.0.1:8766",
+		}})
+
+		// the broken file is reported, and the nodes are kept
+		writeTestRegistryFile(filePath, "{")
+		_, err := rpc.ParseResponseStream(logHub.WaitStream())
+		assert(err.GetCode()).Equal(base.ErrRouterRegistryFormat.GetCode())
+		assert(len(nodesCH)).Equal(0)
+	})
+}
+
+func TestFileRegistry_Close(t *testing.T) {
+	t.Run("registry is not opened", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewFileRegistry("registry.json")
+		assert(v.Close()).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath, clean := makeTestRegistryFile(`{"nodes": []}`)
+		defer clean()
+		v := NewFileRegistry(filePath)
+		assert(v.Open(func(nodes []*RegistryNode) {})).IsTrue()
+		assert(v.Close()).IsTrue()
+		assert(v.content).IsNil()
+	})
+}
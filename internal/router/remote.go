@@ -0,0 +1,262 @@
+package router
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/client"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+const defaultRemoteTimeout = 30 * time.Second
+
+// remoteNode is another server node that mounts some of the services
+type remoteNode struct {
+	network      string
+	addr         string
+	services     []string
+	isRegistered bool
+	client       *client.Client
+}
+
+// hasTarget reports whether the target is an action of the services
+func (p *remoteNode) hasTarget(target string) bool {
+	for _, service := range p.services {
+		if len(target) > len(service) &&
+			strings.HasPrefix(target, service) &&
+			(target[len(service)] == ':' || target[len(service)] == '.') {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoteHub forwards the calls of Runtime.Call to the actions that are mounted
+// on the other server nodes. It is set to the server by Server.SetRemoteHub
+type RemoteHub struct {
+	nodes       []*remoteNode
+	registry    IRegistry
+	credentials rpc.Map
+	logHub      rpc.IStreamHub
+	sync.Mutex
+}
+
+// NewRemoteHub ...
+func NewRemoteHub() *RemoteHub {
+	return &RemoteHub{
+		nodes:       make([]*remoteNode, 0),
+		registry:    nil,
+		credentials: nil,
+		logHub:      rpc.NewLogToScreenErrorStreamHub("RemoteHub"),
+	}
+}
+
+// SetCredentials sets the credentials that are sent to the authenticators of
+// the nodes that are connected later. The nodes trust the principal of the
+// caller that the hub forwards, only if their authenticators give the
+// credentials a principal with rpc.RoleNode
+func (p *RemoteHub) SetCredentials(credentials rpc.Map) *RemoteHub {
+	p.Lock()
+	defer p.Unlock()
+
+	p.credentials = credentials
+	return p
+}
+
+// AddRemote connects to another server node that mounts the services. The
+// services are given by their absolute paths, like "#.user"
+func (p *RemoteHub) AddRemote(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+	services ...string,
+) *RemoteHub {
+	p.Lock()
+	defer p.Unlock()
+
+	p.nodes = append(p.nodes, &remoteNode{
+		network:      network,
+		addr:         addr,
+		services:     services,
+		isRegistered: false,
+		client: client.DialTLSWithCredentials(
+			network,
+			addr,
+			tlsConfig,
+			p.credentials,
+		),
+	})
+
+	return p
+}
+
+// SetRegistry opens the registry, and keeps the nodes of the hub the same as
+// the nodes of the registry. The nodes that are added by AddRemote are not
+// affected. The registry is closed when the hub is closed. If the registry
+// can not be opened, the error is reported, and the hub keeps no registry
+func (p *RemoteHub) SetRegistry(
+	registry IRegistry,
+	tlsConfig *tls.Config,
+) *RemoteHub {
+	p.Lock()
+	oldRegistry := p.registry
+	p.registry = registry
+	p.Unlock()
+
+	if oldRegistry != nil {
+		oldRegistry.Close()
+	}
+
+	if !registry.Open(func(nodes []*RegistryNode) {
+		p.onRegistryChange(nodes, tlsConfig)
+	}) {
+		p.Lock()
+		if p.registry == registry {
+			p.registry = nil
+		}
+		p.Unlock()
+
+		p.logHub.OnReceiveStream(
+			rpc.MakeSystemErrorStream(base.ErrRouterRegistryOpen),
+		)
+	}
+
+	return p
+}
+
+// onRegistryChange replaces the registered nodes. The connections to the nodes
+// that are still in the registry are kept
+func (p *RemoteHub) onRegistryChange(
+	nodes []*RegistryNode,
+	tlsConfig *tls.Config,
+) {
+	p.Lock()
+	defer p.Unlock()
+
+	oldNodes := make(map[string]*remoteNode)
+	newNodes := make([]*remoteNode, 0)
+
+	for _, node := range p.nodes {
+		if node.isRegistered {
+			oldNodes[node.network+"://"+node.addr] = node
+		} else {
+			newNodes = append(newNodes, node)
+		}
+	}
+
+	for _, item := range nodes {
+		key := item.Network + "://" + item.Addr
+		if node, ok := oldNodes[key]; ok {
+			delete(oldNodes, key)
+			node.services = item.Services
+			newNodes = append(newNodes, node)
+		} else {
+			newNodes = append(newNodes, &remoteNode{
+				network:      item.Network,
+				addr:         item.Addr,
+				services:     item.Services,
+				isRegistered: true,
+				client: client.DialTLSWithCredentials(
+					item.Network,
+					item.Addr,
+					tlsConfig,
+					p.credentials,
+				),
+			})
+		}
+	}
+
+	for _, node := range oldNodes {
+		node.client.Close()
+	}
+
+	p.nodes = newNodes
+}
+
+// getNode returns the first healthy node that mounts the target. If none of
+// them is healthy, the first node that mounts the target is returned, and the
+// call waits for its reconnection
+func (p *RemoteHub) getNode(target string) *remoteNode {
+	p.Lock()
+	defer p.Unlock()
+
+	var ret *remoteNode
+
+	for _, node := range p.nodes {
+		if node.hasTarget(target) {
+			if node.client.IsHealthy() {
+				return node
+			} else if ret == nil {
+				ret = node
+			}
+		}
+	}
+
+	return ret
+}
+
+// CallRemote forwards the call to the node that mounts the target. The call
+// times out at the deadline of the caller, or after defaultRemoteTimeout if
+// the caller has no deadline. The calling thread waits for the response, and
+// the cancellation of the caller is forwarded to the node
+func (p *RemoteHub) CallRemote(
+	target string,
+	stream *rpc.Stream,
+	deadline int64,
+	cancelCH <-chan bool,
+) *rpc.Stream {
+	if node := p.getNode(target); node != nil {
+		timeout := defaultRemoteTimeout
+		if deadline > 0 {
+			timeout = time.Duration(deadline - base.TimeNow().UnixNano())
+		}
+
+		if timeout <= 0 {
+			return makeRemoteErrorStream(
+				base.ErrCallDeadlineExceeded.AddDebug(base.ConcatString(
+					"call ",
+					target,
+					" deadline exceeded",
+				)),
+			)
+		}
+
+		return node.client.Forward(timeout, stream, cancelCH)
+	}
+
+	return nil
+}
+
+func makeRemoteErrorStream(err *base.Error) *rpc.Stream {
+	ret := rpc.NewStream()
+	ret.SetKind(rpc.StreamKindRPCResponseError)
+	ret.WriteUint64(uint64(err.GetCode()))
+	ret.WriteString(err.GetMessage())
+	return ret
+}
+
+// Close closes the registry and the connections to the nodes
+func (p *RemoteHub) Close() {
+	// the registry is closed without the lock, because it might be calling
+	// onRegistryChange
+	p.Lock()
+	registry := p.registry
+	p.registry = nil
+	p.Unlock()
+
+	if registry != nil {
+		registry.Close()
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	for _, node := range p.nodes {
+		node.client.Close()
+	}
+	p.nodes = make([]*remoteNode, 0)
+}
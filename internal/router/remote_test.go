@@ -0,0 +1,359 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/client"
+	"github.com/rpccloud/rpc/internal/gateway"
+	"github.com/rpccloud/rpc/internal/rpc"
+	"github.com/rpccloud/rpc/internal/server"
+)
+
+func TestRemoteBasic(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(defaultRemoteTimeout).Equal(30 * time.Second)
+	})
+}
+
+func TestRemoteNode_hasTarget(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &remoteNode{services: []string{"#.user", "#.admin"}}
+		assert(v.hasTarget("#.user:Get")).IsTrue()
+		assert(v.hasTarget("#.user.profile:Get")).IsTrue()
+		assert(v.hasTarget("#.admin:Get")).IsTrue()
+		assert(v.hasTarget("#.user")).IsFalse()
+		assert(v.hasTarget("#.users:Get")).IsFalse()
+		assert(v.hasTarget("#.order:Get")).IsFalse()
+	})
+}
+
+func TestNewRemoteHub(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub()
+		assert(v.nodes).Equal(make([]*remoteNode, 0))
+		assert(v.registry).IsNil()
+		assert(v.credentials).IsNil()
+		assert(v.logHub).IsNotNil()
+	})
+}
+
+func TestRemoteHub_AddRemote(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub()
+		defer v.Close()
+		assert(v.AddRemote("ws", "127.0.0.1:8766", nil, "#.user")).Equal(v)
+		assert(len(v.nodes)).Equal(1)
+		assert(v.nodes[0].network).Equal("ws")
+		assert(v.nodes[0].addr).Equal("127.0.0.1:8766")
+		assert(v.nodes[0].services).Equal([]string{"#.user"})
+		assert(v.nodes[0].isRegistered).IsFalse()
+		assert(v.nodes[0].client).IsNotNil()
+	})
+}
+
+func TestRemoteHub_SetCredentials(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub()
+		assert(v.SetCredentials(rpc.Map{"token": "secret"})).Equal(v)
+		assert(v.credentials).Equal(rpc.Map{"token": "secret"})
+	})
+}
+
+func TestRemoteHub_SetRegistry(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath1, clean1 := makeTestRegistryFile(
+			`{"nodes": [{"network": "ws", "addr": "127.0.0.1:8766"}]}`,
+		)
+		defer clean1()
+		filePath2, clean2 := makeTestRegistryFile(
+			`{"nodes": [{"network": "ws", "addr": "127.0.0.1:8767"}]}`,
+		)
+		defer clean2()
+		registry1 := NewFileRegistry(filePath1)
+		registry2 := NewFileRegistry(filePath2)
+		v := NewRemoteHub()
+		defer v.Close()
+
+		assert(v.SetRegistry(registry1, nil)).Equal(v)
+		assert(v.registry).Equal(registry1)
+		assert(len(v.nodes)).Equal(1)
+		assert(v.nodes[0].addr).Equal("127.0.0.1:8766")
+
+		// the old registry is closed
+		assert(v.SetRegistry(registry2, nil)).Equal(v)
+		assert(v.registry).Equal(registry2)
+		assert(registry1.Close()).IsFalse()
+		assert(len(v.nodes)).Equal(1)
+		assert(v.nodes[0].addr).Equal("127.0.0.1:8767")
+	})
+
+	t.Run("registry can not be opened", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		registry := NewFileRegistry("/not/exist/registry.json")
+		registry.logHub = rpc.NewTestStreamHub()
+		logHub := rpc.NewTestStreamHub()
+		v := NewRemoteHub()
+		v.logHub = logHub
+		defer v.Close()
+
+		assert(v.SetRegistry(registry, nil)).Equal(v)
+		assert(v.registry).IsNil()
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrRouterRegistryOpen)
+	})
+}
+
+func TestRemoteHub_onRegistryChange(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub().AddRemote("ws", "127.0.0.1:8766", nil, "#.user")
+		defer v.Close()
+		staticNode := v.nodes[0]
+
+		v.onRegistryChange([]*RegistryNode{
+			{Network: "ws", Addr: "127.0.0.1:8767", Services: []string{"#.a"}},
+			{Network: "ws", Addr: "127.0.0.1:8768", Services: []string{"#.b"}},
+		}, nil)
+		assert(len(v.nodes)).Equal(3)
+		assert(v.nodes[0]).Equal(staticNode)
+		assert(v.nodes[1].isRegistered).IsTrue()
+		assert(v.nodes[1].services).Equal([]string{"#.a"})
+		assert(v.nodes[2].isRegistered).IsTrue()
+		assert(v.nodes[2].services).Equal([]string{"#.b"})
+		keptNode := v.nodes[2]
+		removedClient := v.nodes[1].client
+
+		// the connection of the kept node is reused
+		v.onRegistryChange([]*RegistryNode{
+			{Network: "ws", Addr: "127.0.0.1:8768", Services: []string{"#.c"}},
+		}, nil)
+		assert(len(v.nodes)).Equal(2)
+		assert(v.nodes[0]).Equal(staticNode)
+		assert(v.nodes[1]).Equal(keptNode)
+		assert(v.nodes[1].services).Equal([]string{"#.c"})
+		assert(removedClient.Close()).IsFalse()
+	})
+}
+
+func TestRemoteHub_getNode(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub().
+			AddRemote("ws", "127.0.0.1:8766", nil, "#.user").
+			AddRemote("ws", "127.0.0.1:8767", nil, "#.order")
+		defer v.Close()
+		assert(v.getNode("#.user:Get")).Equal(v.nodes[0])
+		assert(v.getNode("#.order:Get")).Equal(v.nodes[1])
+		assert(v.getNode("#.admin:Get")).IsNil()
+	})
+
+	t.Run("healthy node is preferred", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		userServer := server.NewServer().
+			SetNumOfThreads(1024).
+			Listen("ws", "127.0.0.1:8766", nil).
+			AddService("user", rpc.NewService(), nil)
+		go func() {
+			userServer.Open()
+		}()
+		defer userServer.Close()
+
+		for !userServer.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		v := NewRemoteHub().
+			AddRemote("ws", "127.0.0.1:8769", nil, "#.user").
+			AddRemote("ws", "127.0.0.1:8766", nil, "#.user")
+		defer v.Close()
+
+		for !v.nodes[1].client.IsHealthy() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		assert(v.getNode("#.user:Get")).Equal(v.nodes[1])
+	})
+}
+
+func TestRemoteHub_CallRemote(t *testing.T) {
+	t.Run("target is not mounted", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub()
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.order:Get", "")
+		defer stream.Release()
+		assert(v.CallRemote("#.order:Get", stream, 0, nil)).IsNil()
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRemoteHub().AddRemote("ws", "127.0.0.1:8769", nil, "#.user")
+		defer v.Close()
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.user:Get", "")
+		defer stream.Release()
+		backStream := v.CallRemote(
+			"#.user:Get",
+			stream,
+			base.TimeNow().UnixNano()-1,
+			nil,
+		)
+		defer backStream.Release()
+		assert(rpc.ParseResponseStream(backStream)).Equal(
+			nil,
+			base.ErrCallDeadlineExceeded.
+				AddDebug("call #.user:Get deadline exceeded").
+				Standardize(),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		userServer := server.NewServer().
+			SetNumOfThreads(1024).
+			Listen("ws", "127.0.0.1:8766", nil).
+			AddService("user", rpc.NewService().
+				On("SayHello", func(rt rpc.Runtime, name string) rpc.Return {
+					return rt.Reply("hello " + name)
+				}), nil)
+		go func() {
+			userServer.Open()
+		}()
+		defer userServer.Close()
+
+		for !userServer.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		remoteHub := NewRemoteHub().AddRemote("ws", "127.0.0.1:8766", nil, "#.user")
+		defer remoteHub.Close()
+
+		proxyServer := server.NewServer().
+			SetNumOfThreads(1024).
+			SetRemoteHub(remoteHub).
+			Listen("ws", "127.0.0.1:8767", nil).
+			AddService("proxy", rpc.NewService().
+				On("Call", func(rt rpc.Runtime, name string) rpc.Return {
+					return rt.Reply(rt.Call("#.user:SayHello", name))
+				}).
+				On("CallAsync", func(rt rpc.Runtime, name string) rpc.Return {
+					return rt.Reply(rt.CallAsync("#.user:SayHello", name).Wait())
+				}).
+				On("NotExist", func(rt rpc.Runtime) rpc.Return {
+					return rt.Reply(rt.Call("#.order:Get"))
+				}), nil)
+		go func() {
+			proxyServer.Open()
+		}()
+		defer proxyServer.Close()
+
+		for !proxyServer.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		rpcClient := client.Dial("ws", "127.0.0.1:8767")
+		defer rpcClient.Close()
+
+		assert(rpcClient.Send(3*time.Second, "#.proxy:Call", "kitty")).
+			Equal("hello kitty", nil)
+		assert(rpcClient.Send(3*time.Second, "#.proxy:CallAsync", "doggy")).
+			Equal("hello doggy", nil)
+		_, err := rpcClient.Send(3*time.Second, "#.proxy:NotExist")
+		assert(err.GetCode()).Equal(base.ErrTargetNotExist.GetCode())
+	})
+
+	t.Run("principal is forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		authenticator := gateway.AuthenticatorFunc(
+			func(credentials rpc.Map) (*rpc.Principal, bool) {
+				switch credentials["token"] {
+				case "node":
+					return rpc.NewPrincipal("proxy", rpc.RoleNode), true
+				case "kitty":
+					return rpc.NewPrincipal("kitty", "admin"), true
+				default:
+					return nil, true
+				}
+			},
+		)
+
+		userServer := server.NewServer().
+			SetNumOfThreads(1024).
+			SetAuthenticator(authenticator).
+			Listen("ws", "127.0.0.1:8766", nil).
+			AddService("user", rpc.NewService().
+				On("Get", func(rt rpc.Runtime) rpc.Return {
+					session, _ := rt.GetMetadata(rpc.MetadataKeySession)
+					return rt.Reply(rpc.Array{
+						rt.GetPrincipal().GetName(),
+						session != "",
+					})
+				}, "admin"), nil)
+		go func() {
+			userServer.Open()
+		}()
+		defer userServer.Close()
+
+		for !userServer.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		remoteHub := NewRemoteHub().
+			SetCredentials(rpc.Map{"token": "node"}).
+			AddRemote("ws", "127.0.0.1:8766", nil, "#.user")
+		defer remoteHub.Close()
+
+		proxyServer := server.NewServer().
+			SetNumOfThreads(1024).
+			SetAuthenticator(authenticator).
+			SetRemoteHub(remoteHub).
+			Listen("ws", "127.0.0.1:8767", nil).
+			AddService("proxy", rpc.NewService().
+				On("Get", func(rt rpc.Runtime) rpc.Return {
+					return rt.Reply(rt.Call("#.user:Get"))
+				}), nil)
+		go func() {
+			proxyServer.Open()
+		}()
+		defer proxyServer.Close()
+
+		for !proxyServer.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		kittyClient := client.DialWithCredentials(
+			"ws", "127.0.0.1:8767", rpc.Map{"token": "kitty"},
+		)
+		defer kittyClient.Close()
+		assert(kittyClient.Send(3*time.Second, "#.proxy:Get")).
+			Equal(rpc.Array{"kitty", true}, nil)
+
+		// the anonymous caller does not get the role of the proxy node
+		anonymousClient := client.Dial("ws", "127.0.0.1:8767")
+		defer anonymousClient.Close()
+		_, err := anonymousClient.Send(3*time.Second, "#.proxy:Get")
+		assert(err.GetCode()).Equal(base.ErrActionForbidden.GetCode())
+	})
+}
+
+func TestRemoteHub_Close(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath, clean := makeTestRegistryFile(`{"nodes": []}`)
+		defer clean()
+		registry := NewFileRegistry(filePath)
+		v := NewRemoteHub().
+			AddRemote("ws", "127.0.0.1:8766", nil, "#.user").
+			SetRegistry(registry, nil)
+		v.Close()
+		assert(v.nodes).Equal(make([]*remoteNode, 0))
+		assert(v.registry).IsNil()
+		assert(registry.Close()).IsFalse()
+	})
+}
@@ -1 +1,625 @@
+// Package router ...
 package router
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/adapter"
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+const (
+	routerReadBufferSize  = 1024
+	routerWriteBufferSize = 1024
+)
+
+// routerSessionKey identifies a session of a gateway
+type routerSessionKey struct {
+	gatewayID uint64
+	sessionID uint64
+}
+
+// routerCallKey identifies a call of a session
+type routerCallKey struct {
+	gatewayID  uint64
+	sessionID  uint64
+	callbackID uint64
+}
+
+// routerQuery is a session query of a worker that waits for the answer of
+// the gateway
+type routerQuery struct {
+	gatewayID  uint64
+	callbackID uint64
+	workerConn *adapter.StreamConn
+}
+
+// Router forwards the streams between the gateway nodes and the worker nodes,
+// so they can be scaled independently. A session sticks to its worker until it
+// is closed or the worker leaves, and a call sticks to the worker that
+// evaluates it. The replies go back to the gateway by the gateway id in the
+// stream header. The gateway nodes and the worker nodes are accepted only if
+// they know the secret of the router
+type Router struct {
+	isRunning    bool
+	secret       string
+	adapters     []*adapter.Adapter
+	gatewayMap   map[uint64]*adapter.StreamConn
+	gatewayConns map[*adapter.StreamConn]uint64
+	workerConns  []*adapter.StreamConn
+	sessionMap   map[routerSessionKey]*adapter.StreamConn
+	callMap      map[routerCallKey]*adapter.StreamConn
+	querySeed    uint64
+	queryMap     map[uint64]routerQuery
+	logHub       rpc.IStreamHub
+	orcManager   *base.ORCManager
+	sync.Mutex
+}
+
+// NewRouter ...
+func NewRouter() *Router {
+	return &Router{
+		isRunning:    false,
+		secret:       "",
+		adapters:     make([]*adapter.Adapter, 0),
+		gatewayMap:   make(map[uint64]*adapter.StreamConn),
+		gatewayConns: make(map[*adapter.StreamConn]uint64),
+		workerConns:  make([]*adapter.StreamConn, 0),
+		sessionMap:   make(map[routerSessionKey]*adapter.StreamConn),
+		callMap:      make(map[routerCallKey]*adapter.StreamConn),
+		querySeed:    0,
+		queryMap:     make(map[uint64]routerQuery),
+		logHub:       rpc.NewLogToScreenErrorStreamHub("Router"),
+		orcManager:   base.NewORCManager(),
+	}
+}
+
+// Listen ...
+func (p *Router) Listen(
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+) *Router {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isRunning {
+		p.adapters = append(p.adapters, adapter.NewServerAdapter(
+			false,
+			network,
+			addr,
+			tlsConfig,
+			routerReadBufferSize,
+			routerWriteBufferSize,
+			p,
+		))
+	} else {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	}
+
+	return p
+}
+
+// SetSecret sets the secret that the gateway nodes and the worker nodes must
+// send when they connect. The router can not be opened without it
+func (p *Router) SetSecret(secret string) *Router {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isRunning {
+		p.secret = secret
+	} else {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	}
+
+	return p
+}
+
+// SetLogHub ...
+func (p *Router) SetLogHub(logHub rpc.IStreamHub) *Router {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isRunning {
+		p.logHub = logHub
+	} else {
+		p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrRouterAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	}
+
+	return p
+}
+
+// Open runs the router until it is closed
+func (p *Router) Open() bool {
+	source := base.GetFileLine(1)
+
+	if !p.orcManager.Open(func() bool {
+		p.Lock()
+		defer p.Unlock()
+
+		if p.isRunning {
+			p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+				base.ErrRouterAlreadyRunning.AddDebug(source),
+			))
+			return false
+		} else if len(p.adapters) <= 0 {
+			p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+				base.ErrRouterNoAvailableAdapter.AddDebug(source),
+			))
+			return false
+		} else if p.secret == "" {
+			p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(
+				base.ErrRouterNoSecret.AddDebug(source),
+			))
+			return false
+		} else {
+			p.isRunning = true
+			return true
+		}
+	}) {
+		return false
+	}
+
+	return p.orcManager.Run(func(isRunning func() bool) bool {
+		waitCH := make(chan bool)
+
+		for _, item := range p.adapters {
+			item.Open()
+			go func(adapter *adapter.Adapter) {
+				adapter.Run()
+				waitCH <- true
+			}(item)
+		}
+
+		for isRunning() {
+			startNS := base.TimeNow().UnixNano()
+			base.WaitAtLeastDurationWhenRunning(startNS, isRunning, time.Second)
+		}
+
+		for range p.adapters {
+			<-waitCH
+		}
+
+		return true
+	})
+}
+
+// IsRunning ...
+func (p *Router) IsRunning() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.isRunning
+}
+
+// Close ...
+func (p *Router) Close() bool {
+	return p.orcManager.Close(func() bool {
+		for _, item := range p.adapters {
+			item.Close()
+		}
+		return true
+	}, func() {
+		p.Lock()
+		defer p.Unlock()
+		p.isRunning = false
+	})
+}
+
+func (p *Router) isWorkerConn(streamConn *adapter.StreamConn) bool {
+	for _, conn := range p.workerConns {
+		if conn == streamConn {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Router) onConnect(
+	streamConn *adapter.StreamConn,
+	stream *rpc.Stream,
+) {
+	defer stream.Release()
+
+	if stream.GetKind() != rpc.StreamKindConnectRequest {
+		p.OnConnError(streamConn, base.ErrStream)
+	} else if isGateway, err := stream.ReadBool(); err != nil {
+		p.OnConnError(streamConn, err)
+	} else if gatewayID, err := stream.ReadUint64(); err != nil {
+		p.OnConnError(streamConn, err)
+	} else if secret, err := stream.ReadString(); err != nil {
+		p.OnConnError(streamConn, err)
+	} else if !stream.IsReadFinish() {
+		p.OnConnError(streamConn, base.ErrStream)
+	} else if err := p.addConn(
+		streamConn,
+		isGateway,
+		gatewayID,
+		secret,
+	); err != nil {
+		p.OnConnError(streamConn, err)
+	}
+}
+
+// addConn registers the connection if it knows the secret
+func (p *Router) addConn(
+	streamConn *adapter.StreamConn,
+	isGateway bool,
+	gatewayID uint64,
+	secret string,
+) *base.Error {
+	p.Lock()
+	defer p.Unlock()
+
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(p.secret)) != 1 {
+		return base.ErrRouterSecretNotMatch
+	} else if !isGateway {
+		p.workerConns = append(p.workerConns, streamConn)
+		return nil
+	} else if _, ok := p.gatewayMap[gatewayID]; ok {
+		return base.ErrRouterGatewayConflict.AddDebug(
+			fmt.Sprintf("gateway id(%d)", gatewayID),
+		)
+	} else {
+		p.gatewayMap[gatewayID] = streamConn
+		p.gatewayConns[streamConn] = gatewayID
+		return nil
+	}
+}
+
+func (p *Router) getGatewayConn(gatewayID uint64) *adapter.StreamConn {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.gatewayMap[gatewayID]
+}
+
+// getSessionWorker returns the worker of the session. The session is assigned
+// to a worker if it has none
+func (p *Router) getSessionWorker(key routerSessionKey) *adapter.StreamConn {
+	if conn, ok := p.sessionMap[key]; ok {
+		return conn
+	} else if numOfWorkers := uint64(len(p.workerConns)); numOfWorkers > 0 {
+		conn = p.workerConns[(key.gatewayID+key.sessionID)%numOfWorkers]
+		p.sessionMap[key] = conn
+		return conn
+	} else {
+		return nil
+	}
+}
+
+func getCallKey(stream *rpc.Stream) routerCallKey {
+	return routerCallKey{
+		gatewayID:  stream.GetGatewayID(),
+		sessionID:  stream.GetSessionID(),
+		callbackID: stream.GetCallbackID(),
+	}
+}
+
+// startCall returns the worker that evaluates the request, and keeps the
+// call on it until the call finishes
+func (p *Router) startCall(stream *rpc.Stream) *adapter.StreamConn {
+	p.Lock()
+	defer p.Unlock()
+
+	ret := p.getSessionWorker(routerSessionKey{
+		gatewayID: stream.GetGatewayID(),
+		sessionID: stream.GetSessionID(),
+	})
+
+	if ret != nil {
+		p.callMap[getCallKey(stream)] = ret
+	}
+
+	return ret
+}
+
+// getCallWorker returns the worker that evaluates the call
+func (p *Router) getCallWorker(stream *rpc.Stream) *adapter.StreamConn {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.callMap[getCallKey(stream)]
+}
+
+func (p *Router) finishCall(stream *rpc.Stream) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.callMap, getCallKey(stream))
+}
+
+// getEventWorker returns the worker of the session event. The session is
+// released from its worker when it is closed
+func (p *Router) getEventWorker(stream *rpc.Stream) *adapter.StreamConn {
+	isClose := false
+	if name, err := stream.ReadString(); err == nil {
+		isClose = name == rpc.SessionEventClose
+	}
+	stream.SetReadPosToBodyStart()
+
+	p.Lock()
+	defer p.Unlock()
+
+	key := routerSessionKey{
+		gatewayID: stream.GetGatewayID(),
+		sessionID: stream.GetSessionID(),
+	}
+	ret := p.getSessionWorker(key)
+
+	if isClose {
+		delete(p.sessionMap, key)
+	}
+
+	return ret
+}
+
+// startQuery replaces the callback id of the session query by an id that is
+// unique on the router, so the answer goes back to the worker that asks
+func (p *Router) startQuery(
+	workerConn *adapter.StreamConn,
+	stream *rpc.Stream,
+) *adapter.StreamConn {
+	p.Lock()
+	defer p.Unlock()
+
+	ret, ok := p.gatewayMap[stream.GetGatewayID()]
+	if ok {
+		p.querySeed++
+		p.queryMap[p.querySeed] = routerQuery{
+			gatewayID:  stream.GetGatewayID(),
+			callbackID: stream.GetCallbackID(),
+			workerConn: workerConn,
+		}
+		stream.SetCallbackID(p.querySeed)
+	}
+
+	return ret
+}
+
+// finishQuery returns the worker that waits for the answer, and restores the
+// callback id of the query
+func (p *Router) finishQuery(stream *rpc.Stream) *adapter.StreamConn {
+	p.Lock()
+	defer p.Unlock()
+
+	if query, ok := p.queryMap[stream.GetCallbackID()]; !ok ||
+		query.gatewayID != stream.GetGatewayID() {
+		return nil
+	} else {
+		delete(p.queryMap, stream.GetCallbackID())
+		stream.SetCallbackID(query.callbackID)
+		return query.workerConn
+	}
+}
+
+// onGatewayStream sends the stream to the worker of the session
+func (p *Router) onGatewayStream(gatewayID uint64, stream *rpc.Stream) {
+	// the gateway can only send the streams of its own sessions
+	stream.SetGatewayID(gatewayID)
+
+	workerConn := (*adapter.StreamConn)(nil)
+
+	switch stream.GetKind() {
+	case rpc.StreamKindRPCRequest:
+		workerConn = p.startCall(stream)
+	case rpc.StreamKindRPCCancel:
+		workerConn = p.getCallWorker(stream)
+	case rpc.StreamKindSessionEvent:
+		workerConn = p.getEventWorker(stream)
+	case rpc.StreamKindSessionAttribute:
+		fallthrough
+	case rpc.StreamKindSessionPrincipal:
+		workerConn = p.finishQuery(stream)
+	default:
+		p.OnConnError(p.getGatewayConn(gatewayID), base.ErrStream)
+		stream.Release()
+		return
+	}
+
+	if workerConn != nil {
+		workerConn.WriteStreamAndRelease(stream)
+	} else if stream.GetKind() == rpc.StreamKindRPCRequest {
+		if conn := p.getGatewayConn(gatewayID); conn != nil {
+			conn.WriteStreamAndRelease(
+				makeErrorStream(getCallKey(stream), base.ErrRouterNoProcessor),
+			)
+		}
+		stream.Release()
+	} else {
+		stream.Release()
+	}
+}
+
+// onWorkerStream sends the stream to the gateway of the session
+func (p *Router) onWorkerStream(
+	streamConn *adapter.StreamConn,
+	stream *rpc.Stream,
+) {
+	gatewayConn := (*adapter.StreamConn)(nil)
+
+	switch stream.GetKind() {
+	case rpc.StreamKindRPCResponseOK:
+		fallthrough
+	case rpc.StreamKindRPCResponseError:
+		p.finishCall(stream)
+		gatewayConn = p.getGatewayConn(stream.GetGatewayID())
+	case rpc.StreamKindRPCResponseChunk:
+		fallthrough
+	case rpc.StreamKindRPCBoardCast:
+		gatewayConn = p.getGatewayConn(stream.GetGatewayID())
+	case rpc.StreamKindSessionAttribute:
+		fallthrough
+	case rpc.StreamKindSessionPrincipal:
+		gatewayConn = p.startQuery(streamConn, stream)
+	case rpc.StreamKindTopicPublish:
+		p.Lock()
+		gatewayConns := make([]*adapter.StreamConn, 0, len(p.gatewayMap))
+		for _, conn := range p.gatewayMap {
+			gatewayConns = append(gatewayConns, conn)
+		}
+		p.Unlock()
+
+		for _, conn := range gatewayConns {
+			conn.WriteStreamAndRelease(stream.Clone())
+		}
+		stream.Release()
+		return
+	case rpc.StreamKindSystemErrorReport:
+		p.logHub.OnReceiveStream(stream)
+		return
+	default:
+		p.OnConnError(streamConn, base.ErrStream)
+		stream.Release()
+		return
+	}
+
+	if gatewayConn != nil {
+		gatewayConn.WriteStreamAndRelease(stream)
+	} else {
+		stream.Release()
+	}
+}
+
+// makeErrorStream makes the error reply of the call
+func makeErrorStream(key routerCallKey, err *base.Error) *rpc.Stream {
+	ret := rpc.NewStream()
+	ret.SetKind(rpc.StreamKindRPCResponseError)
+	ret.SetGatewayID(key.gatewayID)
+	ret.SetSessionID(key.sessionID)
+	ret.SetCallbackID(key.callbackID)
+	ret.WriteUint64(uint64(err.GetCode()))
+	ret.WriteString(err.GetMessage())
+	return ret
+}
+
+// OnConnOpen ...
+func (p *Router) OnConnOpen(_ *adapter.StreamConn) {
+	// ignore
+	// the slot is registered by the connect request
+}
+
+// OnConnReadStream ...
+func (p *Router) OnConnReadStream(
+	streamConn *adapter.StreamConn,
+	stream *rpc.Stream,
+) {
+	p.Lock()
+	gatewayID, isGateway := p.gatewayConns[streamConn]
+	isWorker := p.isWorkerConn(streamConn)
+	p.Unlock()
+
+	if isGateway {
+		p.onGatewayStream(gatewayID, stream)
+	} else if isWorker {
+		p.onWorkerStream(streamConn, stream)
+	} else {
+		p.onConnect(streamConn, stream)
+	}
+}
+
+// OnConnError ...
+func (p *Router) OnConnError(streamConn *adapter.StreamConn, err *base.Error) {
+	p.logHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+
+	if streamConn != nil {
+		streamConn.Close()
+	}
+}
+
+// OnConnClose ...
+func (p *Router) OnConnClose(streamConn *adapter.StreamConn) {
+	// the calls on the worker can not be finished any more
+	for gatewayConn, streams := range p.removeConn(streamConn) {
+		for _, stream := range streams {
+			gatewayConn.WriteStreamAndRelease(stream)
+		}
+	}
+}
+
+// removeConn unregisters the connection. It returns the error replies of the
+// calls that are evaluated by the connection if it is a worker
+func (p *Router) removeConn(
+	streamConn *adapter.StreamConn,
+) map[*adapter.StreamConn][]*rpc.Stream {
+	p.Lock()
+	defer p.Unlock()
+
+	if gatewayID, ok := p.gatewayConns[streamConn]; ok {
+		p.removeGateway(streamConn, gatewayID)
+	}
+
+	for i, conn := range p.workerConns {
+		if conn == streamConn {
+			p.workerConns = append(p.workerConns[:i], p.workerConns[i+1:]...)
+			return p.removeWorker(streamConn)
+		}
+	}
+
+	return nil
+}
+
+func (p *Router) removeGateway(
+	streamConn *adapter.StreamConn,
+	gatewayID uint64,
+) {
+	delete(p.gatewayConns, streamConn)
+	delete(p.gatewayMap, gatewayID)
+
+	for key := range p.sessionMap {
+		if key.gatewayID == gatewayID {
+			delete(p.sessionMap, key)
+		}
+	}
+
+	for id, query := range p.queryMap {
+		if query.gatewayID == gatewayID {
+			delete(p.queryMap, id)
+		}
+	}
+}
+
+func (p *Router) removeWorker(
+	streamConn *adapter.StreamConn,
+) map[*adapter.StreamConn][]*rpc.Stream {
+	ret := make(map[*adapter.StreamConn][]*rpc.Stream)
+
+	// the sessions are assigned to the other workers by their next streams
+	for key, conn := range p.sessionMap {
+		if conn == streamConn {
+			delete(p.sessionMap, key)
+		}
+	}
+
+	for id, query := range p.queryMap {
+		if query.workerConn == streamConn {
+			delete(p.queryMap, id)
+		}
+	}
+
+	for key, conn := range p.callMap {
+		if conn == streamConn {
+			delete(p.callMap, key)
+			if gatewayConn, ok := p.gatewayMap[key.gatewayID]; ok {
+				ret[gatewayConn] = append(
+					ret[gatewayConn],
+					makeErrorStream(key, base.ErrRouterProcessorLost),
+				)
+			}
+		}
+	}
+
+	return ret
+}
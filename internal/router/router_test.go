@@ -4,8 +4,86 @@ import (
 	"fmt"
 	"net"
 	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/adapter"
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
 )
 
+type testNetConn struct {
+	writeCH   chan []byte
+	isRunning bool
+}
+
+func newTestNetConn() *testNetConn {
+	return &testNetConn{
+		isRunning: true,
+		writeCH:   make(chan []byte, 1024),
+	}
+}
+
+func (p *testNetConn) Read(_ []byte) (n int, err error) {
+	panic("not implemented")
+}
+
+func (p *testNetConn) Write(b []byte) (n int, err error) {
+	buf := make([]byte, len(b))
+	copy(buf, b)
+	p.writeCH <- buf
+	return len(b), nil
+}
+
+func (p *testNetConn) Close() error {
+	p.isRunning = false
+	return nil
+}
+
+func (p *testNetConn) LocalAddr() net.Addr {
+	panic("not implemented")
+}
+
+func (p *testNetConn) RemoteAddr() net.Addr {
+	panic("not implemented")
+}
+
+func (p *testNetConn) SetDeadline(_ time.Time) error {
+	panic("not implemented")
+}
+
+func (p *testNetConn) SetReadDeadline(_ time.Time) error {
+	panic("not implemented")
+}
+
+func (p *testNetConn) SetWriteDeadline(_ time.Time) error {
+	panic("not implemented")
+}
+
+func newTestStreamConn(
+	receiver adapter.IReceiver,
+) (*adapter.StreamConn, *testNetConn) {
+	netConn := newTestNetConn()
+	syncConn := adapter.NewClientSyncConn(netConn, 1200, 1200)
+	streamConn := adapter.NewStreamConn(false, syncConn, receiver)
+	syncConn.SetNext(streamConn)
+	return streamConn, netConn
+}
+
+func readTestStream(netConn *testNetConn) *rpc.Stream {
+	stream := rpc.NewStream()
+	stream.PutBytesTo(<-netConn.writeCH, 0)
+	return stream
+}
+
+func makeTestConnectStream(isGateway bool, gatewayID uint64) *rpc.Stream {
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindConnectRequest)
+	stream.WriteBool(isGateway)
+	stream.WriteUint64(gatewayID)
+	stream.WriteString("secret")
+	return stream
+}
+
 func TestDebug(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		ln1, e := net.Listen("tcp", "127.0.0.1:50000")
@@ -15,3 +93,694 @@ func TestDebug(t *testing.T) {
 		fmt.Println(ln2, e)
 	})
 }
+
+func TestRouterBasic(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(routerReadBufferSize).Equal(1024)
+		assert(routerWriteBufferSize).Equal(1024)
+	})
+}
+
+func TestNewRouter(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter()
+		assert(v.isRunning).IsFalse()
+		assert(v.secret).Equal("")
+		assert(len(v.adapters)).Equal(0)
+		assert(len(v.gatewayMap)).Equal(0)
+		assert(len(v.gatewayConns)).Equal(0)
+		assert(len(v.workerConns)).Equal(0)
+		assert(len(v.sessionMap)).Equal(0)
+		assert(len(v.callMap)).Equal(0)
+		assert(v.querySeed).Equal(uint64(0))
+		assert(len(v.queryMap)).Equal(0)
+		assert(v.logHub).IsNotNil()
+		assert(v.orcManager).IsNotNil()
+	})
+}
+
+func TestRouter_Listen(t *testing.T) {
+	t.Run("router is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter()
+		v.logHub = logHub
+		v.isRunning = true
+		_, source := v.Listen("tcp", "127.0.0.1:8780", nil), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(len(v.adapters)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter()
+		assert(v.Listen("tcp", "127.0.0.1:8780", nil)).Equal(v)
+		assert(len(v.adapters)).Equal(1)
+	})
+}
+
+func TestRouter_SetSecret(t *testing.T) {
+	t.Run("router is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter()
+		v.logHub = logHub
+		v.isRunning = true
+		_, source := v.SetSecret("secret"), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(v.secret).Equal("")
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter()
+		assert(v.SetSecret("secret")).Equal(v)
+		assert(v.secret).Equal("secret")
+	})
+}
+
+func TestRouter_SetLogHub(t *testing.T) {
+	t.Run("router is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter()
+		v.logHub = logHub
+		v.isRunning = true
+		_, source := v.SetLogHub(nil), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(v.logHub).Equal(logHub)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter()
+		assert(v.SetLogHub(logHub)).Equal(v)
+		assert(v.logHub).Equal(logHub)
+	})
+}
+
+func TestRouter_Open(t *testing.T) {
+	t.Run("router is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter()
+		v.logHub = logHub
+		v.isRunning = true
+		ret, source := v.Open(), base.GetFileLine(0)
+		assert(ret).IsFalse()
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterAlreadyRunning.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("no listener", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter()
+		v.logHub = logHub
+		ret, source := v.Open(), base.GetFileLine(0)
+		assert(ret).IsFalse()
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterNoAvailableAdapter.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("no secret", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().Listen("tcp", "127.0.0.1:8780", nil)
+		v.logHub = logHub
+		ret, source := v.Open(), base.GetFileLine(0)
+		assert(ret).IsFalse()
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterNoSecret.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().
+			SetSecret("secret").
+			Listen("tcp", "127.0.0.1:8780", nil)
+
+		go func() {
+			for !v.IsRunning() {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			v.Close()
+		}()
+
+		assert(v.Open()).IsTrue()
+	})
+}
+
+func TestRouter_onConnect(t *testing.T) {
+	t.Run("kind error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn, _ := newTestStreamConn(v)
+		stream := makeTestConnectStream(false, 0)
+		stream.SetKind(rpc.StreamKindPing)
+		v.onConnect(streamConn, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(v.workerConns)).Equal(0)
+	})
+
+	t.Run("read isGateway error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn, _ := newTestStreamConn(v)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		v.onConnect(streamConn, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("read gatewayID error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn, _ := newTestStreamConn(v)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteBool(true)
+		v.onConnect(streamConn, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("read secret error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn, _ := newTestStreamConn(v)
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindConnectRequest)
+		stream.WriteBool(true)
+		stream.WriteUint64(3)
+		v.onConnect(streamConn, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("secret does not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for _, isGateway := range []bool{true, false} {
+			logHub := rpc.NewTestStreamHub()
+			v := NewRouter().SetSecret("other").SetLogHub(logHub)
+			streamConn, _ := newTestStreamConn(v)
+			v.onConnect(streamConn, makeTestConnectStream(isGateway, 3))
+			assert(rpc.ParseResponseStream(logHub.GetStream())).
+				Equal(nil, base.ErrRouterSecretNotMatch)
+			assert(len(v.gatewayMap)).Equal(0)
+			assert(len(v.workerConns)).Equal(0)
+		}
+	})
+
+	t.Run("stream is not finish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn, _ := newTestStreamConn(v)
+		stream := makeTestConnectStream(true, 3)
+		stream.WriteBool(true)
+		v.onConnect(streamConn, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(len(v.gatewayMap)).Equal(0)
+	})
+
+	t.Run("worker", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		streamConn, _ := newTestStreamConn(v)
+		v.onConnect(streamConn, makeTestConnectStream(false, 0))
+		assert(v.workerConns).Equal([]*adapter.StreamConn{streamConn})
+	})
+
+	t.Run("gateway id conflicts", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn1, _ := newTestStreamConn(v)
+		streamConn2, _ := newTestStreamConn(v)
+		v.onConnect(streamConn1, makeTestConnectStream(true, 3))
+		v.onConnect(streamConn2, makeTestConnectStream(true, 3))
+		assert(rpc.ParseResponseStream(logHub.GetStream())).Equal(
+			nil, base.ErrRouterGatewayConflict.AddDebug("gateway id(3)").Standardize(),
+		)
+		assert(v.gatewayMap).Equal(map[uint64]*adapter.StreamConn{
+			3: streamConn1,
+		})
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		streamConn, _ := newTestStreamConn(v)
+		v.onConnect(streamConn, makeTestConnectStream(true, 3))
+		assert(v.gatewayMap).Equal(map[uint64]*adapter.StreamConn{
+			3: streamConn,
+		})
+		assert(v.gatewayConns).Equal(map[*adapter.StreamConn]uint64{
+			streamConn: 3,
+		})
+	})
+}
+
+func TestRouter_onGatewayStream(t *testing.T) {
+	t.Run("the session sticks to its worker", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, _ := newTestStreamConn(v)
+		workerConn1, netConn1 := newTestStreamConn(v)
+		workerConn2, netConn2 := newTestStreamConn(v)
+		workerConn3, netConn3 := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn1, makeTestConnectStream(false, 0))
+		v.onConnect(workerConn2, makeTestConnectStream(false, 0))
+
+		for _, sessionID := range []uint64{10, 11} {
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindRPCRequest)
+			stream.SetGatewayID(7)
+			stream.SetSessionID(sessionID)
+			stream.SetCallbackID(15)
+			v.onGatewayStream(3, stream)
+		}
+
+		// (3 + 10) % 2 == 1, (3 + 11) % 2 == 0
+		for _, netConn := range []*testNetConn{netConn2, netConn1} {
+			stream := readTestStream(netConn)
+			assert(stream.GetKind()).Equal(uint8(rpc.StreamKindRPCRequest))
+			assert(stream.GetGatewayID()).Equal(uint64(3))
+			assert(stream.GetCallbackID()).Equal(uint64(15))
+		}
+
+		// the assignment is kept when a worker joins
+		v.onConnect(workerConn3, makeTestConnectStream(false, 0))
+		for _, kind := range []uint8{
+			rpc.StreamKindRPCRequest,
+			rpc.StreamKindRPCCancel,
+			rpc.StreamKindSessionEvent,
+		} {
+			stream := rpc.NewStream()
+			stream.SetKind(kind)
+			stream.SetSessionID(10)
+			stream.SetCallbackID(15)
+			v.onGatewayStream(3, stream)
+			assert(readTestStream(netConn2).GetKind()).Equal(kind)
+		}
+		assert(len(netConn1.writeCH)).Equal(0)
+		assert(len(netConn3.writeCH)).Equal(0)
+	})
+
+	t.Run("cancel goes to the worker of the call", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, _ := newTestStreamConn(v)
+		workerConn1, netConn1 := newTestStreamConn(v)
+		workerConn2, netConn2 := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn1, makeTestConnectStream(false, 0))
+		v.callMap[routerCallKey{
+			gatewayID:  3,
+			sessionID:  10,
+			callbackID: 15,
+		}] = workerConn1
+		v.onConnect(workerConn2, makeTestConnectStream(false, 0))
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCCancel)
+		stream.SetSessionID(10)
+		stream.SetCallbackID(15)
+		v.onGatewayStream(3, stream)
+		assert(readTestStream(netConn1).GetKind()).
+			Equal(uint8(rpc.StreamKindRPCCancel))
+
+		// the call is not known
+		stream = rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCCancel)
+		stream.SetSessionID(10)
+		stream.SetCallbackID(16)
+		v.onGatewayStream(3, stream)
+		assert(len(netConn1.writeCH)).Equal(0)
+		assert(len(netConn2.writeCH)).Equal(0)
+	})
+
+	t.Run("session is released when it is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, _ := newTestStreamConn(v)
+		workerConn, netConn := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+
+		for _, event := range []string{
+			rpc.SessionEventOpen,
+			rpc.SessionEventClose,
+		} {
+			stream := rpc.NewStream()
+			stream.SetKind(rpc.StreamKindSessionEvent)
+			stream.SetSessionID(10)
+			stream.WriteString(event)
+			v.onGatewayStream(3, stream)
+			assert(readTestStream(netConn).ReadString()).Equal(event, nil)
+		}
+		assert(len(v.sessionMap)).Equal(0)
+	})
+
+	t.Run("query answer goes to the worker that asks", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn1, gatewayNetConn := newTestStreamConn(v)
+		gatewayConn2, _ := newTestStreamConn(v)
+		workerConn1, netConn1 := newTestStreamConn(v)
+		workerConn2, netConn2 := newTestStreamConn(v)
+		v.onConnect(gatewayConn1, makeTestConnectStream(true, 3))
+		v.onConnect(gatewayConn2, makeTestConnectStream(true, 4))
+		v.onConnect(workerConn1, makeTestConnectStream(false, 0))
+		v.onConnect(workerConn2, makeTestConnectStream(false, 0))
+
+		for _, kind := range []uint8{
+			rpc.StreamKindSessionAttribute,
+			rpc.StreamKindSessionPrincipal,
+		} {
+			// (3 + 10) % 2 == 1, but workerConn1 asks
+			query := rpc.NewStream()
+			query.SetKind(kind)
+			query.SetGatewayID(3)
+			query.SetSessionID(10)
+			query.SetCallbackID(7)
+			v.onWorkerStream(workerConn1, query)
+			query = readTestStream(gatewayNetConn)
+			assert(query.GetKind()).Equal(kind)
+			assert(query.GetCallbackID()).Equal(v.querySeed)
+
+			// the answer from the other gateway is dropped
+			answer := query.Clone()
+			v.onGatewayStream(4, answer)
+			assert(len(v.queryMap)).Equal(1)
+
+			v.onGatewayStream(3, query)
+			answer = readTestStream(netConn1)
+			assert(answer.GetKind()).Equal(kind)
+			assert(answer.GetCallbackID()).Equal(uint64(7))
+			assert(len(v.queryMap)).Equal(0)
+
+			// the query is answered
+			v.onGatewayStream(3, answer)
+		}
+		assert(len(netConn1.writeCH)).Equal(0)
+		assert(len(netConn2.writeCH)).Equal(0)
+	})
+
+	t.Run("request with no worker", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, netConn := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetSessionID(10)
+		stream.SetCallbackID(15)
+		v.onGatewayStream(3, stream)
+
+		backStream := readTestStream(netConn)
+		assert(backStream.GetGatewayID()).Equal(uint64(3))
+		assert(backStream.GetSessionID()).Equal(uint64(10))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+		assert(rpc.ParseResponseStream(backStream)).
+			Equal(nil, base.ErrRouterNoProcessor)
+	})
+
+	t.Run("cancel with no worker", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, netConn := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCCancel)
+		v.onGatewayStream(3, stream)
+		assert(len(netConn.writeCH)).Equal(0)
+	})
+
+	t.Run("kind error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		gatewayConn, netConn := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseOK)
+		v.onGatewayStream(3, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(netConn.isRunning).IsFalse()
+	})
+}
+
+func TestRouter_onWorkerStream(t *testing.T) {
+	t.Run("send to the gateway", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, netConn := newTestStreamConn(v)
+		workerConn, _ := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+
+		for _, kind := range []uint8{
+			rpc.StreamKindRPCResponseChunk,
+			rpc.StreamKindRPCBoardCast,
+			rpc.StreamKindRPCResponseOK,
+			rpc.StreamKindRPCResponseError,
+		} {
+			v.callMap[routerCallKey{
+				gatewayID:  3,
+				sessionID:  10,
+				callbackID: 15,
+			}] = workerConn
+			stream := rpc.NewStream()
+			stream.SetKind(kind)
+			stream.SetGatewayID(3)
+			stream.SetSessionID(10)
+			stream.SetCallbackID(15)
+			v.onWorkerStream(workerConn, stream)
+			backStream := readTestStream(netConn)
+			assert(backStream.GetKind()).Equal(kind)
+			assert(backStream.GetSessionID()).Equal(uint64(10))
+			// the call is finished by the final reply
+			assert(len(v.callMap)).Equal(
+				map[uint8]int{
+					rpc.StreamKindRPCResponseChunk: 1,
+					rpc.StreamKindRPCBoardCast:     1,
+				}[kind],
+			)
+		}
+	})
+
+	t.Run("gateway is not connected", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, netConn := newTestStreamConn(v)
+		workerConn, _ := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+		for _, kind := range []uint8{
+			rpc.StreamKindRPCResponseOK,
+			rpc.StreamKindSessionAttribute,
+		} {
+			stream := rpc.NewStream()
+			stream.SetKind(kind)
+			stream.SetGatewayID(4)
+			v.onWorkerStream(workerConn, stream)
+		}
+		assert(len(netConn.writeCH)).Equal(0)
+		assert(len(v.queryMap)).Equal(0)
+	})
+
+	t.Run("topic publish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn1, netConn1 := newTestStreamConn(v)
+		gatewayConn2, netConn2 := newTestStreamConn(v)
+		workerConn, _ := newTestStreamConn(v)
+		v.onConnect(gatewayConn1, makeTestConnectStream(true, 3))
+		v.onConnect(gatewayConn2, makeTestConnectStream(true, 4))
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteString("news")
+		v.onWorkerStream(workerConn, stream)
+
+		for _, netConn := range []*testNetConn{netConn1, netConn2} {
+			backStream := readTestStream(netConn)
+			assert(backStream.GetKind()).
+				Equal(uint8(rpc.StreamKindTopicPublish))
+			assert(backStream.ReadString()).Equal("news", nil)
+		}
+	})
+
+	t.Run("system error report", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		workerConn, _ := newTestStreamConn(v)
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+		v.onWorkerStream(workerConn, rpc.MakeSystemErrorStream(base.ErrStream))
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("kind error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		workerConn, netConn := newTestStreamConn(v)
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		v.onWorkerStream(workerConn, stream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(netConn.isRunning).IsFalse()
+	})
+}
+
+func TestRouter_OnConnReadStream(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, gatewayNetConn := newTestStreamConn(v)
+		workerConn, workerNetConn := newTestStreamConn(v)
+		v.OnConnReadStream(gatewayConn, makeTestConnectStream(true, 3))
+		v.OnConnReadStream(workerConn, makeTestConnectStream(false, 0))
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		v.OnConnReadStream(gatewayConn, stream)
+		assert(readTestStream(workerNetConn).GetKind()).
+			Equal(uint8(rpc.StreamKindRPCRequest))
+
+		stream = rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseOK)
+		stream.SetGatewayID(3)
+		v.OnConnReadStream(workerConn, stream)
+		assert(readTestStream(gatewayNetConn).GetKind()).
+			Equal(uint8(rpc.StreamKindRPCResponseOK))
+	})
+}
+
+func TestRouter_OnConnError(t *testing.T) {
+	t.Run("streamConn is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		v.OnConnError(nil, base.ErrStream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		logHub := rpc.NewTestStreamHub()
+		v := NewRouter().SetSecret("secret").SetLogHub(logHub)
+		streamConn, netConn := newTestStreamConn(v)
+		v.OnConnError(streamConn, base.ErrStream)
+		assert(rpc.ParseResponseStream(logHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(netConn.isRunning).IsFalse()
+	})
+}
+
+func TestRouter_OnConnClose(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, _ := newTestStreamConn(v)
+		workerConn1, _ := newTestStreamConn(v)
+		workerConn2, _ := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn1, makeTestConnectStream(false, 0))
+		v.onConnect(workerConn2, makeTestConnectStream(false, 0))
+
+		v.OnConnClose(gatewayConn)
+		assert(len(v.gatewayMap)).Equal(0)
+		assert(len(v.gatewayConns)).Equal(0)
+
+		v.OnConnClose(workerConn1)
+		assert(v.workerConns).Equal([]*adapter.StreamConn{workerConn2})
+	})
+
+	t.Run("gateway is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn1, _ := newTestStreamConn(v)
+		gatewayConn2, _ := newTestStreamConn(v)
+		workerConn, _ := newTestStreamConn(v)
+		v.onConnect(gatewayConn1, makeTestConnectStream(true, 3))
+		v.onConnect(gatewayConn2, makeTestConnectStream(true, 4))
+		v.onConnect(workerConn, makeTestConnectStream(false, 0))
+		v.sessionMap[routerSessionKey{gatewayID: 3, sessionID: 10}] = workerConn
+		v.sessionMap[routerSessionKey{gatewayID: 4, sessionID: 10}] = workerConn
+		v.queryMap[1] = routerQuery{gatewayID: 3, workerConn: workerConn}
+		v.queryMap[2] = routerQuery{gatewayID: 4, workerConn: workerConn}
+
+		v.OnConnClose(gatewayConn1)
+		assert(v.sessionMap).Equal(map[routerSessionKey]*adapter.StreamConn{
+			{gatewayID: 4, sessionID: 10}: workerConn,
+		})
+		assert(v.queryMap).Equal(map[uint64]routerQuery{
+			2: {gatewayID: 4, workerConn: workerConn},
+		})
+	})
+
+	t.Run("worker is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewRouter().SetSecret("secret")
+		gatewayConn, gatewayNetConn := newTestStreamConn(v)
+		workerConn1, _ := newTestStreamConn(v)
+		workerConn2, netConn2 := newTestStreamConn(v)
+		v.onConnect(gatewayConn, makeTestConnectStream(true, 3))
+		v.onConnect(workerConn1, makeTestConnectStream(false, 0))
+		v.onConnect(workerConn2, makeTestConnectStream(false, 0))
+
+		// (3 + 10) % 2 == 1, so the session goes to workerConn2
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetSessionID(10)
+		stream.SetCallbackID(15)
+		v.onGatewayStream(3, stream)
+		readTestStream(netConn2)
+		v.queryMap[1] = routerQuery{gatewayID: 3, workerConn: workerConn2}
+		v.queryMap[2] = routerQuery{gatewayID: 3, workerConn: workerConn1}
+
+		// the calls on the worker fail
+		v.OnConnClose(workerConn2)
+		backStream := readTestStream(gatewayNetConn)
+		assert(backStream.GetSessionID()).Equal(uint64(10))
+		assert(backStream.GetCallbackID()).Equal(uint64(15))
+		assert(rpc.ParseResponseStream(backStream)).
+			Equal(nil, base.ErrRouterProcessorLost)
+		assert(len(v.sessionMap)).Equal(0)
+		assert(len(v.callMap)).Equal(0)
+		assert(v.queryMap).Equal(map[uint64]routerQuery{
+			2: {gatewayID: 3, workerConn: workerConn1},
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/rpccloud/rpc/internal/adapter"
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+const (
+	slotReadBufferSize  = 1024
+	slotWriteBufferSize = 1024
+)
+
+// slot connects a gateway node or a worker node to the router. The adapter
+// reconnects when the connection is broken, and the streams that are sent
+// without a connection are dropped
+type slot struct {
+	isGateway bool
+	gatewayID uint64
+	secret    string
+	adapter   *adapter.Adapter
+	conn      *adapter.StreamConn
+	onStream  func(stream *rpc.Stream)
+	errorHub  rpc.IStreamHub
+	sync.Mutex
+}
+
+func newSlot(
+	isGateway bool,
+	gatewayID uint64,
+	network string,
+	addr string,
+	tlsConfig *tls.Config,
+	onStream func(stream *rpc.Stream),
+	errorHub rpc.IStreamHub,
+) *slot {
+	ret := &slot{
+		isGateway: isGateway,
+		gatewayID: gatewayID,
+		secret:    "",
+		adapter:   nil,
+		conn:      nil,
+		onStream:  onStream,
+		errorHub:  errorHub,
+	}
+
+	ret.adapter = adapter.NewClientAdapter(
+		network,
+		addr,
+		tlsConfig,
+		slotReadBufferSize,
+		slotWriteBufferSize,
+		ret,
+	)
+
+	return ret
+}
+
+// setSecret sets the secret that the router checks when the slot connects
+func (p *slot) setSecret(secret string) {
+	p.Lock()
+	defer p.Unlock()
+	p.secret = secret
+}
+
+func (p *slot) open() {
+	p.adapter.Open()
+	go func() {
+		p.adapter.Run()
+	}()
+}
+
+func (p *slot) close() {
+	p.adapter.Close()
+}
+
+func (p *slot) send(stream *rpc.Stream) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conn != nil {
+		p.conn.WriteStreamAndRelease(stream)
+	} else {
+		stream.Release()
+	}
+}
+
+// OnConnOpen ...
+func (p *slot) OnConnOpen(streamConn *adapter.StreamConn) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.conn = streamConn
+
+	stream := rpc.NewStream()
+	stream.SetKind(rpc.StreamKindConnectRequest)
+	stream.WriteBool(p.isGateway)
+	stream.WriteUint64(p.gatewayID)
+	stream.WriteString(p.secret)
+	streamConn.WriteStreamAndRelease(stream)
+}
+
+// OnConnReadStream ...
+func (p *slot) OnConnReadStream(_ *adapter.StreamConn, stream *rpc.Stream) {
+	p.onStream(stream)
+}
+
+// OnConnError ...
+func (p *slot) OnConnError(streamConn *adapter.StreamConn, err *base.Error) {
+	p.errorHub.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+
+	if streamConn != nil {
+		streamConn.Close()
+	}
+}
+
+// OnConnClose ...
+func (p *slot) OnConnClose(_ *adapter.StreamConn) {
+	p.Lock()
+	defer p.Unlock()
+	p.conn = nil
+}
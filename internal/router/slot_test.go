@@ -0,0 +1,126 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/rpc"
+)
+
+func TestSlotBasic(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(slotReadBufferSize).Equal(1024)
+		assert(slotWriteBufferSize).Equal(1024)
+	})
+}
+
+func TestNewSlot(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := newSlot(true, 3, "tcp", "127.0.0.1:8781", nil, nil, errorHub)
+		assert(v.isGateway).IsTrue()
+		assert(v.gatewayID).Equal(uint64(3))
+		assert(v.secret).Equal("")
+		assert(v.adapter).IsNotNil()
+		assert(v.conn).IsNil()
+		assert(v.errorHub).Equal(errorHub)
+	})
+}
+
+func TestSlot_setSecret(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newSlot(true, 3, "tcp", "127.0.0.1:8781", nil, nil, nil)
+		v.setSecret("secret")
+		assert(v.secret).Equal("secret")
+	})
+}
+
+func TestSlot_send(t *testing.T) {
+	t.Run("no connection", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newSlot(true, 3, "tcp", "127.0.0.1:8781", nil, nil, nil)
+		stream := rpc.NewStream()
+		stream.WriteString("hello")
+		v.send(stream)
+		assert(v.conn).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newSlot(true, 3, "tcp", "127.0.0.1:8781", nil, nil, nil)
+		streamConn, netConn := newTestStreamConn(v)
+		v.conn = streamConn
+		stream := rpc.NewStream()
+		stream.WriteString("hello")
+		v.send(stream)
+		assert(readTestStream(netConn).ReadString()).Equal("hello", nil)
+	})
+}
+
+func TestSlot_OnConnOpen(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newSlot(true, 3, "tcp", "127.0.0.1:8781", nil, nil, nil)
+		v.setSecret("secret")
+		streamConn, netConn := newTestStreamConn(v)
+		v.OnConnOpen(streamConn)
+		assert(v.conn).Equal(streamConn)
+		stream := readTestStream(netConn)
+		assert(stream.GetKind()).Equal(uint8(rpc.StreamKindConnectRequest))
+		assert(stream.ReadBool()).Equal(true, nil)
+		assert(stream.ReadUint64()).Equal(uint64(3), nil)
+		assert(stream.ReadString()).Equal("secret", nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+}
+
+func TestSlot_OnConnReadStream(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamCH := make(chan *rpc.Stream, 1)
+		v := newSlot(false, 0, "tcp", "127.0.0.1:8781", nil, func(
+			stream *rpc.Stream,
+		) {
+			streamCH <- stream
+		}, nil)
+		stream := rpc.NewStream()
+		v.OnConnReadStream(nil, stream)
+		assert(<-streamCH).Equal(stream)
+	})
+}
+
+func TestSlot_OnConnError(t *testing.T) {
+	t.Run("streamConn is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := newSlot(false, 0, "tcp", "127.0.0.1:8781", nil, nil, errorHub)
+		v.OnConnError(nil, base.ErrStream)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := newSlot(false, 0, "tcp", "127.0.0.1:8781", nil, nil, errorHub)
+		streamConn, netConn := newTestStreamConn(v)
+		v.OnConnError(streamConn, base.ErrStream)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).
+			Equal(nil, base.ErrStream)
+		assert(netConn.isRunning).IsFalse()
+	})
+}
+
+func TestSlot_OnConnClose(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newSlot(false, 0, "tcp", "127.0.0.1:8781", nil, nil, nil)
+		streamConn, _ := newTestStreamConn(v)
+		v.OnConnOpen(streamConn)
+		v.OnConnClose(streamConn)
+		assert(v.conn).IsNil()
+	})
+}
@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+const (
+	// CompressionFlate ...
+	CompressionFlate = "flate"
+	// CompressionGzip ...
+	CompressionGzip = "gzip"
+)
+
+// GetCompressions returns the codecs that the streams can be compressed with,
+// in the order of preference
+func GetCompressions() []string {
+	return []string{CompressionFlate, CompressionGzip}
+}
+
+// IsCompressionSupported ...
+func IsCompressionSupported(compression string) bool {
+	return compression == CompressionFlate || compression == CompressionGzip
+}
+
+// the writers and the readers allocate large buffers, so they are pooled
+var (
+	flateWriterCache = &base.SyncPool{
+		New: func() interface{} {
+			// the error is only returned if the level is not correct
+			ret, _ := flate.NewWriter(nil, flate.DefaultCompression)
+			return ret
+		},
+	}
+	gzipWriterCache = &base.SyncPool{
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	}
+	flateReaderCache = &base.SyncPool{
+		New: func() interface{} {
+			return flate.NewReader(bytes.NewReader(nil))
+		},
+	}
+	gzipReaderCache = &base.SyncPool{
+		New: func() interface{} {
+			return &gzip.Reader{}
+		},
+	}
+)
+
+// compressBytes compresses b with the pooled writer of the compression, it
+// returns false if the compression is not supported
+func compressBytes(compression string, w io.Writer, b []byte) bool {
+	switch compression {
+	case CompressionFlate:
+		fw := flateWriterCache.Get().(*flate.Writer)
+		defer flateWriterCache.Put(fw)
+		fw.Reset(w)
+		_, e := fw.Write(b)
+		return e == nil && fw.Close() == nil
+	case CompressionGzip:
+		gw := gzipWriterCache.Get().(*gzip.Writer)
+		defer gzipWriterCache.Put(gw)
+		gw.Reset(w)
+		_, e := gw.Write(b)
+		return e == nil && gw.Close() == nil
+	default:
+		return false
+	}
+}
+
+// decompressBytes decompresses b with the pooled reader of the compression,
+// and reads at most n bytes
+func decompressBytes(compression string, b []byte, n int) ([]byte, bool) {
+	var r io.Reader
+
+	switch compression {
+	case CompressionFlate:
+		fr := flateReaderCache.Get().(io.ReadCloser)
+		defer flateReaderCache.Put(fr)
+		if fr.(flate.Resetter).Reset(bytes.NewReader(b), nil) != nil {
+			return nil, false
+		}
+		r = fr
+	case CompressionGzip:
+		gr := gzipReaderCache.Get().(*gzip.Reader)
+		defer gzipReaderCache.Put(gr)
+		if gr.Reset(bytes.NewReader(b)) != nil {
+			return nil, false
+		}
+		r = gr
+	default:
+		return nil, false
+	}
+
+	ret, e := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	return ret, e == nil
+}
+
+// Compress returns a copy of the stream whose body is compressed, and the
+// status bit compressed is set. It returns nil if the codec is not supported,
+// or the body does not get shorter
+func (p *Stream) Compress(compression string) *Stream {
+	buffer := p.GetBuffer()
+	body := buffer[streamPosBody:]
+	compressed := bytes.NewBuffer(make([]byte, 0, len(body)))
+
+	if !compressBytes(compression, compressed, body) {
+		return nil
+	} else if compressed.Len() >= len(body) {
+		return nil
+	} else {
+		ret := NewStream()
+		copy(*ret.frames[0], buffer[:streamPosBody])
+		ret.PutBytes(compressed.Bytes())
+		ret.SetStatusBitCompressed()
+		return ret
+	}
+}
+
+// Decompress returns a copy of the stream whose body is decompressed, and the
+// status bit compressed is cleared. The body can not be longer than limit
+func (p *Stream) Decompress(compression string, limit int) (*Stream, *base.Error) {
+	buffer := p.GetBuffer()
+
+	// read one more byte to find out whether the body is too long
+	body, ok := decompressBytes(
+		compression,
+		buffer[streamPosBody:],
+		limit-streamPosBody+1,
+	)
+	if !ok || streamPosBody+len(body) > limit {
+		return nil, base.ErrStream
+	}
+
+	ret := NewStream()
+	copy(*ret.frames[0], buffer[:streamPosBody])
+	ret.PutBytes(body)
+	ret.ClearStatusBitCompressed()
+	ret.BuildStreamCheck()
+	return ret, nil
+}
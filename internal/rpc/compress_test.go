@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+func TestCompressions(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(CompressionFlate).Equal("flate")
+		assert(CompressionGzip).Equal("gzip")
+		assert(GetCompressions()).Equal([]string{"flate", "gzip"})
+		assert(IsCompressionSupported("flate")).IsTrue()
+		assert(IsCompressionSupported("gzip")).IsTrue()
+		assert(IsCompressionSupported("")).IsFalse()
+		assert(IsCompressionSupported("br")).IsFalse()
+	})
+}
+
+func TestStream_Compress(t *testing.T) {
+	t.Run("compression is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.WriteString(strings.Repeat("a", 1024))
+		assert(stream.Compress("br")).IsNil()
+	})
+
+	t.Run("body does not get shorter", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.WriteInt64(3)
+		assert(stream.Compress(CompressionFlate)).IsNil()
+		assert(stream.Compress(CompressionGzip)).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		value := Map{"name": strings.Repeat("kitty", 1024)}
+		for _, compression := range GetCompressions() {
+			stream := NewStream()
+			stream.SetKind(StreamKindRPCResponseOK)
+			stream.SetCallbackID(11)
+			stream.Write(value)
+
+			compressed := stream.Compress(compression)
+			assert(compressed.HasStatusBitCompressed()).IsTrue()
+			assert(compressed.GetKind()).Equal(uint8(StreamKindRPCResponseOK))
+			assert(compressed.GetCallbackID()).Equal(uint64(11))
+			assert(compressed.GetWritePos() < stream.GetWritePos()).IsTrue()
+
+			compressed.BuildStreamCheck()
+			assert(compressed.CheckStream()).IsTrue()
+			stream.Release()
+			compressed.Release()
+		}
+	})
+
+	t.Run("test pooled codecs", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		// the pooled writers and readers are reset for every stream
+		for i := 0; i < 8; i++ {
+			for _, compression := range GetCompressions() {
+				value := strings.Repeat(string(rune('a'+i)), 1024+i)
+				stream := NewStream()
+				stream.WriteString(value)
+				compressed := stream.Compress(compression)
+				ret, err := compressed.Decompress(compression, 4096)
+				assert(err).IsNil()
+				assert(ret.ReadString()).Equal(value, nil)
+				stream.Release()
+				compressed.Release()
+				ret.Release()
+			}
+		}
+	})
+}
+
+func TestStream_Decompress(t *testing.T) {
+	fnCompress := func(compression string, value Any) *Stream {
+		stream := NewStream()
+		defer stream.Release()
+		stream.SetCallbackID(11)
+		stream.Write(value)
+		return stream.Compress(compression)
+	}
+
+	t.Run("compression is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := fnCompress(CompressionFlate, strings.Repeat("a", 1024))
+		defer stream.Release()
+		assert(stream.Decompress("br", 4096)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("compression is not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := fnCompress(CompressionFlate, strings.Repeat("a", 1024))
+		defer stream.Release()
+		assert(stream.Decompress(CompressionGzip, 4096)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("body is corrupted", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.PutBytes([]byte{1, 2, 3, 4, 5})
+		assert(stream.Decompress(CompressionFlate, 4096)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("body is too long", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := fnCompress(CompressionFlate, strings.Repeat("a", 1024))
+		defer stream.Release()
+		assert(stream.Decompress(CompressionFlate, 1024)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		value := Map{"name": strings.Repeat("kitty", 1024)}
+		for _, compression := range GetCompressions() {
+			stream := fnCompress(compression, value)
+			ret, err := stream.Decompress(compression, 8192)
+			assert(err).IsNil()
+			assert(ret.HasStatusBitCompressed()).IsFalse()
+			assert(ret.GetCallbackID()).Equal(uint64(11))
+			assert(ret.CheckStream()).IsTrue()
+			assert(ret.Read()).Equal(value, nil)
+			assert(ret.IsReadFinish()).IsTrue()
+			stream.Release()
+			ret.Release()
+		}
+	})
+}
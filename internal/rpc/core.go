@@ -14,6 +14,34 @@ type IStreamHub interface {
 	OnReceiveStream(stream *Stream)
 }
 
+// ISessionHub is implemented by the stream hub of the processor if it can
+// look up the sessions that the requests come from
+type ISessionHub interface {
+	GetPrincipal(gatewayID uint64, sessionID uint64) *Principal
+	GetSessionAttribute(gatewayID uint64, sessionID uint64, key string) (Any, bool)
+	SetSessionAttribute(
+		gatewayID uint64,
+		sessionID uint64,
+		key string,
+		value Any,
+	) bool
+}
+
+// IRemoteHub is implemented by the stream hub of the processor if it can
+// forward the calls to the actions that are mounted on the other nodes
+type IRemoteHub interface {
+	// CallRemote sends the request stream to the node that mounts the target,
+	// and returns the response stream. It returns nil if no node mounts the
+	// target. The call times out at the deadline if it is not zero, and it is
+	// cancelled when cancelCH is closed
+	CallRemote(
+		target string,
+		stream *Stream,
+		deadline int64,
+		cancelCH <-chan bool,
+	) *Stream
+}
+
 // LogToScreenErrorStreamHub ...
 type LogToScreenErrorStreamHub struct {
 	prefix string
@@ -133,7 +161,22 @@ func getFuncKind(fn reflect.Value) (string, *base.Error) {
 				sb.AppendByte(vkRTArray)
 			case rtMapType:
 				sb.AppendByte(vkRTMap)
+			case timeType:
+				sb.AppendByte(vkTime)
+			case durationType:
+				sb.AppendByte(vkDuration)
+			case int64ArrayType:
+				sb.AppendByte(vkInt64Array)
+			case float64ArrayType:
+				sb.AppendByte(vkFloat64Array)
+			case stringArrayType:
+				sb.AppendByte(vkStringArray)
 			default:
+				if isReflectType(fn.Type().In(i)) {
+					sb.AppendByte(vkReflect)
+					continue
+				}
+
 				return "", base.ErrActionHandler.AddDebug(
 					base.ConcatString(
 						"handler ",
@@ -179,6 +222,10 @@ func convertTypeToString(reflectType reflect.Type) string {
 		return "rpc.RTArray"
 	case rtMapType:
 		return "rpc.RTMap"
+	case timeType:
+		return "rpc.Time"
+	case durationType:
+		return "rpc.Duration"
 	default:
 		return reflectType.String()
 	}
@@ -216,6 +263,18 @@ func MakeInternalRequestStream(
 	target string,
 	from string,
 	args ...interface{},
+) (*Stream, *base.Error) {
+	return makeRequestStream(debug, depth, 0, nil, target, from, args...)
+}
+
+func makeRequestStream(
+	debug bool,
+	depth uint16,
+	deadline int64,
+	metadata map[string]string,
+	target string,
+	from string,
+	args ...interface{},
 ) (*Stream, *base.Error) {
 	stream := NewStream()
 	stream.SetKind(StreamKindRPCRequest)
@@ -231,6 +290,16 @@ func MakeInternalRequestStream(
 	stream.WriteString(target)
 	// write from
 	stream.WriteString(from)
+	// write deadline
+	if deadline > 0 {
+		stream.SetStatusBitDeadline()
+		stream.WriteInt64(deadline)
+	}
+	// write metadata
+	if len(metadata) > 0 {
+		stream.SetStatusBitMetadata()
+		stream.WriteMetadata(metadata)
+	}
 	// write args
 	for i := 0; i < len(args); i++ {
 		if reason := stream.Write(args[i]); reason != StreamWriteOK {
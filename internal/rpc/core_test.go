@@ -329,6 +329,47 @@ func TestGetFuncKind(t *testing.T) {
 		}
 		assert(getFuncKind(reflect.ValueOf(v))).Equal("BIUFSXAMVYZ", nil)
 	})
+
+	t.Run("test time ok", func(t *testing.T) {
+		v := func(rt Runtime, _ Time, _ Duration) Return {
+			return rt.Reply(true)
+		}
+		assert(getFuncKind(reflect.ValueOf(v))).Equal("CD", nil)
+	})
+
+	t.Run("test typed array ok", func(t *testing.T) {
+		v := func(rt Runtime, _ []int64, _ []float64, _ []string) Return {
+			return rt.Reply(true)
+		}
+		assert(getFuncKind(reflect.ValueOf(v))).Equal("ifs", nil)
+	})
+
+	t.Run("test reflect ok", func(t *testing.T) {
+		v := func(rt Runtime,
+			_ testReflectUser, _ *testReflectUser, _ []int, _ map[string]bool,
+		) Return {
+			return rt.Reply(true)
+		}
+		assert(getFuncKind(reflect.ValueOf(v))).Equal("RRRR", nil)
+	})
+
+	t.Run("test marshaler ok", func(t *testing.T) {
+		v := func(rt Runtime, _ testMarshalPoint, _ []*testMarshalPoint) Return {
+			return rt.Reply(true)
+		}
+		assert(getFuncKind(reflect.ValueOf(v))).Equal("RR", nil)
+	})
+
+	t.Run("reflect argument unsupported", func(t *testing.T) {
+		v := func(rt Runtime, _ []chan bool) Return {
+			return rt.Reply(true)
+		}
+		assert(getFuncKind(reflect.ValueOf(v))).Equal(
+			"",
+			base.ErrActionHandler.AddDebug(
+				"handler 2nd argument type []chan bool is not supported",
+			))
+	})
 }
 
 func TestConvertTypeToString(t *testing.T) {
@@ -343,6 +384,8 @@ func TestConvertTypeToString(t *testing.T) {
 		assert(convertTypeToString(float64Type)).Equal("rpc.Float64")
 		assert(convertTypeToString(stringType)).Equal("rpc.String")
 		assert(convertTypeToString(bytesType)).Equal("rpc.Bytes")
+		assert(convertTypeToString(timeType)).Equal("rpc.Time")
+		assert(convertTypeToString(durationType)).Equal("rpc.Duration")
 		assert(convertTypeToString(arrayType)).Equal("rpc.Array")
 		assert(convertTypeToString(mapType)).Equal("rpc.Map")
 		assert(convertTypeToString(rtValueType)).Equal("rpc.RTValue")
@@ -418,6 +461,63 @@ func TestMakeInternalRequestStream(t *testing.T) {
 	})
 }
 
+func TestMakeRequestStream(t *testing.T) {
+	t.Run("write error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(makeRequestStream(false, 0, 1234, nil, "#", "", make(chan bool))).
+			Equal(
+				nil,
+				base.ErrUnsupportedValue.AddDebug(
+					"2nd argument: value type(chan bool) is not supported",
+				),
+			)
+	})
+
+	t.Run("deadline is not set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v, err := makeRequestStream(false, 2, 0, nil, "#", "from", true)
+		assert(err).IsNil()
+		assert(v.HasStatusBitDeadline()).IsFalse()
+		assert(v.HasStatusBitMetadata()).IsFalse()
+		assert(v.ReadString()).Equal("#", nil)
+		assert(v.ReadString()).Equal("from", nil)
+		assert(v.ReadBool()).Equal(true, nil)
+		assert(v.IsReadFinish()).IsTrue()
+		v.Release()
+	})
+
+	t.Run("deadline is set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v, err := makeRequestStream(true, 2, 1234, nil, "#", "from", true)
+		assert(err).IsNil()
+		assert(v.HasStatusBitDebug()).IsTrue()
+		assert(v.HasStatusBitDeadline()).IsTrue()
+		assert(v.GetDepth()).Equal(uint16(2))
+		assert(v.ReadString()).Equal("#", nil)
+		assert(v.ReadString()).Equal("from", nil)
+		assert(v.ReadInt64()).Equal(int64(1234), nil)
+		assert(v.ReadBool()).Equal(true, nil)
+		assert(v.IsReadFinish()).IsTrue()
+		v.Release()
+	})
+
+	t.Run("metadata is set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		metadata := map[string]string{"traceID": "1234"}
+		v, err := makeRequestStream(false, 2, 1234, metadata, "#", "from", true)
+		assert(err).IsNil()
+		assert(v.HasStatusBitDeadline()).IsTrue()
+		assert(v.HasStatusBitMetadata()).IsTrue()
+		assert(v.ReadString()).Equal("#", nil)
+		assert(v.ReadString()).Equal("from", nil)
+		assert(v.ReadInt64()).Equal(int64(1234), nil)
+		assert(v.ReadMetadata()).Equal(metadata, nil)
+		assert(v.ReadBool()).Equal(true, nil)
+		assert(v.IsReadFinish()).IsTrue()
+		v.Release()
+	})
+}
+
 func TestParseResponseStream(t *testing.T) {
 	t.Run("errCode format error", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -512,6 +612,7 @@ func newTestProcessorHelper(
 		fnCache,
 		closeTimeout,
 		mountServices,
+		nil,
 		streamHub,
 	)
 	return &testProcessorHelper{
@@ -565,6 +666,8 @@ func testWithProcessorAndRuntime(
 	stream, _ := MakeInternalRequestStream(true, 0, "#.test:Eval", "")
 	stream.SetGatewayID(1234)
 	stream.SetSessionID(5678)
+	// the calls from the sessions always have a callbackID
+	stream.SetCallbackID(1)
 	helper.GetProcessor().PutStream(stream)
 	return <-helper.streamHub.streamCH
 }
@@ -0,0 +1,149 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+// DeferredReply finishes a call after its action has returned. It is created
+// by Runtime.Defer, and it can be used in any goroutine
+type DeferredReply struct {
+	processor    *Processor
+	stream       *Stream
+	actionNode   *rpcActionNode
+	frame        *rpcThreadFrame
+	ctx          *ActionContext
+	interceptors []ActionInterceptor
+	timeStart    time.Time
+	readyCH      chan bool
+	isReplied    bool
+	timer        *time.Timer
+	sync.Mutex
+}
+
+func newDeferredReply(
+	processor *Processor,
+	stream *Stream,
+	actionNode *rpcActionNode,
+) *DeferredReply {
+	return &DeferredReply{
+		processor:    processor,
+		stream:       stream,
+		actionNode:   actionNode,
+		frame:        &rpcThreadFrame{},
+		ctx:          nil,
+		interceptors: nil,
+		timeStart:    base.TimeNow(),
+		readyCH:      make(chan bool),
+		isReplied:    false,
+		timer:        nil,
+	}
+}
+
+// startTimer finishes the call with the error if it is not replied in time.
+// The timeout is the defer timeout of the processor, or the time left before
+// the deadline of the call if it is shorter
+func (p *DeferredReply) startTimer(deadline int64) {
+	timeout := p.processor.getDeferTimeout()
+	err := base.ErrDeferredReplyTimeout.AddDebug(base.ConcatString(
+		"the deferred reply of ",
+		p.actionNode.path,
+		" timed out",
+	))
+
+	if deadline > 0 {
+		left := time.Duration(deadline - base.TimeNow().UnixNano())
+		if left < timeout {
+			timeout = left
+			err = base.ErrCallDeadlineExceeded.AddDebug(base.ConcatString(
+				"call ",
+				p.actionNode.path,
+				" deadline exceeded",
+			))
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.timer = time.AfterFunc(timeout, func() {
+		_ = p.reply(err, 0)
+	})
+}
+
+// Reply finishes the call with value. It waits until the action has returned,
+// and it can only be called once
+func (p *DeferredReply) Reply(value interface{}) error {
+	if p == nil {
+		return base.ErrRuntimeReplyHasBeenCalled.AddDebug(base.GetFileLine(1))
+	}
+
+	return p.reply(value, 1)
+}
+
+func (p *DeferredReply) reply(value interface{}, skip uint) error {
+	<-p.readyCH
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isReplied {
+		return base.ErrRuntimeReplyHasBeenCalled.AddDebug(
+			base.GetFileLine(skip + 1),
+		)
+	}
+	p.isReplied = true
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+
+	stream := p.stream
+	retStatus := writeReply(
+		stream,
+		value,
+		p.actionNode.path,
+		skip+1,
+		stream.HasStatusBitDebug(),
+	)
+
+	// count
+	p.actionNode.indicator.Count(
+		base.TimeNow().Sub(p.timeStart),
+		retStatus == 1,
+	)
+
+	// interceptors
+	if ctx := p.ctx; ctx != nil {
+		stream.SetReadPosToBodyStart()
+		ctx.result, ctx.err = ParseResponseStream(stream)
+		ctx.cost = base.TimeNow().Sub(p.timeStart)
+		afterIntercept(p.interceptors, ctx)
+	}
+
+	// callback
+	stream.SetReadPosToBodyStart()
+	p.stream = nil
+	p.processor.removeRunning(stream)
+	p.processor.onCallback(stream)
+	return nil
+}
+
+// IsCancelled reports whether the caller has cancelled the call
+func (p *DeferredReply) IsCancelled() bool {
+	if p != nil {
+		return p.frame.getCancelled()
+	}
+
+	return false
+}
+
+// Done returns a channel that is closed when the caller cancels the call
+func (p *DeferredReply) Done() <-chan bool {
+	if p != nil {
+		return p.frame.getCancelCH()
+	}
+
+	return nil
+}
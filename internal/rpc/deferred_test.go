@@ -0,0 +1,244 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+func testWithDeferredReply(
+	interceptor ActionInterceptor,
+) (*testProcessorHelper, chan *DeferredReply) {
+	stream, _ := MakeInternalRequestStream(true, 0, "#.test:Defer", "")
+	stream.SetGatewayID(1234)
+	stream.SetSessionID(5678)
+	stream.SetCallbackID(7)
+	return testWithDeferredStream(interceptor, defaultDeferTimeout, stream)
+}
+
+func testWithDeferredStream(
+	interceptor ActionInterceptor,
+	deferTimeout time.Duration,
+	stream *Stream,
+) (*testProcessorHelper, chan *DeferredReply) {
+	replyCH := make(chan *DeferredReply, 1)
+	service := NewService().
+		On("Defer", func(rt Runtime) Return {
+			ret, reply := rt.Defer()
+			replyCH <- reply
+			return ret
+		}).
+		On("SayHello", func(rt Runtime, name string) Return {
+			return rt.Reply("hello " + name)
+		})
+
+	if interceptor != nil {
+		service.AddInterceptor(interceptor)
+	}
+
+	helper := newTestProcessorHelper(
+		1,
+		16,
+		16,
+		2048,
+		nil,
+		3*time.Second,
+		[]*ServiceMeta{{
+			name:     "test",
+			service:  service,
+			fileLine: "",
+			data:     nil,
+		}},
+	)
+
+	helper.GetProcessor().SetDeferTimeout(deferTimeout)
+	helper.GetProcessor().PutStream(stream)
+	return helper, replyCH
+}
+
+func TestNewDeferredReply(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{}
+		stream := NewStream()
+		actionNode := &rpcActionNode{path: "#.test:Defer"}
+		v := newDeferredReply(processor, stream, actionNode)
+		assert(v.processor).Equal(processor)
+		assert(v.stream).Equal(stream)
+		assert(v.actionNode).Equal(actionNode)
+		assert(v.frame).IsNotNil()
+		assert(v.ctx).IsNil()
+		assert(v.interceptors).IsNil()
+		assert(v.readyCH).IsNotNil()
+		assert(v.isReplied).IsFalse()
+	})
+}
+
+func TestDeferredReply_Reply(t *testing.T) {
+	t.Run("p is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ret, source := (*DeferredReply)(nil).Reply(true), base.GetFileLine(0)
+		assert(ret).Equal(base.ErrRuntimeReplyHasBeenCalled.AddDebug(source))
+	})
+
+	t.Run("the thread is released", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper, replyCH := testWithDeferredReply(nil)
+		defer helper.Close()
+		reply := <-replyCH
+
+		// the processor has only one thread, so the call can only be
+		// finished if the thread is released
+		stream, _ := MakeInternalRequestStream(true, 0, "#.test:SayHello", "", "kitty")
+		helper.GetProcessor().PutStream(stream)
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).
+			Equal("hello kitty", nil)
+
+		go func() {
+			assert(reply.Reply("ok")).IsNil()
+		}()
+		backStream := helper.streamHub.WaitStream()
+		assert(backStream.GetGatewayID()).Equal(uint64(1234))
+		assert(backStream.GetSessionID()).Equal(uint64(5678))
+		assert(backStream.GetCallbackID()).Equal(uint64(7))
+		assert(ParseResponseStream(backStream)).Equal("ok", nil)
+	})
+
+	t.Run("reply error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper, replyCH := testWithDeferredReply(nil)
+		defer helper.Close()
+		reply := <-replyCH
+		ret, source := reply.Reply(base.ErrStream), base.GetFileLine(0)
+		assert(ret).IsNil()
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).Equal(
+			nil,
+			base.ErrStream.AddDebug("#.test:Defer "+source).Standardize(),
+		)
+	})
+
+	t.Run("reply has been called", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper, replyCH := testWithDeferredReply(nil)
+		defer helper.Close()
+		reply := <-replyCH
+		assert(reply.Reply("ok")).IsNil()
+		ret, source := reply.Reply("ok"), base.GetFileLine(0)
+		assert(ret).Equal(base.ErrRuntimeReplyHasBeenCalled.AddDebug(source))
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).
+			Equal("ok", nil)
+	})
+
+	t.Run("reply timeout", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream, _ := MakeInternalRequestStream(false, 0, "#.test:Defer", "")
+		stream.SetCallbackID(7)
+		helper, replyCH := testWithDeferredStream(
+			nil,
+			100*time.Millisecond,
+			stream,
+		)
+		defer helper.Close()
+		reply := <-replyCH
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).Equal(
+			nil,
+			base.ErrDeferredReplyTimeout.
+				AddDebug("the deferred reply of #.test:Defer timed out").
+				Standardize(),
+		)
+		ret, source := reply.Reply("ok"), base.GetFileLine(0)
+		assert(ret).Equal(base.ErrRuntimeReplyHasBeenCalled.AddDebug(source))
+	})
+
+	t.Run("reply deadline exceeded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		deadline := base.TimeNow().Add(100 * time.Millisecond).UnixNano()
+		stream, _ := makeRequestStream(
+			false,
+			0,
+			deadline,
+			nil,
+			"#.test:Defer",
+			"",
+		)
+		stream.SetCallbackID(7)
+		helper, replyCH := testWithDeferredStream(
+			nil,
+			defaultDeferTimeout,
+			stream,
+		)
+		defer helper.Close()
+		reply := <-replyCH
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).Equal(
+			nil,
+			base.ErrCallDeadlineExceeded.
+				AddDebug("call #.test:Defer deadline exceeded").
+				Standardize(),
+		)
+		assert(reply.Reply("ok")).IsNotNil()
+	})
+
+	t.Run("with interceptor", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ctxCH := make(chan *ActionContext, 1)
+		helper, replyCH := testWithDeferredReply(&testActionInterceptor{
+			after: func(ctx *ActionContext) {
+				ctxCH <- ctx
+			},
+		})
+		defer helper.Close()
+		reply := <-replyCH
+		assert(reply.Reply("ok")).IsNil()
+		ctx := <-ctxCH
+		assert(ctx.GetPath()).Equal("#.test:Defer")
+		assert(ctx.GetResult()).Equal("ok")
+		assert(ctx.GetError()).IsNil()
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).
+			Equal("ok", nil)
+	})
+}
+
+func TestDeferredReply_IsCancelled(t *testing.T) {
+	t.Run("p is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert((*DeferredReply)(nil).IsCancelled()).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper, replyCH := testWithDeferredReply(nil)
+		defer helper.Close()
+		reply := <-replyCH
+		assert(reply.IsCancelled()).IsFalse()
+		assert(helper.GetProcessor().Cancel(1234, 5678, 7)).IsTrue()
+		assert(reply.IsCancelled()).IsTrue()
+		assert(reply.Reply("ok")).IsNil()
+		assert(helper.GetProcessor().Cancel(1234, 5678, 7)).IsFalse()
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).
+			Equal("ok", nil)
+	})
+}
+
+func TestDeferredReply_Done(t *testing.T) {
+	t.Run("p is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert((*DeferredReply)(nil).Done() == nil).IsTrue()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper, replyCH := testWithDeferredReply(nil)
+		defer helper.Close()
+		reply := <-replyCH
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			helper.GetProcessor().Cancel(1234, 5678, 7)
+		}()
+		<-reply.Done()
+		assert(reply.IsCancelled()).IsTrue()
+		assert(reply.Reply("ok")).IsNil()
+		assert(ParseResponseStream(helper.streamHub.WaitStream())).
+			Equal("ok", nil)
+	})
+}
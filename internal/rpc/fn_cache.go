@@ -71,6 +71,21 @@ func getFuncBodyByKind(name string, kind string) (string, *base.Error) {
 			case vkRTValue:
 				callString = "stream.ReadRTValue(rt)"
 				typeArray = append(typeArray, "rpc.RTValue")
+			case vkTime:
+				callString = "stream.ReadTime()"
+				typeArray = append(typeArray, "rpc.Time")
+			case vkDuration:
+				callString = "stream.ReadDuration()"
+				typeArray = append(typeArray, "rpc.Duration")
+			case vkInt64Array:
+				callString = "stream.ReadInt64Array()"
+				typeArray = append(typeArray, "[]int64")
+			case vkFloat64Array:
+				callString = "stream.ReadFloat64Array()"
+				typeArray = append(typeArray, "[]float64")
+			case vkStringArray:
+				callString = "stream.ReadStringArray()"
+				typeArray = append(typeArray, "[]string")
 			default:
 				return "", base.ErrFnCacheIllegalKindString.
 					AddDebug(fmt.Sprintf("illegal kind %s", kind))
@@ -67,6 +67,26 @@ func TestBuildFuncCache(t *testing.T) {
 		})).IsNil()
 		assert(base.ReadFromFile(filePath)).Equal(base.ReadFromFile(snapPath))
 	})
+
+	t.Run("test time", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_tmp_/test-cache-03.go")
+		snapPath := path.Join(curDir, "_snapshot_/test-cache-03.snapshot")
+		assert(buildFuncCache("pkgName", filePath, []string{
+			"C", "D", "CD", "SCI",
+		})).IsNil()
+		assert(base.ReadFromFile(filePath)).Equal(base.ReadFromFile(snapPath))
+	})
+
+	t.Run("test typed array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_tmp_/test-cache-04.go")
+		snapPath := path.Join(curDir, "_snapshot_/test-cache-04.snapshot")
+		assert(buildFuncCache("pkgName", filePath, []string{
+			"i", "f", "s", "ifs", "Si",
+		})).IsNil()
+		assert(base.ReadFromFile(filePath)).Equal(base.ReadFromFile(snapPath))
+	})
 }
 
 type testFuncCache struct{}
@@ -107,6 +127,20 @@ func (p *testFuncCache) Get(fnString string) ActionCacheFunc {
 				return 0
 			}
 		}
+	case "CD":
+		return func(rt Runtime, stream *Stream, fn interface{}) int {
+			if arg0, err := stream.ReadTime(); err != nil {
+				return 1
+			} else if arg1, err := stream.ReadDuration(); err != nil {
+				return 2
+			} else if !stream.IsReadFinish() {
+				return -1
+			} else {
+				stream.SetWritePosToBodyStart()
+				fn.(func(Runtime, Time, Duration) Return)(rt, arg0, arg1)
+				return 0
+			}
+		}
 	case "M":
 		return func(rt Runtime, stream *Stream, fn interface{}) int {
 			if arg0, err := stream.ReadMap(); err != nil {
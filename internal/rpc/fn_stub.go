@@ -0,0 +1,324 @@
+package rpc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+// getStubMethodName converts the action path to an exported method name, for
+// example, #.user.info:Get is converted to UserInfoGet
+func getStubMethodName(actionPath string) string {
+	sb := base.NewStringBuilder()
+	defer sb.Release()
+
+	for _, name := range strings.FieldsFunc(
+		strings.TrimPrefix(actionPath, rootName),
+		func(c rune) bool { return c == '.' || c == ':' },
+	) {
+		sb.AppendString(strings.ToUpper(name[:1]) + name[1:])
+	}
+
+	// the service name might start with a digit or an underline
+	ret := sb.String()
+	if ret == "" || !unicode.IsUpper(rune(ret[0])) {
+		ret = "Call" + ret
+	}
+
+	return ret
+}
+
+// stubAction is what the client stub needs to know about an action
+type stubAction struct {
+	kind  string         // the kind of the handler
+	args  []reflect.Type // the argument types, without the Runtime
+	reply reflect.Type   // the declared reply type, nil means rpc.Any
+}
+
+// stubImports maps the package names that the stub imports to the package
+// paths. The packages that can not be imported, like the main package, make
+// the types fall back to rpc.Any
+type stubImports map[string]string
+
+func newStubImports() stubImports {
+	return stubImports{
+		"time": "time",
+		"rpc":  "github.com/rpccloud/rpc",
+	}
+}
+
+// getTypeString returns the type name in the stub package. The imports are
+// only added if the whole type can be named
+func (p stubImports) getTypeString(t reflect.Type) (string, bool) {
+	pending := make(stubImports)
+	if ret, ok := p.getTypeStringWithPending(t, pending); ok {
+		for pkgName, pkgPath := range pending {
+			p[pkgName] = pkgPath
+		}
+		return ret, true
+	}
+
+	return "", false
+}
+
+func (p stubImports) getTypeStringWithPending(
+	t reflect.Type,
+	pending stubImports,
+) (string, bool) {
+	switch t {
+	case bytesType:
+		return "rpc.Bytes", true
+	case arrayType:
+		return "rpc.Array", true
+	case mapType:
+		return "rpc.Map", true
+	case timeType:
+		return "rpc.Time", true
+	case durationType:
+		return "rpc.Duration", true
+	}
+
+	if name := t.Name(); name != "" {
+		if t.PkgPath() == "" {
+			// the predeclared types, like int64 and string
+			return name, true
+		} else if t.PkgPath() == "main" || !unicode.IsUpper(rune(name[0])) {
+			return "", false
+		}
+
+		pkgName := strings.TrimSuffix(t.String(), "."+name)
+		if pkgPath, ok := p[pkgName]; ok && pkgPath != t.PkgPath() {
+			return "", false
+		} else if pkgPath, ok := pending[pkgName]; ok && pkgPath != t.PkgPath() {
+			return "", false
+		} else {
+			pending[pkgName] = t.PkgPath()
+			return t.String(), true
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		prefix := "*"
+		if t.Kind() == reflect.Slice {
+			prefix = "[]"
+		}
+		if elem, ok := p.getTypeStringWithPending(t.Elem(), pending); ok {
+			return prefix + elem, true
+		}
+	case reflect.Map:
+		key, okKey := p.getTypeStringWithPending(t.Key(), pending)
+		elem, okElem := p.getTypeStringWithPending(t.Elem(), pending)
+		if okKey && okElem {
+			return "map[" + key + "]" + elem, true
+		}
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "rpc.Any", true
+		}
+	}
+
+	// the anonymous structs and the other unnamed types
+	return "", false
+}
+
+func getStubMethodBody(
+	name string,
+	actionPath string,
+	action stubAction,
+	imports stubImports,
+) (string, *base.Error) {
+	argArray := make([]string, 0)
+	paramArray := make([]string, 0)
+
+	for idx, c := range action.kind {
+		argName := "arg" + strconv.Itoa(idx)
+		typeString := ""
+
+		switch c {
+		case vkBool:
+			typeString = "rpc.Bool"
+		case vkInt64:
+			typeString = "rpc.Int64"
+		case vkUint64:
+			typeString = "rpc.Uint64"
+		case vkFloat64:
+			typeString = "rpc.Float64"
+		case vkString:
+			typeString = "rpc.String"
+		case vkBytes:
+			typeString = "rpc.Bytes"
+		case vkArray, vkRTArray:
+			typeString = "rpc.Array"
+		case vkMap, vkRTMap:
+			typeString = "rpc.Map"
+		case vkTime:
+			typeString = "rpc.Time"
+		case vkDuration:
+			typeString = "rpc.Duration"
+		case vkInt64Array:
+			typeString = "[]int64"
+		case vkFloat64Array:
+			typeString = "[]float64"
+		case vkStringArray:
+			typeString = "[]string"
+		case vkRTValue:
+			typeString = "rpc.Any"
+		case vkReflect:
+			typeString = "rpc.Any"
+			if idx < len(action.args) {
+				if ret, ok := imports.getTypeString(action.args[idx]); ok {
+					typeString = ret
+				}
+			}
+		default:
+			return "", base.ErrFnCacheIllegalKindString.
+				AddDebug(fmt.Sprintf("illegal kind %s", action.kind))
+		}
+
+		argArray = append(argArray, ", "+argName)
+		paramArray = append(paramArray, argName+" "+typeString)
+	}
+
+	replyString := ""
+	if action.reply != nil {
+		if ret, ok := imports.getTypeString(action.reply); ok && ret != "rpc.Any" {
+			replyString = ret
+		}
+	}
+
+	if replyString == "" {
+		return fmt.Sprintf(
+			"// %s calls %s\n"+
+				"func (p *Client) %s(%s) (rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"%s\"%s)\n"+
+				"}",
+			name,
+			actionPath,
+			name,
+			strings.Join(paramArray, ", "),
+			actionPath,
+			strings.Join(argArray, ""),
+		), nil
+	}
+
+	return fmt.Sprintf(
+		"// %s calls %s\n"+
+			"func (p *Client) %s(%s) (ret %s, err *rpc.Error) {\n"+
+			"\tvar v rpc.Any\n"+
+			"\tif v, err = p.client.Send(p.timeout, \"%s\"%s); err == nil {\n"+
+			"\t\terr = rpc.DecodeValue(v, &ret)\n"+
+			"\t}\n"+
+			"\treturn\n"+
+			"}",
+		name,
+		actionPath,
+		name,
+		strings.Join(paramArray, ", "),
+		replyString,
+		actionPath,
+		strings.Join(argArray, ""),
+	), nil
+}
+
+// buildClientStub writes a client package that has a typed method for each
+// action. The actions map the action paths to the handlers information
+func buildClientStub(
+	pkgName string,
+	output string,
+	actions map[string]stubAction,
+) *base.Error {
+	actionPaths := make([]string, 0, len(actions))
+	for actionPath := range actions {
+		actionPaths = append(actionPaths, actionPath)
+	}
+	sort.Strings(actionPaths)
+
+	// the methods are built first, because they decide the imports
+	imports := newStubImports()
+	methods := base.NewStringBuilder()
+	defer methods.Release()
+
+	nameMap := make(map[string]string)
+	for _, actionPath := range actionPaths {
+		name := getStubMethodName(actionPath)
+		if conflict, ok := nameMap[name]; ok {
+			return base.ErrClientStubDuplicateName.AddDebug(fmt.Sprintf(
+				"duplicate method name %s (%s and %s)",
+				name,
+				conflict,
+				actionPath,
+			))
+		} else if body, err := getStubMethodBody(
+			name,
+			actionPath,
+			actions[actionPath],
+			imports,
+		); err != nil {
+			return err
+		} else {
+			nameMap[name] = actionPath
+			methods.AppendString(fmt.Sprintf("\n%s\n", body))
+		}
+	}
+
+	stdPaths := make([]string, 0)
+	otherPaths := make([]string, 0)
+	for _, pkgPath := range imports {
+		if strings.Contains(pkgPath, ".") {
+			otherPaths = append(otherPaths, pkgPath)
+		} else {
+			stdPaths = append(stdPaths, pkgPath)
+		}
+	}
+	sort.Strings(stdPaths)
+	sort.Strings(otherPaths)
+
+	sb := base.NewStringBuilder()
+	defer sb.Release()
+
+	sb.AppendString(fmt.Sprintf("package %s\n\n", pkgName))
+	sb.AppendString("import (\n")
+	for _, pkgPath := range stdPaths {
+		sb.AppendString(fmt.Sprintf("\t\"%s\"\n", pkgPath))
+	}
+	sb.AppendString("\n")
+	for _, pkgPath := range otherPaths {
+		sb.AppendString(fmt.Sprintf("\t\"%s\"\n", pkgPath))
+	}
+	sb.AppendString(")\n\n")
+
+	sb.AppendString("// Client calls the actions of the server with typed arguments\n")
+	sb.AppendString("type Client struct {\n")
+	sb.AppendString("\tclient  *rpc.Client\n")
+	sb.AppendString("\ttimeout time.Duration\n")
+	sb.AppendString("}\n\n")
+
+	sb.AppendString("// NewClient ...\n")
+	sb.AppendString(
+		"func NewClient(client *rpc.Client, timeout time.Duration) *Client {\n",
+	)
+	sb.AppendString("\treturn &Client{client: client, timeout: timeout}\n")
+	sb.AppendString("}\n")
+	sb.AppendString(methods.String())
+
+	if err := os.MkdirAll(path.Dir(output), os.ModePerm); err != nil {
+		return base.ErrCacheMkdirAll
+	} else if err := ioutil.WriteFile(
+		output,
+		[]byte(sb.String()),
+		0666,
+	); err != nil {
+		return base.ErrCacheWriteFile
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,266 @@
+package rpc
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+var (
+	monthType           = reflect.TypeOf(time.January)
+	urlType             = reflect.TypeOf(&url.URL{})
+	testReflectUserType = reflect.TypeOf(testReflectUser{})
+)
+
+func TestStubImports_getTypeString(t *testing.T) {
+	t.Run("rpc types", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		imports := newStubImports()
+		assert(imports.getTypeString(bytesType)).Equal("rpc.Bytes", true)
+		assert(imports.getTypeString(arrayType)).Equal("rpc.Array", true)
+		assert(imports.getTypeString(mapType)).Equal("rpc.Map", true)
+		assert(imports.getTypeString(timeType)).Equal("rpc.Time", true)
+		assert(imports.getTypeString(durationType)).Equal("rpc.Duration", true)
+		assert(imports.getTypeString(reflect.TypeOf(int32(0)))).
+			Equal("int32", true)
+		assert(imports.getTypeString(reflect.TypeOf([]*Any{}))).
+			Equal("[]*rpc.Any", true)
+		assert(imports).Equal(newStubImports())
+	})
+
+	t.Run("named types", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		imports := newStubImports()
+		assert(imports.getTypeString(monthType)).Equal("time.Month", true)
+		assert(imports.getTypeString(reflect.TypeOf(map[string]*url.URL{}))).
+			Equal("map[string]*url.URL", true)
+		assert(imports["url"]).Equal("net/url")
+	})
+
+	t.Run("types can not be named", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		imports := newStubImports()
+		assert(imports.getTypeString(testReflectUserType)).Equal("", false)
+		assert(imports.getTypeString(reflect.TypeOf(struct{ A int }{}))).
+			Equal("", false)
+		assert(imports.getTypeString(reflect.TypeOf(
+			map[*url.URL]testReflectUser{},
+		))).Equal("", false)
+		// the imports are not changed if the type can not be named
+		assert(imports).Equal(newStubImports())
+	})
+
+	t.Run("package name conflicts", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		imports := newStubImports()
+		imports["url"] = "example.com/url"
+		assert(imports.getTypeString(urlType)).Equal("", false)
+	})
+}
+
+func TestGetStubMethodName(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodName("#.user:Get")).Equal("UserGet")
+		assert(getStubMethodName("#.user.info:getName")).Equal("UserInfoGetName")
+		assert(getStubMethodName("#.user_info:Get")).Equal("User_infoGet")
+		assert(getStubMethodName("#.2user:Get")).Equal("Call2userGet")
+		assert(getStubMethodName("#._user:Get")).Equal("Call_userGet")
+	})
+}
+
+func TestGetStubMethodBody(t *testing.T) {
+	t.Run("illegal kind", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodBody(
+			"UserGet", "#.user:Get", stubAction{kind: "ST"}, newStubImports(),
+		)).Equal(
+			"",
+			base.ErrFnCacheIllegalKindString.AddDebug("illegal kind ST"),
+		)
+	})
+
+	t.Run("no argument", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodBody(
+			"UserGet", "#.user:Get", stubAction{kind: ""}, newStubImports(),
+		)).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet() (rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"#.user:Get\")\n"+
+				"}",
+			nil,
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodBody(
+			"UserGet", "#.user:Get", stubAction{kind: "SY"}, newStubImports(),
+		)).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet(arg0 rpc.String, arg1 rpc.Array) "+
+				"(rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"#.user:Get\", arg0, arg1)\n"+
+				"}",
+			nil,
+		)
+	})
+
+	t.Run("time and duration", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodBody(
+			"UserGet", "#.user:Get", stubAction{kind: "CD"}, newStubImports(),
+		)).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet(arg0 rpc.Time, arg1 rpc.Duration) "+
+				"(rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"#.user:Get\", arg0, arg1)\n"+
+				"}",
+			nil,
+		)
+	})
+
+	t.Run("typed arrays", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodBody(
+			"UserGet", "#.user:Get", stubAction{kind: "ifs"}, newStubImports(),
+		)).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet(arg0 []int64, arg1 []float64, "+
+				"arg2 []string) (rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"#.user:Get\", "+
+				"arg0, arg1, arg2)\n"+
+				"}",
+			nil,
+		)
+	})
+}
+
+func TestGetStubMethodBody_typed(t *testing.T) {
+	t.Run("reflect arguments", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		imports := newStubImports()
+		assert(getStubMethodBody("UserGet", "#.user:Get", stubAction{
+			kind: "RRR",
+			args: []reflect.Type{monthType, testReflectUserType},
+		}, imports)).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet(arg0 time.Month, arg1 rpc.Any, "+
+				"arg2 rpc.Any) (rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"#.user:Get\", "+
+				"arg0, arg1, arg2)\n"+
+				"}",
+			nil,
+		)
+	})
+
+	t.Run("reply type", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		imports := newStubImports()
+		assert(getStubMethodBody("UserGet", "#.user:Get", stubAction{
+			kind:  "S",
+			reply: urlType,
+		}, imports)).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet(arg0 rpc.String) "+
+				"(ret *url.URL, err *rpc.Error) {\n"+
+				"\tvar v rpc.Any\n"+
+				"\tif v, err = p.client.Send(p.timeout, \"#.user:Get\", "+
+				"arg0); err == nil {\n"+
+				"\t\terr = rpc.DecodeValue(v, &ret)\n"+
+				"\t}\n"+
+				"\treturn\n"+
+				"}",
+			nil,
+		)
+		assert(imports["url"]).Equal("net/url")
+	})
+
+	t.Run("reply type can not be named", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getStubMethodBody("UserGet", "#.user:Get", stubAction{
+			kind:  "",
+			reply: testReflectUserType,
+		}, newStubImports())).Equal(
+			"// UserGet calls #.user:Get\n"+
+				"func (p *Client) UserGet() (rpc.Any, *rpc.Error) {\n"+
+				"\treturn p.client.Send(p.timeout, \"#.user:Get\")\n"+
+				"}",
+			nil,
+		)
+	})
+}
+
+func TestBuildClientStub(t *testing.T) {
+	_, curFile, _, _ := runtime.Caller(0)
+	curDir := path.Dir(curFile)
+	defer func() {
+		_ = os.RemoveAll(path.Join(path.Dir(curFile), "_tmp_"))
+	}()
+
+	t.Run("duplicate name", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_tmp_/duplicate-name.go")
+		assert(buildClientStub("pkgName", filePath, map[string]stubAction{
+			"#.user:Get": {kind: ""},
+			"#.User:Get": {kind: ""},
+		})).Equal(base.ErrClientStubDuplicateName.AddDebug(
+			"duplicate method name UserGet (#.User:Get and #.user:Get)",
+		))
+	})
+
+	t.Run("illegal kind", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_tmp_/illegal-kind.go")
+		assert(buildClientStub("pkgName", filePath, map[string]stubAction{
+			"#.user:Get": {kind: "T"},
+		})).Equal(base.ErrFnCacheIllegalKindString.AddDebug("illegal kind T"))
+	})
+
+	t.Run("mkdir error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "fn_stub_test.go", "error.go")
+		assert(buildClientStub("pkgName", filePath, map[string]stubAction{})).
+			Equal(base.ErrCacheMkdirAll)
+	})
+
+	t.Run("write to file error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_snapshot_")
+		assert(buildClientStub("pkgName", filePath, map[string]stubAction{})).
+			Equal(base.ErrCacheWriteFile)
+	})
+
+	t.Run("actions is empty", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_tmp_/test-stub-01.go")
+		snapPath := path.Join(curDir, "_snapshot_/test-stub-01.snapshot")
+		assert(buildClientStub("pkgName", filePath, map[string]stubAction{})).IsNil()
+		assert(base.ReadFromFile(filePath)).Equal(base.ReadFromFile(snapPath))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		filePath := path.Join(curDir, "_tmp_/test-stub-02.go")
+		snapPath := path.Join(curDir, "_snapshot_/test-stub-02.snapshot")
+		assert(buildClientStub("pkgName", filePath, map[string]stubAction{
+			"#.user:Login":       {kind: ""},
+			"#.user:SayHello":    {kind: "S"},
+			"#.user.info:Set":    {kind: "BIUFSX"},
+			"#.user.info:Update": {kind: "AMVYZ"},
+			"#.user.info:Get": {
+				kind:  "RR",
+				args:  []reflect.Type{monthType, testReflectUserType},
+				reply: urlType,
+			},
+		})).IsNil()
+		assert(base.ReadFromFile(filePath)).Equal(base.ReadFromFile(snapPath))
+	})
+}
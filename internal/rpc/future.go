@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+// Future is the result of Runtime.CallAsync. It can only be waited in the
+// goroutine of the action that makes the call
+type Future struct {
+	rt          Runtime
+	fileLine    string
+	stream      *Stream
+	ret         RTValue
+	isParsed    bool
+	doneCH      chan bool
+	frame       *rpcThreadFrame
+	isCancelled bool
+	isDropped   bool
+	sync.Mutex
+}
+
+func newFuture(rt Runtime, fileLine string) *Future {
+	return &Future{
+		rt:          rt,
+		fileLine:    fileLine,
+		stream:      nil,
+		ret:         RTValue{},
+		isParsed:    false,
+		doneCH:      make(chan bool),
+		frame:       nil,
+		isCancelled: false,
+		isDropped:   false,
+	}
+}
+
+func newErrorFuture(err *base.Error) *Future {
+	ret := newFuture(Runtime{}, "")
+	ret.ret = RTValue{err: err}
+	ret.isParsed = true
+	close(ret.doneCH)
+	return ret
+}
+
+// start binds the frame that evaluates the call, so the cancellation of the
+// caller can reach it
+func (p *Future) start(frame *rpcThreadFrame) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.frame = frame
+	if p.isCancelled {
+		frame.cancel()
+	}
+}
+
+func (p *Future) cancel() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.isCancelled = true
+	if p.frame != nil {
+		p.frame.cancel()
+	}
+}
+
+// drop releases the result when the caller has finished without waiting
+func (p *Future) drop() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.isDropped = true
+	if p.stream != nil && !p.isParsed {
+		p.stream.Release()
+		p.stream = nil
+	}
+}
+
+func (p *Future) finish(stream *Stream) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.frame = nil
+	if p.isDropped {
+		stream.Release()
+	} else {
+		p.stream = stream
+	}
+	close(p.doneCH)
+}
+
+// Wait waits until the call finishes, and returns its result
+func (p *Future) Wait() RTValue {
+	<-p.doneCH
+
+	if !p.isParsed {
+		if p.rt.lock() == nil {
+			return RTValue{
+				err: base.ErrRuntimeIllegalInCurrentGoroutine.
+					AddDebug(base.GetFileLine(1)),
+			}
+		}
+		defer p.rt.unlock()
+
+		p.ret = p.rt.parseResponseStream(p.stream)
+		if p.ret.err != nil {
+			p.ret.err = p.ret.err.AddDebug(p.fileLine)
+		}
+		p.stream.Release()
+		p.stream = nil
+		p.isParsed = true
+	}
+
+	return p.ret
+}
+
+// WaitAll waits until all the calls finish, and returns their results in
+// order
+func WaitAll(futures ...*Future) []RTValue {
+	ret := make([]RTValue, len(futures))
+	for i, future := range futures {
+		ret[i] = future.Wait()
+	}
+	return ret
+}
+
+// WaitAny waits until one of the calls finishes, and returns its index and
+// result. It returns -1 if futures is empty
+func WaitAny(futures ...*Future) (int, RTValue) {
+	if len(futures) == 0 {
+		return -1, RTValue{}
+	}
+
+	cases := make([]reflect.SelectCase, len(futures))
+	for i, future := range futures {
+		cases[i] = reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(future.doneCH),
+		}
+	}
+
+	idx, _, _ := reflect.Select(cases)
+	return idx, futures[idx].Wait()
+}
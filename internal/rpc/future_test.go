@@ -0,0 +1,201 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+func TestNewFuture(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		rt := Runtime{id: 3}
+		v := newFuture(rt, "fileLine")
+		assert(v.rt).Equal(rt)
+		assert(v.fileLine).Equal("fileLine")
+		assert(v.stream).IsNil()
+		assert(v.ret).Equal(RTValue{})
+		assert(v.isParsed).IsFalse()
+		assert(v.doneCH).IsNotNil()
+		assert(v.frame).IsNil()
+		assert(v.isCancelled).IsFalse()
+		assert(v.isDropped).IsFalse()
+	})
+}
+
+func TestNewErrorFuture(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newErrorFuture(base.ErrStream)
+		assert(v.ret).Equal(RTValue{err: base.ErrStream})
+		assert(v.isParsed).IsTrue()
+		_, ok := <-v.doneCH
+		assert(ok).IsFalse()
+	})
+}
+
+func TestFuture_start(t *testing.T) {
+	t.Run("not cancelled", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		frame := newRPCThreadFrame()
+		defer frame.Release()
+		v.start(frame)
+		assert(v.frame).Equal(frame)
+		assert(frame.getCancelled()).IsFalse()
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		v.cancel()
+		frame := newRPCThreadFrame()
+		defer frame.Release()
+		v.start(frame)
+		assert(frame.getCancelled()).IsTrue()
+	})
+}
+
+func TestFuture_cancel(t *testing.T) {
+	t.Run("not started", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		v.cancel()
+		assert(v.isCancelled).IsTrue()
+	})
+
+	t.Run("started", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		frame := newRPCThreadFrame()
+		defer frame.Release()
+		v.start(frame)
+		v.cancel()
+		assert(v.isCancelled).IsTrue()
+		assert(frame.getCancelled()).IsTrue()
+	})
+}
+
+func TestFuture_drop(t *testing.T) {
+	t.Run("not finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		v.drop()
+		assert(v.isDropped).IsTrue()
+		v.finish(NewStream())
+		assert(v.stream).IsNil()
+	})
+
+	t.Run("finished", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		v.finish(NewStream())
+		v.drop()
+		assert(v.isDropped).IsTrue()
+		assert(v.stream).IsNil()
+	})
+}
+
+func TestFuture_finish(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		frame := newRPCThreadFrame()
+		defer frame.Release()
+		v.start(frame)
+		stream := NewStream()
+		defer stream.Release()
+		v.finish(stream)
+		assert(v.stream).Equal(stream)
+		assert(v.frame).IsNil()
+		_, ok := <-v.doneCH
+		assert(ok).IsFalse()
+	})
+}
+
+func TestFuture_Wait(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newFuture(Runtime{}, "")
+		v.finish(NewStream())
+		ret, source := v.Wait(), base.GetFileLine(0)
+		assert(ret).Equal(RTValue{
+			err: base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(source),
+		})
+		assert(v.isParsed).IsFalse()
+	})
+
+	t.Run("is parsed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newErrorFuture(base.ErrStream)
+		assert(v.Wait()).Equal(RTValue{err: base.ErrStream})
+		assert(v.Wait()).Equal(RTValue{err: base.ErrStream})
+	})
+
+	t.Run("reply error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		source := ""
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				v := newFuture(rt, "fileLine")
+				stream := NewStream()
+				stream.SetKind(StreamKindRPCResponseError)
+				stream.WriteUint64(uint64(base.ErrStream.GetCode()))
+				stream.WriteString(base.ErrStream.GetMessage())
+				v.finish(stream)
+				_, err := v.Wait().ToString()
+				assert(v.stream).IsNil()
+				assert(v.isParsed).IsTrue()
+				ret, s := rt.Reply(err), base.GetFileLine(0)
+				source = rt.thread.GetActionNode().path + " " + s
+				return ret
+			}, nil),
+		)).Equal(nil, base.ErrStream.AddDebug("fileLine").AddDebug(source).Standardize())
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				v := newFuture(rt, "")
+				stream := NewStream()
+				stream.SetKind(StreamKindRPCResponseOK)
+				stream.WriteString("hello")
+				v.finish(stream)
+				ret1, _ := v.Wait().ToString()
+				ret2, _ := v.Wait().ToString()
+				return rt.Reply(Array{ret1, ret2})
+			}, nil),
+		)).Equal(Array{"hello", "hello"}, nil)
+	})
+}
+
+func TestWaitAll(t *testing.T) {
+	t.Run("futures is empty", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WaitAll()).Equal([]RTValue{})
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WaitAll(
+			newErrorFuture(base.ErrStream),
+			newErrorFuture(base.ErrAction),
+		)).Equal([]RTValue{{err: base.ErrStream}, {err: base.ErrAction}})
+	})
+}
+
+func TestWaitAny(t *testing.T) {
+	t.Run("futures is empty", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WaitAny()).Equal(-1, RTValue{})
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(WaitAny(
+			newFuture(Runtime{}, ""),
+			newErrorFuture(base.ErrStream),
+		)).Equal(1, RTValue{err: base.ErrStream})
+	})
+}
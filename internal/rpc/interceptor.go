@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+// ActionInterceptor runs around the actions. Before is called before the
+// action is executed, and if it returns an error, the action is skipped and
+// the error is replied to the caller. After is called when the reply has been
+// made, and it is only called on the interceptors whose Before has been
+// called and passed
+type ActionInterceptor interface {
+	Before(rt Runtime, ctx *ActionContext) *base.Error
+	After(ctx *ActionContext)
+}
+
+// ActionContext ...
+type ActionContext struct {
+	path   string
+	from   string
+	args   Array
+	result Any
+	err    *base.Error
+	cost   time.Duration
+}
+
+func newActionContext(path string, from string, stream *Stream) *ActionContext {
+	ret := &ActionContext{
+		path:   path,
+		from:   from,
+		args:   Array{},
+		result: nil,
+		err:    nil,
+		cost:   0,
+	}
+
+	readPos := stream.GetReadPos()
+	for !stream.IsReadFinish() {
+		if v, err := stream.Read(); err == nil {
+			ret.args = append(ret.args, v)
+		} else {
+			break
+		}
+	}
+	stream.SetReadPos(readPos)
+
+	return ret
+}
+
+// GetPath ...
+func (p *ActionContext) GetPath() string {
+	return p.path
+}
+
+// GetFrom ...
+func (p *ActionContext) GetFrom() string {
+	return p.from
+}
+
+// GetArgs ...
+func (p *ActionContext) GetArgs() Array {
+	return p.args
+}
+
+// GetResult returns the reply value. It is only available in After
+func (p *ActionContext) GetResult() Any {
+	return p.result
+}
+
+// GetError returns the reply error. It is only available in After
+func (p *ActionContext) GetError() *base.Error {
+	return p.err
+}
+
+// GetCost returns the execution time. It is only available in After
+func (p *ActionContext) GetCost() time.Duration {
+	return p.cost
+}
@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+type testActionInterceptor struct {
+	name   string
+	before func(rt Runtime, ctx *ActionContext) *base.Error
+	after  func(ctx *ActionContext)
+}
+
+func (p *testActionInterceptor) Before(
+	rt Runtime,
+	ctx *ActionContext,
+) *base.Error {
+	if p.before != nil {
+		return p.before(rt, ctx)
+	}
+	return nil
+}
+
+func (p *testActionInterceptor) After(ctx *ActionContext) {
+	if p.after != nil {
+		p.after(ctx)
+	}
+}
+
+func TestNewActionContext(t *testing.T) {
+	t.Run("no args", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		v := newActionContext("#.user:sayHello", "@", stream)
+		assert(v.path).Equal("#.user:sayHello")
+		assert(v.from).Equal("@")
+		assert(v.args).Equal(Array{})
+		assert(v.result).IsNil()
+		assert(v.err).IsNil()
+		assert(v.cost).Equal(time.Duration(0))
+	})
+
+	t.Run("args ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.WriteString("#.user:sayHello")
+		stream.WriteString("@")
+		stream.WriteInt64(3)
+		stream.WriteString("kitty")
+		stream.Write(Map{"age": int64(18)})
+		_, _ = stream.ReadString()
+		_, _ = stream.ReadString()
+		readPos := stream.GetReadPos()
+
+		v := newActionContext("#.user:sayHello", "@", stream)
+		assert(v.args).Equal(Array{int64(3), "kitty", Map{"age": int64(18)}})
+		assert(stream.GetReadPos()).Equal(readPos)
+	})
+
+	t.Run("args error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		readPos := stream.GetReadPos()
+		stream.WriteBool(true)
+		stream.PutBytes([]byte{13})
+		v := newActionContext("#.user:sayHello", "@", stream)
+		assert(v.args).Equal(Array{true})
+		assert(stream.GetReadPos()).Equal(readPos)
+	})
+}
+
+func TestActionContext_GetPath(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &ActionContext{path: "#.user:sayHello"}
+		assert(v.GetPath()).Equal("#.user:sayHello")
+	})
+}
+
+func TestActionContext_GetFrom(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &ActionContext{from: "@"}
+		assert(v.GetFrom()).Equal("@")
+	})
+}
+
+func TestActionContext_GetArgs(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &ActionContext{args: Array{true, "kitty"}}
+		assert(v.GetArgs()).Equal(Array{true, "kitty"})
+	})
+}
+
+func TestActionContext_GetResult(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &ActionContext{result: "hello"}
+		assert(v.GetResult()).Equal("hello")
+	})
+}
+
+func TestActionContext_GetError(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &ActionContext{err: base.ErrStream}
+		assert(v.GetError()).Equal(base.ErrStream)
+	})
+}
+
+func TestActionContext_GetCost(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := &ActionContext{cost: time.Second}
+		assert(v.GetCost()).Equal(time.Second)
+	})
+}
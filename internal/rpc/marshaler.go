@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// Marshaler is implemented by the types that write themselves to the stream
+// and read themselves back, like decimal amounts, UUIDs or geo points.
+// MarshalStream must write exactly one value, which may be an Array or a Map,
+// and UnmarshalStream must read exactly that value. For example:
+//
+//	func (p *Point) MarshalStream(stream *Stream) *base.Error {
+//	    stream.WriteBytes(p.encode())
+//	    return nil
+//	}
+//
+//	func (p *Point) UnmarshalStream(stream *Stream) *base.Error {
+//	    b, err := stream.ReadBytes()
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return p.decode(b)
+//	}
+//
+// The callers that do not know the type read the value that MarshalStream
+// writes, like the Bytes in the example
+type Marshaler interface {
+	MarshalStream(stream *Stream) *base.Error
+	UnmarshalStream(stream *Stream) *base.Error
+}
+
+// isMarshalerType reports whether the values of the type, or the pointers to
+// them, implement Marshaler
+func isMarshalerType(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+
+	return t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType)
+}
+
+func (p *Stream) writeMarshaler(v Marshaler) string {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		p.WriteNil()
+		return StreamWriteOK
+	}
+
+	startPos := p.GetWritePos()
+	if err := v.MarshalStream(p); err != nil {
+		p.SetWritePos(startPos)
+		return fmt.Sprintf(
+			" type(%T) marshal error: %s",
+			v,
+			err.GetMessage(),
+		)
+	} else if p.GetWritePos() == startPos {
+		return fmt.Sprintf(" type(%T) marshal error: nothing is written", v)
+	}
+
+	return StreamWriteOK
+}
+
+// ReadMarshaler reads the value that the Marshaler writes. The read position
+// is restored if the Marshaler does not read exactly one value
+func (p *Stream) ReadMarshaler(v Marshaler) *base.Error {
+	readPos := p.GetReadPos()
+
+	if skip, _ := p.peekSkip(); skip <= 0 {
+		return base.ErrStream
+	} else if err := v.UnmarshalStream(p); err != nil {
+		p.SetReadPos(readPos)
+		return err
+	} else if p.GetReadPos()-readPos != skip {
+		p.SetReadPos(readPos)
+		return base.ErrStream
+	} else {
+		return nil
+	}
+}
+
+// readMarshalerValue reads the value of the type that isMarshalerType accepts
+func (p *Stream) readMarshalerValue(t reflect.Type) (reflect.Value, *base.Error) {
+	if t.Kind() == reflect.Ptr {
+		if _, err := p.ReadNil(); err == nil {
+			return reflect.Zero(t), nil
+		}
+
+		ret := reflect.New(t.Elem())
+		return ret, p.ReadMarshaler(ret.Interface().(Marshaler))
+	}
+
+	ret := reflect.New(t)
+	return ret.Elem(), p.ReadMarshaler(ret.Interface().(Marshaler))
+}
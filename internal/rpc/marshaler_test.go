@@ -0,0 +1,211 @@
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+type testMarshalPoint struct {
+	X int64
+	Y int64
+}
+
+func (p *testMarshalPoint) MarshalStream(stream *Stream) *base.Error {
+	stream.WriteString(fmt.Sprintf("%d,%d", p.X, p.Y))
+	return nil
+}
+
+func (p *testMarshalPoint) UnmarshalStream(stream *Stream) *base.Error {
+	s, err := stream.ReadString()
+	if err != nil {
+		return err
+	}
+
+	if _, e := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y); e != nil {
+		return base.ErrUnsupportedValue.AddDebug(e.Error())
+	}
+
+	return nil
+}
+
+type testMarshalError struct{}
+
+func (p testMarshalError) MarshalStream(_ *Stream) *base.Error {
+	return base.ErrUnsupportedValue
+}
+
+func (p testMarshalError) UnmarshalStream(_ *Stream) *base.Error {
+	return base.ErrUnsupportedValue
+}
+
+type testMarshalNothing struct{}
+
+func (p testMarshalNothing) MarshalStream(_ *Stream) *base.Error {
+	return nil
+}
+
+func (p testMarshalNothing) UnmarshalStream(_ *Stream) *base.Error {
+	return nil
+}
+
+type testMarshalTwice struct{}
+
+func (p *testMarshalTwice) MarshalStream(stream *Stream) *base.Error {
+	stream.WriteInt64(1)
+	return nil
+}
+
+func (p *testMarshalTwice) UnmarshalStream(stream *Stream) *base.Error {
+	stream.ReadInt64()
+	stream.ReadInt64()
+	return nil
+}
+
+func TestIsMarshalerType(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(isMarshalerType(reflect.TypeOf(testMarshalPoint{}))).IsTrue()
+		assert(isMarshalerType(reflect.TypeOf(&testMarshalPoint{}))).IsTrue()
+		assert(isMarshalerType(reflect.TypeOf(testMarshalError{}))).IsTrue()
+		assert(isMarshalerType(marshalerType)).IsFalse()
+		assert(isMarshalerType(reflect.TypeOf(testReflectUser{}))).IsFalse()
+	})
+}
+
+func TestStream_writeMarshaler(t *testing.T) {
+	t.Run("nil pointer", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		assert(stream.Write((*testMarshalPoint)(nil))).Equal(StreamWriteOK)
+		assert(stream.Read()).Equal(nil, nil)
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		writePos := stream.GetWritePos()
+		assert(stream.Write(testMarshalError{})).Equal(
+			"value type(rpc.testMarshalError) marshal error: " +
+				base.ErrUnsupportedValue.GetMessage(),
+		)
+		assert(stream.GetWritePos()).Equal(writePos)
+	})
+
+	t.Run("nothing is written", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		assert(stream.Write(testMarshalNothing{})).Equal(
+			"value type(rpc.testMarshalNothing) marshal error: nothing is written",
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		assert(stream.Write(&testMarshalPoint{X: 1, Y: -2})).Equal(StreamWriteOK)
+		// the value is not addressable
+		assert(stream.Write(testMarshalPoint{X: 3, Y: 4})).Equal(StreamWriteOK)
+		assert(stream.Write(Array{testMarshalPoint{X: 5, Y: 6}})).
+			Equal(StreamWriteOK)
+		assert(stream.Read()).Equal("1,-2", nil)
+		assert(stream.Read()).Equal("3,4", nil)
+		assert(stream.Read()).Equal(Array{"5,6"}, nil)
+	})
+}
+
+func TestStream_ReadMarshaler(t *testing.T) {
+	t.Run("nothing to read", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		assert(stream.ReadMarshaler(&testMarshalPoint{})).Equal(base.ErrStream)
+	})
+
+	t.Run("unmarshal error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.WriteString("hello")
+		readPos := stream.GetReadPos()
+		assert(stream.ReadMarshaler(&testMarshalPoint{})).Equal(
+			base.ErrUnsupportedValue.AddDebug("expected integer"),
+		)
+		assert(stream.GetReadPos()).Equal(readPos)
+	})
+
+	t.Run("read more than one value", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.WriteInt64(1)
+		stream.WriteInt64(2)
+		readPos := stream.GetReadPos()
+		assert(stream.ReadMarshaler(&testMarshalTwice{})).Equal(base.ErrStream)
+		assert(stream.GetReadPos()).Equal(readPos)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.Write(&testMarshalPoint{X: 1, Y: -2})
+		v := &testMarshalPoint{}
+		assert(stream.ReadMarshaler(v)).IsNil()
+		assert(v).Equal(&testMarshalPoint{X: 1, Y: -2})
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+}
+
+func TestStream_readMarshalerValue(t *testing.T) {
+	t.Run("test pointer", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.WriteNil()
+		stream.Write(&testMarshalPoint{X: 1, Y: 2})
+		pointType := reflect.TypeOf(&testMarshalPoint{})
+
+		v, err := stream.readMarshalerValue(pointType)
+		assert(v.Interface(), err).Equal((*testMarshalPoint)(nil), nil)
+		v, err = stream.readMarshalerValue(pointType)
+		assert(v.Interface(), err).Equal(&testMarshalPoint{X: 1, Y: 2}, nil)
+	})
+
+	t.Run("test value", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.Write(&testMarshalPoint{X: 1, Y: 2})
+		v, err := stream.readMarshalerValue(reflect.TypeOf(testMarshalPoint{}))
+		assert(v.Interface(), err).Equal(testMarshalPoint{X: 1, Y: 2}, nil)
+	})
+}
+
+func TestReadReflect_Marshaler(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := struct {
+			Points []testMarshalPoint
+			Center *testMarshalPoint
+		}{}
+		assert(DecodeValue(Map{
+			"Points": Array{"1,2", "3,4"},
+			"Center": "5,6",
+		}, &v)).IsNil()
+		assert(v.Points).Equal([]testMarshalPoint{{X: 1, Y: 2}, {X: 3, Y: 4}})
+		assert(v.Center).Equal(&testMarshalPoint{X: 5, Y: 6})
+		assert(DecodeValue(Map{"Center": int64(3)}, &v)).Equal(
+			base.ErrUnsupportedValue.AddDebug(
+				"value[\"Center\"] type(rpc.Int64) can not be converted to " +
+					"*rpc.testMarshalPoint",
+			),
+		)
+	})
+}
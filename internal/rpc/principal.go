@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"strings"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+const (
+	// RoleNode is the role of the peer nodes. The calls that come from a
+	// session of this role are trusted to carry the identity of the caller
+	RoleNode = "$node"
+
+	// MetadataKeySession is the metadata key of the session that a remote call
+	// comes from. Its value is the post endpoint of the session on the origin
+	// node
+	MetadataKeySession = "$session"
+
+	metadataKeyPrincipal = "$principal"
+	metadataKeyRoles     = "$roles"
+)
+
+// Principal is the identity of an authenticated client. It is attached to the
+// session by the authenticator of the gateway
+type Principal struct {
+	name  string
+	roles []string
+}
+
+// NewPrincipal ...
+func NewPrincipal(name string, roles ...string) *Principal {
+	return &Principal{
+		name:  name,
+		roles: append([]string{}, roles...),
+	}
+}
+
+// GetName ...
+func (p *Principal) GetName() string {
+	return p.name
+}
+
+// GetRoles ...
+func (p *Principal) GetRoles() []string {
+	return append([]string{}, p.roles...)
+}
+
+// HasRole ...
+func (p *Principal) HasRole(role string) bool {
+	for _, v := range p.roles {
+		if v == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Principal) hasAnyRole(roles []string) bool {
+	if p != nil {
+		for _, role := range roles {
+			if p.HasRole(role) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// readForwardedPrincipal reads the principal that a peer node forwards in the
+// metadata. ok is false if the metadata does not carry the principal
+func readForwardedPrincipal(
+	metadata map[string]string,
+) (principal *Principal, ok bool) {
+	name, ok := metadata[metadataKeyPrincipal]
+	if !ok {
+		return nil, false
+	} else if name == "" {
+		// the caller is not authenticated
+		return nil, true
+	} else if strRoles := metadata[metadataKeyRoles]; strRoles == "" {
+		return NewPrincipal(name), true
+	} else {
+		return NewPrincipal(name, strings.Split(strRoles, ",")...), true
+	}
+}
+
+// makeForwardMetadata copies the metadata of the call, and adds the identity
+// of the caller for the node that mounts the target
+func makeForwardMetadata(
+	metadata map[string]string,
+	principal *Principal,
+	session string,
+) map[string]string {
+	ret := make(map[string]string, len(metadata)+3)
+	for key, value := range metadata {
+		ret[key] = value
+	}
+
+	if principal != nil {
+		ret[metadataKeyPrincipal] = principal.name
+		ret[metadataKeyRoles] = strings.Join(principal.roles, ",")
+	} else {
+		ret[metadataKeyPrincipal] = ""
+		ret[metadataKeyRoles] = ""
+	}
+	ret[MetadataKeySession] = session
+
+	return ret
+}
+
+// ForwardRequestStream replaces the identity in the metadata of the request
+// stream by the principal and the session of the caller, so the node that
+// evaluates the stream knows the caller. The node should trust the stream
+// only if the session that it comes from has a principal with RoleNode
+func ForwardRequestStream(
+	stream *Stream,
+	principal *Principal,
+	session string,
+) *base.Error {
+	stream.SetReadPosToBodyStart()
+
+	if target, err := stream.ReadString(); err != nil {
+		return err
+	} else if from, err := stream.ReadString(); err != nil {
+		return err
+	} else if deadline, err := readDeadline(stream); err != nil {
+		return err
+	} else if metadata, err := readMetadata(stream); err != nil {
+		return err
+	} else {
+		args := stream.GetBuffer()[stream.GetReadPos():]
+		stream.SetWritePosToBodyStart()
+		stream.WriteString(target)
+		stream.WriteString(from)
+		if deadline > 0 {
+			stream.WriteInt64(deadline)
+		}
+		stream.SetStatusBitMetadata()
+		stream.WriteMetadata(makeForwardMetadata(metadata, principal, session))
+		stream.PutBytes(args)
+		stream.SetReadPosToBodyStart()
+		return nil
+	}
+}
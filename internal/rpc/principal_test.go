@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+func TestNewPrincipal(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		roles := []string{"admin", "user"}
+		v := NewPrincipal("kitty", roles...)
+		assert(v.name).Equal("kitty")
+		assert(v.roles).Equal([]string{"admin", "user"})
+		roles[0] = "guest"
+		assert(v.roles).Equal([]string{"admin", "user"})
+	})
+}
+
+func TestPrincipal_GetName(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(NewPrincipal("kitty").GetName()).Equal("kitty")
+	})
+}
+
+func TestPrincipal_GetRoles(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewPrincipal("kitty", "admin")
+		roles := v.GetRoles()
+		assert(roles).Equal([]string{"admin"})
+		roles[0] = "guest"
+		assert(v.GetRoles()).Equal([]string{"admin"})
+	})
+}
+
+func TestPrincipal_HasRole(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewPrincipal("kitty", "admin", "user")
+		assert(v.HasRole("admin")).IsTrue()
+		assert(v.HasRole("user")).IsTrue()
+		assert(v.HasRole("guest")).IsFalse()
+		assert(NewPrincipal("kitty").HasRole("")).IsFalse()
+	})
+}
+
+func TestPrincipal_hasAnyRole(t *testing.T) {
+	t.Run("principal is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert((*Principal)(nil).hasAnyRole([]string{"admin"})).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewPrincipal("kitty", "admin", "user")
+		assert(v.hasAnyRole([]string{"guest", "user"})).IsTrue()
+		assert(v.hasAnyRole([]string{"guest"})).IsFalse()
+		assert(v.hasAnyRole(nil)).IsFalse()
+	})
+}
+
+func TestReadForwardedPrincipal(t *testing.T) {
+	t.Run("principal is not forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readForwardedPrincipal(nil)).Equal(nil, false)
+		assert(readForwardedPrincipal(map[string]string{"key": "value"})).
+			Equal(nil, false)
+	})
+
+	t.Run("caller is not authenticated", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readForwardedPrincipal(map[string]string{
+			metadataKeyPrincipal: "",
+			metadataKeyRoles:     "admin",
+		})).Equal(nil, true)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readForwardedPrincipal(map[string]string{
+			metadataKeyPrincipal: "kitty",
+		})).Equal(NewPrincipal("kitty"), true)
+		assert(readForwardedPrincipal(map[string]string{
+			metadataKeyPrincipal: "kitty",
+			metadataKeyRoles:     "admin,user",
+		})).Equal(NewPrincipal("kitty", "admin", "user"), true)
+	})
+}
+
+func TestMakeForwardMetadata(t *testing.T) {
+	t.Run("principal is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(makeForwardMetadata(nil, nil, "session")).
+			Equal(map[string]string{
+				metadataKeyPrincipal: "",
+				metadataKeyRoles:     "",
+				MetadataKeySession:   "session",
+			})
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		metadata := map[string]string{
+			"key":                "value",
+			metadataKeyPrincipal: "doggy",
+		}
+		assert(makeForwardMetadata(
+			metadata,
+			NewPrincipal("kitty", "admin", "user"),
+			"session",
+		)).Equal(map[string]string{
+			"key":                "value",
+			metadataKeyPrincipal: "kitty",
+			metadataKeyRoles:     "admin,user",
+			MetadataKeySession:   "session",
+		})
+		// the metadata of the call is not modified
+		assert(metadata[metadataKeyPrincipal]).Equal("doggy")
+	})
+}
+
+func TestForwardRequestStream(t *testing.T) {
+	t.Run("read target error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		assert(ForwardRequestStream(stream, nil, "")).Equal(base.ErrStream)
+	})
+
+	t.Run("read from error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.WriteString("#.user:Get")
+		assert(ForwardRequestStream(stream, nil, "")).Equal(base.ErrStream)
+	})
+
+	t.Run("read deadline error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.SetStatusBitDeadline()
+		stream.WriteString("#.user:Get")
+		stream.WriteString("")
+		assert(ForwardRequestStream(stream, nil, "")).Equal(base.ErrStream)
+	})
+
+	t.Run("read metadata error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.SetStatusBitMetadata()
+		stream.WriteString("#.user:Get")
+		stream.WriteString("")
+		assert(ForwardRequestStream(stream, nil, "")).Equal(base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for _, deadline := range []int64{0, 1234} {
+			for _, metadata := range []map[string]string{
+				nil,
+				{"key": "value", metadataKeyPrincipal: "doggy"},
+			} {
+				// the args are long enough to cross the frames
+				name := strings.Repeat("a", 3000)
+				stream, _ := makeRequestStream(
+					true, 3, deadline, metadata, "#.user:Get", "@", name, 5,
+				)
+				stream.SetCallbackID(15)
+				assert(ForwardRequestStream(
+					stream,
+					NewPrincipal("kitty", "admin"),
+					"session",
+				)).IsNil()
+				assert(stream.GetCallbackID()).Equal(uint64(15))
+				assert(stream.GetDepth()).Equal(uint16(3))
+				assert(stream.HasStatusBitDebug()).IsTrue()
+				assert(stream.ReadString()).Equal("#.user:Get", nil)
+				assert(stream.ReadString()).Equal("@", nil)
+				assert(readDeadline(stream)).Equal(deadline, nil)
+				expected := makeForwardMetadata(
+					metadata,
+					NewPrincipal("kitty", "admin"),
+					"session",
+				)
+				assert(readMetadata(stream)).Equal(expected, nil)
+				assert(stream.ReadString()).Equal(name, nil)
+				assert(stream.ReadInt64()).Equal(int64(5), nil)
+				assert(stream.IsReadFinish()).IsTrue()
+				stream.Release()
+			}
+		}
+	})
+}
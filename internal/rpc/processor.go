@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -12,48 +13,71 @@ import (
 	"github.com/rpccloud/rpc/internal/base"
 )
 
+// the session events that the gateway reports to the processor
+const (
+	// SessionEventOpen ...
+	SessionEventOpen = "onSessionOpen"
+	// SessionEventReconnect ...
+	SessionEventReconnect = "onSessionReconnect"
+	// SessionEventClose ...
+	SessionEventClose = "onSessionClose"
+)
+
 const (
 	rootName               = "#"
+	describeActionName     = "$describe"
 	freeGroups             = 1024
 	processorStatusClosed  = 0
 	processorStatusRunning = 1
+	defaultDeferTimeout    = 60 * time.Second
+	sessionHookQueueSize   = 8192
 )
 
 var (
 	nodeNameRegex   = regexp.MustCompile(`^[_0-9a-zA-Z]+$`)
 	actionNameRegex = regexp.MustCompile(
-		`^([_a-zA-Z][_0-9a-zA-Z]*)|(\$onMount)|(\$onUnmount)|(\$onUpdateConfig)$`,
+		`^(([_a-zA-Z][_0-9a-zA-Z]*)|` +
+			`(\$onMount)|(\$onUnmount)|(\$onUpdateConfig)|` +
+			`(\$onSessionOpen)|(\$onSessionReconnect)|(\$onSessionClose))$`,
 	)
 	emptyEvalBack   = func(*Stream) {}
 	emptyEvalFinish = func(*rpcThread) {}
 )
 
 type rpcActionNode struct {
-	path       string
-	meta       *ActionMeta
-	service    *rpcServiceNode
-	cacheFN    ActionCacheFunc
-	reflectFn  reflect.Value
-	callString string
-	argTypes   []reflect.Type
-	indicator  *base.PerformanceIndicator
+	path         string
+	meta         *ActionMeta
+	service      *rpcServiceNode
+	cacheFN      ActionCacheFunc
+	reflectFn    reflect.Value
+	callString   string
+	argTypes     []reflect.Type
+	indicator    *base.PerformanceIndicator
+	interceptors []ActionInterceptor
 }
 
 type rpcServiceNode struct {
-	path    string
-	addMeta *ServiceMeta
-	depth   uint16
-	isMount bool
-	data    Map
+	path         string
+	addMeta      *ServiceMeta
+	depth        uint16
+	isMount      bool
+	data         Map
+	interceptors []ActionInterceptor
 	sync.Mutex
 }
 
 func (p *rpcServiceNode) GetConfig(key string) (Any, bool) {
+	p.Lock()
+	defer p.Unlock()
+
 	v, ok := p.data[key]
 	return v, ok
 }
 
 func (p *rpcServiceNode) SetConfig(key string, value Any) bool {
+	p.Lock()
+	defer p.Unlock()
+
 	if p.data != nil {
 		p.data[key] = value
 		return true
@@ -62,6 +86,31 @@ func (p *rpcServiceNode) SetConfig(key string, value Any) bool {
 	return false
 }
 
+type rpcRunningKey struct {
+	gatewayID  uint64
+	sessionID  uint64
+	callbackID uint64
+}
+
+type rpcSessionKey struct {
+	gatewayID uint64
+	sessionID uint64
+}
+
+// rpcSessionSnapshot keeps the principal and the attributes of a closed
+// session, so the $onSessionClose hooks can still visit them
+type rpcSessionSnapshot struct {
+	principal  *Principal
+	attributes Map
+}
+
+// rpcSessionEvent is a session event that waits in the hook queue
+type rpcSessionEvent struct {
+	name     string
+	key      rpcSessionKey
+	snapshot *rpcSessionSnapshot
+}
+
 // Processor ...
 type Processor struct {
 	status            int32
@@ -71,12 +120,21 @@ type Processor struct {
 	maxCallDepth      uint16
 	threads           []*rpcThread
 	systemThread      *rpcThread
+	systemLock        sync.Mutex
 	freeCHArray       []chan *rpcThread
 	readThreadPos     uint64
 	writeThreadPos    uint64
 	panicSubscription *base.PanicSubscription
 	streamHub         IStreamHub
 	closeCH           chan string
+	runningMap        map[rpcRunningKey]*rpcThreadFrame
+	runningLock       sync.Mutex
+	futureMap         map[*Stream]*Future
+	futureLock        sync.Mutex
+	snapshotMap       map[rpcSessionKey]*rpcSessionSnapshot
+	snapshotLock      sync.Mutex
+	hookCH            chan *rpcSessionEvent
+	deferTimeout      int64
 	sync.Mutex
 }
 
@@ -89,6 +147,7 @@ func NewProcessor(
 	fnCache ActionCache,
 	closeTimeout time.Duration,
 	mountServices []*ServiceMeta,
+	interceptors []ActionInterceptor,
 	streamHub IStreamHub,
 ) *Processor {
 	if streamHub == nil {
@@ -124,6 +183,11 @@ func NewProcessor(
 			writeThreadPos: 0,
 			streamHub:      streamHub,
 			closeCH:        make(chan string),
+			runningMap:     make(map[rpcRunningKey]*rpcThreadFrame),
+			futureMap:      make(map[*Stream]*Future),
+			snapshotMap:    make(map[rpcSessionKey]*rpcSessionSnapshot),
+			hookCH:         make(chan *rpcSessionEvent, sessionHookQueueSize),
+			deferTimeout:   int64(defaultDeferTimeout),
 		}
 
 		// subscribe panic
@@ -144,10 +208,28 @@ func NewProcessor(
 
 		// mount nodes
 		ret.servicesMap[rootName] = &rpcServiceNode{
-			path:    rootName,
-			addMeta: nil,
-			depth:   0,
-			data:    Map{},
+			path:         rootName,
+			addMeta:      nil,
+			depth:        0,
+			data:         Map{},
+			interceptors: interceptors,
+		}
+
+		// mount the built-in actions on the root
+		if err := ret.mountAction(ret.servicesMap[rootName], &ActionMeta{
+			name: describeActionName,
+			handler: func(rt Runtime) Return {
+				debug := false
+				if thread := rt.lock(); thread != nil {
+					debug = thread.top.stream.HasStatusBitDebug()
+					rt.unlock()
+				}
+				return rt.Reply(ret.describe(rootName, debug))
+			},
+			fileLine: "",
+		}, nil); err != nil {
+			streamHub.OnReceiveStream(MakeSystemErrorStream(err))
+			return nil
 		}
 
 		for _, meta := range mountServices {
@@ -196,6 +278,14 @@ func NewProcessor(
 			ret.freeCHArray[i%freeGroups] <- thread
 		}
 
+		// start session hooks
+		go func() {
+			for event := range ret.hookCH {
+				ret.runSessionEvent(event)
+			}
+			ret.closeCH <- ""
+		}()
+
 		return ret
 	}
 }
@@ -213,6 +303,10 @@ func (p *Processor) Close() bool {
 		// wait for config update thread finish
 		<-p.closeCH
 
+		// the queued session hooks are put to the threads before they close
+		close(p.hookCH)
+		<-p.closeCH
+
 		// close worker threads
 		for i := 0; i < len(p.threads); i++ {
 			go func(idx int) {
@@ -297,6 +391,156 @@ func (p *Processor) PutStream(stream *Stream) (ret bool) {
 	return false
 }
 
+// tryPutFutureStream puts the stream of Runtime.CallAsync to a free thread. It
+// does not wait for a free thread, and returns false if there is none
+func (p *Processor) tryPutFutureStream(
+	stream *Stream,
+	future *Future,
+) (ret bool) {
+	p.futureLock.Lock()
+	p.futureMap[stream] = future
+	p.futureLock.Unlock()
+
+	defer func() {
+		if v := recover(); v != nil {
+			ret = false
+		}
+
+		if !ret {
+			p.futureLock.Lock()
+			delete(p.futureMap, stream)
+			p.futureLock.Unlock()
+		}
+	}()
+
+	select {
+	case thread := <-p.freeCHArray[atomic.AddUint64(
+		&p.readThreadPos,
+		1,
+	)%freeGroups]:
+		if thread != nil {
+			if thread.PutStream(stream) {
+				return true
+			}
+
+			p.freeCHArray[atomic.AddUint64(
+				&p.writeThreadPos,
+				1,
+			)%freeGroups] <- thread
+		}
+	default:
+	}
+
+	return false
+}
+
+// putHookStream puts the stream of a session hook to a free thread. It waits
+// for a free thread, and returns nil if the processor is closed
+func (p *Processor) putHookStream(stream *Stream) *Future {
+	// nobody waits the result, so the stream is released when it finishes
+	future := newFuture(Runtime{}, "")
+	future.drop()
+
+	p.futureLock.Lock()
+	p.futureMap[stream] = future
+	p.futureLock.Unlock()
+
+	if !p.PutStream(stream) {
+		p.futureLock.Lock()
+		delete(p.futureMap, stream)
+		p.futureLock.Unlock()
+		return nil
+	}
+
+	return future
+}
+
+// getRemoteHub returns the hub that forwards the call to another node. It
+// returns nil if the target is mounted on the processor
+func (p *Processor) getRemoteHub(target string) IRemoteHub {
+	if _, ok := p.actionsMap[target]; ok {
+		return nil
+	} else if hub, ok := p.streamHub.(IRemoteHub); ok {
+		return hub
+	} else {
+		return nil
+	}
+}
+
+// onCallback sends the evaluated stream back. The stream of Runtime.CallAsync
+// goes to its Future, the others go to the streamHub
+func (p *Processor) onCallback(stream *Stream) {
+	// the calls from the sessions always have a callbackID
+	if stream.GetCallbackID() == 0 {
+		p.futureLock.Lock()
+		future, ok := p.futureMap[stream]
+		delete(p.futureMap, stream)
+		p.futureLock.Unlock()
+
+		if ok {
+			future.finish(stream)
+			return
+		}
+	}
+
+	p.streamHub.OnReceiveStream(stream)
+}
+
+// Cancel marks the running call identified by gatewayID, sessionID and
+// callbackID as cancelled. It returns false if the call is not running
+func (p *Processor) Cancel(
+	gatewayID uint64,
+	sessionID uint64,
+	callbackID uint64,
+) bool {
+	p.runningLock.Lock()
+	defer p.runningLock.Unlock()
+
+	if frame, ok := p.runningMap[rpcRunningKey{
+		gatewayID:  gatewayID,
+		sessionID:  sessionID,
+		callbackID: callbackID,
+	}]; ok {
+		frame.cancel()
+		return true
+	}
+
+	return false
+}
+
+func (p *Processor) addRunning(stream *Stream, frame *rpcThreadFrame) {
+	if callbackID := stream.GetCallbackID(); callbackID != 0 {
+		p.runningLock.Lock()
+		p.runningMap[rpcRunningKey{
+			gatewayID:  stream.GetGatewayID(),
+			sessionID:  stream.GetSessionID(),
+			callbackID: callbackID,
+		}] = frame
+		p.runningLock.Unlock()
+	} else {
+		// the stream of Runtime.CallAsync is cancelled through its Future
+		p.futureLock.Lock()
+		future, ok := p.futureMap[stream]
+		p.futureLock.Unlock()
+
+		if ok {
+			future.start(frame)
+		}
+	}
+}
+
+func (p *Processor) removeRunning(stream *Stream) {
+	if callbackID := stream.GetCallbackID(); callbackID != 0 {
+		p.runningLock.Lock()
+		delete(p.runningMap, rpcRunningKey{
+			gatewayID:  stream.GetGatewayID(),
+			sessionID:  stream.GetSessionID(),
+			callbackID: callbackID,
+		})
+		p.runningLock.Unlock()
+	}
+}
+
 // BuildCache ...
 func (p *Processor) BuildCache(pkgName string, path string) *base.Error {
 	p.Lock()
@@ -305,7 +549,14 @@ func (p *Processor) BuildCache(pkgName string, path string) *base.Error {
 	if atomic.LoadInt32(&p.status) == processorStatusRunning {
 		retMap := make(map[string]bool)
 		for _, action := range p.actionsMap {
-			if fnTypeString, err := getFuncKind(action.reflectFn); err == nil {
+			// the built-in actions on the root never use the cache
+			if action.service.path == rootName {
+				continue
+			}
+
+			// the arguments that are decoded by reflection never use the cache
+			if fnTypeString, err := getFuncKind(action.reflectFn); err == nil &&
+				!strings.ContainsRune(fnTypeString, vkReflect) {
 				retMap[fnTypeString] = true
 			}
 		}
@@ -321,12 +572,274 @@ func (p *Processor) BuildCache(pkgName string, path string) *base.Error {
 	return base.ErrProcessorIsNotRunning
 }
 
+// SetDeferTimeout sets how long a DeferredReply can wait. If the call is not
+// replied in time, it is finished with ErrDeferredReplyTimeout. The deadline
+// of the call also bounds the DeferredReply
+func (p *Processor) SetDeferTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		atomic.StoreInt64(&p.deferTimeout, int64(timeout))
+	}
+}
+
+func (p *Processor) getDeferTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.deferTimeout))
+}
+
+// BuildClientStub writes a client package that has a typed method for each
+// action. The hooks and the built-in actions are not included
+func (p *Processor) BuildClientStub(pkgName string, path string) *base.Error {
+	p.Lock()
+	defer p.Unlock()
+
+	if atomic.LoadInt32(&p.status) == processorStatusRunning {
+		actions := make(map[string]stubAction)
+		for actionPath, action := range p.actionsMap {
+			if strings.HasPrefix(action.meta.name, "$") {
+				continue
+			}
+
+			if fnTypeString, err := getFuncKind(action.reflectFn); err == nil {
+				args := make([]reflect.Type, 0)
+				for i := 1; i < action.reflectFn.Type().NumIn(); i++ {
+					args = append(args, action.reflectFn.Type().In(i))
+				}
+				actions[actionPath] = stubAction{
+					kind:  fnTypeString,
+					args:  args,
+					reply: action.meta.reply,
+				}
+			}
+		}
+
+		return buildClientStub(pkgName, path, actions)
+	}
+
+	return base.ErrProcessorIsNotRunning
+}
+
 func (p *Processor) onUpdateConfig() {
 	for key := range p.servicesMap {
 		p.invokeSystemAction("onUpdateConfig", key)
 	}
 }
 
+// OnSessionEvent invokes the session hooks ($onSessionOpen,
+// $onSessionReconnect and $onSessionClose) of all the services. The hooks run
+// on the threads of the processor, so the hooks of different events may run
+// at the same time. The close event carries the snapshot of the session,
+// which the hooks get by Runtime.GetPrincipal and Runtime.SessionGet.
+// The event is queued, so the caller is not blocked when all the threads are
+// busy. The event is dropped and reported if the queue is full
+func (p *Processor) OnSessionEvent(stream *Stream) {
+	defer stream.Release()
+
+	if name, err := stream.ReadString(); err != nil {
+		p.streamHub.OnReceiveStream(MakeSystemErrorStream(base.ErrStream))
+	} else if name != SessionEventOpen &&
+		name != SessionEventReconnect &&
+		name != SessionEventClose {
+		p.streamHub.OnReceiveStream(MakeSystemErrorStream(base.ErrStream))
+	} else if snapshot, err := readSessionSnapshot(stream); err != nil ||
+		!stream.IsReadFinish() {
+		p.streamHub.OnReceiveStream(MakeSystemErrorStream(base.ErrStream))
+	} else {
+		key := rpcSessionKey{
+			gatewayID: stream.GetGatewayID(),
+			sessionID: stream.GetSessionID(),
+		}
+
+		if snapshot != nil {
+			p.snapshotLock.Lock()
+			p.snapshotMap[key] = snapshot
+			p.snapshotLock.Unlock()
+		}
+
+		if !p.putSessionEvent(&rpcSessionEvent{
+			name:     name,
+			key:      key,
+			snapshot: snapshot,
+		}) {
+			if snapshot != nil {
+				p.snapshotLock.Lock()
+				delete(p.snapshotMap, key)
+				p.snapshotLock.Unlock()
+			}
+
+			if atomic.LoadInt32(&p.status) == processorStatusRunning {
+				p.streamHub.OnReceiveStream(
+					MakeSystemErrorStream(base.ErrProcessorHookQueueIsFull),
+				)
+			}
+		}
+	}
+}
+
+// putSessionEvent puts the event to the hook queue. It does not wait, and
+// returns false if the queue is full or the processor is closed
+func (p *Processor) putSessionEvent(event *rpcSessionEvent) (ret bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			ret = false
+		}
+	}()
+
+	select {
+	case p.hookCH <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// runSessionEvent puts the hooks of the event to the threads. It waits for
+// the free threads, so it only runs on the hook queue goroutine
+func (p *Processor) runSessionEvent(event *rpcSessionEvent) {
+	futures := make([]*Future, 0)
+	for path := range p.servicesMap {
+		if future := p.invokeSessionAction(
+			event.name,
+			path,
+			event.key.gatewayID,
+			event.key.sessionID,
+		); future != nil {
+			futures = append(futures, future)
+		}
+	}
+
+	// the snapshot is removed after all the hooks finish
+	if event.snapshot != nil {
+		go func() {
+			for _, future := range futures {
+				<-future.doneCH
+			}
+			p.snapshotLock.Lock()
+			delete(p.snapshotMap, event.key)
+			p.snapshotLock.Unlock()
+		}()
+	}
+}
+
+func (p *Processor) getSessionSnapshot(
+	gatewayID uint64,
+	sessionID uint64,
+) *rpcSessionSnapshot {
+	p.snapshotLock.Lock()
+	defer p.snapshotLock.Unlock()
+	return p.snapshotMap[rpcSessionKey{
+		gatewayID: gatewayID,
+		sessionID: sessionID,
+	}]
+}
+
+// readSessionSnapshot reads the principal and the attributes that follow the
+// event name. It returns nil if the stream carries no snapshot
+func readSessionSnapshot(stream *Stream) (*rpcSessionSnapshot, *base.Error) {
+	if stream.IsReadFinish() {
+		return nil, nil
+	}
+
+	ret := &rpcSessionSnapshot{}
+	if _, err := stream.ReadNil(); err != nil {
+		name, err := stream.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		roles, err := stream.ReadArray()
+		if err != nil {
+			return nil, err
+		}
+		strRoles := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if v, ok := role.(string); ok {
+				strRoles = append(strRoles, v)
+			} else {
+				return nil, base.ErrStream
+			}
+		}
+		ret.principal = NewPrincipal(name, strRoles...)
+	}
+
+	if _, err := stream.ReadNil(); err != nil {
+		if ret.attributes, err = stream.ReadMap(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// describe returns the catalog of the service and all its descendants. It is
+// the reply of the built-in action "#:$describe". The hooks and the built-in
+// actions, whose names start with '$', are not included. The source file lines
+// are empty unless debug is true, so they are only shown to the debug callers
+func (p *Processor) describe(path string, debug bool) Map {
+	node := p.servicesMap[path]
+
+	name, fileLine := rootName, ""
+	if node.addMeta != nil {
+		name, fileLine = node.addMeta.name, node.addMeta.fileLine
+	}
+
+	node.Lock()
+	configKeys := make([]string, 0, len(node.data))
+	for key := range node.data {
+		configKeys = append(configKeys, key)
+	}
+	node.Unlock()
+	sort.Strings(configKeys)
+	config := make(Array, 0, len(configKeys))
+	for _, key := range configKeys {
+		config = append(config, key)
+	}
+
+	actionPaths := make([]string, 0)
+	for key, actionNode := range p.actionsMap {
+		if actionNode.service == node &&
+			!strings.HasPrefix(actionNode.meta.name, "$") {
+			actionPaths = append(actionPaths, key)
+		}
+	}
+	sort.Strings(actionPaths)
+	actions := make(Array, 0, len(actionPaths))
+	for _, key := range actionPaths {
+		actionNode := p.actionsMap[key]
+		// the first argument is always rpc.Runtime
+		args := make(Array, 0, len(actionNode.argTypes))
+		for i := 1; i < len(actionNode.argTypes); i++ {
+			args = append(args, convertTypeToString(actionNode.argTypes[i]))
+		}
+		actions = append(actions, Map{
+			"name":       actionNode.meta.name,
+			"path":       actionNode.path,
+			"args":       args,
+			"callString": actionNode.callString,
+			"fileLine":   getDebugFileLine(actionNode.meta.fileLine, debug),
+		})
+	}
+
+	childPaths := make([]string, 0)
+	for key := range p.servicesMap {
+		if strings.HasPrefix(key, path+".") &&
+			!strings.Contains(key[len(path)+1:], ".") {
+			childPaths = append(childPaths, key)
+		}
+	}
+	sort.Strings(childPaths)
+	children := make(Array, 0, len(childPaths))
+	for _, key := range childPaths {
+		children = append(children, p.describe(key, debug))
+	}
+
+	return Map{
+		"name":     name,
+		"path":     path,
+		"fileLine": getDebugFileLine(fileLine, debug),
+		"config":   config,
+		"actions":  actions,
+		"children": children,
+	}
+}
+
 func (p *Processor) invokeSystemAction(name string, path string) bool {
 	actionPath := path + ":$" + name
 	if _, ok := p.actionsMap[actionPath]; ok {
@@ -334,6 +847,10 @@ func (p *Processor) invokeSystemAction(name string, path string) bool {
 		defer func() {
 			stream.Release()
 		}()
+
+		// the system thread is shared by the mount and the config update
+		p.systemLock.Lock()
+		defer p.systemLock.Unlock()
 		p.systemThread.Eval(stream, false)
 		return true
 	}
@@ -341,6 +858,29 @@ func (p *Processor) invokeSystemAction(name string, path string) bool {
 	return false
 }
 
+func (p *Processor) invokeSessionAction(
+	name string,
+	path string,
+	gatewayID uint64,
+	sessionID uint64,
+) *Future {
+	actionPath := path + ":$" + name
+	if _, ok := p.actionsMap[actionPath]; ok {
+		stream, _ := MakeInternalRequestStream(true, 0, actionPath, "")
+		stream.SetGatewayID(gatewayID)
+		stream.SetSessionID(sessionID)
+
+		// the hooks do not block the system thread and the gateway
+		if future := p.putHookStream(stream); future != nil {
+			return future
+		}
+
+		stream.Release()
+	}
+
+	return nil
+}
+
 func (p *Processor) mountNode(
 	parentServiceNodePath string,
 	nodeMeta *ServiceMeta,
@@ -374,12 +914,18 @@ func (p *Processor) mountNode(
 					base.AddPrefixPerLine(item.addMeta.fileLine, "\t"),
 				))
 		} else {
+			// the interceptors of the parent run before its own
+			interceptors := make([]ActionInterceptor, 0)
+			interceptors = append(interceptors, parentNode.interceptors...)
+			interceptors = append(interceptors, nodeMeta.service.interceptors...)
+
 			node := &rpcServiceNode{
-				path:    servicePath,
-				addMeta: nodeMeta,
-				depth:   parentNode.depth + 1,
-				data:    Map{},
-				isMount: false,
+				path:         servicePath,
+				addMeta:      nodeMeta,
+				depth:        parentNode.depth + 1,
+				data:         Map{},
+				isMount:      false,
+				interceptors: interceptors,
 			}
 
 			for k, v := range nodeMeta.data {
@@ -415,6 +961,22 @@ func (p *Processor) mountNode(
 	}
 }
 
+func getDebugFileLine(fileLine string, debug bool) string {
+	if debug {
+		return fileLine
+	}
+
+	return ""
+}
+
+// isActionNameLegal reports whether the action can be mounted by the name. The
+// names of the built-in actions are only legal on the root, where the services
+// can not mount their actions
+func isActionNameLegal(servicePath string, name string) bool {
+	return actionNameRegex.MatchString(name) ||
+		(servicePath == rootName && name == describeActionName)
+}
+
 func (p *Processor) mountAction(
 	serviceNode *rpcServiceNode,
 	meta *ActionMeta,
@@ -422,7 +984,7 @@ func (p *Processor) mountAction(
 ) *base.Error {
 	if meta == nil {
 		return base.ErrProcessorActionMetaIsNil
-	} else if !actionNameRegex.MatchString(meta.name) {
+	} else if !isActionNameLegal(serviceNode.path, meta.name) {
 		return base.ErrActionName.
 			AddDebug(fmt.Sprintf("action name %s is illegal", meta.name)).
 			AddDebug(meta.fileLine)
@@ -473,8 +1035,9 @@ func (p *Processor) mountAction(
 				strings.Join(argStrings, ", "),
 				convertTypeToString(returnType),
 			),
-			argTypes:  argTypes,
-			indicator: base.NewPerformanceIndicator(),
+			argTypes:     argTypes,
+			indicator:    base.NewPerformanceIndicator(),
+			interceptors: serviceNode.interceptors,
 		}
 
 		if fnCache != nil {
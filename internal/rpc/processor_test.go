@@ -7,6 +7,8 @@ import (
 	"path"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -21,6 +23,7 @@ var (
 		nil,
 		5*time.Second,
 		nil,
+		nil,
 		NewTestStreamHub(),
 	)
 )
@@ -39,6 +42,7 @@ func testProcessorMountError(services []*ServiceMeta) *base.Error {
 		nil,
 		time.Second,
 		services,
+		nil,
 		streamHub,
 	)
 
@@ -101,10 +105,17 @@ func TestProcessor(t *testing.T) {
 		assert(actionNameRegex.MatchString("$onMount")).IsTrue()
 		assert(actionNameRegex.MatchString("$onUnmount")).IsTrue()
 		assert(actionNameRegex.MatchString("$onUpdateConfig")).IsTrue()
+		assert(actionNameRegex.MatchString("$onSessionOpen")).IsTrue()
+		assert(actionNameRegex.MatchString("$onSessionReconnect")).IsTrue()
+		assert(actionNameRegex.MatchString("$onSessionClose")).IsTrue()
 		assert(actionNameRegex.MatchString("onMount")).IsTrue()
 		assert(actionNameRegex.MatchString("sayHello")).IsTrue()
 		assert(actionNameRegex.MatchString("$sayHello")).IsFalse()
+		assert(actionNameRegex.MatchString("$describe")).IsFalse()
+		assert(actionNameRegex.MatchString("sayHello$")).IsFalse()
+		assert(actionNameRegex.MatchString("$sayHello$onMount")).IsFalse()
 		assert(rootName).Equal("#")
+		assert(describeActionName).Equal("$describe")
 		assert(freeGroups).Equal(1024)
 		assert(processorStatusClosed).Equal(0)
 		assert(processorStatusRunning).Equal(1)
@@ -116,7 +127,7 @@ func TestNewProcessor(t *testing.T) {
 		assert := base.NewAssert(t)
 		assert(base.RunWithCatchPanic(func() {
 			NewProcessor(
-				1024, 16, 16, 2048, nil, 5*time.Second, nil, nil,
+				1024, 16, 16, 2048, nil, 5*time.Second, nil, nil, nil,
 			)
 		})).Equal("streamHub is nil")
 	})
@@ -125,7 +136,7 @@ func TestNewProcessor(t *testing.T) {
 		assert := base.NewAssert(t)
 		streamHub := NewTestStreamHub()
 		assert(NewProcessor(
-			0, 16, 16, 2048, nil, 5*time.Second, nil, streamHub,
+			0, 16, 16, 2048, nil, 5*time.Second, nil, nil, streamHub,
 		)).Equal(nil)
 		assert(ParseResponseStream(streamHub.GetStream())).
 			Equal(nil, base.ErrNumOfThreadsIsWrong)
@@ -135,7 +146,7 @@ func TestNewProcessor(t *testing.T) {
 		assert := base.NewAssert(t)
 		streamHub := NewTestStreamHub()
 		assert(NewProcessor(
-			1024, 0, 16, 2048, nil, 5*time.Second, nil, streamHub,
+			1024, 0, 16, 2048, nil, 5*time.Second, nil, nil, streamHub,
 		)).Equal(nil)
 		assert(ParseResponseStream(streamHub.GetStream())).
 			Equal(nil, base.ErrMaxNodeDepthIsWrong)
@@ -145,7 +156,7 @@ func TestNewProcessor(t *testing.T) {
 		assert := base.NewAssert(t)
 		streamHub := NewTestStreamHub()
 		assert(NewProcessor(
-			1024, 16, 0, 2048, nil, 5*time.Second, nil, streamHub,
+			1024, 16, 0, 2048, nil, 5*time.Second, nil, nil, streamHub,
 		)).Equal(nil)
 		assert(ParseResponseStream(streamHub.GetStream())).
 			Equal(nil, base.ErrProcessorMaxCallDepthIsWrong)
@@ -156,7 +167,7 @@ func TestNewProcessor(t *testing.T) {
 		streamHub := NewTestStreamHub()
 		assert(NewProcessor(
 			1024, 16, 16, 2048, nil, 5*time.Second,
-			[]*ServiceMeta{nil}, streamHub,
+			[]*ServiceMeta{nil}, nil, streamHub,
 		)).Equal(nil)
 		assert(ParseResponseStream(streamHub.GetStream())).
 			Equal(nil, base.ErrProcessorNodeMetaIsNil)
@@ -173,10 +184,12 @@ func TestNewProcessor(t *testing.T) {
 				}),
 				fileLine: "",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 		assert(processor).IsNotNil()
 		assert(len(processor.threads)).Equal(freeGroups)
+		assert(processor.futureMap).Equal(map[*Stream]*Future{})
 		_ = processor.Close()
 	})
 
@@ -192,6 +205,7 @@ func TestNewProcessor(t *testing.T) {
 				}),
 				fileLine: "",
 			}},
+			nil,
 			streamHub,
 		)
 		assert(processor).IsNotNil()
@@ -225,6 +239,7 @@ func TestNewProcessor(t *testing.T) {
 				service:  service,
 				fileLine: "",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 		assert(processor).IsNotNil()
@@ -248,6 +263,7 @@ func TestNewProcessor(t *testing.T) {
 				service:  service,
 				fileLine: "",
 			}},
+			nil,
 			streamHub,
 		)
 
@@ -284,6 +300,7 @@ func TestProcessor_Close(t *testing.T) {
 			nil,
 			5*time.Second,
 			nil,
+			nil,
 			NewTestStreamHub(),
 		)
 		processor.Close()
@@ -317,6 +334,7 @@ func TestProcessor_Close(t *testing.T) {
 					}),
 					fileLine: "",
 				}},
+				nil,
 				streamHub,
 			)
 
@@ -367,6 +385,7 @@ func TestProcessor_PutStream(t *testing.T) {
 			nil,
 			time.Second,
 			nil,
+			nil,
 			NewTestStreamHub(),
 		)
 		processor.Close()
@@ -388,6 +407,7 @@ func TestProcessor_PutStream(t *testing.T) {
 			nil,
 			time.Second,
 			nil,
+			nil,
 			streamHub,
 		)
 
@@ -411,6 +431,243 @@ func TestProcessor_PutStream(t *testing.T) {
 	})
 }
 
+func TestProcessor_Cancel(t *testing.T) {
+	t.Run("call is not running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := NewProcessor(
+			1,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			nil,
+			nil,
+			NewTestStreamHub(),
+		)
+		defer processor.Close()
+		assert(processor.Cancel(1, 2, 3)).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		waitCH := make(chan bool)
+		helper := newTestProcessorHelper(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().
+					On("Eval", func(rt Runtime) Return {
+						waitCH <- true
+						<-rt.Done()
+						return rt.Reply(rt.IsCancelled())
+					}),
+				fileLine: "",
+			}},
+		)
+		defer helper.Close()
+
+		stream, _ := MakeInternalRequestStream(false, 0, "#.test:Eval", "")
+		stream.SetGatewayID(1)
+		stream.SetSessionID(2)
+		stream.SetCallbackID(3)
+		helper.GetProcessor().PutStream(stream)
+		<-waitCH
+		assert(helper.GetProcessor().Cancel(1, 2, 4)).IsFalse()
+		assert(helper.GetProcessor().Cancel(1, 2, 3)).IsTrue()
+		assert(ParseResponseStream(<-helper.streamHub.streamCH)).
+			Equal(true, nil)
+		assert(helper.GetProcessor().Cancel(1, 2, 3)).IsFalse()
+		assert(len(helper.GetProcessor().runningMap)).Equal(0)
+	})
+}
+
+func TestProcessor_tryPutFutureStream(t *testing.T) {
+	t.Run("no free thread", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{
+			freeCHArray: make([]chan *rpcThread, freeGroups),
+			futureMap:   make(map[*Stream]*Future),
+		}
+		for i := 0; i < freeGroups; i++ {
+			processor.freeCHArray[i] = make(chan *rpcThread, 1)
+		}
+		stream := NewStream()
+		defer stream.Release()
+		assert(processor.tryPutFutureStream(stream, newFuture(Runtime{}, ""))).
+			IsFalse()
+		assert(len(processor.futureMap)).Equal(0)
+	})
+
+	t.Run("processor is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper := newTestProcessorHelper(
+			1, 16, 16, 2048, nil, 3*time.Second, nil,
+		)
+		processor := helper.GetProcessor()
+		helper.Close()
+		stream := NewStream()
+		defer stream.Release()
+		assert(processor.tryPutFutureStream(stream, newFuture(Runtime{}, ""))).
+			IsFalse()
+		assert(len(processor.futureMap)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper := newTestProcessorHelper(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().
+					On("SayHello", func(rt Runtime, name string) Return {
+						return rt.Reply("hello " + name)
+					}),
+				fileLine: "",
+			}},
+		)
+		defer helper.Close()
+		future := newFuture(Runtime{}, "")
+		stream, _ := MakeInternalRequestStream(
+			true, 0, "#.test:SayHello", "", "kitty",
+		)
+		assert(helper.GetProcessor().tryPutFutureStream(stream, future)).
+			IsTrue()
+		<-future.doneCH
+		assert(future.stream).Equal(stream)
+		assert(ParseResponseStream(future.stream)).Equal("hello kitty", nil)
+		assert(helper.streamHub.GetStream()).IsNil()
+	})
+}
+
+func TestProcessor_getRemoteHub(t *testing.T) {
+	t.Run("target is mounted", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{
+			actionsMap: map[string]*rpcActionNode{"#.test:Eval": nil},
+			streamHub:  &testRemoteHub{},
+		}
+		assert(processor.getRemoteHub("#.test:Eval")).IsNil()
+	})
+
+	t.Run("stream hub is not a remote hub", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{
+			actionsMap: map[string]*rpcActionNode{},
+			streamHub:  NewTestStreamHub(),
+		}
+		assert(processor.getRemoteHub("#.test:Eval")).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		remoteHub := &testRemoteHub{}
+		processor := &Processor{
+			actionsMap: map[string]*rpcActionNode{},
+			streamHub:  remoteHub,
+		}
+		assert(processor.getRemoteHub("#.test:Eval")).Equal(remoteHub)
+	})
+}
+
+func TestProcessor_onCallback(t *testing.T) {
+	t.Run("stream belongs to a future", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := NewTestStreamHub()
+		processor := &Processor{
+			streamHub: streamHub,
+			futureMap: make(map[*Stream]*Future),
+		}
+		future := newFuture(Runtime{}, "")
+		stream := NewStream()
+		processor.futureMap[stream] = future
+		processor.onCallback(stream)
+		<-future.doneCH
+		assert(future.stream).Equal(stream)
+		assert(len(processor.futureMap)).Equal(0)
+		assert(streamHub.GetStream()).IsNil()
+	})
+
+	t.Run("stream has callbackID", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := NewTestStreamHub()
+		processor := &Processor{
+			streamHub: streamHub,
+			futureMap: make(map[*Stream]*Future),
+		}
+		stream := NewStream()
+		stream.SetCallbackID(3)
+		processor.onCallback(stream)
+		assert(streamHub.GetStream()).Equal(stream)
+	})
+
+	t.Run("stream does not belong to a future", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := NewTestStreamHub()
+		processor := &Processor{
+			streamHub: streamHub,
+			futureMap: make(map[*Stream]*Future),
+		}
+		stream := NewStream()
+		processor.onCallback(stream)
+		assert(streamHub.GetStream()).Equal(stream)
+	})
+}
+
+func TestProcessor_addRunning(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{
+			runningMap: make(map[rpcRunningKey]*rpcThreadFrame),
+		}
+		frame := newRPCThreadFrame()
+		defer frame.Release()
+		stream := NewStream()
+		defer stream.Release()
+
+		// callbackID is zero
+		processor.addRunning(stream, frame)
+		assert(len(processor.runningMap)).Equal(0)
+
+		stream.SetGatewayID(1)
+		stream.SetSessionID(2)
+		stream.SetCallbackID(3)
+		processor.addRunning(stream, frame)
+		assert(processor.runningMap).Equal(map[rpcRunningKey]*rpcThreadFrame{
+			{gatewayID: 1, sessionID: 2, callbackID: 3}: frame,
+		})
+	})
+}
+
+func TestProcessor_removeRunning(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{
+			runningMap: make(map[rpcRunningKey]*rpcThreadFrame),
+		}
+		frame := newRPCThreadFrame()
+		defer frame.Release()
+		stream := NewStream()
+		defer stream.Release()
+		stream.SetGatewayID(1)
+		stream.SetSessionID(2)
+		stream.SetCallbackID(3)
+		processor.addRunning(stream, frame)
+		processor.removeRunning(stream)
+		assert(len(processor.runningMap)).Equal(0)
+	})
+}
+
 func TestProcessor_BuildCache(t *testing.T) {
 	_, file, _, _ := runtime.Caller(0)
 	curDir := path.Dir(file)
@@ -430,6 +687,7 @@ func TestProcessor_BuildCache(t *testing.T) {
 			nil,
 			time.Second,
 			nil,
+			nil,
 			NewTestStreamHub(),
 		)
 		defer processor.Close()
@@ -455,6 +713,7 @@ func TestProcessor_BuildCache(t *testing.T) {
 				}),
 				fileLine: "",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 		defer processor.Close()
@@ -472,6 +731,7 @@ func TestProcessor_BuildCache(t *testing.T) {
 			nil,
 			time.Second,
 			nil,
+			nil,
 			NewTestStreamHub(),
 		)
 		processor.Close()
@@ -480,6 +740,68 @@ func TestProcessor_BuildCache(t *testing.T) {
 	})
 }
 
+func TestProcessor_BuildClientStub(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	curDir := path.Dir(file)
+	defer func() {
+		_ = os.RemoveAll(path.Join(path.Dir(file), "_tmp_"))
+	}()
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		tmpFile := path.Join(curDir, "_tmp_/test-processor-03.go")
+		processor := NewProcessor(
+			freeGroups,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().
+					On("Eval", func(rt Runtime, name String) Return {
+						return rt.Reply(name)
+					}).
+					SetReplyType("Eval", "").
+					On("$onMount", func(rt Runtime) Return {
+						return rt.Reply(true)
+					}),
+				fileLine: "",
+			}},
+			nil,
+			NewTestStreamHub(),
+		)
+		defer processor.Close()
+		assert(processor.BuildClientStub("pkgName", tmpFile)).IsNil()
+		content, _ := base.ReadFromFile(tmpFile)
+		assert(strings.Contains(
+			content,
+			"func (p *Client) TestEval(arg0 rpc.String) (ret string, err *rpc.Error) {",
+		)).IsTrue()
+		assert(strings.Contains(content, "$onMount")).IsFalse()
+		assert(strings.Contains(content, "$describe")).IsFalse()
+	})
+
+	t.Run("processor is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := NewProcessor(
+			freeGroups,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			nil,
+			nil,
+			NewTestStreamHub(),
+		)
+		processor.Close()
+		assert(processor.BuildClientStub("pkgName", "")).
+			Equal(base.ErrProcessorIsNotRunning)
+	})
+}
+
 func TestProcessor_onUpdateConfig(t *testing.T) {
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -506,6 +828,7 @@ func TestProcessor_onUpdateConfig(t *testing.T) {
 				}),
 				fileLine: "",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 		processor.onUpdateConfig()
@@ -515,6 +838,390 @@ func TestProcessor_onUpdateConfig(t *testing.T) {
 	})
 }
 
+func TestProcessor_OnSessionEvent(t *testing.T) {
+	fnStream := func(args ...interface{}) *Stream {
+		stream := NewStream()
+		stream.SetKind(StreamKindSessionEvent)
+		stream.SetGatewayID(1234)
+		stream.SetSessionID(5678)
+		for _, arg := range args {
+			stream.Write(arg)
+		}
+		return stream
+	}
+
+	fnTest := func(stream *Stream) ([]string, *Stream) {
+		waitCH := make(chan string, 8)
+		fnHook := func(name string) func(rt Runtime) Return {
+			return func(rt Runtime) Return {
+				waitCH <- name + " " + rt.GetPrincipal().GetName()
+				return rt.Reply(true)
+			}
+		}
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			principal:     NewPrincipal("kitty"),
+		}
+		processor := NewProcessor(
+			freeGroups,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			[]*ServiceMeta{{
+				name: "test1",
+				service: NewService().
+					On("$onSessionOpen", fnHook("test1:open")).
+					On("$onSessionReconnect", fnHook("test1:reconnect")).
+					On("$onSessionClose", fnHook("test1:close")),
+				fileLine: "",
+			}, {
+				name: "test2",
+				service: NewService().
+					On("$onSessionOpen", fnHook("test2:open")),
+				fileLine: "",
+			}},
+			nil,
+			sessionHub,
+		)
+		processor.OnSessionEvent(stream)
+		processor.Close()
+		close(waitCH)
+
+		ret := make([]string, 0)
+		for v := range waitCH {
+			ret = append(ret, v)
+		}
+		sort.Strings(ret)
+
+		select {
+		case errStream := <-sessionHub.streamCH:
+			return ret, errStream
+		default:
+			return ret, nil
+		}
+	}
+
+	t.Run("stream error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream())
+		assert(calls).Equal([]string{})
+		assert(ParseResponseStream(errStream)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("stream is not finish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream(SessionEventOpen, true))
+		assert(calls).Equal([]string{})
+		assert(ParseResponseStream(errStream)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("event is unknown", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream("onMount"))
+		assert(calls).Equal([]string{})
+		assert(ParseResponseStream(errStream)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("session open", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream(SessionEventOpen))
+		assert(calls).Equal([]string{"test1:open kitty", "test2:open kitty"})
+		assert(errStream).IsNil()
+	})
+
+	t.Run("session reconnect", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream(SessionEventReconnect))
+		assert(calls).Equal([]string{"test1:reconnect kitty"})
+		assert(errStream).IsNil()
+	})
+
+	t.Run("session close", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream(SessionEventClose))
+		assert(calls).Equal([]string{"test1:close kitty"})
+		assert(errStream).IsNil()
+	})
+
+	t.Run("session close with snapshot", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream(
+			SessionEventClose,
+			"doggy",
+			Array{"admin"},
+			nil,
+		))
+		assert(calls).Equal([]string{"test1:close doggy"})
+		assert(errStream).IsNil()
+	})
+
+	t.Run("snapshot error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls, errStream := fnTest(fnStream(SessionEventClose, "doggy", true))
+		assert(calls).Equal([]string{})
+		assert(ParseResponseStream(errStream)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("threads are busy", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		waitCH := make(chan string, 1)
+		processor := NewProcessor(
+			freeGroups,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().On("$onSessionOpen", func(rt Runtime) Return {
+					waitCH <- "open"
+					return rt.Reply(true)
+				}),
+				fileLine: "",
+			}},
+			nil,
+			NewTestStreamHub(),
+		)
+
+		// take all the free threads
+		threads := make([]*rpcThread, 0)
+		for _, freeCH := range processor.freeCHArray {
+			threads = append(threads, <-freeCH)
+		}
+
+		doneCH := make(chan bool)
+		go func() {
+			processor.OnSessionEvent(fnStream(SessionEventOpen))
+			doneCH <- true
+		}()
+
+		isBlocked := false
+		select {
+		case <-doneCH:
+		case <-time.After(time.Second):
+			isBlocked = true
+		}
+		assert(isBlocked).IsFalse()
+
+		for i, thread := range threads {
+			processor.freeCHArray[i] <- thread
+		}
+		assert(<-waitCH).Equal("open")
+		processor.Close()
+	})
+
+	t.Run("hook queue is full", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := NewTestStreamHub()
+		processor := &Processor{
+			status:      processorStatusRunning,
+			streamHub:   streamHub,
+			snapshotMap: make(map[rpcSessionKey]*rpcSessionSnapshot),
+			hookCH:      make(chan *rpcSessionEvent),
+		}
+		processor.OnSessionEvent(fnStream(SessionEventClose, "doggy", Array{}, nil))
+		assert(ParseResponseStream(streamHub.GetStream())).
+			Equal(nil, base.ErrProcessorHookQueueIsFull)
+		assert(len(processor.snapshotMap)).Equal(0)
+	})
+
+	t.Run("processor is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		streamHub := NewTestStreamHub()
+		processor := &Processor{
+			status:      processorStatusClosed,
+			streamHub:   streamHub,
+			snapshotMap: make(map[rpcSessionKey]*rpcSessionSnapshot),
+			hookCH:      make(chan *rpcSessionEvent, 1),
+		}
+		close(processor.hookCH)
+		processor.OnSessionEvent(fnStream(SessionEventClose, "doggy", Array{}, nil))
+		assert(streamHub.GetStream()).IsNil()
+		assert(len(processor.snapshotMap)).Equal(0)
+	})
+}
+
+func TestProcessor_putSessionEvent(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{hookCH: make(chan *rpcSessionEvent, 1)}
+		assert(processor.putSessionEvent(&rpcSessionEvent{})).IsTrue()
+	})
+
+	t.Run("queue is full", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{hookCH: make(chan *rpcSessionEvent, 1)}
+		processor.hookCH <- &rpcSessionEvent{}
+		assert(processor.putSessionEvent(&rpcSessionEvent{})).IsFalse()
+	})
+
+	t.Run("queue is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		processor := &Processor{hookCH: make(chan *rpcSessionEvent, 1)}
+		close(processor.hookCH)
+		assert(processor.putSessionEvent(&rpcSessionEvent{})).IsFalse()
+	})
+}
+
+func TestReadSessionSnapshot(t *testing.T) {
+	fnStream := func(args ...interface{}) *Stream {
+		stream := NewStream()
+		for _, arg := range args {
+			stream.Write(arg)
+		}
+		return stream
+	}
+
+	t.Run("no snapshot", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream())).Equal(nil, nil)
+	})
+
+	t.Run("principal and attributes are nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream(nil, nil))).
+			Equal(&rpcSessionSnapshot{}, nil)
+	})
+
+	t.Run("read principal name error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream(true))).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("read principal roles error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream("kitty", true))).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("principal role is not string", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream("kitty", Array{true}))).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("read attributes error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream(nil, true))).
+			Equal(nil, base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(readSessionSnapshot(fnStream(
+			"kitty",
+			Array{"admin"},
+			Map{"name": "doggy"},
+		))).Equal(&rpcSessionSnapshot{
+			principal:  NewPrincipal("kitty", "admin"),
+			attributes: Map{"name": "doggy"},
+		}, nil)
+	})
+}
+
+func TestProcessor_describe(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		getHandler := func(rt Runtime, name String) Return { return rt.Reply(name) }
+		setHandler := func(rt Runtime, v Int64, b Bytes) Return { return rt.Reply(v) }
+		streamHub := NewTestStreamHub()
+		processor := NewProcessor(
+			freeGroups,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			[]*ServiceMeta{{
+				name: "user",
+				service: &Service{
+					children: []*ServiceMeta{{
+						name: "info",
+						service: &Service{
+							children: []*ServiceMeta{},
+							actions: []*ActionMeta{{
+								name:     "Set",
+								handler:  setHandler,
+								fileLine: "setDebug",
+							}},
+						},
+						fileLine: "infoDebug",
+					}},
+					actions: []*ActionMeta{{
+						name:     "Get",
+						handler:  getHandler,
+						fileLine: "getDebug",
+					}, {
+						name:     "$onMount",
+						handler:  func(rt Runtime) Return { return rt.Reply(true) },
+						fileLine: "onMountDebug",
+					}},
+				},
+				fileLine: "userDebug",
+				data:     Map{"level": int64(3), "age": int64(18)},
+			}},
+			nil,
+			streamHub,
+		)
+		defer processor.Close()
+
+		fnCatalog := func(debug bool) Map {
+			fileLine := func(v string) string {
+				return getDebugFileLine(v, debug)
+			}
+			return Map{
+				"name":     "#",
+				"path":     "#",
+				"fileLine": "",
+				"config":   Array{},
+				"actions":  Array{},
+				"children": Array{Map{
+					"name":     "user",
+					"path":     "#.user",
+					"fileLine": fileLine("userDebug"),
+					"config":   Array{"age", "level"},
+					"actions": Array{Map{
+						"name": "Get",
+						"path": "#.user:Get",
+						"args": Array{"rpc.String"},
+						"callString": "#.user:Get" +
+							"(rpc.Runtime, rpc.String) rpc.Return",
+						"fileLine": fileLine("getDebug"),
+					}},
+					"children": Array{Map{
+						"name":     "info",
+						"path":     "#.user.info",
+						"fileLine": fileLine("infoDebug"),
+						"config":   Array{},
+						"actions": Array{Map{
+							"name": "Set",
+							"path": "#.user.info:Set",
+							"args": Array{"rpc.Int64", "rpc.Bytes"},
+							"callString": "#.user.info:Set" +
+								"(rpc.Runtime, rpc.Int64, rpc.Bytes) rpc.Return",
+							"fileLine": fileLine("setDebug"),
+						}},
+						"children": Array{},
+					}},
+				}},
+			}
+		}
+		assert(processor.describe("#", true)).Equal(fnCatalog(true))
+		assert(processor.describe("#", false)).Equal(fnCatalog(false))
+
+		// call the built-in action
+		for _, debug := range []bool{true, false} {
+			stream, _ := MakeInternalRequestStream(debug, 0, "#:$describe", "")
+			assert(processor.PutStream(stream)).IsTrue()
+			assert(ParseResponseStream(streamHub.WaitStream())).
+				Equal(fnCatalog(debug), nil)
+		}
+	})
+}
+
 func TestProcessor_invokeSystemAction(t *testing.T) {
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -543,6 +1250,7 @@ func TestProcessor_invokeSystemAction(t *testing.T) {
 					}),
 				fileLine: "",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 
@@ -559,6 +1267,52 @@ func TestProcessor_invokeSystemAction(t *testing.T) {
 }
 
 func TestProcessor_mountNode(t *testing.T) {
+	t.Run("test interceptors", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		interceptor1 := &testActionInterceptor{name: "1"}
+		interceptor2 := &testActionInterceptor{name: "2"}
+		interceptor3 := &testActionInterceptor{name: "3"}
+		handler := func(rt Runtime) Return { return rt.Reply(true) }
+
+		processor := NewProcessor(
+			freeGroups,
+			2,
+			3,
+			2048,
+			nil,
+			time.Second,
+			[]*ServiceMeta{{
+				name: "user",
+				service: NewService().
+					AddInterceptor(interceptor2).
+					AddChildService(
+						"info",
+						NewService().
+							AddInterceptor(interceptor3).
+							On("Get", handler),
+						nil,
+					).
+					On("Login", handler),
+				fileLine: "",
+			}},
+			[]ActionInterceptor{interceptor1},
+			NewTestStreamHub(),
+		)
+		assert(processor).IsNotNil()
+		defer processor.Close()
+
+		assert(len(processor.servicesMap["#"].interceptors)).Equal(1)
+		login := processor.actionsMap["#.user:Login"].interceptors
+		assert(len(login)).Equal(2)
+		assert(login[0] == interceptor1).IsTrue()
+		assert(login[1] == interceptor2).IsTrue()
+		get := processor.actionsMap["#.user.info:Get"].interceptors
+		assert(len(get)).Equal(3)
+		assert(get[0] == interceptor1).IsTrue()
+		assert(get[1] == interceptor2).IsTrue()
+		assert(get[2] == interceptor3).IsTrue()
+	})
+
 	t.Run("nodeMeta is nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		assert(testProcessorMountError([]*ServiceMeta{
@@ -677,6 +1431,7 @@ func TestProcessor_mountNode(t *testing.T) {
 				fileLine: "dbg",
 				data:     Map{"name": "kitty", "age": 18},
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 		assert(processor).IsNotNil()
@@ -688,13 +1443,33 @@ func TestProcessor_mountNode(t *testing.T) {
 				fileLine: "dbg",
 				data:     Map{"name": "kitty", "age": 18},
 			},
-			depth:   1,
-			data:    Map{"name": "kitty", "age": 18},
-			isMount: true,
+			depth:        1,
+			data:         Map{"name": "kitty", "age": 18},
+			isMount:      true,
+			interceptors: []ActionInterceptor{},
 		})
 	})
 }
 
+func TestIsActionNameLegal(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(isActionNameLegal("#.user", "sayHello")).IsTrue()
+		assert(isActionNameLegal("#.user", "$onMount")).IsTrue()
+		assert(isActionNameLegal("#.user", "$describe")).IsFalse()
+		assert(isActionNameLegal("#", "$describe")).IsTrue()
+		assert(isActionNameLegal("#", "$sayHello")).IsFalse()
+	})
+}
+
+func TestGetDebugFileLine(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(getDebugFileLine("/file:1", true)).Equal("/file:1")
+		assert(getDebugFileLine("/file:1", false)).Equal("")
+	})
+}
+
 func TestProcessor_mountAction(t *testing.T) {
 	t.Run("meta is nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -730,6 +1505,27 @@ func TestProcessor_mountAction(t *testing.T) {
 		)
 	})
 
+	t.Run("name of the built-in action", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(testProcessorMountError([]*ServiceMeta{{
+			name: "user",
+			service: &Service{
+				children: []*ServiceMeta{},
+				actions: []*ActionMeta{{
+					name:     "$describe",
+					handler:  func(rt Runtime) Return { return rt.Reply(true) },
+					fileLine: "actionDebug",
+				}},
+			},
+			fileLine: "nodeDebug",
+		}})).Equal(
+			base.ErrActionName.
+				AddDebug("action name $describe is illegal").
+				AddDebug("actionDebug").
+				Standardize(),
+		)
+	})
+
 	t.Run("handler is nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		assert(testProcessorMountError([]*ServiceMeta{{
@@ -863,18 +1659,20 @@ func TestProcessor_mountAction(t *testing.T) {
 				},
 				fileLine: "nodeDebug",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 		assert(processor).IsNotNil()
 		assert(processor.actionsMap["#.user:login"]).Equal(&rpcActionNode{
-			path:       "#.user:login",
-			meta:       processor.actionsMap["#.user:login"].meta,
-			service:    processor.servicesMap["#.user"],
-			cacheFN:    fnCache.Get("B"),
-			reflectFn:  reflect.ValueOf(handler),
-			callString: "#.user:login(rpc.Runtime, rpc.Bool) rpc.Return",
-			argTypes:   []reflect.Type{runtimeType, boolType},
-			indicator:  processor.actionsMap["#.user:login"].indicator,
+			path:         "#.user:login",
+			meta:         processor.actionsMap["#.user:login"].meta,
+			service:      processor.servicesMap["#.user"],
+			cacheFN:      fnCache.Get("B"),
+			reflectFn:    reflect.ValueOf(handler),
+			callString:   "#.user:login(rpc.Runtime, rpc.Bool) rpc.Return",
+			argTypes:     []reflect.Type{runtimeType, boolType},
+			indicator:    processor.actionsMap["#.user:login"].indicator,
+			interceptors: []ActionInterceptor{},
 		})
 		processor.Close()
 	})
@@ -921,17 +1719,19 @@ func TestProcessor_unmount(t *testing.T) {
 					}),
 				fileLine: "nodeDebug",
 			}},
+			nil,
 			NewTestStreamHub(),
 		)
 
 		processor.unmount("#.test1")
 		assert(<-waitCH).Equal("test1")
 		assert(len(processor.servicesMap)).Equal(3)
-		assert(len(processor.actionsMap)).Equal(4)
+		// includes the built-in action #:$describe
+		assert(len(processor.actionsMap)).Equal(5)
 		processor.unmount("#.test2")
 		assert(<-waitCH).Equal("test2")
 		assert(len(processor.servicesMap)).Equal(2)
-		assert(len(processor.actionsMap)).Equal(2)
+		assert(len(processor.actionsMap)).Equal(3)
 		processor.unmount("#")
 		assert(<-waitCH).Equal("test3")
 		assert(len(processor.servicesMap)).Equal(0)
@@ -0,0 +1,315 @@
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+// reflectTagName is the tag that renames a struct field, and the field is
+// skipped if the name is "-". For example:
+//
+//	type User struct {
+//	    Name     string `rpc:"name"`
+//	    Password string `rpc:"-"`
+//	}
+const reflectTagName = "rpc"
+
+type reflectField struct {
+	name  string
+	index int
+}
+
+var reflectFieldsCache = sync.Map{}
+
+// getReflectFields returns the exported fields of the struct type, and the
+// names that they are encoded with
+func getReflectFields(t reflect.Type) []reflectField {
+	if v, ok := reflectFieldsCache.Load(t); ok {
+		return v.([]reflectField)
+	}
+
+	ret := make([]reflectField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get(reflectTagName)
+
+		if field.PkgPath != "" || name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		ret = append(ret, reflectField{name: name, index: i})
+	}
+
+	reflectFieldsCache.Store(t, ret)
+	return ret
+}
+
+// isReflectType reports whether the handler argument of the type is decoded
+// by reflection. The structs, the pointers, the slices and the maps with
+// string keys are supported, if all their elements are supported
+func isReflectType(t reflect.Type) bool {
+	if isMarshalerType(t) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Ptr, reflect.Slice, reflect.Map:
+		return checkReflectType(t, make(map[reflect.Type]bool))
+	default:
+		return false
+	}
+}
+
+func checkReflectType(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] || isMarshalerType(t) {
+		// the recursive types, like the nodes of a tree
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Ptr, reflect.Slice:
+		return checkReflectType(t.Elem(), visited)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String &&
+			checkReflectType(t.Elem(), visited)
+	case reflect.Struct:
+		visited[t] = true
+		for _, field := range getReflectFields(t) {
+			if !checkReflectType(t.Field(field.index).Type, visited) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// writeReflect writes the value that is not one of the built-in types. The
+// structs are written as Map, and the slices are written as Array, so they can
+// be read by the callers that do not know the types
+func (p *Stream) writeReflect(v reflect.Value, depth int) string {
+	if v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		// the value is not addressable, so copy it to call the pointer methods
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return p.writeMarshaler(ptr.Interface().(Marshaler))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		p.WriteBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p.WriteInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		p.WriteUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		p.WriteFloat64(v.Float())
+	case reflect.String:
+		p.WriteString(v.String())
+	case reflect.Ptr:
+		if v.IsNil() {
+			p.WriteNil()
+		} else {
+			// the pointers count as a level, so the pointer cycles overflow
+			return p.write(v.Elem().Interface(), depth-1)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			p.WriteNil()
+		} else if v.Type().Elem().Kind() == reflect.Uint8 {
+			p.WriteBytes(v.Bytes())
+		} else {
+			arr := make(Array, v.Len())
+			for i := 0; i < len(arr); i++ {
+				arr[i] = v.Index(i).Interface()
+			}
+			return p.writeArray(arr, depth)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Sprintf(" type(%s) is not supported", v.Type())
+		} else if v.IsNil() {
+			p.WriteNil()
+		} else {
+			m := make(Map, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				m[iter.Key().String()] = iter.Value().Interface()
+			}
+			return p.writeMap(m, depth)
+		}
+	case reflect.Struct:
+		fields := getReflectFields(v.Type())
+		m := make(Map, len(fields))
+		for _, field := range fields {
+			m[field.name] = v.Field(field.index).Interface()
+		}
+		return p.writeMap(m, depth)
+	default:
+		return fmt.Sprintf(" type(%s) is not supported", v.Type())
+	}
+
+	return StreamWriteOK
+}
+
+// readReflect converts the value that is read from the stream to the type. It
+// returns the reason if the value can not be converted
+func readReflect(v Any, t reflect.Type) (reflect.Value, string) {
+	ret := reflect.New(t).Elem()
+
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return ret, StreamWriteOK
+		}
+	} else if reflect.TypeOf(v) == t {
+		// the types that the stream reads natively, like Time and Duration
+		ret.Set(reflect.ValueOf(v))
+		return ret, StreamWriteOK
+	}
+
+	if isMarshalerType(t) {
+		// the Marshaler reads the value back from a stream
+		stream := NewStream()
+		defer stream.Release()
+
+		if stream.Write(v) == StreamWriteOK {
+			if rv, err := stream.readMarshalerValue(t); err == nil &&
+				stream.IsReadFinish() {
+				return rv, StreamWriteOK
+			}
+		}
+
+		return ret, fmt.Sprintf(
+			" type(%s) can not be converted to %s",
+			convertTypeToString(reflect.TypeOf(v)),
+			t,
+		)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := v.(bool); ok {
+			ret.SetBool(b)
+			return ret, StreamWriteOK
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := v.(int64); ok {
+			if ret.OverflowInt(i) {
+				return ret, fmt.Sprintf(" %d overflows %s", i, t)
+			}
+			ret.SetInt(i)
+			return ret, StreamWriteOK
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		if u, ok := v.(uint64); ok {
+			if ret.OverflowUint(u) {
+				return ret, fmt.Sprintf(" %d overflows %s", u, t)
+			}
+			ret.SetUint(u)
+			return ret, StreamWriteOK
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := v.(float64); ok {
+			ret.SetFloat(f)
+			return ret, StreamWriteOK
+		}
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			ret.SetString(s)
+			return ret, StreamWriteOK
+		}
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			ret.Set(reflect.ValueOf(v))
+			return ret, StreamWriteOK
+		}
+	case reflect.Ptr:
+		elem, reason := readReflect(v, t.Elem())
+		if reason != StreamWriteOK {
+			return ret, reason
+		}
+		ret.Set(reflect.New(t.Elem()))
+		ret.Elem().Set(elem)
+		return ret, StreamWriteOK
+	case reflect.Slice:
+		if b, ok := v.(Bytes); ok && t.Elem().Kind() == reflect.Uint8 {
+			ret.SetBytes(b)
+			return ret, StreamWriteOK
+		} else if arr := reflect.ValueOf(v); !ok && arr.Kind() == reflect.Slice {
+			// the Array, and the packed arrays like []int64
+			ret.Set(reflect.MakeSlice(t, arr.Len(), arr.Len()))
+			for i := 0; i < arr.Len(); i++ {
+				elem, reason := readReflect(arr.Index(i).Interface(), t.Elem())
+				if reason != StreamWriteOK {
+					return ret, base.ConcatString("[", strconv.Itoa(i), "]", reason)
+				}
+				ret.Index(i).Set(elem)
+			}
+			return ret, StreamWriteOK
+		}
+	case reflect.Map:
+		if m, ok := v.(Map); ok && t.Key().Kind() == reflect.String {
+			ret.Set(reflect.MakeMapWithSize(t, len(m)))
+			for key, item := range m {
+				elem, reason := readReflect(item, t.Elem())
+				if reason != StreamWriteOK {
+					return ret, base.ConcatString("[\"", key, "\"]", reason)
+				}
+				ret.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+			}
+			return ret, StreamWriteOK
+		}
+	case reflect.Struct:
+		// the missing fields keep zero values, and the unknown keys are
+		// ignored, so the both sides can add fields independently
+		if m, ok := v.(Map); ok {
+			for _, field := range getReflectFields(t) {
+				if item, ok := m[field.name]; ok {
+					elem, reason := readReflect(item, t.Field(field.index).Type)
+					if reason != StreamWriteOK {
+						return ret, base.ConcatString("[\"", field.name, "\"]", reason)
+					}
+					ret.Field(field.index).Set(elem)
+				}
+			}
+			return ret, StreamWriteOK
+		}
+	}
+
+	return ret, fmt.Sprintf(
+		" type(%s) can not be converted to %s",
+		convertTypeToString(reflect.TypeOf(v)),
+		t,
+	)
+}
+
+// DecodeValue converts the value that is read from the stream, like the Map
+// that a struct is written as, to the value that out points to
+func DecodeValue(value Any, out interface{}) *base.Error {
+	if rv := reflect.ValueOf(out); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return base.ErrUnsupportedValue.AddDebug("out must be a non-nil pointer")
+	} else if ret, reason := readReflect(value, rv.Elem().Type()); reason != StreamWriteOK {
+		return base.ErrUnsupportedValue.AddDebug(base.ConcatString("value", reason))
+	} else {
+		rv.Elem().Set(ret)
+		return nil
+	}
+}
@@ -0,0 +1,286 @@
+package rpc
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/rpccloud/rpc/internal/base"
+)
+
+type testReflectUser struct {
+	Name   string `rpc:"name"`
+	Age    int
+	Tags   []string
+	Hidden bool `rpc:"-"`
+	secret string
+}
+
+type testReflectNode struct {
+	Value    int64
+	Children []*testReflectNode
+}
+
+func TestGetReflectFields(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		userType := reflect.TypeOf(testReflectUser{})
+		assert(getReflectFields(userType)).Equal([]reflectField{
+			{name: "name", index: 0},
+			{name: "Age", index: 1},
+			{name: "Tags", index: 2},
+		})
+		// load from the cache
+		assert(getReflectFields(userType)).Equal([]reflectField{
+			{name: "name", index: 0},
+			{name: "Age", index: 1},
+			{name: "Tags", index: 2},
+		})
+	})
+}
+
+func TestIsReflectType(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(isReflectType(reflect.TypeOf(testReflectUser{}))).IsTrue()
+		assert(isReflectType(reflect.TypeOf(&testReflectUser{}))).IsTrue()
+		assert(isReflectType(reflect.TypeOf(testReflectNode{}))).IsTrue()
+		assert(isReflectType(reflect.TypeOf([]int32{}))).IsTrue()
+		assert(isReflectType(reflect.TypeOf([]interface{}{}))).IsTrue()
+		assert(isReflectType(reflect.TypeOf(map[string]float32{}))).IsTrue()
+	})
+
+	t.Run("test failed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(isReflectType(reflect.TypeOf(int32(0)))).IsFalse()
+		assert(isReflectType(reflect.TypeOf([]chan bool{}))).IsFalse()
+		assert(isReflectType(reflect.TypeOf([]uintptr{}))).IsFalse()
+		assert(isReflectType(reflect.TypeOf([]error{}))).IsFalse()
+		assert(isReflectType(reflect.TypeOf(map[int]bool{}))).IsFalse()
+		assert(isReflectType(reflect.TypeOf(struct{ C chan bool }{}))).IsFalse()
+		assert(isReflectType(reflect.TypeOf(struct {
+			Fn func()
+		}{}))).IsFalse()
+	})
+}
+
+func TestStream_writeReflect(t *testing.T) {
+	fnTest := func(v interface{}) (Any, string) {
+		stream := NewStream()
+		defer stream.Release()
+		if reason := stream.Write(v); reason != StreamWriteOK {
+			return nil, reason
+		}
+		ret, err := stream.Read()
+		if err != nil {
+			return nil, err.Error()
+		}
+		return ret, StreamWriteOK
+	}
+
+	t.Run("test primitive", func(t *testing.T) {
+		type testBool bool
+		type testInt int16
+		type testUint uint16
+		type testFloat float32
+		type testString string
+		assert := base.NewAssert(t)
+		assert(fnTest(testBool(true))).Equal(true, StreamWriteOK)
+		assert(fnTest(testInt(-3))).Equal(int64(-3), StreamWriteOK)
+		assert(fnTest(testUint(3))).Equal(uint64(3), StreamWriteOK)
+		assert(fnTest(testFloat(1.5))).Equal(float64(1.5), StreamWriteOK)
+		assert(fnTest(testString("hi"))).Equal("hi", StreamWriteOK)
+	})
+
+	t.Run("test pointer", func(t *testing.T) {
+		type testPtr *testPtr
+		assert := base.NewAssert(t)
+		v := int64(3)
+		assert(fnTest((*int64)(nil))).Equal(nil, StreamWriteOK)
+		assert(fnTest(&v)).Equal(int64(3), StreamWriteOK)
+		// the pointer cycle overflows, instead of recursing forever
+		cycle := testPtr(nil)
+		cycle = &cycle
+		assert(fnTest(cycle)).Equal(nil, "value overflows")
+	})
+
+	t.Run("test slice", func(t *testing.T) {
+		type testBytes []uint8
+		assert := base.NewAssert(t)
+		assert(fnTest([]int(nil))).Equal(nil, StreamWriteOK)
+		assert(fnTest([]int{1, 2})).Equal(Array{int64(1), int64(2)}, StreamWriteOK)
+		assert(fnTest(testBytes{1, 2})).Equal(Bytes{1, 2}, StreamWriteOK)
+		assert(fnTest([]chan bool{nil})).
+			Equal(nil, "value[0] type(chan bool) is not supported")
+	})
+
+	t.Run("test map", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(map[string]int(nil))).Equal(nil, StreamWriteOK)
+		assert(fnTest(map[string]int{"a": 1})).
+			Equal(Map{"a": int64(1)}, StreamWriteOK)
+		assert(fnTest(map[int]int{1: 1})).
+			Equal(nil, "value type(map[int]int) is not supported")
+	})
+
+	t.Run("test struct", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(testReflectUser{
+			Name:   "kitty",
+			Age:    18,
+			Tags:   []string{"cat"},
+			Hidden: true,
+			secret: "secret",
+		})).Equal(Map{
+			"name": "kitty",
+			"Age":  int64(18),
+			"Tags": Array{"cat"},
+		}, StreamWriteOK)
+		assert(fnTest(struct{ C chan bool }{})).
+			Equal(nil, "value[\"C\"] type(chan bool) is not supported")
+	})
+
+	t.Run("test error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(errors.New("error"))).
+			Equal(nil, "value type(*errors.errorString) is not supported")
+	})
+
+	t.Run("test overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		node := &testReflectNode{}
+		node.Children = []*testReflectNode{node}
+		_, reason := fnTest(node)
+		assert(reason != StreamWriteOK).IsTrue()
+	})
+}
+
+func TestReadReflect(t *testing.T) {
+	fnTest := func(v Any, t reflect.Type) (Any, string) {
+		ret, reason := readReflect(v, t)
+		if reason != StreamWriteOK {
+			return nil, reason
+		}
+		return ret.Interface(), reason
+	}
+
+	t.Run("test primitive", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(true, reflect.TypeOf(false))).Equal(true, StreamWriteOK)
+		assert(fnTest(int64(-3), reflect.TypeOf(int8(0)))).
+			Equal(int8(-3), StreamWriteOK)
+		assert(fnTest(uint64(3), reflect.TypeOf(uint8(0)))).
+			Equal(uint8(3), StreamWriteOK)
+		assert(fnTest(float64(1.5), reflect.TypeOf(float32(0)))).
+			Equal(float32(1.5), StreamWriteOK)
+		assert(fnTest("hi", reflect.TypeOf(""))).Equal("hi", StreamWriteOK)
+		assert(fnTest(int64(3), reflect.TypeOf((*interface{})(nil)).Elem())).
+			Equal(int64(3), StreamWriteOK)
+	})
+
+	t.Run("test primitive error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(int64(3), reflect.TypeOf(false))).
+			Equal(nil, " type(rpc.Int64) can not be converted to bool")
+		assert(fnTest(int64(math.MaxInt64), reflect.TypeOf(int8(0)))).
+			Equal(nil, " 9223372036854775807 overflows int8")
+		assert(fnTest(uint64(256), reflect.TypeOf(uint8(0)))).
+			Equal(nil, " 256 overflows uint8")
+		assert(fnTest(nil, reflect.TypeOf(""))).
+			Equal(nil, " type(<nil>) can not be converted to string")
+	})
+
+	t.Run("test pointer", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v, reason := readReflect(int64(3), reflect.TypeOf((*int)(nil)))
+		assert(*(v.Interface().(*int)), reason).Equal(3, StreamWriteOK)
+		assert(fnTest(nil, reflect.TypeOf((*int)(nil)))).
+			Equal((*int)(nil), StreamWriteOK)
+		assert(fnTest("3", reflect.TypeOf((*int)(nil)))).
+			Equal(nil, " type(rpc.String) can not be converted to int")
+	})
+
+	t.Run("test slice", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(Array{int64(1)}, reflect.TypeOf([]int{}))).
+			Equal([]int{1}, StreamWriteOK)
+		assert(fnTest(Bytes{1}, reflect.TypeOf([]uint8{}))).
+			Equal([]uint8{1}, StreamWriteOK)
+		assert(fnTest([]int64{1}, reflect.TypeOf([]int32{}))).
+			Equal([]int32{1}, StreamWriteOK)
+		assert(fnTest(nil, reflect.TypeOf([]int{}))).
+			Equal([]int(nil), StreamWriteOK)
+		assert(fnTest(Array{"a"}, reflect.TypeOf([]int{}))).
+			Equal(nil, "[0] type(rpc.String) can not be converted to int")
+		assert(fnTest(Bytes{1}, reflect.TypeOf([]int{}))).
+			Equal(nil, " type(rpc.Bytes) can not be converted to []int")
+	})
+
+	t.Run("test map", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(Map{"a": int64(1)}, reflect.TypeOf(map[string]int{}))).
+			Equal(map[string]int{"a": 1}, StreamWriteOK)
+		assert(fnTest(Map{"a": "1"}, reflect.TypeOf(map[string]int{}))).
+			Equal(nil, "[\"a\"] type(rpc.String) can not be converted to int")
+		assert(fnTest(Array{}, reflect.TypeOf(map[string]int{}))).
+			Equal(nil, " type(rpc.Array) can not be converted to map[string]int")
+	})
+
+	t.Run("test struct", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(fnTest(
+			Map{"name": "kitty", "Tags": Array{"cat"}, "Hidden": true, "x": 1},
+			reflect.TypeOf(testReflectUser{}),
+		)).Equal(testReflectUser{
+			Name: "kitty",
+			Tags: []string{"cat"},
+		}, StreamWriteOK)
+		assert(fnTest(
+			Map{"name": int64(3)},
+			reflect.TypeOf(testReflectUser{}),
+		)).Equal(nil, "[\"name\"] type(rpc.Int64) can not be converted to string")
+		assert(fnTest(nil, reflect.TypeOf(testReflectUser{}))).Equal(
+			nil,
+			" type(<nil>) can not be converted to rpc.testReflectUser",
+		)
+	})
+}
+
+func TestDecodeValue(t *testing.T) {
+	t.Run("out is not a pointer", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(DecodeValue(Map{}, testReflectUser{})).Equal(
+			base.ErrUnsupportedValue.AddDebug("out must be a non-nil pointer"),
+		)
+		assert(DecodeValue(Map{}, (*testReflectUser)(nil))).Equal(
+			base.ErrUnsupportedValue.AddDebug("out must be a non-nil pointer"),
+		)
+	})
+
+	t.Run("value can not be converted", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := testReflectUser{}
+		assert(DecodeValue(Map{"Age": "18"}, &v)).Equal(
+			base.ErrUnsupportedValue.AddDebug(
+				"value[\"Age\"] type(rpc.String) can not be converted to int",
+			),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := testReflectNode{}
+		assert(DecodeValue(Map{
+			"Value":    int64(1),
+			"Children": Array{Map{"Value": int64(2)}, nil},
+		}, &v)).IsNil()
+		assert(v).Equal(testReflectNode{
+			Value: 1,
+			Children: []*testReflectNode{
+				{Value: 2},
+				nil,
+			},
+		})
+	})
+}
@@ -94,6 +94,32 @@ func (p RTValue) ToFloat64() (Float64, *base.Error) {
 	}
 }
 
+// ToTime ...
+func (p RTValue) ToTime() (Time, *base.Error) {
+	if p.err != nil {
+		return Time{}, p.err
+	} else if thread := p.rt.lock(); thread != nil {
+		defer p.rt.unlock()
+		thread.rtStream.SetReadPos(int(p.pos))
+		return thread.rtStream.ReadTime()
+	} else {
+		return Time{}, base.ErrRuntimeIllegalInCurrentGoroutine
+	}
+}
+
+// ToDuration ...
+func (p RTValue) ToDuration() (Duration, *base.Error) {
+	if p.err != nil {
+		return 0, p.err
+	} else if thread := p.rt.lock(); thread != nil {
+		defer p.rt.unlock()
+		thread.rtStream.SetReadPos(int(p.pos))
+		return thread.rtStream.ReadDuration()
+	} else {
+		return 0, base.ErrRuntimeIllegalInCurrentGoroutine
+	}
+}
+
 // ToString ...
 func (p RTValue) ToString() (String, *base.Error) {
 	if p.err != nil {
@@ -3,6 +3,7 @@ package rpc
 import (
 	"github.com/rpccloud/rpc/internal/base"
 	"testing"
+	"time"
 )
 
 func testWithRTValue(fn func(v RTValue), v interface{}) {
@@ -125,6 +126,62 @@ func TestRTValue_ToFloat64(t *testing.T) {
 	})
 }
 
+func TestRTValue_ToTime(t *testing.T) {
+	t.Run("err is not nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(RTValue{err: base.ErrStream}.ToTime()).
+			Equal(time.Time{}, base.ErrStream)
+	})
+
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(RTValue{}.ToTime()).
+			Equal(time.Time{}, base.ErrRuntimeIllegalInCurrentGoroutine)
+	})
+
+	t.Run("type error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testWithRTValue(func(v RTValue) {
+			assert(v.ToTime()).Equal(time.Time{}, base.ErrStream)
+		}, "kitty")
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testWithRTValue(func(v RTValue) {
+			assert(v.ToTime()).Equal(time.Unix(12, 0).UTC(), nil)
+		}, time.Unix(12, 0).UTC())
+	})
+}
+
+func TestRTValue_ToDuration(t *testing.T) {
+	t.Run("err is not nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(RTValue{err: base.ErrStream}.ToDuration()).
+			Equal(time.Duration(0), base.ErrStream)
+	})
+
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(RTValue{}.ToDuration()).
+			Equal(time.Duration(0), base.ErrRuntimeIllegalInCurrentGoroutine)
+	})
+
+	t.Run("type error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testWithRTValue(func(v RTValue) {
+			assert(v.ToDuration()).Equal(time.Duration(0), base.ErrStream)
+		}, "kitty")
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testWithRTValue(func(v RTValue) {
+			assert(v.ToDuration()).Equal(time.Second, nil)
+		}, time.Second)
+	})
+}
+
 func TestRTValue_ToString(t *testing.T) {
 	t.Run("err is not nil", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -240,6 +297,24 @@ func TestRTValue_ToRTArray(t *testing.T) {
 			assert(rtArray.Get(2).ToInt64()).Equal(int64(3), nil)
 		}, Array{"1", true, int64(3)})
 	})
+
+	t.Run("packed array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testWithRTValue(func(v RTValue) {
+			rtArray, err := v.ToRTArray()
+			assert(err).IsNil()
+			assert(rtArray.Size()).Equal(2)
+			assert(rtArray.Get(0).ToString()).Equal("1", nil)
+			assert(rtArray.Get(1).ToString()).Equal("2", nil)
+		}, []string{"1", "2"})
+		testWithRTValue(func(v RTValue) {
+			rtArray, err := v.ToRTArray()
+			assert(err).IsNil()
+			assert(rtArray.Size()).Equal(2)
+			assert(rtArray.Get(0).ToInt64()).Equal(int64(1), nil)
+			assert(rtArray.Get(1).ToInt64()).Equal(int64(2), nil)
+		}, []int64{1, 2})
+	})
 }
 
 func TestRTValue_ToAMap(t *testing.T) {
@@ -3,6 +3,7 @@ package rpc
 import (
 	"github.com/rpccloud/rpc/internal/base"
 	"math"
+	"time"
 )
 
 // Runtime ...
@@ -39,6 +40,61 @@ func (p Runtime) Reply(value interface{}) Return {
 	return emptyReturn
 }
 
+// Defer defers the reply of the call. The action should return the Return at
+// once, and finish the call later by DeferredReply.Reply from any goroutine.
+// The thread is released when the action returns. If the reply can not be
+// deferred, the call is finished with the error and the DeferredReply is nil
+func (p Runtime) Defer() (Return, *DeferredReply) {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		ret, err := thread.Defer()
+		if err != nil {
+			return thread.Write(err, 1, true), nil
+		}
+		return emptyReturn, ret
+	}
+
+	base.PublishPanic(
+		base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(base.GetFileLine(1)),
+	)
+	return emptyReturn, nil
+}
+
+// ReplyChunk sends one value of a streaming reply. The chunks are delivered
+// to the caller in order, and the stream is finished by Runtime.Reply
+func (p Runtime) ReplyChunk(value interface{}) error {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		if err := thread.WriteChunk(value); err != nil {
+			return err.AddDebug(base.AddFileLine(thread.GetExecActionNodePath(), 1))
+		}
+		return nil
+	}
+
+	return base.ErrRuntimeIllegalInCurrentGoroutine.
+		AddDebug(base.GetFileLine(1))
+}
+
+// IsCancelled reports whether the caller has cancelled the call
+func (p Runtime) IsCancelled() bool {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		return thread.rootFrame.getCancelled()
+	}
+
+	return false
+}
+
+// Done returns a channel that is closed when the caller cancels the call
+func (p Runtime) Done() <-chan bool {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		return thread.rootFrame.getCancelCH()
+	}
+
+	return nil
+}
+
 // Post ...
 func (p Runtime) Post(endpoint string, message string, value Any) error {
 	if thread := p.lock(); thread != nil {
@@ -69,16 +125,46 @@ func (p Runtime) Post(endpoint string, message string, value Any) error {
 		AddDebug(base.GetFileLine(1))
 }
 
+// Publish sends the value to all the sessions that subscribe the topic
+func (p Runtime) Publish(topic string, value Any) error {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+
+		stream := NewStream()
+		stream.SetKind(StreamKindTopicPublish)
+		stream.WriteString(topic)
+		if reason := stream.Write(value); reason != StreamWriteOK {
+			stream.Release()
+			return base.ErrUnsupportedValue.AddDebug(reason)
+		}
+
+		thread.processor.streamHub.OnReceiveStream(stream)
+		return nil
+	}
+
+	return base.ErrRuntimeIllegalInCurrentGoroutine.
+		AddDebug(base.GetFileLine(1))
+}
+
 // Call ...
 func (p Runtime) Call(target string, args ...interface{}) RTValue {
 	if thread := p.lock(); thread != nil {
 		defer p.unlock()
 		frame := thread.top
+		hub := thread.processor.getRemoteHub(target)
+
+		// the call to a peer node carries the identity of the caller
+		metadata := frame.metadata
+		if hub != nil {
+			metadata = thread.getForwardMetadata()
+		}
 
 		// make stream
-		stream, err := MakeInternalRequestStream(
+		stream, err := makeRequestStream(
 			frame.stream.HasStatusBitDebug(),
 			frame.depth+1,
+			frame.deadline,
+			metadata,
 			target,
 			frame.from,
 			args...,
@@ -92,12 +178,32 @@ func (p Runtime) Call(target string, args ...interface{}) RTValue {
 		}
 		defer stream.Release()
 
-		// switch thread frame and eval
-		func() {
-			thread.pushFrame()
-			defer thread.popFrame()
-			thread.Eval(stream, false)
-		}()
+		// the nested call comes from the same session
+		stream.SetGatewayID(frame.stream.GetGatewayID())
+		stream.SetSessionID(frame.stream.GetSessionID())
+
+		// the target might be mounted on another node
+		remoteStream := (*Stream)(nil)
+		if hub != nil {
+			remoteStream = hub.CallRemote(
+				target,
+				stream,
+				frame.deadline,
+				thread.rootFrame.getCancelCH(),
+			)
+		}
+
+		if remoteStream != nil {
+			defer remoteStream.Release()
+			stream = remoteStream
+		} else {
+			// switch thread frame and eval
+			func() {
+				thread.pushFrame()
+				defer thread.popFrame()
+				thread.Eval(stream, false)
+			}()
+		}
 
 		// return
 		ret := p.parseResponseStream(stream)
@@ -117,6 +223,95 @@ func (p Runtime) Call(target string, args ...interface{}) RTValue {
 
 }
 
+// CallAsync calls the target on a free thread, and returns the Future of the
+// result at once. If there is no free thread, the call runs on the current
+// thread before CallAsync returns. The target that is mounted on another node
+// is called in a new goroutine
+func (p Runtime) CallAsync(target string, args ...interface{}) *Future {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		frame := thread.top
+		ret := newFuture(p, base.AddFileLine(thread.GetExecActionNodePath(), 1))
+		hub := thread.processor.getRemoteHub(target)
+
+		// the call to a peer node carries the identity of the caller
+		metadata := frame.metadata
+		if hub != nil {
+			metadata = thread.getForwardMetadata()
+		}
+
+		// make stream
+		stream, err := makeRequestStream(
+			frame.stream.HasStatusBitDebug(),
+			frame.depth+1,
+			frame.deadline,
+			metadata,
+			target,
+			frame.from,
+			args...,
+		)
+		if err != nil {
+			return newErrorFuture(err.AddDebug(ret.fileLine))
+		}
+
+		// the nested call comes from the same session
+		stream.SetGatewayID(frame.stream.GetGatewayID())
+		stream.SetSessionID(frame.stream.GetSessionID())
+
+		// the future follows the cancellation of the call, and its result is
+		// released if the call finishes without waiting it
+		thread.rootFrame.addFuture(ret)
+
+		if hub != nil {
+			// the target is mounted on another node. the remote frame follows
+			// the cancellation of the future
+			deadline := frame.deadline
+			go func() {
+				remoteFrame := newRPCThreadFrame()
+				defer remoteFrame.Release()
+				ret.start(remoteFrame)
+
+				if remoteStream := hub.CallRemote(
+					target,
+					stream,
+					deadline,
+					remoteFrame.getCancelCH(),
+				); remoteStream != nil {
+					stream.Release()
+					ret.finish(remoteStream)
+				} else {
+					writeReply(
+						stream,
+						base.ErrTargetNotExist.AddDebug(base.ConcatString(
+							"rpc-call: ",
+							target,
+							" does not exist",
+						)),
+						"",
+						0,
+						false,
+					)
+					stream.SetReadPosToBodyStart()
+					ret.finish(stream)
+				}
+			}()
+		} else if !thread.processor.tryPutFutureStream(stream, ret) {
+			func() {
+				thread.pushFrame()
+				defer thread.popFrame()
+				thread.Eval(stream, false)
+			}()
+			ret.finish(stream)
+		}
+
+		return ret
+	}
+
+	return newErrorFuture(
+		base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(base.GetFileLine(1)),
+	)
+}
+
 // NewRTArray ...
 func (p Runtime) NewRTArray(size int) RTArray {
 	if p.lock() != nil {
@@ -154,6 +349,106 @@ func (p Runtime) GetPostEndPoint() string {
 	return ""
 }
 
+// GetDeadline returns the time when the caller gives up the call. ok is false
+// if the caller did not set a deadline
+func (p Runtime) GetDeadline() (deadline time.Time, ok bool) {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+
+		if ns := thread.top.deadline; ns > 0 {
+			return time.Unix(0, ns), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// GetPrincipal returns the principal of the session that the call comes from.
+// It returns nil if the session is not authenticated. The call that is
+// forwarded by a peer node gets the principal of the caller on the origin node
+func (p Runtime) GetPrincipal() *Principal {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		return thread.getPrincipal()
+	}
+
+	return nil
+}
+
+// SessionGet returns the attribute of the session that the call comes from.
+// The returned value should not be modified, use SessionSet instead
+func (p Runtime) SessionGet(key string) (Any, bool) {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		stream := thread.top.stream
+
+		// the session is closed, and the hook visits its snapshot
+		if snapshot := thread.processor.getSessionSnapshot(
+			stream.GetGatewayID(),
+			stream.GetSessionID(),
+		); snapshot != nil {
+			ret, ok := snapshot.attributes[key]
+			return ret, ok
+		}
+
+		if hub, ok := thread.processor.streamHub.(ISessionHub); ok {
+			return hub.GetSessionAttribute(
+				stream.GetGatewayID(),
+				stream.GetSessionID(),
+				key,
+			)
+		}
+	}
+
+	return nil, false
+}
+
+// SessionSet sets the attribute of the session that the call comes from. The
+// value is copied, and the attribute is removed if the value is nil. The
+// attributes last until the session is timeout
+func (p Runtime) SessionSet(key string, value Any) error {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		stream := thread.top.stream
+
+		// copy the value, so it does not depend on the runtime
+		copyStream := NewStream()
+		defer copyStream.Release()
+		if reason := copyStream.Write(value); reason != StreamWriteOK {
+			return base.ErrUnsupportedValue.AddDebug(reason)
+		}
+		value, _ = copyStream.Read()
+
+		if hub, ok := thread.processor.streamHub.(ISessionHub); !ok ||
+			!hub.SetSessionAttribute(
+				stream.GetGatewayID(),
+				stream.GetSessionID(),
+				key,
+				value,
+			) {
+			return base.ErrRuntimeSessionNotAvailable.
+				AddDebug(base.GetFileLine(1))
+		}
+
+		return nil
+	}
+
+	return base.ErrRuntimeIllegalInCurrentGoroutine.
+		AddDebug(base.GetFileLine(1))
+}
+
+// GetMetadata returns the metadata value of the call by key. The metadata is
+// set by the caller, and it is forwarded to the nested calls by Runtime.Call
+func (p Runtime) GetMetadata(key string) (string, bool) {
+	if thread := p.lock(); thread != nil {
+		defer p.unlock()
+		ret, ok := thread.top.metadata[key]
+		return ret, ok
+	}
+
+	return "", false
+}
+
 // GetServiceConfig ...
 func (p Runtime) GetServiceConfig(key string) (Any, bool) {
 	if thread := p.lock(); thread != nil {
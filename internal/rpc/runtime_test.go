@@ -2,7 +2,9 @@ package rpc
 
 import (
 	"github.com/rpccloud/rpc/internal/base"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -139,6 +141,227 @@ func TestRuntime_Reply(t *testing.T) {
 	})
 }
 
+func TestRuntime_Defer(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnDefer, source := func() { Runtime{}.Defer() }, base.GetFileLine(0)
+		assert(base.RunWithSubscribePanic(fnDefer)).Equal(
+			base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(source),
+		)
+	})
+
+	t.Run("reply has been called", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		source := ""
+		var reply *DeferredReply
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				ret := rt.Reply(true)
+				fnDefer, s := func() { ret, reply = rt.Defer() }, base.GetFileLine(0)
+				fnDefer()
+				source = rt.thread.GetActionNode().path + " " + s
+				return ret
+			}, nil),
+		)).Equal(
+			nil,
+			base.ErrRuntimeReplyHasBeenCalled.AddDebug(source).Standardize(),
+		)
+		assert(reply).IsNil()
+	})
+
+	t.Run("defer in the nested call", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var reply *DeferredReply
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				thread := rt.thread
+				thread.pushFrame()
+				thread.top.lockStatus = rt.id
+				thread.top.stream = NewStream()
+				_, reply = rt.Defer()
+				ret, err := ParseResponseStream(thread.top.stream)
+				thread.popFrame()
+				return rt.Reply(Array{ret, err.GetCode()})
+			}, nil),
+		)).Equal(Array{nil, uint64(base.ErrRuntimeDeferNotAvailable.GetCode())}, nil)
+		assert(reply).IsNil()
+	})
+
+	t.Run("defer in the call of CallAsync", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var reply *DeferredReply
+		source := ""
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				ret := emptyReturn
+				// like the calls of Runtime.CallAsync
+				rt.thread.top.stream.SetCallbackID(0)
+				fnDefer, s := func() { ret, reply = rt.Defer() }, base.GetFileLine(0)
+				fnDefer()
+				rt.thread.top.stream.SetCallbackID(1)
+				source = rt.thread.GetActionNode().path + " " + s
+				return ret
+			}, nil),
+		)).Equal(
+			nil,
+			base.ErrRuntimeDeferNotAvailable.AddDebug(source).Standardize(),
+		)
+		assert(reply).IsNil()
+	})
+
+	t.Run("defer twice", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		source := ""
+		// the stream belongs to the first DeferredReply, so the error of the
+		// second Defer is reported
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				ret, reply := rt.Defer()
+				_, reply2 := rt.Defer()
+				assert(reply).IsNotNil()
+				assert(reply2).IsNil()
+				source = rt.thread.GetExecActionDebug()
+				return ret
+			}, nil),
+		)).Equal(
+			nil,
+			base.ErrRuntimeReplyHasBeenCalled.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				ret, reply := rt.Defer()
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					_ = reply.Reply("ok")
+				}()
+				return ret
+			}, nil),
+		)).Equal("ok", nil)
+	})
+}
+
+func TestRuntime_ReplyChunk(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ret, source := Runtime{}.ReplyChunk("HI"), base.GetFileLine(0)
+		assert(ret).
+			Equal(base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(source))
+	})
+
+	t.Run("write chunk error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var e error
+		source := ""
+		testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				ret := rt.Reply("ok")
+				err, s := rt.ReplyChunk("HI"), base.GetFileLine(0)
+				e, source = err, rt.thread.GetActionNode().path+" "+s
+				return ret
+			},
+			nil,
+		)
+		assert(e).Equal(base.ErrRuntimeReplyHasBeenCalled.AddDebug(source))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		helper := newTestProcessorHelper(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().
+					On("Eval", func(rt Runtime, n int64) Return {
+						for i := int64(0); i < n; i++ {
+							if err := rt.ReplyChunk(i * 10); err != nil {
+								return rt.Reply(err)
+							}
+						}
+						return rt.Reply("done")
+					}),
+				fileLine: "",
+			}},
+		)
+		defer helper.Close()
+
+		stream, _ := MakeInternalRequestStream(false, 0, "#.test:Eval", "", 3)
+		stream.SetCallbackID(17)
+		helper.GetProcessor().PutStream(stream)
+
+		for i := 0; i < 3; i++ {
+			chunk := <-helper.streamHub.streamCH
+			assert(chunk.GetKind()).Equal(uint8(StreamKindRPCResponseChunk))
+			assert(chunk.GetCallbackID()).Equal(uint64(17))
+			assert(chunk.ReadUint64()).Equal(uint64(i), nil)
+			assert(chunk.Read()).Equal(int64(i*10), nil)
+			assert(chunk.IsReadFinish()).IsTrue()
+			chunk.Release()
+		}
+
+		assert(ParseResponseStream(<-helper.streamHub.streamCH)).
+			Equal("done", nil)
+	})
+}
+
+func TestRuntime_IsCancelled(t *testing.T) {
+	t.Run("thread is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{}.IsCancelled()).IsFalse()
+	})
+
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{id: 1234, thread: testThread}.IsCancelled()).IsFalse()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					ret := Array{rt.IsCancelled()}
+					rt.thread.rootFrame.cancel()
+					return rt.Reply(append(ret, rt.IsCancelled()))
+				},
+				nil,
+			),
+		)).Equal(Array{false, true}, nil)
+	})
+}
+
+func TestRuntime_Done(t *testing.T) {
+	t.Run("thread is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{}.Done() == nil).IsTrue()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					go func() {
+						time.Sleep(50 * time.Millisecond)
+						rt.thread.rootFrame.cancel()
+					}()
+					<-rt.Done()
+					return rt.Reply(rt.IsCancelled())
+				},
+				nil,
+			),
+		)).Equal(true, nil)
+	})
+}
+
 func TestRuntime_Post(t *testing.T) {
 	t.Run("thread lock error", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -195,6 +418,47 @@ func TestRuntime_Post(t *testing.T) {
 	})
 }
 
+func TestRuntime_Publish(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ret, source := Runtime{}.Publish("news", "HI"), base.GetFileLine(0)
+		assert(ret).
+			Equal(base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(source))
+	})
+
+	t.Run("Publish value not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var e error
+		testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				e = rt.Publish("news", make(chan bool))
+				return rt.Reply("ok")
+			},
+			nil,
+		)
+		assert(e).Equal(base.ErrUnsupportedValue.AddDebug(
+			"value type(chan bool) is not supported",
+		))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var e error
+		stream := testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				e = rt.Publish("news", "HI")
+				return emptyReturn
+			},
+			nil,
+		)
+		assert(e).IsNil()
+		assert(stream.GetKind()).Equal(uint8(StreamKindTopicPublish))
+		assert(stream.Read()).Equal("news", nil)
+		assert(stream.Read()).Equal("HI", nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+}
+
 func TestRuntime_Call(t *testing.T) {
 	t.Run("thread lock error", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -254,6 +518,59 @@ func TestRuntime_Call(t *testing.T) {
 		)
 	})
 
+	t.Run("deadline is propagated", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		deadline := base.TimeNow().Add(time.Minute).UnixNano()
+		stream, _ := makeRequestStream(true, 0, deadline, nil, "#.test:Eval", "", 1)
+		assert(testReply(true, nil, nil, func(rt Runtime, n int64) Return {
+			if n > 0 {
+				return rt.Reply(rt.Call("#.test:Eval", n-1))
+			}
+			v, ok := rt.GetDeadline()
+			return rt.Reply(Array{v.UnixNano(), ok})
+		}, stream)).Equal(Array{deadline, true}, nil)
+	})
+
+	t.Run("metadata is propagated", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream, _ := makeRequestStream(
+			true, 0, 0, map[string]string{"traceID": "1234"}, "#.test:Eval", "", 1,
+		)
+		assert(testReply(true, nil, nil, func(rt Runtime, n int64) Return {
+			if n > 0 {
+				return rt.Reply(rt.Call("#.test:Eval", n-1))
+			}
+			v, ok := rt.GetMetadata("traceID")
+			return rt.Reply(Array{v, ok})
+		}, stream)).Equal(Array{"1234", true}, nil)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		source1 := ""
+		deadline := base.TimeNow().Add(100 * time.Millisecond).UnixNano()
+		stream, _ := makeRequestStream(true, 0, deadline, nil, "#.test:Eval", "", 1)
+		retStream, _ := testReplyWithSource(
+			true, nil, nil,
+			func(rt Runtime, n int64) Return {
+				if n > 0 {
+					time.Sleep(300 * time.Millisecond)
+					v, s1 := rt.Call("#.test:Eval", n-1), base.GetFileLine(0)
+					source1 = rt.thread.GetActionNode().path + " " + s1
+					return rt.Reply(v)
+				}
+				return rt.Reply(true)
+			},
+			stream,
+		)
+		_, err := ParseResponseStream(retStream)
+		assert(err.GetCode()).Equal(base.ErrCallDeadlineExceeded.GetCode())
+		assert(strings.Contains(
+			err.GetMessage(),
+			"call #.test:Eval deadline exceeded\n"+source1,
+		)).IsTrue()
+	})
+
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		assert(ParseResponseStream(
@@ -268,6 +585,398 @@ func TestRuntime_Call(t *testing.T) {
 	})
 }
 
+func TestRuntime_CallAsync(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ret, source := Runtime{}.CallAsync("#"), base.GetFileLine(0)
+		assert(ret.Wait()).Equal(RTValue{
+			err: base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(source),
+		})
+	})
+
+	t.Run("make stream error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		source1 := ""
+		source2 := ""
+
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				errArg := make(chan bool)
+				v, s1 := rt.CallAsync("#.test.SayHello", errArg), base.GetFileLine(0)
+				source1 = rt.thread.GetActionNode().path + " " + s1
+				_, err := v.Wait().ToString()
+				ret, s2 := rt.Reply(err), base.GetFileLine(0)
+				source2 = rt.thread.GetActionNode().path + " " + s2
+				return ret
+			}, nil),
+		)).Equal(nil, base.ErrUnsupportedValue.
+			AddDebug("2nd argument: value type(chan bool) is not supported").
+			AddDebug(source1).AddDebug(source2).Standardize(),
+		)
+	})
+
+	t.Run("reply error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		source1 := ""
+		source2 := ""
+
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(_ *Processor, rt Runtime) Return {
+				v, s1 := rt.CallAsync("#.test:Unknown"), base.GetFileLine(0)
+				source1 = rt.thread.GetActionNode().path + " " + s1
+				_, err := v.Wait().ToString()
+				ret, s2 := rt.Reply(err), base.GetFileLine(0)
+				source2 = rt.thread.GetActionNode().path + " " + s2
+				return ret
+			}, nil),
+		)).Equal(nil, base.ErrTargetNotExist.
+			AddDebug("rpc-call: #.test:Unknown does not exist").
+			AddDebug(source1).AddDebug(source2).Standardize(),
+		)
+	})
+
+	t.Run("depth and session are preserved", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream, _ := makeRequestStream(true, 0, 0, nil, "#.test:Eval", "", 1)
+		stream.SetGatewayID(12)
+		stream.SetSessionID(34)
+		assert(testReply(true, nil, nil, func(rt Runtime, n int64) Return {
+			if n > 0 {
+				return rt.Reply(rt.CallAsync("#.test:Eval", n-1).Wait())
+			}
+			s := rt.thread.top.stream
+			return rt.Reply(Array{
+				uint64(s.GetDepth()),
+				s.HasStatusBitDebug(),
+				uint64(s.GetGatewayID()),
+				s.GetSessionID(),
+			})
+		}, stream)).Equal(Array{uint64(1), true, uint64(12), uint64(34)}, nil)
+	})
+
+	t.Run("calls run in parallel", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(testReply(true, nil, nil, func(rt Runtime, n int64) Return {
+			if n > 0 {
+				start := base.TimeNow()
+				ret := WaitAll(
+					rt.CallAsync("#.test:Eval", n-1),
+					rt.CallAsync("#.test:Eval", n-1),
+					rt.CallAsync("#.test:Eval", n-1),
+				)
+				cost := base.TimeNow().Sub(start)
+				return rt.Reply(Array{
+					ret[0], ret[1], ret[2], cost < 250*time.Millisecond,
+				})
+			}
+			time.Sleep(100 * time.Millisecond)
+			return rt.Reply(true)
+		}, 1)).Equal(Array{true, true, true, true}, nil)
+	})
+
+	t.Run("cancellation is propagated", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(testReply(true, nil, nil, func(rt Runtime, n int64) Return {
+			if n > 0 {
+				v := rt.CallAsync("#.test:Eval", n-1)
+				rt.thread.rootFrame.cancel()
+				return rt.Reply(v.Wait())
+			}
+			select {
+			case <-rt.Done():
+				return rt.Reply(true)
+			case <-time.After(time.Second):
+				return rt.Reply(false)
+			}
+		}, 1)).Equal(true, nil)
+	})
+
+	t.Run("result is released if not waited", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		futureCH := make(chan *Future, 1)
+		assert(testReply(true, nil, nil, func(rt Runtime, n int64) Return {
+			if n > 0 {
+				futureCH <- rt.CallAsync("#.test:Eval", n-1)
+				return rt.Reply(true)
+			}
+			time.Sleep(100 * time.Millisecond)
+			return rt.Reply(true)
+		}, 1)).Equal(true, nil)
+		v := <-futureCH
+		<-v.doneCH
+		v.Lock()
+		defer v.Unlock()
+		assert(v.isDropped).IsTrue()
+		assert(v.stream).IsNil()
+	})
+
+	t.Run("no free thread", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(func(processor *Processor, rt Runtime) Return {
+				// take all the free threads away
+				threads := make([]*rpcThread, 0)
+				for _, freeCH := range processor.freeCHArray {
+					for len(freeCH) > 0 {
+						threads = append(threads, <-freeCH)
+					}
+				}
+				defer func() {
+					for i, thread := range threads {
+						processor.freeCHArray[i%freeGroups] <- thread
+					}
+				}()
+
+				v := rt.CallAsync("#.test:SayHello", "ts")
+				select {
+				case <-v.doneCH:
+				default:
+					return rt.Reply(base.ErrStream)
+				}
+				return rt.Reply(v.Wait())
+			}, nil),
+		)).Equal("hello ts", nil)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					idx, rtValue := WaitAny(rt.CallAsync("#.test:SayHello", "ts"))
+					if idx != 0 {
+						return rt.Reply(base.ErrStream)
+					}
+					return rt.Reply(rtValue)
+				},
+				nil,
+			),
+		)).Equal("hello ts", nil)
+	})
+}
+
+type fnTestCallRemote = func(
+	target string,
+	stream *Stream,
+	deadline int64,
+	cancelCH <-chan bool,
+) *Stream
+
+type testRemoteHub struct {
+	*TestStreamHub
+	fnCallRemote fnTestCallRemote
+}
+
+func (p *testRemoteHub) CallRemote(
+	target string,
+	stream *Stream,
+	deadline int64,
+	cancelCH <-chan bool,
+) *Stream {
+	return p.fnCallRemote(target, stream, deadline, cancelCH)
+}
+
+func testWithRemoteHub(
+	fnCallRemote fnTestCallRemote,
+	fn func(rt Runtime) Return,
+) *Stream {
+	remoteHub := &testRemoteHub{
+		TestStreamHub: NewTestStreamHub(),
+		fnCallRemote:  fnCallRemote,
+	}
+	processor := NewProcessor(
+		1,
+		16,
+		16,
+		2048,
+		nil,
+		3*time.Second,
+		[]*ServiceMeta{{
+			name: "test",
+			service: NewService().
+				On("Eval", fn).
+				On("SayHello", func(rt Runtime, name string) Return {
+					return rt.Reply("hello " + name)
+				}),
+			fileLine: "",
+			data:     nil,
+		}},
+		nil,
+		remoteHub,
+	)
+	defer processor.Close()
+
+	stream, _ := MakeInternalRequestStream(true, 0, "#.test:Eval", "")
+	stream.SetGatewayID(1234)
+	stream.SetSessionID(5678)
+	processor.PutStream(stream)
+	return <-remoteHub.streamCH
+}
+
+func testRemoteReply(
+	target string,
+	stream *Stream,
+	_ int64,
+	_ <-chan bool,
+) *Stream {
+	if target != "#.user:SayHello" {
+		return nil
+	}
+
+	_, _ = readMetadata(stream)
+	name, _ := stream.ReadString()
+	ret := NewStream()
+	ret.SetKind(StreamKindRPCResponseOK)
+	ret.Write(Array{
+		"remote hello " + name,
+		uint64(stream.GetDepth()),
+		stream.GetGatewayID(),
+		stream.GetSessionID(),
+	})
+	return ret
+}
+
+func TestRuntime_CallRemote(t *testing.T) {
+	t.Run("call is forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithRemoteHub(func(
+				target string,
+				stream *Stream,
+				deadline int64,
+				cancelCH <-chan bool,
+			) *Stream {
+				// skip the target and the from
+				_, _ = stream.ReadString()
+				_, _ = stream.ReadString()
+				return testRemoteReply(target, stream, deadline, cancelCH)
+			}, func(rt Runtime) Return {
+				return rt.Reply(rt.Call("#.user:SayHello", "kitty"))
+			}),
+		)).Equal(Array{
+			"remote hello kitty", uint64(1), uint64(1234), uint64(5678),
+		}, nil)
+	})
+
+	t.Run("async call is forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithRemoteHub(func(
+				target string,
+				stream *Stream,
+				deadline int64,
+				cancelCH <-chan bool,
+			) *Stream {
+				_, _ = stream.ReadString()
+				_, _ = stream.ReadString()
+				return testRemoteReply(target, stream, deadline, cancelCH)
+			}, func(rt Runtime) Return {
+				return rt.Reply(rt.CallAsync("#.user:SayHello", "kitty").Wait())
+			}),
+		)).Equal(Array{
+			"remote hello kitty", uint64(1), uint64(1234), uint64(5678),
+		}, nil)
+	})
+
+	t.Run("target is mounted locally", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithRemoteHub(func(
+				_ string,
+				_ *Stream,
+				_ int64,
+				_ <-chan bool,
+			) *Stream {
+				panic("should not be called")
+			}, func(rt Runtime) Return {
+				return rt.Reply(Array{
+					rt.Call("#.test:SayHello", "kitty"),
+					rt.CallAsync("#.test:SayHello", "doggy").Wait(),
+				})
+			}),
+		)).Equal(Array{"hello kitty", "hello doggy"}, nil)
+	})
+
+	t.Run("target is not mounted", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithRemoteHub(testRemoteReply, func(rt Runtime) Return {
+				_, err := rt.Call("#.order:Get").ToString()
+				return rt.Reply(strings.HasPrefix(
+					err.GetMessage(),
+					"rpc-call: #.order:Get does not exist\n",
+				))
+			}),
+		)).Equal(true, nil)
+	})
+
+	t.Run("async target is not mounted", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithRemoteHub(testRemoteReply, func(rt Runtime) Return {
+				_, err := rt.CallAsync("#.order:Get").Wait().ToString()
+				return rt.Reply(strings.HasPrefix(
+					err.GetMessage(),
+					"rpc-call: #.order:Get does not exist\n",
+				))
+			}),
+		)).Equal(true, nil)
+	})
+
+	t.Run("identity of the caller is forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		session, _ := base.EncryptSessionEndpoint(1234, 5678)
+		assert(ParseResponseStream(
+			testWithRemoteHub(func(
+				_ string,
+				stream *Stream,
+				_ int64,
+				_ <-chan bool,
+			) *Stream {
+				_, _ = stream.ReadString()
+				_, _ = stream.ReadString()
+				metadata, _ := readMetadata(stream)
+				ret := NewStream()
+				ret.SetKind(StreamKindRPCResponseOK)
+				ret.Write(Array{
+					metadata[metadataKeyPrincipal],
+					metadata[metadataKeyRoles],
+					metadata[MetadataKeySession],
+				})
+				return ret
+			}, func(rt Runtime) Return {
+				return rt.Reply(rt.Call("#.user:SayHello", "kitty"))
+			}),
+		)).Equal(Array{"", "", session}, nil)
+	})
+
+	t.Run("cancellation is forwarded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithRemoteHub(func(
+				_ string,
+				_ *Stream,
+				_ int64,
+				cancelCH <-chan bool,
+			) *Stream {
+				ret := NewStream()
+				ret.SetKind(StreamKindRPCResponseOK)
+				select {
+				case <-cancelCH:
+					ret.Write("cancelled")
+				case <-time.After(3 * time.Second):
+					ret.Write("timeout")
+				}
+				return ret
+			}, func(rt Runtime) Return {
+				future := rt.CallAsync("#.user:SayHello", "kitty")
+				future.cancel()
+				return rt.Reply(future.Wait())
+			}),
+		)).Equal("cancelled", nil)
+	})
+}
+
 func TestRuntime_NewRTArray(t *testing.T) {
 	t.Run("runtime error", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -335,6 +1044,429 @@ func TestRuntime_GetPostEndPoint(t *testing.T) {
 	})
 }
 
+func TestRuntime_GetDeadline(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{}.GetDeadline()).Equal(time.Time{}, false)
+	})
+
+	t.Run("deadline is not set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					deadline, ok := rt.GetDeadline()
+					return rt.Reply(Array{deadline.IsZero(), ok})
+				},
+				nil,
+			),
+		)).Equal(Array{true, false}, nil)
+	})
+
+	t.Run("deadline is set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		deadline := base.TimeNow().Add(time.Minute)
+		stream := NewStream()
+		stream.SetStatusBitDeadline()
+		stream.WriteString("#.test:Eval")
+		stream.WriteString("")
+		stream.WriteInt64(deadline.UnixNano())
+		assert(testReply(true, nil, nil, func(rt Runtime) Return {
+			v, ok := rt.GetDeadline()
+			return rt.Reply(Array{v.UnixNano(), ok})
+		}, stream)).Equal(Array{deadline.UnixNano(), true}, nil)
+	})
+}
+
+type testSessionHub struct {
+	*TestStreamHub
+	principal  *Principal
+	attributes Map
+}
+
+func (p *testSessionHub) GetPrincipal(
+	gatewayID uint64,
+	sessionID uint64,
+) *Principal {
+	if gatewayID == 1234 && sessionID == 5678 {
+		return p.principal
+	}
+	return nil
+}
+
+func (p *testSessionHub) GetSessionAttribute(
+	gatewayID uint64,
+	sessionID uint64,
+	key string,
+) (Any, bool) {
+	if gatewayID == 1234 && sessionID == 5678 {
+		ret, ok := p.attributes[key]
+		return ret, ok
+	}
+	return nil, false
+}
+
+func (p *testSessionHub) SetSessionAttribute(
+	gatewayID uint64,
+	sessionID uint64,
+	key string,
+	value Any,
+) bool {
+	if gatewayID == 1234 && sessionID == 5678 {
+		if value == nil {
+			delete(p.attributes, key)
+		} else {
+			p.attributes[key] = value
+		}
+		return true
+	}
+	return false
+}
+
+func testWithSessionHub(
+	sessionHub *testSessionHub,
+	sessionID uint64,
+	fn func(rt Runtime) Return,
+) *Stream {
+	processor := NewProcessor(
+		1,
+		16,
+		16,
+		2048,
+		nil,
+		3*time.Second,
+		[]*ServiceMeta{{
+			name:     "test",
+			service:  NewService().On("Eval", fn),
+			fileLine: "",
+			data:     nil,
+		}},
+		nil,
+		sessionHub,
+	)
+	defer processor.Close()
+
+	stream, _ := MakeInternalRequestStream(true, 0, "#.test:Eval", "")
+	stream.SetGatewayID(1234)
+	stream.SetSessionID(sessionID)
+	processor.PutStream(stream)
+	return <-sessionHub.streamCH
+}
+
+func TestRuntime_GetPrincipal(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{}.GetPrincipal()).IsNil()
+	})
+
+	t.Run("stream hub is not a session hub", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					return rt.Reply(rt.GetPrincipal() == nil)
+				},
+				nil,
+			),
+		)).Equal(true, nil)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			principal:     NewPrincipal("kitty", "admin"),
+		}
+		processor := NewProcessor(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().
+					On("Eval", func(rt Runtime, nested bool) Return {
+						if nested {
+							return rt.Reply(rt.Call("#.test:Eval", false))
+						}
+						if principal := rt.GetPrincipal(); principal != nil {
+							return rt.Reply(principal.GetName())
+						}
+						return rt.Reply(nil)
+					}),
+				fileLine: "",
+				data:     nil,
+			}},
+			nil,
+			sessionHub,
+		)
+		defer processor.Close()
+
+		for _, nested := range []bool{false, true} {
+			stream, _ := MakeInternalRequestStream(
+				true, 0, "#.test:Eval", "", nested,
+			)
+			stream.SetGatewayID(1234)
+			stream.SetSessionID(5678)
+			processor.PutStream(stream)
+			assert(ParseResponseStream(<-sessionHub.streamCH)).
+				Equal("kitty", nil)
+		}
+
+		stream, _ := MakeInternalRequestStream(true, 0, "#.test:Eval", "", false)
+		processor.PutStream(stream)
+		assert(ParseResponseStream(<-sessionHub.streamCH)).Equal(nil, nil)
+	})
+
+	t.Run("principal is forwarded by a node", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sessionHub := &testSessionHub{TestStreamHub: NewTestStreamHub()}
+		processor := NewProcessor(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().On("Eval", func(rt Runtime) Return {
+					if principal := rt.GetPrincipal(); principal != nil {
+						return rt.Reply(Array{
+							principal.GetName(),
+							len(principal.GetRoles()),
+						})
+					}
+					return rt.Reply(nil)
+				}),
+				fileLine: "",
+				data:     nil,
+			}},
+			nil,
+			sessionHub,
+		)
+		defer processor.Close()
+
+		testCollection := []struct {
+			principal *Principal
+			metadata  map[string]string
+			expected  Any
+		}{
+			{
+				principal: NewPrincipal("node", RoleNode),
+				metadata: map[string]string{
+					metadataKeyPrincipal: "kitty",
+					metadataKeyRoles:     "admin,user",
+				},
+				expected: Array{"kitty", int64(2)},
+			},
+			{
+				principal: NewPrincipal("node", RoleNode),
+				metadata: map[string]string{
+					metadataKeyPrincipal: "",
+					metadataKeyRoles:     "",
+				},
+				expected: nil,
+			},
+			{
+				principal: NewPrincipal("node", RoleNode),
+				metadata:  map[string]string{"key": "value"},
+				expected:  Array{"node", int64(1)},
+			},
+			{
+				// the principal can not be forged by a client
+				principal: NewPrincipal("doggy"),
+				metadata: map[string]string{
+					metadataKeyPrincipal: "kitty",
+					metadataKeyRoles:     "admin",
+				},
+				expected: Array{"doggy", int64(0)},
+			},
+		}
+
+		for _, it := range testCollection {
+			sessionHub.principal = it.principal
+			stream, _ := makeRequestStream(
+				true, 0, 0, it.metadata, "#.test:Eval", "",
+			)
+			stream.SetGatewayID(1234)
+			stream.SetSessionID(5678)
+			processor.PutStream(stream)
+			assert(ParseResponseStream(<-sessionHub.streamCH)).
+				Equal(it.expected, nil)
+		}
+	})
+}
+
+func TestRuntime_SessionGet(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{}.SessionGet("name")).Equal(nil, false)
+	})
+
+	t.Run("stream hub is not a session hub", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					v, ok := rt.SessionGet("name")
+					return rt.Reply(Array{v, ok})
+				},
+				nil,
+			),
+		)).Equal(Array{nil, false}, nil)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			attributes:    Map{"name": "kitty"},
+		}
+		fn := func(rt Runtime) Return {
+			v, ok := rt.SessionGet("name")
+			return rt.Reply(Array{v, ok})
+		}
+		assert(ParseResponseStream(testWithSessionHub(sessionHub, 5678, fn))).
+			Equal(Array{"kitty", true}, nil)
+		assert(ParseResponseStream(testWithSessionHub(sessionHub, 1, fn))).
+			Equal(Array{nil, false}, nil)
+	})
+}
+
+func TestRuntime_SessionSet(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		err, source := Runtime{}.SessionSet("name", "kitty"), base.GetFileLine(0)
+		assert(err).Equal(
+			base.ErrRuntimeIllegalInCurrentGoroutine.AddDebug(source),
+		)
+	})
+
+	t.Run("value is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			attributes:    Map{},
+		}
+		var e error
+		testWithSessionHub(sessionHub, 5678, func(rt Runtime) Return {
+			e = rt.SessionSet("name", make(chan bool))
+			return rt.Reply(true)
+		})
+		assert(e).Equal(base.ErrUnsupportedValue.
+			AddDebug("value type(chan bool) is not supported"))
+		assert(sessionHub.attributes).Equal(Map{})
+	})
+
+	t.Run("stream hub is not a session hub", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var e error
+		source := ""
+		testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				e, source = rt.SessionSet("name", "kitty"), base.GetFileLine(0)
+				return rt.Reply(true)
+			},
+			nil,
+		)
+		assert(e).Equal(base.ErrRuntimeSessionNotAvailable.AddDebug(source))
+	})
+
+	t.Run("session is not exist", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		var e error
+		source := ""
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			attributes:    Map{},
+		}
+		testWithSessionHub(sessionHub, 1, func(rt Runtime) Return {
+			e, source = rt.SessionSet("name", "kitty"), base.GetFileLine(0)
+			return rt.Reply(true)
+		})
+		assert(e).Equal(base.ErrRuntimeSessionNotAvailable.AddDebug(source))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			attributes:    Map{"age": int64(18)},
+		}
+		assert(ParseResponseStream(testWithSessionHub(
+			sessionHub,
+			5678,
+			func(rt Runtime) Return {
+				if err := rt.SessionSet("name", "kitty"); err != nil {
+					return rt.Reply(err)
+				}
+				if err := rt.SessionSet("cart", Array{1, "apple"}); err != nil {
+					return rt.Reply(err)
+				}
+				if err := rt.SessionSet("age", nil); err != nil {
+					return rt.Reply(err)
+				}
+				return rt.Reply(true)
+			},
+		))).Equal(true, nil)
+		assert(sessionHub.attributes).Equal(Map{
+			"name": "kitty",
+			"cart": Array{int64(1), "apple"},
+		})
+	})
+
+	t.Run("value is copied from the runtime", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			attributes:    Map{},
+		}
+		assert(ParseResponseStream(testWithSessionHub(
+			sessionHub,
+			5678,
+			func(rt Runtime) Return {
+				return rt.Reply(rt.SessionSet("map", rt.NewRTMap(2)))
+			},
+		))).Equal(nil, nil)
+		assert(sessionHub.attributes).Equal(Map{"map": Map{}})
+	})
+}
+
+func TestRuntime_GetMetadata(t *testing.T) {
+	t.Run("thread lock error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(Runtime{}.GetMetadata("traceID")).Equal("", false)
+	})
+
+	t.Run("metadata is not set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			testWithProcessorAndRuntime(
+				func(processor *Processor, rt Runtime) Return {
+					v, ok := rt.GetMetadata("traceID")
+					return rt.Reply(Array{v, ok})
+				},
+				nil,
+			),
+		)).Equal(Array{"", false}, nil)
+	})
+
+	t.Run("metadata is set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream, _ := makeRequestStream(
+			true, 0, 0, map[string]string{"traceID": "1234"}, "#.test:Eval", "",
+		)
+		assert(testReply(true, nil, nil, func(rt Runtime) Return {
+			v1, ok1 := rt.GetMetadata("traceID")
+			v2, ok2 := rt.GetMetadata("locale")
+			return rt.Reply(Array{v1, ok1, v2, ok2})
+		}, stream)).Equal(Array{"1234", true, "", false}, nil)
+	})
+}
+
 func TestRuntime_GetServiceConfig(t *testing.T) {
 	t.Run("runtime is invalid", func(t *testing.T) {
 		assert := base.NewAssert(t)
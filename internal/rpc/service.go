@@ -1,15 +1,19 @@
 package rpc
 
 import (
-	"github.com/rpccloud/rpc/internal/base"
+	"reflect"
 	"sync"
+
+	"github.com/rpccloud/rpc/internal/base"
 )
 
 // ActionMeta ...
 type ActionMeta struct {
-	name     string      // action name
-	handler  interface{} // action handler
-	fileLine string      // where the action add in source file
+	name     string       // action name
+	handler  interface{}  // action handler
+	fileLine string       // where the action add in source file
+	roles    []string     // the caller must have one of the roles
+	reply    reflect.Type // the declared reply type, used by the client stub
 }
 
 // ServiceMeta ...
@@ -37,16 +41,18 @@ func NewServiceMeta(
 
 // Service ...
 type Service struct {
-	children []*ServiceMeta // all the children node meta pointer
-	actions  []*ActionMeta  // all the actions meta pointer
+	children     []*ServiceMeta      // all the children node meta pointer
+	actions      []*ActionMeta       // all the actions meta pointer
+	interceptors []ActionInterceptor // run around the actions of the service
 	sync.Mutex
 }
 
 // NewService define a new service
 func NewService() *Service {
 	return &Service{
-		children: nil,
-		actions:  nil,
+		children:     nil,
+		actions:      nil,
+		interceptors: nil,
 	}
 }
 
@@ -69,10 +75,14 @@ func (p *Service) AddChildService(
 	return p
 }
 
-// On ...
+// On registers an action. If roles are given, only the callers whose
+// principal has one of the roles are allowed to invoke the action. The call
+// that is forwarded by a peer node is checked with the principal of the caller
+// on the origin node, see RoleNode
 func (p *Service) On(
 	name string,
 	handler interface{},
+	roles ...string,
 ) *Service {
 	p.Lock()
 	defer p.Unlock()
@@ -82,6 +92,34 @@ func (p *Service) On(
 		name:     name,
 		handler:  handler,
 		fileLine: base.GetFileLine(1),
+		roles:    append([]string(nil), roles...),
 	})
 	return p
 }
+
+// SetReplyType declares the type of the value that the action replies, for
+// example SetReplyType("Get", User{}). The client stub returns the value of the
+// type instead of rpc.Any. The action must be registered before
+func (p *Service) SetReplyType(name string, reply interface{}) *Service {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, action := range p.actions {
+		if action.name == name {
+			action.reply = reflect.TypeOf(reply)
+		}
+	}
+	return p
+}
+
+// AddInterceptor adds an interceptor to the service. It runs around the
+// actions of the service and its children services
+func (p *Service) AddInterceptor(interceptor ActionInterceptor) *Service {
+	p.Lock()
+	defer p.Unlock()
+
+	if interceptor != nil {
+		p.interceptors = append(p.interceptors, interceptor)
+	}
+	return p
+}
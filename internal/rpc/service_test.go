@@ -24,6 +24,7 @@ func TestNewService(t *testing.T) {
 		assert(service).IsNotNil()
 		assert(len(service.children)).Equal(0)
 		assert(len(service.actions)).Equal(0)
+		assert(len(service.interceptors)).Equal(0)
 	})
 }
 
@@ -52,5 +53,53 @@ func TestService_On(t *testing.T) {
 		assert(service.actions[0].name).Equal("sayHello")
 		assert(service.actions[0].handler).Equal(2345)
 		assert(service.actions[0].fileLine).Equal(fileLine)
+		assert(service.actions[0].roles).IsNil()
+	})
+
+	t.Run("with roles", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		roles := []string{"admin", "root"}
+		service := NewService().On("sayHello", 2345, roles...)
+		assert(service.actions[0].roles).Equal([]string{"admin", "root"})
+		roles[0] = "guest"
+		assert(service.actions[0].roles).Equal([]string{"admin", "root"})
+	})
+}
+
+func TestService_SetReplyType(t *testing.T) {
+	t.Run("action is not registered", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		service := NewService().On("sayHello", 2345)
+		assert(service.SetReplyType("sayBye", "")).Equal(service)
+		assert(service.actions[0].reply).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		service := NewService().
+			On("sayHello", 2345).
+			SetReplyType("sayHello", "")
+		assert(service.actions[0].reply == stringType).IsTrue()
+	})
+}
+
+func TestService_AddInterceptor(t *testing.T) {
+	t.Run("interceptor is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		service := NewService()
+		assert(service.AddInterceptor(nil)).Equal(service)
+		assert(len(service.interceptors)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		interceptor1 := &testActionInterceptor{name: "1"}
+		interceptor2 := &testActionInterceptor{name: "2"}
+		service := NewService().
+			AddInterceptor(interceptor1).
+			AddInterceptor(interceptor2)
+		assert(len(service.interceptors)).Equal(2)
+		assert(service.interceptors[0] == interceptor1).IsTrue()
+		assert(service.interceptors[1] == interceptor2).IsTrue()
 	})
 }
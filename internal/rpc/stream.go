@@ -6,6 +6,7 @@ import (
 	"math"
 	"reflect"
 	"strconv"
+	"time"
 	"unsafe"
 
 	"github.com/rpccloud/rpc/internal/base"
@@ -31,7 +32,17 @@ const (
 	streamPosDepth      = 58
 	streamPosBody       = 60
 
-	streamStatusBitDebug = 0
+	streamStatusBitDebug    = 0
+	streamStatusBitDeadline = 1
+	streamStatusBitMetadata = 2
+	// the body is compressed with the codec that the connection negotiated
+	streamStatusBitCompressed = 3
+
+	// the packed array is code 0, the total length (4 bytes) and the kind
+	streamPackedHeadSize = 6
+	streamPackedInt64    = 1
+	streamPackedFloat64  = 2
+	streamPackedString   = 3
 
 	// StreamHeadSize ...
 	StreamHeadSize = streamPosBody
@@ -60,6 +71,24 @@ const (
 	StreamKindRPCBoardCast = 8
 	// StreamKindSystemErrorReport ...
 	StreamKindSystemErrorReport = 9
+	// StreamKindRPCResponseChunk ...
+	StreamKindRPCResponseChunk = 10
+	// StreamKindRPCCancel ...
+	StreamKindRPCCancel = 11
+	// StreamKindSessionEvent ...
+	StreamKindSessionEvent = 12
+	// StreamKindTopicSubscribe ...
+	StreamKindTopicSubscribe = 13
+	// StreamKindTopicUnsubscribe ...
+	StreamKindTopicUnsubscribe = 14
+	// StreamKindTopicPublish ...
+	StreamKindTopicPublish = 15
+	// StreamKindRPCBoardCastAck ...
+	StreamKindRPCBoardCastAck = 16
+	// StreamKindSessionAttribute ...
+	StreamKindSessionAttribute = 17
+	// StreamKindSessionPrincipal ...
+	StreamKindSessionPrincipal = 18
 )
 
 var (
@@ -93,7 +122,7 @@ var (
 	}
 	readSkipArray = [256]int{
 		-0x01, +0x01, +0x01, +0x01, +0x01, +0x09, +0x03, +0x05,
-		+0x09, +0x03, +0x05, +0x09, -0x01, -0x01, +0x01, +0x01,
+		+0x09, +0x03, +0x05, +0x09, +0x11, +0x09, +0x01, +0x01,
 		+0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01,
 		+0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01,
 		+0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01,
@@ -230,6 +259,51 @@ func (p *Stream) ClearStatusBitDebug() {
 	(*p.frames[0])[streamPosStatusBit] &= (1 << streamStatusBitDebug) ^ 0xFF
 }
 
+// HasStatusBitDeadline ...
+func (p *Stream) HasStatusBitDeadline() bool {
+	return (*p.frames[0])[streamPosStatusBit]&(1<<streamStatusBitDeadline) != 0
+}
+
+// SetStatusBitDeadline ...
+func (p *Stream) SetStatusBitDeadline() {
+	(*p.frames[0])[streamPosStatusBit] |= 1 << streamStatusBitDeadline
+}
+
+// ClearStatusBitDeadline ...
+func (p *Stream) ClearStatusBitDeadline() {
+	(*p.frames[0])[streamPosStatusBit] &= (1 << streamStatusBitDeadline) ^ 0xFF
+}
+
+// HasStatusBitMetadata ...
+func (p *Stream) HasStatusBitMetadata() bool {
+	return (*p.frames[0])[streamPosStatusBit]&(1<<streamStatusBitMetadata) != 0
+}
+
+// SetStatusBitMetadata ...
+func (p *Stream) SetStatusBitMetadata() {
+	(*p.frames[0])[streamPosStatusBit] |= 1 << streamStatusBitMetadata
+}
+
+// ClearStatusBitMetadata ...
+func (p *Stream) ClearStatusBitMetadata() {
+	(*p.frames[0])[streamPosStatusBit] &= (1 << streamStatusBitMetadata) ^ 0xFF
+}
+
+// HasStatusBitCompressed ...
+func (p *Stream) HasStatusBitCompressed() bool {
+	return (*p.frames[0])[streamPosStatusBit]&(1<<streamStatusBitCompressed) != 0
+}
+
+// SetStatusBitCompressed ...
+func (p *Stream) SetStatusBitCompressed() {
+	(*p.frames[0])[streamPosStatusBit] |= 1 << streamStatusBitCompressed
+}
+
+// ClearStatusBitCompressed ...
+func (p *Stream) ClearStatusBitCompressed() {
+	(*p.frames[0])[streamPosStatusBit] &= (1 << streamStatusBitCompressed) ^ 0xFF
+}
+
 // GetKind ...
 func (p *Stream) GetKind() uint8 {
 	return (*p.frames[0])[streamPosKind]
@@ -504,6 +578,9 @@ func (p *Stream) peekSkip() (int, byte) {
 	op := p.readFrame[p.readIndex]
 	if skip := readSkipArray[op]; skip > 0 && p.CanRead() {
 		return skip, op
+	} else if op == 0 {
+		// code 0 is only legal as the header of a packed array
+		return p.peekPackedSkip(), op
 	} else if skip < 0 {
 		return 0, op
 	} else if p.isSafetyReadNBytesInCurrentFrame(5) {
@@ -914,6 +991,78 @@ func (p *Stream) WriteString(v string) {
 	}
 }
 
+// WriteMetadata write the metadata of a request. It is encoded as the number
+// of entries followed by the key-value strings
+func (p *Stream) WriteMetadata(v map[string]string) {
+	p.WriteUint64(uint64(len(v)))
+	for key, value := range v {
+		p.WriteString(key)
+		p.WriteString(value)
+	}
+}
+
+// WriteTime write time value to stream. The time zone is written as the
+// offset, so the name of the zone is lost
+func (p *Stream) WriteTime(v Time) {
+	_, offset := v.Zone()
+	sec := uint64(v.Unix())
+	nsec := uint32(v.Nanosecond())
+	zone := uint32(int32(offset))
+
+	b := [17]byte{
+		12,
+		byte(sec),
+		byte(sec >> 8),
+		byte(sec >> 16),
+		byte(sec >> 24),
+		byte(sec >> 32),
+		byte(sec >> 40),
+		byte(sec >> 48),
+		byte(sec >> 56),
+		byte(nsec),
+		byte(nsec >> 8),
+		byte(nsec >> 16),
+		byte(nsec >> 24),
+		byte(zone),
+		byte(zone >> 8),
+		byte(zone >> 16),
+		byte(zone >> 24),
+	}
+
+	p.PutBytes(b[:])
+}
+
+// WriteDuration write duration value to stream
+func (p *Stream) WriteDuration(v Duration) {
+	d := uint64(v)
+
+	if p.writeIndex < streamBlockSize-9 {
+		b := p.writeFrame[p.writeIndex:]
+		b[0] = 13
+		b[1] = byte(d)
+		b[2] = byte(d >> 8)
+		b[3] = byte(d >> 16)
+		b[4] = byte(d >> 24)
+		b[5] = byte(d >> 32)
+		b[6] = byte(d >> 40)
+		b[7] = byte(d >> 48)
+		b[8] = byte(d >> 56)
+		p.writeIndex += 9
+	} else {
+		p.PutBytes([]byte{
+			13,
+			byte(d),
+			byte(d >> 8),
+			byte(d >> 16),
+			byte(d >> 24),
+			byte(d >> 32),
+			byte(d >> 40),
+			byte(d >> 48),
+			byte(d >> 56),
+		})
+	}
+}
+
 // WriteBytes ...
 func (p *Stream) WriteBytes(v Bytes) {
 	length := len(v)
@@ -971,6 +1120,60 @@ func (p *Stream) WriteBytes(v Bytes) {
 	}
 }
 
+func (p *Stream) writePackedHeader(kind byte, totalLength int) {
+	p.PutBytes([]byte{
+		0,
+		byte(uint32(totalLength)),
+		byte(uint32(totalLength) >> 8),
+		byte(uint32(totalLength) >> 16),
+		byte(uint32(totalLength) >> 24),
+		kind,
+	})
+}
+
+// WriteInt64Array write []int64 value to stream. The items are packed without
+// the type codes, so they are not boxed when they are read back
+func (p *Stream) WriteInt64Array(v []int64) {
+	p.writePackedHeader(streamPackedInt64, streamPackedHeadSize+8*len(v))
+
+	b := [8]byte{}
+	for _, item := range v {
+		binary.LittleEndian.PutUint64(b[:], uint64(item))
+		p.PutBytes(b[:])
+	}
+}
+
+// WriteFloat64Array write []float64 value to stream. The items are packed
+// without the type codes, so they are not boxed when they are read back
+func (p *Stream) WriteFloat64Array(v []float64) {
+	p.writePackedHeader(streamPackedFloat64, streamPackedHeadSize+8*len(v))
+
+	b := [8]byte{}
+	for _, item := range v {
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(item))
+		p.PutBytes(b[:])
+	}
+}
+
+// WriteStringArray write []string value to stream. The items are packed
+// without the type codes, so they are not boxed when they are read back
+func (p *Stream) WriteStringArray(v []string) {
+	totalLength := streamPackedHeadSize + 4
+	for _, item := range v {
+		totalLength += 4 + len(item)
+	}
+	p.writePackedHeader(streamPackedString, totalLength)
+
+	b := [4]byte{}
+	binary.LittleEndian.PutUint32(b[:], uint32(len(v)))
+	p.PutBytes(b[:])
+	for _, item := range v {
+		binary.LittleEndian.PutUint32(b[:], uint32(len(item)))
+		p.PutBytes(b[:])
+		p.PutBytes(base.StringToBytesUnsafe(item))
+	}
+}
+
 func (p *Stream) writeArray(v Array, depth int) string {
 	length := len(v)
 	if length == 0 {
@@ -1303,6 +1506,13 @@ func (p *Stream) writeRTValue(v RTValue) string {
 	return StreamWriteIsNotAvailable
 }
 
+// Write writes a value and returns StreamWriteOK if succeeded. Besides the
+// built-in types, the other Go values are written through reflection: the
+// named basic types are written as their underlying types, the structs are
+// written as Map, the slices are written as Array, the pointers are written as
+// what they point to, and the nil pointers are written as nil. The channels,
+// the functions, the maps whose keys are not strings and the errors are not
+// supported
 func (p *Stream) Write(v interface{}) string {
 	if reason := p.write(v, 64); reason != StreamWriteOK {
 		return "value" + reason
@@ -1367,6 +1577,33 @@ func (p *Stream) write(v interface{}, depth int) string {
 	case Bytes:
 		p.WriteBytes(v)
 		return StreamWriteOK
+	case []int64:
+		if v == nil {
+			p.WriteNil()
+		} else {
+			p.WriteInt64Array(v)
+		}
+		return StreamWriteOK
+	case []float64:
+		if v == nil {
+			p.WriteNil()
+		} else {
+			p.WriteFloat64Array(v)
+		}
+		return StreamWriteOK
+	case []string:
+		if v == nil {
+			p.WriteNil()
+		} else {
+			p.WriteStringArray(v)
+		}
+		return StreamWriteOK
+	case Time:
+		p.WriteTime(v)
+		return StreamWriteOK
+	case Duration:
+		p.WriteDuration(v)
+		return StreamWriteOK
 	case Array:
 		return p.writeArray(v, depth)
 	case Map:
@@ -1377,8 +1614,13 @@ func (p *Stream) write(v interface{}, depth int) string {
 		return p.writeRTMap(v)
 	case RTValue:
 		return p.writeRTValue(v)
-	default:
+	case Marshaler:
+		return p.writeMarshaler(v)
+	case error:
+		// the errors are replied by the error streams
 		return fmt.Sprintf(" type(%T) is not supported", v)
+	default:
+		return p.writeReflect(reflect.ValueOf(v), depth)
 	}
 }
 
@@ -1583,6 +1825,69 @@ func (p *Stream) ReadUint64() (uint64, *base.Error) {
 	return 0, base.ErrStream
 }
 
+// ReadTime read a time
+func (p *Stream) ReadTime() (Time, *base.Error) {
+	if p.readFrame[p.readIndex] == 12 {
+		var b []byte
+		if p.isSafetyReadNBytesInCurrentFrame(17) {
+			b = p.readFrame[p.readIndex : p.readIndex+17]
+			p.readIndex += 17
+		} else if p.hasNBytesToRead(17) {
+			b = p.readNBytesCrossFrameUnsafe(17)
+		}
+
+		if b != nil {
+			sec := int64(uint64(b[1]) |
+				(uint64(b[2]) << 8) |
+				(uint64(b[3]) << 16) |
+				(uint64(b[4]) << 24) |
+				(uint64(b[5]) << 32) |
+				(uint64(b[6]) << 40) |
+				(uint64(b[7]) << 48) |
+				(uint64(b[8]) << 56))
+			nsec := int64(uint32(b[9]) |
+				(uint32(b[10]) << 8) |
+				(uint32(b[11]) << 16) |
+				(uint32(b[12]) << 24))
+			offset := int(int32(uint32(b[13]) |
+				(uint32(b[14]) << 8) |
+				(uint32(b[15]) << 16) |
+				(uint32(b[16]) << 24)))
+
+			if offset == 0 {
+				return time.Unix(sec, nsec).UTC(), nil
+			}
+			return time.Unix(sec, nsec).In(time.FixedZone("", offset)), nil
+		}
+	}
+	return Time{}, base.ErrStream
+}
+
+// ReadDuration read a duration
+func (p *Stream) ReadDuration() (Duration, *base.Error) {
+	if p.readFrame[p.readIndex] == 13 {
+		var b []byte
+		if p.isSafetyReadNBytesInCurrentFrame(9) {
+			b = p.readFrame[p.readIndex : p.readIndex+9]
+			p.readIndex += 9
+		} else if p.hasNBytesToRead(9) {
+			b = p.readNBytesCrossFrameUnsafe(9)
+		}
+
+		if b != nil {
+			return Duration(uint64(b[1]) |
+				(uint64(b[2]) << 8) |
+				(uint64(b[3]) << 16) |
+				(uint64(b[4]) << 24) |
+				(uint64(b[5]) << 32) |
+				(uint64(b[6]) << 40) |
+				(uint64(b[7]) << 48) |
+				(uint64(b[8]) << 56)), nil
+		}
+	}
+	return 0, base.ErrStream
+}
+
 // ReadString read a string value
 func (p *Stream) ReadString() (string, *base.Error) {
 	// empty string
@@ -1824,9 +2129,263 @@ func (p *Stream) ReadBytes() (Bytes, *base.Error) {
 	return Bytes{}, base.ErrStream
 }
 
+// peekPackedKind returns the kind of the packed array at the read position, or
+// 0 if there is not a packed array
+func (p *Stream) peekPackedKind() byte {
+	if p.readFrame[p.readIndex] != 0 {
+		return 0
+	} else if p.isSafetyReadNBytesInCurrentFrame(streamPackedHeadSize) {
+		return p.readFrame[p.readIndex+streamPackedHeadSize-1]
+	} else if p.hasNBytesToRead(streamPackedHeadSize) {
+		return p.peekNBytesCrossFrameUnsafe(
+			streamPackedHeadSize,
+		)[streamPackedHeadSize-1]
+	} else {
+		return 0
+	}
+}
+
+// peekPackedSkip returns the total length of the packed array at the read
+// position, or 0 if the header is not correct
+func (p *Stream) peekPackedSkip() int {
+	var b []byte
+	if p.isSafetyReadNBytesInCurrentFrame(streamPackedHeadSize) {
+		b = p.readFrame[p.readIndex:]
+	} else if p.hasNBytesToRead(streamPackedHeadSize) {
+		b = p.peekNBytesCrossFrameUnsafe(streamPackedHeadSize)
+	} else {
+		return 0
+	}
+
+	totalLen := int(binary.LittleEndian.Uint32(b[1:]))
+	bodyLen := totalLen - streamPackedHeadSize
+	switch b[streamPackedHeadSize-1] {
+	case streamPackedInt64, streamPackedFloat64:
+		if bodyLen >= 0 && bodyLen%8 == 0 {
+			return totalLen
+		}
+	case streamPackedString:
+		if bodyLen >= 4 {
+			return totalLen
+		}
+	}
+
+	return 0
+}
+
+// readPackedHeader reads the header of the packed array, and returns the
+// length of the items, or -1 if the header is not correct
+func (p *Stream) readPackedHeader(kind byte) int {
+	if p.peekPackedKind() != kind {
+		return -1
+	}
+
+	readStart := p.GetReadPos()
+	var b []byte
+	if p.isSafetyReadNBytesInCurrentFrame(streamPackedHeadSize) {
+		b = p.readFrame[p.readIndex:]
+		p.readIndex += streamPackedHeadSize
+	} else {
+		b = p.readNBytesCrossFrameUnsafe(streamPackedHeadSize)
+	}
+
+	bodyLen := int(binary.LittleEndian.Uint32(b[1:])) - streamPackedHeadSize
+	if bodyLen < 0 || !p.hasNBytesToRead(bodyLen) {
+		p.SetReadPos(readStart)
+		return -1
+	}
+
+	return bodyLen
+}
+
+// readNBytesToUnsafe fills v with the next bytes of the stream, the caller
+// should make sure there are enough bytes to read
+func (p *Stream) readNBytesToUnsafe(v []byte) {
+	for reads := 0; reads < len(v); {
+		readLen := copy(v[reads:], p.readFrame[p.readIndex:])
+		reads += readLen
+		p.readIndex += readLen
+		if p.readIndex == streamBlockSize {
+			p.gotoNextReadFrameUnsafe()
+		}
+	}
+}
+
+// ReadInt64Array read a []int64 value. The nil, and the Array that only
+// contains Int64 values can also be read
+func (p *Stream) ReadInt64Array() ([]int64, *base.Error) {
+	readStart := p.GetReadPos()
+
+	if p.readFrame[p.readIndex] == 0 {
+		// the packed array, which is never read as an Array below
+		bodyLen := p.readPackedHeader(streamPackedInt64)
+		if bodyLen >= 0 && bodyLen%8 == 0 {
+			ret := make([]int64, bodyLen/8)
+			b := [8]byte{}
+			for i := 0; i < len(ret); i++ {
+				p.readNBytesToUnsafe(b[:])
+				ret[i] = int64(binary.LittleEndian.Uint64(b[:]))
+			}
+			return ret, nil
+		}
+	} else if _, err := p.ReadNil(); err == nil {
+		return nil, nil
+	} else if arr, err := p.ReadArray(); err == nil {
+		ret := make([]int64, len(arr))
+		ok := true
+		for i := 0; i < len(arr) && ok; i++ {
+			ret[i], ok = arr[i].(int64)
+		}
+		if ok {
+			return ret, nil
+		}
+	}
+
+	p.SetReadPos(readStart)
+	return nil, base.ErrStream
+}
+
+// ReadFloat64Array read a []float64 value. The nil, and the Array that only
+// contains Float64 values can also be read
+func (p *Stream) ReadFloat64Array() ([]float64, *base.Error) {
+	readStart := p.GetReadPos()
+
+	if p.readFrame[p.readIndex] == 0 {
+		// the packed array, which is never read as an Array below
+		bodyLen := p.readPackedHeader(streamPackedFloat64)
+		if bodyLen >= 0 && bodyLen%8 == 0 {
+			ret := make([]float64, bodyLen/8)
+			b := [8]byte{}
+			for i := 0; i < len(ret); i++ {
+				p.readNBytesToUnsafe(b[:])
+				ret[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+			}
+			return ret, nil
+		}
+	} else if _, err := p.ReadNil(); err == nil {
+		return nil, nil
+	} else if arr, err := p.ReadArray(); err == nil {
+		ret := make([]float64, len(arr))
+		ok := true
+		for i := 0; i < len(arr) && ok; i++ {
+			ret[i], ok = arr[i].(float64)
+		}
+		if ok {
+			return ret, nil
+		}
+	}
+
+	p.SetReadPos(readStart)
+	return nil, base.ErrStream
+}
+
+// ReadStringArray read a []string value. The nil, and the Array that only
+// contains String values can also be read
+func (p *Stream) ReadStringArray() ([]string, *base.Error) {
+	readStart := p.GetReadPos()
+
+	if p.readFrame[p.readIndex] == 0 {
+		// the packed array, which is never read as an Array below
+		if bodyLen := p.readPackedHeader(streamPackedString); bodyLen >= 4 {
+			if ret, ok := p.readPackedStrings(bodyLen); ok {
+				return ret, nil
+			}
+		}
+	} else if _, err := p.ReadNil(); err == nil {
+		return nil, nil
+	} else if arr, err := p.ReadArray(); err == nil {
+		ret := make([]string, len(arr))
+		ok := true
+		for i := 0; i < len(arr) && ok; i++ {
+			ret[i], ok = arr[i].(string)
+		}
+		if ok {
+			return ret, nil
+		}
+	}
+
+	p.SetReadPos(readStart)
+	return nil, base.ErrStream
+}
+
+func (p *Stream) readPackedStrings(bodyLen int) ([]string, bool) {
+	b := [4]byte{}
+	p.readNBytesToUnsafe(b[:])
+	bodyLen -= 4
+
+	// every item takes at least 4 bytes
+	count := int(binary.LittleEndian.Uint32(b[:]))
+	if count > bodyLen/4 {
+		return nil, false
+	}
+
+	ret := make([]string, count)
+	for i := 0; i < count; i++ {
+		if bodyLen < 4 {
+			return nil, false
+		}
+		p.readNBytesToUnsafe(b[:])
+		itemLen := int(binary.LittleEndian.Uint32(b[:]))
+		if bodyLen -= 4 + itemLen; bodyLen < 0 {
+			return nil, false
+		}
+		item := make([]byte, itemLen)
+		p.readNBytesToUnsafe(item)
+		ret[i] = base.BytesToStringUnsafe(item)
+	}
+
+	return ret, bodyLen == 0
+}
+
+// readPackedArray read the packed array as the []int64, []float64 or []string
+func (p *Stream) readPackedArray() (Any, *base.Error) {
+	switch p.peekPackedKind() {
+	case streamPackedInt64:
+		return p.ReadInt64Array()
+	case streamPackedFloat64:
+		return p.ReadFloat64Array()
+	case streamPackedString:
+		return p.ReadStringArray()
+	default:
+		return nil, base.ErrStream
+	}
+}
+
+func (p *Stream) readPackedArrayAsArray() (Array, *base.Error) {
+	packed, err := p.readPackedArray()
+	if err != nil {
+		return Array{}, err
+	}
+
+	switch packed := packed.(type) {
+	case []int64:
+		ret := make(Array, len(packed))
+		for i, item := range packed {
+			ret[i] = item
+		}
+		return ret, nil
+	case []float64:
+		ret := make(Array, len(packed))
+		for i, item := range packed {
+			ret[i] = item
+		}
+		return ret, nil
+	default:
+		ret := make(Array, len(packed.([]string)))
+		for i, item := range packed.([]string) {
+			ret[i] = item
+		}
+		return ret, nil
+	}
+}
+
 // ReadArray ...
 func (p *Stream) ReadArray() (Array, *base.Error) {
 	v := p.readFrame[p.readIndex]
+	if v == 0 {
+		// the packed arrays are boxed for the callers that want Array
+		return p.readPackedArrayAsArray()
+	}
 	if v >= 64 && v < 96 {
 		arrLen := 0
 		totalLen := 0
@@ -1976,7 +2535,8 @@ func (p *Stream) ReadMap() (Map, *base.Error) {
 	return Map{}, base.ErrStream
 }
 
-// Read read a generic value
+// Read read a generic value. The packed arrays are read as Array, use
+// ReadInt64Array, ReadFloat64Array or ReadStringArray to get the typed slices
 func (p *Stream) Read() (ret Any, err *base.Error) {
 	defer func() {
 		if err != nil {
@@ -1986,6 +2546,8 @@ func (p *Stream) Read() (ret Any, err *base.Error) {
 
 	op := p.readFrame[p.readIndex]
 	switch op {
+	case byte(0):
+		return p.ReadArray()
 	case byte(1):
 		return p.ReadNil()
 	case byte(2):
@@ -2009,9 +2571,9 @@ func (p *Stream) Read() (ret Any, err *base.Error) {
 	case byte(11):
 		return p.ReadUint64()
 	case byte(12):
-		return nil, base.ErrStream
+		return p.ReadTime()
 	case byte(13):
-		return nil, base.ErrStream
+		return p.ReadDuration()
 	}
 
 	switch op >> 6 {
@@ -2032,10 +2594,34 @@ func (p *Stream) Read() (ret Any, err *base.Error) {
 	}
 }
 
-// ReadRTArray read a RPCArray value
+// readPackedArrayAsRTArray reads the packed array, and boxes the items into
+// the runtime stream one by one, like RTArray.Append does
+func (p *Stream) readPackedArrayAsRTArray(rt Runtime) (RTArray, *base.Error) {
+	arr, err := p.readPackedArrayAsArray()
+	if err != nil {
+		return RTArray{}, err
+	}
+
+	cs := rt.thread.rtStream
+	ret := newRTArray(rt, len(arr))
+	for _, item := range arr {
+		pos := int64(cs.GetWritePos())
+		// the items are Int64, Float64 or String, so they can always be written
+		cs.Write(item)
+		_, isString := item.(string)
+		*ret.items = append(*ret.items, makePosRecord(pos, isString))
+	}
+	return ret, nil
+}
+
+// ReadRTArray read a RPCArray value. The packed arrays are boxed into the
+// runtime stream
 func (p *Stream) ReadRTArray(rt Runtime) (RTArray, *base.Error) {
 	if thread := rt.thread; thread != nil {
 		v := p.readFrame[p.readIndex]
+		if v == 0 {
+			return p.readPackedArrayAsRTArray(rt)
+		}
 		if v >= 64 && v < 96 {
 			cs := thread.rtStream
 			arrLen := 0
@@ -2298,3 +2884,36 @@ func (p *Stream) ReadRTValue(rt Runtime) (RTValue, *base.Error) {
 	return RTValue{err: base.ErrRuntimeIllegalInCurrentGoroutine},
 		base.ErrRuntimeIllegalInCurrentGoroutine
 }
+
+// ReadMetadata read the metadata written by WriteMetadata
+func (p *Stream) ReadMetadata() (map[string]string, *base.Error) {
+	readStart := p.GetReadPos()
+
+	size, err := p.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+
+	// every entry takes at least 2 bytes
+	if size > uint64(p.GetWritePos()-p.GetReadPos())/2 {
+		p.SetReadPos(readStart)
+		return nil, base.ErrStream
+	}
+
+	ret := make(map[string]string, size)
+	for i := uint64(0); i < size; i++ {
+		key, err := p.ReadString()
+		if err != nil {
+			p.SetReadPos(readStart)
+			return nil, err
+		}
+		value, err := p.ReadString()
+		if err != nil {
+			p.SetReadPos(readStart)
+			return nil, err
+		}
+		ret[key] = value
+	}
+
+	return ret, nil
+}
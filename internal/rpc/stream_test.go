@@ -3,7 +3,10 @@ package rpc
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rpccloud/rpc/internal/base"
 )
@@ -155,6 +158,55 @@ var streamTestSuccessCollections = map[string][][2]interface{}{
 			0xBA, 0xF0, 0x9F, 0x8C, 0xB3, 0xF0, 0x9F, 0x8D, 0x8A, 0x00,
 		}},
 	},
+	"time": {
+		{time.Unix(0, 0).UTC(), []byte{
+			0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}},
+		{time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("", 28800)), []byte{
+			0x0C, 0x25, 0xED, 0x0C, 0x5E, 0x00, 0x00, 0x00, 0x00,
+			0x06, 0x00, 0x00, 0x00, 0x80, 0x70, 0x00, 0x00,
+		}},
+		{time.Unix(-1, 999999999).In(time.FixedZone("", -3600)), []byte{
+			0x0C, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0xC9, 0x9A, 0x3B, 0xF0, 0xF1, 0xFF, 0xFF,
+		}},
+	},
+	"duration": {
+		{time.Duration(0), []byte{
+			0x0D, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}},
+		{time.Second, []byte{
+			0x0D, 0x00, 0xCA, 0x9A, 0x3B, 0x00, 0x00, 0x00, 0x00,
+		}},
+		{time.Duration(-1), []byte{
+			0x0D, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		}},
+	},
+	"int64Array": {
+		{[]int64{}, []byte{0x00, 0x06, 0x00, 0x00, 0x00, 0x01}},
+		{[]int64{1, -1}, []byte{
+			0x00, 0x16, 0x00, 0x00, 0x00, 0x01,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		}},
+	},
+	"float64Array": {
+		{[]float64{}, []byte{0x00, 0x06, 0x00, 0x00, 0x00, 0x02}},
+		{[]float64{1.5}, []byte{
+			0x00, 0x0E, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x3F,
+		}},
+	},
+	"stringArray": {
+		{[]string{}, []byte{
+			0x00, 0x0A, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00,
+		}},
+		{[]string{"a", ""}, []byte{
+			0x00, 0x13, 0x00, 0x00, 0x00, 0x03, 0x02, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x61, 0x00, 0x00, 0x00, 0x00,
+		}},
+	},
 	"bytes": {
 		{[]byte{}, []byte{0xC0}},
 		{[]byte{0xDA}, []byte{0xC1, 0xDA}},
@@ -412,6 +464,15 @@ var streamTestWriteCollections = map[string][][2]interface{}{
 			RTMap{rt: testRuntime, items: &[]mapItem{}, length: new(uint32)},
 			StreamWriteOK,
 		},
+		// the values below are written through reflection
+		{testReflectUser{}, StreamWriteOK},
+		{&testReflectUser{}, StreamWriteOK},
+		{(*testReflectUser)(nil), StreamWriteOK},
+		{[]int32{}, StreamWriteOK},
+		{map[string]float32{}, StreamWriteOK},
+		{make(chan bool), "value type(chan bool) is not supported"},
+		{func() {}, "value type(func()) is not supported"},
+		{map[int]bool{}, "value type(map[int]bool) is not supported"},
 		{RTValue{}, "value is not available"},
 		{RTArray{}, "value is not available"},
 		{RTMap{}, "value is not available"},
@@ -434,6 +495,29 @@ var streamTestWriteCollections = map[string][][2]interface{}{
 	},
 }
 
+// getTestReadValue returns the value that Stream.Read gets, the packed arrays
+// are read as Array
+func getTestReadValue(v Any) Any {
+	ret := Array{}
+	switch v := v.(type) {
+	case []int64:
+		for _, item := range v {
+			ret = append(ret, item)
+		}
+	case []float64:
+		for _, item := range v {
+			ret = append(ret, item)
+		}
+	case []string:
+		for _, item := range v {
+			ret = append(ret, item)
+		}
+	default:
+		return v
+	}
+	return ret
+}
+
 func getTestRange(start uint, end uint, head uint, tail uint, step uint) []int {
 	ret := make([]int, 0)
 	for i := start; i <= end; i++ {
@@ -476,6 +560,9 @@ func TestStream(t *testing.T) {
 		assert(streamPosDepth).Equal(58)
 		assert(streamPosBody).Equal(60)
 		assert(streamStatusBitDebug).Equal(0)
+		assert(streamStatusBitDeadline).Equal(1)
+		assert(streamStatusBitMetadata).Equal(2)
+		assert(streamStatusBitCompressed).Equal(3)
 		assert(StreamHeadSize).Equal(60)
 		assert(StreamWriteOK).Equal("")
 		assert(StreamKindConnectRequest).Equal(1)
@@ -487,6 +574,8 @@ func TestStream(t *testing.T) {
 		assert(StreamKindRPCResponseError).Equal(7)
 		assert(StreamKindRPCBoardCast).Equal(8)
 		assert(StreamKindSystemErrorReport).Equal(9)
+		assert(StreamKindRPCResponseChunk).Equal(10)
+		assert(StreamKindRPCCancel).Equal(11)
 	})
 
 	t.Run("test initStreamFrame0", func(t *testing.T) {
@@ -539,7 +628,7 @@ func TestStream(t *testing.T) {
 		assert := base.NewAssert(t)
 		assert(readSkipArray).Equal([256]int{
 			-0x01, +0x01, +0x01, +0x01, +0x01, +0x09, +0x03, +0x05,
-			+0x09, +0x03, +0x05, +0x09, -0x01, -0x01, +0x01, +0x01,
+			+0x09, +0x03, +0x05, +0x09, +0x11, +0x09, +0x01, +0x01,
 			+0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01,
 			+0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01,
 			+0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01, +0x01,
@@ -734,6 +823,180 @@ func TestStream_ClearStatusBitDebug(t *testing.T) {
 	})
 }
 
+func TestStream_HasStatusBitDeadline(t *testing.T) {
+	t.Run("test bit set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			v.SetStatusBitDeadline()
+			assert(v.HasStatusBitDeadline()).IsTrue()
+			v.Release()
+		}
+	})
+
+	t.Run("test bit unset", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			v.ClearStatusBitDeadline()
+			assert(v.HasStatusBitDeadline()).IsFalse()
+			v.Release()
+		}
+	})
+}
+
+func TestStream_SetStatusBitDeadline(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			if !v.HasStatusBitDeadline() {
+				v.SetStatusBitDeadline()
+				assert(v.HasStatusBitDeadline()).IsTrue()
+				v.ClearStatusBitDeadline()
+			}
+			assert((*v.frames[0])[streamPosStatusBit]).Equal(byte(i))
+			v.Release()
+		}
+	})
+}
+
+func TestStream_ClearStatusBitDeadline(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			if v.HasStatusBitDeadline() {
+				v.ClearStatusBitDeadline()
+				assert(v.HasStatusBitDeadline()).IsFalse()
+				v.SetStatusBitDeadline()
+			}
+			assert((*v.frames[0])[streamPosStatusBit]).Equal(byte(i))
+			v.Release()
+		}
+	})
+}
+
+func TestStream_HasStatusBitMetadata(t *testing.T) {
+	t.Run("test bit set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			v.SetStatusBitMetadata()
+			assert(v.HasStatusBitMetadata()).IsTrue()
+			v.Release()
+		}
+	})
+
+	t.Run("test bit unset", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			v.ClearStatusBitMetadata()
+			assert(v.HasStatusBitMetadata()).IsFalse()
+			v.Release()
+		}
+	})
+}
+
+func TestStream_SetStatusBitMetadata(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			if !v.HasStatusBitMetadata() {
+				v.SetStatusBitMetadata()
+				assert(v.HasStatusBitMetadata()).IsTrue()
+				v.ClearStatusBitMetadata()
+			}
+			assert((*v.frames[0])[streamPosStatusBit]).Equal(byte(i))
+			v.Release()
+		}
+	})
+}
+
+func TestStream_ClearStatusBitMetadata(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			if v.HasStatusBitMetadata() {
+				v.ClearStatusBitMetadata()
+				assert(v.HasStatusBitMetadata()).IsFalse()
+				v.SetStatusBitMetadata()
+			}
+			assert((*v.frames[0])[streamPosStatusBit]).Equal(byte(i))
+			v.Release()
+		}
+	})
+}
+
+func TestStream_HasStatusBitCompressed(t *testing.T) {
+	t.Run("test bit set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			v.SetStatusBitCompressed()
+			assert(v.HasStatusBitCompressed()).IsTrue()
+			v.Release()
+		}
+	})
+
+	t.Run("test bit unset", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			v.ClearStatusBitCompressed()
+			assert(v.HasStatusBitCompressed()).IsFalse()
+			v.Release()
+		}
+	})
+}
+
+func TestStream_SetStatusBitCompressed(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			if !v.HasStatusBitCompressed() {
+				v.SetStatusBitCompressed()
+				assert(v.HasStatusBitCompressed()).IsTrue()
+				v.ClearStatusBitCompressed()
+			}
+			assert((*v.frames[0])[streamPosStatusBit]).Equal(byte(i))
+			v.Release()
+		}
+	})
+}
+
+func TestStream_ClearStatusBitCompressed(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for i := 0; i < 256; i++ {
+			v := NewStream()
+			(*v.frames[0])[streamPosStatusBit] = byte(i)
+			if v.HasStatusBitCompressed() {
+				v.ClearStatusBitCompressed()
+				assert(v.HasStatusBitCompressed()).IsFalse()
+				v.SetStatusBitCompressed()
+			}
+			assert((*v.frames[0])[streamPosStatusBit]).Equal(byte(i))
+			v.Release()
+		}
+	})
+}
+
 func TestStream_GetLength(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -1365,6 +1628,15 @@ func TestRpcStream_peekSkip(t *testing.T) {
 
 		testCollection := Array{
 			Array{[]byte{0}, 0, byte(0)},
+			Array{[]byte{0, 6, 0, 0, 0}, 0, byte(0)},
+			Array{[]byte{0, 6, 0, 0, 0, 0}, 0, byte(0)},
+			Array{[]byte{0, 6, 0, 0, 0, 1}, 6, byte(0)},
+			Array{[]byte{0, 14, 0, 0, 0, 2}, 14, byte(0)},
+			Array{[]byte{0, 7, 0, 0, 0, 1}, 0, byte(0)},
+			Array{[]byte{0, 5, 0, 0, 0, 2}, 0, byte(0)},
+			Array{[]byte{0, 10, 0, 0, 0, 3}, 10, byte(0)},
+			Array{[]byte{0, 6, 0, 0, 0, 3}, 0, byte(0)},
+			Array{[]byte{0, 6, 0, 0, 0, 4}, 0, byte(0)},
 			Array{[]byte{1}, 1, byte(1)},
 			Array{[]byte{2}, 1, byte(2)},
 			Array{[]byte{3}, 1, byte(3)},
@@ -1376,8 +1648,8 @@ func TestRpcStream_peekSkip(t *testing.T) {
 			Array{[]byte{9}, 3, byte(9)},
 			Array{[]byte{10}, 5, byte(10)},
 			Array{[]byte{11}, 9, byte(11)},
-			Array{[]byte{12}, 0, byte(12)},
-			Array{[]byte{13}, 0, byte(13)},
+			Array{[]byte{12}, 17, byte(12)},
+			Array{[]byte{13}, 9, byte(13)},
 			Array{[]byte{14}, 1, byte(14)},
 			Array{[]byte{63}, 1, byte(63)},
 			Array{[]byte{64}, 1, byte(64)},
@@ -1650,6 +1922,91 @@ func TestStream_WriteFloat64(t *testing.T) {
 	})
 }
 
+func TestStream_WriteTime(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["time"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.WriteTime(testData[0].(time.Time))
+				assert(stream.GetBuffer()[i:]).Equal(testData[1])
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+}
+
+func TestStream_WriteDuration(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["duration"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.WriteDuration(testData[0].(time.Duration))
+				assert(stream.GetBuffer()[i:]).Equal(testData[1])
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+}
+
+func TestStream_WriteInt64Array(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["int64Array"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.WriteInt64Array(testData[0].([]int64))
+				assert(stream.GetBuffer()[i:]).Equal(testData[1])
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+}
+
+func TestStream_WriteFloat64Array(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["float64Array"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.WriteFloat64Array(testData[0].([]float64))
+				assert(stream.GetBuffer()[i:]).Equal(testData[1])
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+}
+
+func TestStream_WriteStringArray(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["stringArray"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.WriteStringArray(testData[0].([]string))
+				assert(stream.GetBuffer()[i:]).Equal(testData[1])
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+}
+
 func TestStream_WriteInt64(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -1718,6 +2075,28 @@ func TestStream_WriteBytes(t *testing.T) {
 	})
 }
 
+func TestStream_WriteMetadata(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStream()
+		v.WriteMetadata(nil)
+		assert(v.ReadUint64()).Equal(uint64(0), nil)
+		assert(v.IsReadFinish()).IsTrue()
+		v.Release()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStream()
+		v.WriteMetadata(map[string]string{"traceID": "1234"})
+		assert(v.ReadUint64()).Equal(uint64(1), nil)
+		assert(v.ReadString()).Equal("traceID", nil)
+		assert(v.ReadString()).Equal("1234", nil)
+		assert(v.IsReadFinish()).IsTrue()
+		v.Release()
+	})
+}
+
 func TestStream_writeArray(t *testing.T) {
 	t.Run("test write failed", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -1898,7 +2277,8 @@ func TestStream_writeRTValue(t *testing.T) {
 					assert(stream.writeRTValue(rtValue)).Equal(StreamWriteOK)
 					assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
 					stream.SetReadPos(i)
-					assert(stream.Read()).Equal(testData[0], nil)
+					assert(stream.Read()).
+						Equal(getTestReadValue(testData[0]), nil)
 					stream.Release()
 				}
 			}
@@ -2086,17 +2466,17 @@ func TestStream_ReadFloat64(t *testing.T) {
 	})
 }
 
-func TestStream_ReadInt64(t *testing.T) {
+func TestStream_ReadTime(t *testing.T) {
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
-		for _, testData := range streamTestSuccessCollections["int64"] {
+		for _, testData := range streamTestSuccessCollections["time"] {
 			for _, i := range testRange {
 				stream := NewStream()
 				stream.SetWritePos(i)
 				stream.SetReadPos(i)
 				stream.Write(testData[0])
-				assert(stream.ReadInt64()).Equal(testData[0], nil)
+				assert(stream.ReadTime()).Equal(testData[0], nil)
 				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
 				stream.Release()
 			}
@@ -2106,7 +2486,7 @@ func TestStream_ReadInt64(t *testing.T) {
 	t.Run("test readIndex overflow", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
-		for _, testData := range streamTestSuccessCollections["int64"] {
+		for _, testData := range streamTestSuccessCollections["time"] {
 			for _, i := range testRange {
 				stream := NewStream()
 				stream.SetWritePos(i)
@@ -2116,7 +2496,7 @@ func TestStream_ReadInt64(t *testing.T) {
 				for idx := i; idx < writePos-1; idx++ {
 					stream.SetReadPos(i)
 					stream.SetWritePos(idx)
-					assert(stream.ReadInt64()).Equal(int64(0), base.ErrStream)
+					assert(stream.ReadTime()).Equal(time.Time{}, base.ErrStream)
 					assert(stream.GetReadPos()).Equal(i)
 				}
 				stream.Release()
@@ -2132,24 +2512,24 @@ func TestStream_ReadInt64(t *testing.T) {
 			stream.SetWritePos(i)
 			stream.SetReadPos(i)
 			stream.PutBytes([]byte{13})
-			assert(stream.ReadInt64()).Equal(int64(0), base.ErrStream)
+			assert(stream.ReadTime()).Equal(time.Time{}, base.ErrStream)
 			assert(stream.GetReadPos()).Equal(i)
 			stream.Release()
 		}
 	})
 }
 
-func TestStream_ReadUint64(t *testing.T) {
+func TestStream_ReadDuration(t *testing.T) {
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
-		for _, testData := range streamTestSuccessCollections["uint64"] {
+		for _, testData := range streamTestSuccessCollections["duration"] {
 			for _, i := range testRange {
 				stream := NewStream()
 				stream.SetWritePos(i)
 				stream.SetReadPos(i)
 				stream.Write(testData[0])
-				assert(stream.ReadUint64()).Equal(testData[0], nil)
+				assert(stream.ReadDuration()).Equal(testData[0], nil)
 				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
 				stream.Release()
 			}
@@ -2159,7 +2539,7 @@ func TestStream_ReadUint64(t *testing.T) {
 	t.Run("test readIndex overflow", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
-		for _, testData := range streamTestSuccessCollections["uint64"] {
+		for _, testData := range streamTestSuccessCollections["duration"] {
 			for _, i := range testRange {
 				stream := NewStream()
 				stream.SetWritePos(i)
@@ -2169,7 +2549,7 @@ func TestStream_ReadUint64(t *testing.T) {
 				for idx := i; idx < writePos-1; idx++ {
 					stream.SetReadPos(i)
 					stream.SetWritePos(idx)
-					assert(stream.ReadUint64()).Equal(uint64(0), base.ErrStream)
+					assert(stream.ReadDuration()).Equal(time.Duration(0), base.ErrStream)
 					assert(stream.GetReadPos()).Equal(i)
 				}
 				stream.Release()
@@ -2184,42 +2564,418 @@ func TestStream_ReadUint64(t *testing.T) {
 			stream := NewStream()
 			stream.SetWritePos(i)
 			stream.SetReadPos(i)
-			stream.PutBytes([]byte{13})
-			assert(stream.ReadUint64()).Equal(uint64(0), base.ErrStream)
+			stream.PutBytes([]byte{12})
+			assert(stream.ReadDuration()).Equal(time.Duration(0), base.ErrStream)
 			assert(stream.GetReadPos()).Equal(i)
 			stream.Release()
 		}
 	})
 }
 
-func TestStream_ReadString(t *testing.T) {
+func TestStream_ReadInt64Array(t *testing.T) {
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
-		for _, testData := range streamTestSuccessCollections["string"] {
+		for _, testData := range streamTestSuccessCollections["int64Array"] {
 			for _, i := range testRange {
 				stream := NewStream()
 				stream.SetWritePos(i)
 				stream.SetReadPos(i)
 				stream.Write(testData[0])
-				assert(stream.ReadString()).Equal(testData[0], nil)
+				assert(stream.ReadInt64Array()).Equal(testData[0], nil)
 				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				assert(stream.IsReadFinish()).IsTrue()
 				stream.Release()
 			}
 		}
 	})
 
-	t.Run("test readIndex overflow", func(t *testing.T) {
+	t.Run("test large array", func(t *testing.T) {
 		assert := base.NewAssert(t)
-		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
-		for _, testData := range streamTestSuccessCollections["string"] {
-			for _, i := range testRange {
-				stream := NewStream()
-				stream.SetWritePos(i)
-				stream.SetReadPos(i)
-				stream.Write(testData[0])
-				writePos := stream.GetWritePos()
-				for idx := i; idx < writePos-1; idx++ {
+		v := make([]int64, 3*streamBlockSize)
+		for i := 0; i < len(v); i++ {
+			v[i] = int64(i)
+		}
+		stream := NewStream()
+		stream.Write(v)
+		assert(stream.ReadInt64Array()).Equal(v, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		stream.Release()
+	})
+
+	t.Run("test nil and Array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.Write([]int64(nil))
+		stream.Write(Array{int64(1)})
+		assert(stream.ReadInt64Array()).Equal([]int64(nil), nil)
+		assert(stream.ReadInt64Array()).Equal([]int64{int64(1)}, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		stream.Release()
+	})
+
+	t.Run("test readIndex overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["int64Array"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				writePos := stream.GetWritePos()
+				for idx := i; idx < writePos-1; idx++ {
+					stream.SetReadPos(i)
+					stream.SetWritePos(idx)
+					assert(stream.ReadInt64Array()).Equal([]int64(nil), base.ErrStream)
+					assert(stream.GetReadPos()).Equal(i)
+				}
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test type not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, i := range testRange {
+			stream := NewStream()
+			stream.SetWritePos(i)
+			stream.SetReadPos(i)
+			stream.Write([]float64{1})
+			stream.Write(Array{true})
+			assert(stream.ReadInt64Array()).Equal([]int64(nil), base.ErrStream)
+			assert(stream.GetReadPos()).Equal(i)
+			stream.Read()
+			assert(stream.ReadInt64Array()).Equal([]int64(nil), base.ErrStream)
+			stream.Release()
+		}
+	})
+
+	t.Run("test length error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.PutBytes([]byte{0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x00})
+		assert(stream.ReadInt64Array()).Equal([]int64(nil), base.ErrStream)
+		assert(stream.GetReadPos()).Equal(streamPosBody)
+		stream.Release()
+	})
+}
+
+func TestStream_ReadFloat64Array(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["float64Array"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				assert(stream.ReadFloat64Array()).Equal(testData[0], nil)
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				assert(stream.IsReadFinish()).IsTrue()
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test large array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := make([]float64, 3*streamBlockSize)
+		for i := 0; i < len(v); i++ {
+			v[i] = float64(i) / 3
+		}
+		stream := NewStream()
+		stream.Write(v)
+		assert(stream.ReadFloat64Array()).Equal(v, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		stream.Release()
+	})
+
+	t.Run("test nil and Array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.Write([]float64(nil))
+		stream.Write(Array{float64(1)})
+		assert(stream.ReadFloat64Array()).Equal([]float64(nil), nil)
+		assert(stream.ReadFloat64Array()).Equal([]float64{float64(1)}, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		stream.Release()
+	})
+
+	t.Run("test readIndex overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["float64Array"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				writePos := stream.GetWritePos()
+				for idx := i; idx < writePos-1; idx++ {
+					stream.SetReadPos(i)
+					stream.SetWritePos(idx)
+					assert(stream.ReadFloat64Array()).Equal([]float64(nil), base.ErrStream)
+					assert(stream.GetReadPos()).Equal(i)
+				}
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test type not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, i := range testRange {
+			stream := NewStream()
+			stream.SetWritePos(i)
+			stream.SetReadPos(i)
+			stream.Write([]string{"a"})
+			stream.Write(Array{true})
+			assert(stream.ReadFloat64Array()).Equal([]float64(nil), base.ErrStream)
+			assert(stream.GetReadPos()).Equal(i)
+			stream.Read()
+			assert(stream.ReadFloat64Array()).Equal([]float64(nil), base.ErrStream)
+			stream.Release()
+		}
+	})
+
+	t.Run("test length error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.PutBytes([]byte{0x00, 0x07, 0x00, 0x00, 0x00, 0x02, 0x00})
+		assert(stream.ReadFloat64Array()).Equal([]float64(nil), base.ErrStream)
+		assert(stream.GetReadPos()).Equal(streamPosBody)
+		stream.Release()
+	})
+}
+
+func TestStream_ReadStringArray(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["stringArray"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				assert(stream.ReadStringArray()).Equal(testData[0], nil)
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				assert(stream.IsReadFinish()).IsTrue()
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test large array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := make([]string, 3*streamBlockSize)
+		for i := 0; i < len(v); i++ {
+			v[i] = strconv.Itoa(i)
+		}
+		stream := NewStream()
+		stream.Write(v)
+		assert(stream.ReadStringArray()).Equal(v, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		stream.Release()
+	})
+
+	t.Run("test nil and Array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.Write([]string(nil))
+		stream.Write(Array{"a"})
+		assert(stream.ReadStringArray()).Equal([]string(nil), nil)
+		assert(stream.ReadStringArray()).Equal([]string{"a"}, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+		stream.Release()
+	})
+
+	t.Run("test readIndex overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["stringArray"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				writePos := stream.GetWritePos()
+				for idx := i; idx < writePos-1; idx++ {
+					stream.SetReadPos(i)
+					stream.SetWritePos(idx)
+					assert(stream.ReadStringArray()).Equal([]string(nil), base.ErrStream)
+					assert(stream.GetReadPos()).Equal(i)
+				}
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test type not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, i := range testRange {
+			stream := NewStream()
+			stream.SetWritePos(i)
+			stream.SetReadPos(i)
+			stream.Write([]int64{1})
+			stream.Write(Array{true})
+			assert(stream.ReadStringArray()).Equal([]string(nil), base.ErrStream)
+			assert(stream.GetReadPos()).Equal(i)
+			stream.Read()
+			assert(stream.ReadStringArray()).Equal([]string(nil), base.ErrStream)
+			stream.Release()
+		}
+	})
+
+	t.Run("test length error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.PutBytes([]byte{0x00, 0x0A, 0x00, 0x00, 0x00, 0x03, 0x02, 0x00, 0x00, 0x00})
+		assert(stream.ReadStringArray()).Equal([]string(nil), base.ErrStream)
+		assert(stream.GetReadPos()).Equal(streamPosBody)
+		stream.Release()
+	})
+}
+
+func TestStream_ReadInt64(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["int64"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				assert(stream.ReadInt64()).Equal(testData[0], nil)
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test readIndex overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["int64"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				writePos := stream.GetWritePos()
+				for idx := i; idx < writePos-1; idx++ {
+					stream.SetReadPos(i)
+					stream.SetWritePos(idx)
+					assert(stream.ReadInt64()).Equal(int64(0), base.ErrStream)
+					assert(stream.GetReadPos()).Equal(i)
+				}
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test type not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, i := range testRange {
+			stream := NewStream()
+			stream.SetWritePos(i)
+			stream.SetReadPos(i)
+			stream.PutBytes([]byte{13})
+			assert(stream.ReadInt64()).Equal(int64(0), base.ErrStream)
+			assert(stream.GetReadPos()).Equal(i)
+			stream.Release()
+		}
+	})
+}
+
+func TestStream_ReadUint64(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["uint64"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				assert(stream.ReadUint64()).Equal(testData[0], nil)
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test readIndex overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["uint64"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				writePos := stream.GetWritePos()
+				for idx := i; idx < writePos-1; idx++ {
+					stream.SetReadPos(i)
+					stream.SetWritePos(idx)
+					assert(stream.ReadUint64()).Equal(uint64(0), base.ErrStream)
+					assert(stream.GetReadPos()).Equal(i)
+				}
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test type not match", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, i := range testRange {
+			stream := NewStream()
+			stream.SetWritePos(i)
+			stream.SetReadPos(i)
+			stream.PutBytes([]byte{13})
+			assert(stream.ReadUint64()).Equal(uint64(0), base.ErrStream)
+			assert(stream.GetReadPos()).Equal(i)
+			stream.Release()
+		}
+	})
+}
+
+func TestStream_ReadString(t *testing.T) {
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["string"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				assert(stream.ReadString()).Equal(testData[0], nil)
+				assert(stream.GetWritePos()).Equal(len(testData[1].([]byte)) + i)
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test readIndex overflow", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range streamTestSuccessCollections["string"] {
+			for _, i := range testRange {
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				writePos := stream.GetWritePos()
+				for idx := i; idx < writePos-1; idx++ {
 					stream.SetReadPos(i)
 					stream.SetWritePos(idx)
 					assert(stream.ReadString()).Equal("", base.ErrStream)
@@ -2525,6 +3281,19 @@ func TestStream_ReadBytes(t *testing.T) {
 }
 
 func TestStream_ReadArray(t *testing.T) {
+	t.Run("test packed array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.Write([]int64{1, 2})
+		stream.Write([]float64{1.5})
+		stream.Write([]string{"a"})
+		assert(stream.ReadArray()).Equal(Array{int64(1), int64(2)}, nil)
+		assert(stream.ReadArray()).Equal(Array{float64(1.5)}, nil)
+		assert(stream.ReadArray()).Equal(Array{"a"}, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+
 	t.Run("test ok", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
@@ -2737,12 +3506,12 @@ func TestStream_Read(t *testing.T) {
 		for _, item := range streamTestSuccessCollections[key] {
 			stream := NewStream()
 			stream.PutBytes(item[1].([]byte))
-			assert(stream.Read()).Equal(item[0], nil)
+			assert(stream.Read()).Equal(getTestReadValue(item[0]), nil)
 			stream.Release()
 		}
 	}
 
-	t.Run("unsupported code 12", func(t *testing.T) {
+	t.Run("incomplete time", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := NewStream()
 		stream.PutBytes([]byte{12})
@@ -2750,13 +3519,63 @@ func TestStream_Read(t *testing.T) {
 		stream.Release()
 	})
 
-	t.Run("unsupported code 13", func(t *testing.T) {
+	t.Run("incomplete duration", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream := NewStream()
 		stream.PutBytes([]byte{13})
 		assert(stream.Read()).Equal(nil, base.ErrStream)
 		stream.Release()
 	})
+
+	t.Run("packed array is read as Array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		defer stream.Release()
+		stream.Write([]int64{1, 2})
+		stream.Write([]float64{1.5})
+		stream.Write([]string{"a"})
+		stream.Write(Array{[]int64{3}})
+		assert(stream.Read()).Equal(Array{int64(1), int64(2)}, nil)
+		assert(stream.Read()).Equal(Array{float64(1.5)}, nil)
+		assert(stream.Read()).Equal(Array{"a"}, nil)
+		assert(stream.Read()).Equal(Array{Array{int64(3)}}, nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+
+	t.Run("code 0 is not a packed array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		for _, v := range [][]byte{
+			{0},
+			{0, 6, 0, 0, 0, 0},
+			{0, 6, 0, 0, 0, 4},
+			{0, 7, 0, 0, 0, 1, 0},
+			{0, 5, 0, 0, 0, 2},
+			{0, 6, 0, 0, 0, 3},
+		} {
+			// code 0 at the top level
+			stream := NewStream()
+			stream.PutBytes(v)
+			assert(stream.Read()).Equal(nil, base.ErrStream)
+			assert(stream.GetReadPos()).Equal(streamPosBody)
+			stream.Release()
+
+			// code 0 in an Array, the length of the Array covers it
+			stream = NewStream()
+			stream.PutBytes([]byte{65, byte(5 + len(v)), 0, 0, 0})
+			stream.PutBytes(v)
+			assert(stream.Read()).Equal(nil, base.ErrStream)
+			assert(stream.GetReadPos()).Equal(streamPosBody)
+			stream.Release()
+
+			// code 0 in a Map
+			stream = NewStream()
+			stream.PutBytes([]byte{97, byte(6 + len(v)), 0, 0, 0, 0x80})
+			stream.PutBytes(v)
+			assert(stream.Read()).Equal(nil, base.ErrStream)
+			assert(stream.GetReadPos()).Equal(streamPosBody)
+			stream.Release()
+		}
+	})
 }
 
 func TestStream_ReadRTArray(t *testing.T) {
@@ -2782,6 +3601,67 @@ func TestStream_ReadRTArray(t *testing.T) {
 		}
 	})
 
+	t.Run("test packed array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, testData := range [][2]interface{}{
+			{[]int64{1, -2}, Array{int64(1), int64(-2)}},
+			{[]float64{1.5, 2}, Array{1.5, float64(2)}},
+			{
+				[]string{"a", strings.Repeat("b", 80)},
+				Array{"a", strings.Repeat("b", 80)},
+			},
+		} {
+			for _, i := range testRange {
+				testRuntime.thread.Reset()
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(testData[0])
+				rtArray, err := stream.ReadRTArray(testRuntime)
+				assert(err).IsNil()
+				assert(stream.IsReadFinish()).IsTrue()
+				assert(len(*rtArray.items)).Equal(2)
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.writeRTArray(rtArray)
+				assert(stream.ReadArray()).Equal(testData[1], nil)
+				stream.Release()
+			}
+		}
+	})
+
+	t.Run("test packed array (runtime stream)", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRuntime.thread.Reset()
+		stream := testRuntime.thread.rtStream
+		stream.SetReadPos(stream.GetWritePos())
+		stream.WriteStringArray([]string{"a", "b"})
+		rtArray, err := stream.ReadRTArray(testRuntime)
+		assert(err).IsNil()
+		assert(len(*rtArray.items)).Equal(2)
+		assert((*rtArray.items)[0].isString()).IsTrue()
+		stream.SetReadPos(int((*rtArray.items)[1].getPos()))
+		assert(stream.ReadString()).Equal("b", nil)
+	})
+
+	t.Run("test packed array error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, i := range testRange {
+			testRuntime.thread.Reset()
+			stream := NewStream()
+			stream.SetWritePos(i)
+			stream.SetReadPos(i)
+			// the body of the packed []int64 is not a multiple of 8
+			stream.PutBytes([]byte{0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x02})
+			assert(stream.ReadRTArray(testRuntime)).
+				Equal(RTArray{}, base.ErrStream)
+			assert(stream.GetReadPos()).Equal(i)
+			stream.Release()
+		}
+	})
+
 	t.Run("test readIndex overflow (outer stream)", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
@@ -3075,13 +3955,40 @@ func TestStream_ReadRTValue(t *testing.T) {
 					stream.SetWritePos(i)
 					stream.SetReadPos(i)
 					stream.writeRTValue(rtValue)
-					assert(stream.Read()).Equal(testData[0], nil)
+					assert(stream.Read()).
+						Equal(getTestReadValue(testData[0]), nil)
 					stream.Release()
 				}
 			}
 		}
 	})
 
+	t.Run("test packed array", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
+		for _, v := range [][2]Any{
+			{[]int64{1}, Array{int64(1)}},
+			{[]float64{1.5}, Array{1.5}},
+			{[]string{"a"}, Array{"a"}},
+		} {
+			for _, i := range testRange {
+				testRuntime.thread.Reset()
+				stream := NewStream()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.Write(v[0])
+				rtValue, err := stream.ReadRTValue(testRuntime)
+				assert(err).IsNil()
+				assert(stream.IsReadFinish()).IsTrue()
+				stream.SetWritePos(i)
+				stream.SetReadPos(i)
+				stream.writeRTValue(rtValue)
+				assert(stream.Read()).Equal(v[1], nil)
+				stream.Release()
+			}
+		}
+	})
+
 	t.Run("test readIndex overflow (outer stream)", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		testRange := getTestRange(streamPosBody, 3*streamBlockSize, 80, 80, 61)
@@ -3165,3 +4072,58 @@ func TestStream_ReadRTValue(t *testing.T) {
 			)
 	})
 }
+
+func TestStream_ReadMetadata(t *testing.T) {
+	t.Run("size error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStream()
+		v.WriteBool(true)
+		assert(v.ReadMetadata()).Equal(nil, base.ErrStream)
+		assert(v.GetReadPos()).Equal(streamPosBody)
+		v.Release()
+	})
+
+	t.Run("size overflows", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStream()
+		v.WriteUint64(2)
+		v.WriteString("")
+		v.WriteString("")
+		assert(v.ReadMetadata()).Equal(nil, base.ErrStream)
+		assert(v.GetReadPos()).Equal(streamPosBody)
+		v.Release()
+	})
+
+	t.Run("key error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStream()
+		v.WriteUint64(1)
+		v.WriteBool(true)
+		v.WriteString("1234")
+		assert(v.ReadMetadata()).Equal(nil, base.ErrStream)
+		assert(v.GetReadPos()).Equal(streamPosBody)
+		v.Release()
+	})
+
+	t.Run("value error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewStream()
+		v.WriteUint64(1)
+		v.WriteString("traceID")
+		v.WriteBool(true)
+		assert(v.ReadMetadata()).Equal(nil, base.ErrStream)
+		assert(v.GetReadPos()).Equal(streamPosBody)
+		v.Release()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		metadata := map[string]string{"traceID": "1234", "locale": "en"}
+		v := NewStream()
+		v.WriteMetadata(metadata)
+		v.WriteBool(true)
+		assert(v.ReadMetadata()).Equal(metadata, nil)
+		assert(v.ReadBool()).Equal(true, nil)
+		v.Release()
+	})
+}
@@ -28,6 +28,14 @@ var (
 				cacheArrayEntryPos: 0,
 				cacheMapEntryPos:   0,
 				retStatus:          0,
+				numOfChunks:        0,
+				deadline:           0,
+				metadata:           nil,
+				needCallback:       false,
+				deferredReply:      nil,
+				isCancelled:        false,
+				cancelCH:           nil,
+				futures:            nil,
 				lockStatus:         0,
 				parentRTWritePos:   streamPosBody,
 				next:               nil,
@@ -47,6 +55,15 @@ type rpcThreadFrame struct {
 	cacheArrayEntryPos uint32
 	cacheMapEntryPos   uint32
 	retStatus          uint32
+	numOfChunks        uint64
+	deadline           int64
+	metadata           map[string]string
+	needCallback       bool
+	deferredReply      *DeferredReply
+	isCancelled        bool
+	cancelCH           chan bool
+	futures            []*Future
+	cancelLock         sync.Mutex
 	lockStatus         uint64
 	parentRTWritePos   int
 	next               *rpcThreadFrame
@@ -60,12 +77,75 @@ func (p *rpcThreadFrame) Reset() {
 	p.stream = nil
 	atomic.StorePointer(&p.actionNode, nil)
 	p.from = ""
+	p.metadata = nil
+	p.deferredReply = nil
 	p.cacheArrayItemsPos = 0
 	p.cacheMapItemsPos = 0
 	p.cacheArrayEntryPos = 0
 	p.cacheMapEntryPos = 0
 	p.parentRTWritePos = streamPosBody
 	p.next = nil
+	p.cancelLock.Lock()
+	// the goroutines that wait on Runtime.Done are released when the call ends
+	if p.cancelCH != nil && !p.isCancelled {
+		close(p.cancelCH)
+	}
+	p.isCancelled = false
+	p.cancelCH = nil
+	futures := p.futures
+	p.futures = nil
+	p.cancelLock.Unlock()
+
+	// the results of the futures that are never waited are released
+	for _, future := range futures {
+		future.drop()
+	}
+}
+
+func (p *rpcThreadFrame) cancel() {
+	p.cancelLock.Lock()
+	defer p.cancelLock.Unlock()
+
+	if !p.isCancelled {
+		p.isCancelled = true
+		if p.cancelCH != nil {
+			close(p.cancelCH)
+		}
+		// the calls made by Runtime.CallAsync are cancelled with the caller
+		for _, future := range p.futures {
+			future.cancel()
+		}
+	}
+}
+
+func (p *rpcThreadFrame) addFuture(future *Future) {
+	p.cancelLock.Lock()
+	defer p.cancelLock.Unlock()
+
+	p.futures = append(p.futures, future)
+	if p.isCancelled {
+		future.cancel()
+	}
+}
+
+func (p *rpcThreadFrame) getCancelled() bool {
+	p.cancelLock.Lock()
+	defer p.cancelLock.Unlock()
+	return p.isCancelled
+}
+
+func (p *rpcThreadFrame) getCancelCH() <-chan bool {
+	p.cancelLock.Lock()
+	defer p.cancelLock.Unlock()
+
+	if p.cancelCH == nil {
+		p.cancelCH = make(chan bool)
+		if p.isCancelled {
+			close(p.cancelCH)
+		}
+	}
+
+	return p.cancelCH
 }
 
 func (p *rpcThreadFrame) Release() {
@@ -294,21 +374,110 @@ func (p *rpcThread) GetExecActionDebug() string {
 	return ""
 }
 
+// getSessionPrincipal returns the principal of the session that the call
+// comes from
+func (p *rpcThread) getSessionPrincipal() *Principal {
+	stream := p.top.stream
+
+	// the session is closed, and the hook visits its snapshot
+	if snapshot := p.processor.getSessionSnapshot(
+		stream.GetGatewayID(),
+		stream.GetSessionID(),
+	); snapshot != nil {
+		return snapshot.principal
+	}
+
+	if hub, ok := p.processor.streamHub.(ISessionHub); ok {
+		return hub.GetPrincipal(stream.GetGatewayID(), stream.GetSessionID())
+	}
+
+	return nil
+}
+
+// isNodePrincipal reports whether the session of the principal is a peer node
+func isNodePrincipal(principal *Principal) bool {
+	return principal != nil && principal.HasRole(RoleNode)
+}
+
+// getPrincipal returns the principal of the caller. The call that comes from a
+// peer node carries the principal of the caller on the origin node
+func (p *rpcThread) getPrincipal() *Principal {
+	return p.getCallerPrincipal(p.getSessionPrincipal())
+}
+
+// getCallerPrincipal returns the principal of the caller by the principal of
+// the session, so the session is only looked up once
+func (p *rpcThread) getCallerPrincipal(sessionPrincipal *Principal) *Principal {
+	if isNodePrincipal(sessionPrincipal) {
+		if ret, ok := readForwardedPrincipal(p.top.metadata); ok {
+			return ret
+		}
+	}
+
+	return sessionPrincipal
+}
+
+// getForwardMetadata returns the metadata of the call to a peer node
+func (p *rpcThread) getForwardMetadata() map[string]string {
+	frame := p.top
+	sessionPrincipal := p.getSessionPrincipal()
+	session, _ := base.EncryptSessionEndpoint(
+		frame.stream.GetGatewayID(),
+		frame.stream.GetSessionID(),
+	)
+
+	// the call that comes from a peer node keeps its origin session
+	if isNodePrincipal(sessionPrincipal) {
+		if origin, ok := frame.metadata[MetadataKeySession]; ok {
+			session = origin
+		}
+	}
+
+	return makeForwardMetadata(
+		frame.metadata,
+		p.getCallerPrincipal(sessionPrincipal),
+		session,
+	)
+}
+
 func (p *rpcThread) Write(value interface{}, skip uint, debug bool) Return {
 	frame := p.top
 
-	if frame.retStatus != 0 {
+	if frame.retStatus == 3 {
+		// the stream belongs to the DeferredReply, so it can not be written
+		base.PublishPanic(
+			base.ErrRuntimeReplyHasBeenCalled.AddDebug(p.GetExecActionDebug()),
+		)
+		return emptyReturn
+	} else if frame.retStatus != 0 {
 		value = base.ErrRuntimeReplyHasBeenCalled
 	}
 
-	stream := frame.stream
+	frame.retStatus = writeReply(
+		frame.stream,
+		value,
+		p.GetExecActionNodePath(),
+		skip+1,
+		debug,
+	)
+	return emptyReturn
+}
+
+// writeReply writes the reply to the stream. It returns 1 if the reply is ok,
+// otherwise it returns 2
+func writeReply(
+	stream *Stream,
+	value interface{},
+	actionPath string,
+	skip uint,
+	debug bool,
+) uint32 {
 	stream.SetWritePosToBodyStart()
 	writeErr := (*base.Error)(nil)
 
 	if reason := stream.Write(value); reason == StreamWriteOK {
 		stream.SetKind(StreamKindRPCResponseOK)
-		frame.retStatus = 1
-		return emptyReturn
+		return 1
 	} else if err, ok := value.(*base.Error); ok {
 		if err == nil {
 			writeErr = base.ErrUnsupportedValue.AddDebug("value is nil")
@@ -323,7 +492,6 @@ func (p *rpcThread) Write(value interface{}, skip uint, debug bool) Return {
 		writeErr = base.ErrUnsupportedValue.AddDebug(reason)
 	}
 
-	frame.retStatus = 2
 	stream.SetKind(StreamKindRPCResponseError)
 
 	if debug {
@@ -332,20 +500,76 @@ func (p *rpcThread) Write(value interface{}, skip uint, debug bool) Return {
 		if msg == "" {
 			stream.WriteString(base.ConcatString(
 				writeErr.GetMessage(),
-				base.AddFileLine(p.GetExecActionNodePath(), skip+1),
+				base.AddFileLine(actionPath, skip+1),
 			))
 		} else {
 			stream.WriteString(base.ConcatString(
 				writeErr.GetMessage(),
 				"\n",
-				base.AddFileLine(p.GetExecActionNodePath(), skip+1),
+				base.AddFileLine(actionPath, skip+1),
 			))
 		}
 	} else {
 		stream.WriteUint64(uint64(writeErr.GetCode()))
 		stream.WriteString(writeErr.GetMessage())
 	}
-	return emptyReturn
+	return 2
+}
+
+func (p *rpcThread) WriteChunk(value interface{}) *base.Error {
+	frame := p.top
+
+	// the nested calls and the calls of Runtime.CallAsync have no callbackID,
+	// so the chunks could not be delivered to a caller
+	if frame.next != nil || frame.stream.GetCallbackID() == 0 {
+		return base.ErrRuntimeReplyChunkNotAvailable
+	} else if frame.retStatus != 0 {
+		return base.ErrRuntimeReplyHasBeenCalled
+	}
+
+	stream := NewStream()
+	stream.SetKind(StreamKindRPCResponseChunk)
+	if frame.stream.HasStatusBitDebug() {
+		stream.SetStatusBitDebug()
+	}
+	stream.SetGatewayID(frame.stream.GetGatewayID())
+	stream.SetSessionID(frame.stream.GetSessionID())
+	stream.SetCallbackID(frame.stream.GetCallbackID())
+	stream.WriteUint64(frame.numOfChunks)
+	if reason := stream.Write(value); reason != StreamWriteOK {
+		stream.Release()
+		return base.ErrUnsupportedValue.AddDebug(reason)
+	}
+
+	frame.numOfChunks++
+	p.processor.streamHub.OnReceiveStream(stream)
+	return nil
+}
+
+func (p *rpcThread) Defer() (*DeferredReply, *base.Error) {
+	frame := p.top
+
+	if frame.next != nil ||
+		!frame.needCallback ||
+		frame.stream.GetCallbackID() == 0 {
+		return nil, base.ErrRuntimeDeferNotAvailable
+	} else if frame.retStatus != 0 {
+		return nil, base.ErrRuntimeReplyHasBeenCalled
+	}
+
+	ret := newDeferredReply(p.processor, frame.stream, p.GetActionNode())
+	ret.startTimer(frame.deadline)
+
+	// the frame is reused after the action returns, so the cancellation of
+	// the call is moved to the frame of the DeferredReply
+	p.processor.addRunning(frame.stream, ret.frame)
+	if frame.getCancelled() {
+		ret.frame.cancel()
+	}
+
+	frame.retStatus = 3
+	frame.deferredReply = ret
+	return ret, nil
 }
 
 func (p *rpcThread) PutStream(stream *Stream) (ret bool) {
@@ -366,6 +590,41 @@ func (p *rpcThread) PutStream(stream *Stream) (ret bool) {
 	return false
 }
 
+func afterIntercept(
+	interceptors []ActionInterceptor,
+	ctx *ActionContext,
+) {
+	defer func() {
+		if v := recover(); v != nil {
+			base.PublishPanic(
+				base.ErrActionPanic.
+					AddDebug(fmt.Sprintf("runtime error: %v", v)).
+					AddDebug(string(debug.Stack())),
+			)
+		}
+	}()
+
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptors[i].After(ctx)
+	}
+}
+
+func readDeadline(stream *Stream) (int64, *base.Error) {
+	if stream.HasStatusBitDeadline() {
+		return stream.ReadInt64()
+	}
+
+	return 0, nil
+}
+
+func readMetadata(stream *Stream) (map[string]string, *base.Error) {
+	if stream.HasStatusBitMetadata() {
+		return stream.ReadMetadata()
+	}
+
+	return nil, nil
+}
+
 func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 	timeStart := base.TimeNow()
 	frame := p.top
@@ -374,20 +633,33 @@ func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 	rtID := p.sequence
 	frame.lockStatus = rtID
 	frame.retStatus = 0
+	frame.numOfChunks = 0
+	frame.deadline = 0
+	frame.metadata = nil
+	frame.needCallback = needCallback
 	frame.depth = inStream.GetDepth()
 	execActionNode := (*rpcActionNode)(nil)
 	argErrorIndex := 0
+	actionContext := (*ActionContext)(nil)
+	numOfBefore := 0
+
+	if needCallback {
+		p.processor.addRunning(inStream, frame)
+	}
 
 	defer func() {
 		if v := recover(); v != nil {
-			// write runtime error
-			p.Write(
-				base.ErrActionPanic.
-					AddDebug(fmt.Sprintf("runtime error: %v", v)).
-					AddDebug(p.GetExecActionDebug()).AddDebug(string(debug.Stack())),
-				0,
-				false,
-			)
+			err := base.ErrActionPanic.
+				AddDebug(fmt.Sprintf("runtime error: %v", v)).
+				AddDebug(p.GetExecActionDebug()).AddDebug(string(debug.Stack()))
+
+			if frame.retStatus == 3 {
+				// the reply is deferred, so the panic can only be reported
+				base.PublishPanic(err)
+			} else {
+				// write runtime error
+				p.Write(err, 0, false)
+			}
 		}
 
 		defer func() {
@@ -410,6 +682,16 @@ func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 				0,
 				false,
 			)
+		} else if frame.retStatus == 3 {
+			// the reply is deferred, the DeferredReply finishes the call
+			reply := frame.deferredReply
+			reply.timeStart = timeStart
+			if ctx := actionContext; ctx != nil {
+				reply.ctx = ctx
+				reply.interceptors = execActionNode.interceptors[:numOfBefore]
+			}
+			close(reply.readyCH)
+			return
 		} else {
 			// count
 			if execActionNode != nil {
@@ -420,11 +702,20 @@ func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 			}
 		}
 
+		// interceptors
+		if ctx := actionContext; ctx != nil {
+			inStream.SetReadPosToBodyStart()
+			ctx.result, ctx.err = ParseResponseStream(inStream)
+			ctx.cost = base.TimeNow().Sub(timeStart)
+			afterIntercept(execActionNode.interceptors[:numOfBefore], ctx)
+		}
+
 		// callback
 		inStream.SetReadPosToBodyStart()
 
 		if needCallback {
-			p.processor.streamHub.OnReceiveStream(inStream)
+			p.processor.removeRunning(inStream)
+			p.processor.onCallback(inStream)
 		}
 	}()
 
@@ -462,10 +753,55 @@ func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 		)
 	} else if frame.from, _, err = inStream.readUnsafeString(); err != nil {
 		return p.Write(err, 0, false)
+	} else if frame.deadline, err = readDeadline(inStream); err != nil {
+		return p.Write(err, 0, false)
+	} else if frame.metadata, err = readMetadata(inStream); err != nil {
+		return p.Write(err, 0, false)
+	} else if frame.deadline > 0 && timeStart.UnixNano() >= frame.deadline {
+		return p.Write(
+			base.ErrCallDeadlineExceeded.
+				AddDebug(base.ConcatString(
+					"call ",
+					actionPath,
+					" deadline exceeded",
+				)),
+			0,
+			false,
+		)
 	} else {
 		// create context
 		rt := Runtime{id: rtID, thread: p}
 
+		// authorization, the interceptors never see the forbidden calls
+		if roles := execActionNode.meta.roles; len(roles) > 0 {
+			if !rt.GetPrincipal().hasAnyRole(roles) {
+				return p.Write(
+					base.ErrActionForbidden.AddDebug(base.ConcatString(
+						"rpc-call: ",
+						actionPath,
+						" is forbidden",
+					)),
+					0,
+					false,
+				)
+			}
+		}
+
+		// interceptors
+		if interceptors := execActionNode.interceptors; len(interceptors) > 0 {
+			actionContext = newActionContext(
+				execActionNode.path,
+				string([]byte(frame.from)),
+				inStream,
+			)
+			for _, interceptor := range interceptors {
+				if err := interceptor.Before(rt, actionContext); err != nil {
+					return p.Write(err, 0, false)
+				}
+				numOfBefore++
+			}
+		}
+
 		if fnCache := execActionNode.cacheFN; fnCache != nil {
 			argErrorIndex = fnCache(rt, inStream, execActionNode.meta.handler)
 			if argErrorIndex == 0 {
@@ -543,8 +879,56 @@ func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 					} else {
 						argErrorIndex = i
 					}
+				case timeType:
+					if v, err := inStream.ReadTime(); err == nil {
+						rv = reflect.ValueOf(v)
+					} else {
+						argErrorIndex = i
+					}
+				case durationType:
+					if v, err := inStream.ReadDuration(); err == nil {
+						rv = reflect.ValueOf(v)
+					} else {
+						argErrorIndex = i
+					}
+				case int64ArrayType:
+					if v, err := inStream.ReadInt64Array(); err == nil {
+						rv = reflect.ValueOf(v)
+					} else {
+						argErrorIndex = i
+					}
+				case float64ArrayType:
+					if v, err := inStream.ReadFloat64Array(); err == nil {
+						rv = reflect.ValueOf(v)
+					} else {
+						argErrorIndex = i
+					}
+				case stringArrayType:
+					if v, err := inStream.ReadStringArray(); err == nil {
+						rv = reflect.ValueOf(v)
+					} else {
+						argErrorIndex = i
+					}
 				default:
-					argErrorIndex = i
+					// the types that are decoded by reflection
+					readPos := inStream.GetReadPos()
+					if argType := execActionNode.argTypes[i]; isMarshalerType(argType) {
+						if v, err := inStream.readMarshalerValue(argType); err != nil {
+							argErrorIndex = i
+						} else {
+							rv = v
+						}
+					} else if v, err := inStream.Read(); err != nil {
+						argErrorIndex = i
+					} else if v, reason := readReflect(
+						v,
+						execActionNode.argTypes[i],
+					); reason != StreamWriteOK {
+						inStream.SetReadPos(readPos)
+						argErrorIndex = i
+					} else {
+						rv = v
+					}
 				}
 
 				if argErrorIndex != 0 {
@@ -565,7 +949,13 @@ func (p *rpcThread) Eval(inStream *Stream, needCallback bool) Return {
 			}
 		}
 
-		if val, err := inStream.Read(); err != nil {
+		// the packed arrays are reported as the typed slices
+		fnRead := inStream.Read
+		if inStream.peekPackedKind() != 0 {
+			fnRead = inStream.readPackedArray
+		}
+
+		if val, err := fnRead(); err != nil {
 			return p.Write(err, 0, false)
 		} else if argErrorIndex < 0 {
 			return p.Write(base.ErrStream, 0, false)
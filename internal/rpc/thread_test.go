@@ -49,6 +49,7 @@ func TestRpcThreadFrame_Reset(t *testing.T) {
 		v.lockStatus = 82737243243
 		v.parentRTWritePos = 100
 		v.next = &rpcThreadFrame{}
+		v.cancel()
 		v.Reset()
 		assert(v.stream).Equal(nil)
 		assert(v.actionNode).Equal(nil)
@@ -62,10 +63,91 @@ func TestRpcThreadFrame_Reset(t *testing.T) {
 		assert(v.lockStatus).Equal(uint64(82737243243))
 		assert(v.parentRTWritePos).Equal(streamPosBody)
 		assert(v.next).Equal(nil)
+		assert(v.isCancelled).IsFalse()
+		assert(v.cancelCH == nil).IsTrue()
+		v.Release()
+	})
+
+	t.Run("cancelCH is closed", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newRPCThreadFrame()
+		ch := v.getCancelCH()
+		v.Reset()
+		_, ok := <-ch
+		assert(ok).IsFalse()
+		assert(v.cancelCH == nil).IsTrue()
+		// the channel that has been closed by cancel is not closed again
+		ch = v.getCancelCH()
+		v.cancel()
+		v.Reset()
+		_, ok = <-ch
+		assert(ok).IsFalse()
 		v.Release()
 	})
 }
 
+func TestRpcThreadFrame_cancel(t *testing.T) {
+	t.Run("cancelCH is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newRPCThreadFrame()
+		defer v.Release()
+		v.cancel()
+		assert(v.isCancelled).IsTrue()
+		assert(v.cancelCH == nil).IsTrue()
+		// cancel again
+		v.cancel()
+		assert(v.isCancelled).IsTrue()
+	})
+
+	t.Run("cancelCH is not nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newRPCThreadFrame()
+		defer v.Release()
+		ch := v.getCancelCH()
+		v.cancel()
+		_, ok := <-ch
+		assert(ok).IsFalse()
+		// cancel again
+		v.cancel()
+		assert(v.isCancelled).IsTrue()
+	})
+}
+
+func TestRpcThreadFrame_getCancelled(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newRPCThreadFrame()
+		defer v.Release()
+		assert(v.getCancelled()).IsFalse()
+		v.cancel()
+		assert(v.getCancelled()).IsTrue()
+	})
+}
+
+func TestRpcThreadFrame_getCancelCH(t *testing.T) {
+	t.Run("not cancelled", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newRPCThreadFrame()
+		defer v.Release()
+		ch := v.getCancelCH()
+		assert(ch == v.getCancelCH()).IsTrue()
+		select {
+		case <-ch:
+			assert().Fail("channel should not be closed")
+		default:
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := newRPCThreadFrame()
+		defer v.Release()
+		v.cancel()
+		_, ok := <-v.getCancelCH()
+		assert(ok).IsFalse()
+	})
+}
+
 func TestRpcThreadFrame_Release(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -704,6 +786,89 @@ func TestRpcThread_Write(t *testing.T) {
 	})
 }
 
+func TestRpcThread_WriteChunk(t *testing.T) {
+	t.Run("frame is not the outermost", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		err := (*base.Error)(nil)
+		testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				rt.thread.pushFrame()
+				err = rt.thread.WriteChunk(1)
+				rt.thread.popFrame()
+				return rt.Reply(true)
+			},
+			nil,
+		)
+		assert(err).Equal(base.ErrRuntimeReplyChunkNotAvailable)
+	})
+
+	t.Run("callbackID is zero", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		err := (*base.Error)(nil)
+		testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				// like the calls of Runtime.CallAsync
+				rt.thread.top.stream.SetCallbackID(0)
+				err = rt.thread.WriteChunk(1)
+				rt.thread.top.stream.SetCallbackID(1)
+				return rt.Reply(true)
+			},
+			nil,
+		)
+		assert(err).Equal(base.ErrRuntimeReplyChunkNotAvailable)
+	})
+
+	t.Run("reply has been called", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		err := (*base.Error)(nil)
+		stream := testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				ret := rt.Reply(true)
+				err = rt.thread.WriteChunk(1)
+				return ret
+			},
+			nil,
+		)
+		assert(err).Equal(base.ErrRuntimeReplyHasBeenCalled)
+		assert(stream.GetKind()).Equal(uint8(StreamKindRPCResponseOK))
+	})
+
+	t.Run("value is not supported", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		err := (*base.Error)(nil)
+		testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				err = rt.thread.WriteChunk(make(chan bool))
+				return rt.Reply(true)
+			},
+			nil,
+		)
+		assert(err).Equal(base.ErrUnsupportedValue.AddDebug(
+			"value type(chan bool) is not supported",
+		))
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		err := (*base.Error)(nil)
+		stream := testWithProcessorAndRuntime(
+			func(processor *Processor, rt Runtime) Return {
+				err = rt.thread.WriteChunk("hello")
+				return emptyReturn
+			},
+			nil,
+		)
+		assert(err).IsNil()
+		assert(stream.GetKind()).Equal(uint8(StreamKindRPCResponseChunk))
+		assert(stream.HasStatusBitDebug()).IsTrue()
+		assert(stream.GetGatewayID()).Equal(uint64(1234))
+		assert(stream.GetSessionID()).Equal(uint64(5678))
+		assert(stream.ReadUint64()).Equal(uint64(0), nil)
+		assert(stream.Read()).Equal("hello", nil)
+		assert(stream.IsReadFinish()).IsTrue()
+	})
+}
+
 func TestRpcThread_PutStream(t *testing.T) {
 	t.Run("thread is close", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -737,6 +902,31 @@ func TestRpcThread_PutStream(t *testing.T) {
 	})
 }
 
+func TestReadDeadline(t *testing.T) {
+	t.Run("status bit is not set", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.WriteInt64(1234)
+		assert(readDeadline(stream)).Equal(int64(0), nil)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetStatusBitDeadline()
+		stream.WriteBool(true)
+		assert(readDeadline(stream)).Equal(int64(0), base.ErrStream)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetStatusBitDeadline()
+		stream.WriteInt64(1234)
+		assert(readDeadline(stream)).Equal(int64(1234), nil)
+	})
+}
+
 func TestRpcThread_Eval(t *testing.T) {
 	t.Run("action path type is not string", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -793,6 +983,83 @@ func TestRpcThread_Eval(t *testing.T) {
 		}, stream)).Equal(nil, base.ErrStream)
 	})
 
+	t.Run("deadline data format error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetDepth(3)
+		stream.SetStatusBitDeadline()
+		stream.WriteString("#.test:Eval")
+		stream.WriteString("")
+		stream.WriteBool(true)
+		assert(testReply(true, nil, nil, func(rt Runtime) Return {
+			return rt.Reply(true)
+		}, stream)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("metadata data format error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetDepth(3)
+		stream.SetStatusBitMetadata()
+		stream.WriteString("#.test:Eval")
+		stream.WriteString("")
+		stream.WriteBool(true)
+		assert(testReply(true, nil, nil, func(rt Runtime) Return {
+			return rt.Reply(true)
+		}, stream)).Equal(nil, base.ErrStream)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetDepth(3)
+		stream.SetStatusBitDeadline()
+		stream.WriteString("#.test:Eval")
+		stream.WriteString("")
+		stream.WriteInt64(base.TimeNow().Add(-time.Second).UnixNano())
+		assert(testReply(true, nil, nil, func(rt Runtime) Return {
+			return rt.Reply(true)
+		}, stream)).Equal(
+			nil,
+			base.ErrCallDeadlineExceeded.
+				AddDebug("call #.test:Eval deadline exceeded").
+				Standardize(),
+		)
+	})
+
+	t.Run("deadline is read before args", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetDepth(3)
+		stream.SetStatusBitDeadline()
+		stream.WriteString("#.test:Eval")
+		stream.WriteString("")
+		deadline := base.TimeNow().Add(time.Minute).UnixNano()
+		stream.WriteInt64(deadline)
+		stream.WriteString("hello")
+		assert(testReply(true, nil, nil, func(rt Runtime, name String) Return {
+			v, ok := rt.GetDeadline()
+			return rt.Reply(Array{v.UnixNano(), ok, name})
+		}, stream)).Equal(Array{deadline, true, "hello"}, nil)
+	})
+
+	t.Run("metadata is read before args", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream := NewStream()
+		stream.SetDepth(3)
+		stream.SetStatusBitDeadline()
+		stream.SetStatusBitMetadata()
+		stream.WriteString("#.test:Eval")
+		stream.WriteString("")
+		stream.WriteInt64(base.TimeNow().Add(time.Minute).UnixNano())
+		stream.WriteMetadata(map[string]string{"locale": "en"})
+		stream.WriteString("hello")
+		assert(testReply(true, nil, nil, func(rt Runtime, name String) Return {
+			v, ok := rt.GetMetadata("locale")
+			return rt.Reply(Array{v, ok, name})
+		}, stream)).Equal(Array{"en", true, "hello"}, nil)
+	})
+
 	t.Run("call with all type value", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		fnTest := func(dbg bool, fnCache ActionCache) {
@@ -1134,6 +1401,170 @@ func TestRpcThread_Eval(t *testing.T) {
 		fnTest(false, &testFuncCache{})
 	})
 
+	t.Run("call with time value", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			assert(testReply(dbg, fnCache, nil,
+				func(rt Runtime, c Time, d Duration) Return {
+					return rt.Reply(c.Add(d))
+				},
+				time.Unix(10, 0).UTC(), time.Second,
+			)).Equal(time.Unix(11, 0).UTC(), nil)
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+		fnTest(true, &testFuncCache{})
+		fnTest(false, &testFuncCache{})
+	})
+
+	t.Run("time param error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			stream, source := testReplyWithSource(dbg, fnCache, nil,
+				func(rt Runtime, c Time, d Duration) Return {
+					return rt.Reply(true)
+				},
+				time.Unix(10, 0).UTC(), int64(3))
+
+			if dbg {
+				assert(ParseResponseStream(stream)).
+					Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+						"rpc-call: #.test:Eval 2nd argument does not match. "+
+							"want: rpc.Duration got: rpc.Int64",
+					).AddDebug("#.test:Eval "+source).Standardize())
+			} else {
+				assert(ParseResponseStream(stream)).
+					Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+						"rpc-call: #.test:Eval arguments does not match",
+					).Standardize())
+			}
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+		fnTest(true, &testFuncCache{})
+		fnTest(false, &testFuncCache{})
+	})
+
+	t.Run("call with typed arrays", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fn := func(rt Runtime, a []int64, b []float64, c []string) Return {
+			return rt.Reply(len(a) + len(b) + len(c))
+		}
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			assert(testReply(dbg, fnCache, nil, fn,
+				[]int64{1, 2}, []float64{1.5}, []string{"a"},
+			)).Equal(int64(4), nil)
+			// the Array and the nil are also accepted
+			assert(testReply(dbg, fnCache, nil, fn,
+				Array{int64(1)}, nil, Array{"a", "b"},
+			)).Equal(int64(3), nil)
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+	})
+
+	t.Run("typed array param error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			stream, source := testReplyWithSource(dbg, fnCache, nil,
+				func(rt Runtime, a []int64, b []string) Return {
+					return rt.Reply(true)
+				},
+				[]int64{1}, []float64{1})
+
+			if dbg {
+				assert(ParseResponseStream(stream)).
+					Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+						"rpc-call: #.test:Eval 2nd argument does not match. "+
+							"want: []string got: []float64",
+					).AddDebug("#.test:Eval "+source).Standardize())
+			} else {
+				assert(ParseResponseStream(stream)).
+					Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+						"rpc-call: #.test:Eval arguments does not match",
+					).Standardize())
+			}
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+	})
+
+	t.Run("call with reflect value", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			assert(testReply(dbg, fnCache, nil,
+				func(rt Runtime, u testReflectUser, ids []int) Return {
+					u.Age += len(ids)
+					return rt.Reply(&u)
+				},
+				testReflectUser{Name: "kitty", Age: 18}, Array{1, 2},
+			)).Equal(Map{"name": "kitty", "Age": int64(20), "Tags": nil}, nil)
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+		fnTest(true, &testFuncCache{})
+		fnTest(false, &testFuncCache{})
+	})
+
+	t.Run("reflect param error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			stream, source := testReplyWithSource(dbg, fnCache, nil,
+				func(rt Runtime, b Bool, u testReflectUser) Return {
+					return rt.Reply(true)
+				},
+				true, Map{"name": int64(3)})
+
+			if dbg {
+				assert(ParseResponseStream(stream)).
+					Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+						"rpc-call: #.test:Eval 2nd argument does not match. "+
+							"want: rpc.testReflectUser got: rpc.Map",
+					).AddDebug("#.test:Eval "+source).Standardize())
+			} else {
+				assert(ParseResponseStream(stream)).
+					Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+						"rpc-call: #.test:Eval arguments does not match",
+					).Standardize())
+			}
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+		fnTest(true, &testFuncCache{})
+		fnTest(false, &testFuncCache{})
+	})
+
+	t.Run("call with marshaler value", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		fnTest := func(dbg bool, fnCache ActionCache) {
+			assert(testReply(dbg, fnCache, nil,
+				func(rt Runtime, p testMarshalPoint, c *testMarshalPoint) Return {
+					p.X++
+					return rt.Reply(Array{p, c})
+				},
+				&testMarshalPoint{X: 1, Y: 2}, nil,
+			)).Equal(Array{"2,2", nil}, nil)
+		}
+		fnTest(true, nil)
+		fnTest(false, nil)
+		fnTest(true, &testFuncCache{})
+		fnTest(false, &testFuncCache{})
+	})
+
+	t.Run("marshaler param error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		stream, source := testReplyWithSource(true, nil, nil,
+			func(rt Runtime, p testMarshalPoint) Return {
+				return rt.Reply(true)
+			},
+			int64(3))
+		assert(ParseResponseStream(stream)).
+			Equal(nil, base.ErrArgumentsNotMatch.AddDebug(
+				"rpc-call: #.test:Eval 1st argument does not match. "+
+					"want: rpc.testMarshalPoint got: rpc.Int64",
+			).AddDebug("#.test:Eval "+source).Standardize())
+	})
+
 	t.Run("arguments length not match", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		fnTest := func(dbg bool, fnCache ActionCache) {
@@ -1297,6 +1728,7 @@ func TestRpcThread_Eval(t *testing.T) {
 					fileLine: "",
 					data:     nil,
 				}},
+				nil,
 				streamHub,
 			)
 			defer processor.Close()
@@ -1328,6 +1760,189 @@ func TestRpcThread_Eval(t *testing.T) {
 		fnTest(false, &testFuncCache{})
 	})
 
+	fnIntercept := func(
+		interceptors []ActionInterceptor,
+		handler interface{},
+		args ...interface{},
+	) *Stream {
+		service := NewService().On("Eval", handler)
+		for _, interceptor := range interceptors {
+			service.AddInterceptor(interceptor)
+		}
+		streamHub := NewTestStreamHub()
+		processor := NewProcessor(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name:     "test",
+				service:  service,
+				fileLine: "",
+				data:     nil,
+			}},
+			nil,
+			streamHub,
+		)
+		defer processor.Close()
+		stream, _ := MakeInternalRequestStream(
+			true, 0, "#.test:Eval", "@", args...,
+		)
+		processor.PutStream(stream)
+		for {
+			// skip the panic reports
+			ret := <-streamHub.streamCH
+			if ret.GetKind() != StreamKindSystemErrorReport {
+				return ret
+			}
+		}
+	}
+
+	t.Run("interceptor ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls := make([]string, 0)
+		ctxCH := make(chan *ActionContext, 1)
+		fnNew := func(name string) *testActionInterceptor {
+			return &testActionInterceptor{
+				name: name,
+				before: func(rt Runtime, ctx *ActionContext) *base.Error {
+					assert(rt.thread).IsNotNil()
+					assert(ctx.GetPath()).Equal("#.test:Eval")
+					assert(ctx.GetFrom()).Equal("@")
+					assert(ctx.GetArgs()).Equal(Array{"kitty"})
+					assert(ctx.GetResult()).IsNil()
+					assert(ctx.GetError()).IsNil()
+					calls = append(calls, "before"+name)
+					return nil
+				},
+				after: func(ctx *ActionContext) {
+					calls = append(calls, "after"+name)
+					if name == "1" {
+						ctxCH <- ctx
+					}
+				},
+			}
+		}
+
+		stream := fnIntercept(
+			[]ActionInterceptor{fnNew("1"), fnNew("2")},
+			func(rt Runtime, name String) Return {
+				calls = append(calls, "action")
+				time.Sleep(10 * time.Millisecond)
+				return rt.Reply("hello " + name)
+			},
+			"kitty",
+		)
+		assert(ParseResponseStream(stream)).Equal("hello kitty", nil)
+		ctx := <-ctxCH
+		assert(ctx.GetResult()).Equal("hello kitty")
+		assert(ctx.GetError()).IsNil()
+		assert(ctx.GetCost() >= 10*time.Millisecond).IsTrue()
+		assert(calls).Equal([]string{
+			"before1", "before2", "action", "after2", "after1",
+		})
+	})
+
+	t.Run("interceptor before error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		calls := make([]string, 0)
+		ctxCH := make(chan *ActionContext, 1)
+		stream := fnIntercept(
+			[]ActionInterceptor{
+				&testActionInterceptor{
+					name: "1",
+					before: func(rt Runtime, ctx *ActionContext) *base.Error {
+						calls = append(calls, "before1")
+						return nil
+					},
+					after: func(ctx *ActionContext) {
+						calls = append(calls, "after1")
+						ctxCH <- ctx
+					},
+				},
+				&testActionInterceptor{
+					name: "2",
+					before: func(rt Runtime, ctx *ActionContext) *base.Error {
+						calls = append(calls, "before2")
+						return base.ErrStream.AddDebug("denied")
+					},
+					after: func(ctx *ActionContext) {
+						calls = append(calls, "after2")
+					},
+				},
+				&testActionInterceptor{
+					name: "3",
+					before: func(rt Runtime, ctx *ActionContext) *base.Error {
+						calls = append(calls, "before3")
+						return nil
+					},
+				},
+			},
+			func(rt Runtime) Return {
+				calls = append(calls, "action")
+				return rt.Reply(true)
+			},
+		)
+		assert(ParseResponseStream(stream)).Equal(
+			nil, base.ErrStream.AddDebug("denied").Standardize(),
+		)
+		ctx := <-ctxCH
+		assert(ctx.GetResult()).IsNil()
+		assert(ctx.GetError()).Equal(
+			base.ErrStream.AddDebug("denied").Standardize(),
+		)
+		assert(calls).Equal([]string{"before1", "before2", "after1"})
+	})
+
+	t.Run("interceptor action error", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		ctxCH := make(chan *ActionContext, 1)
+		stream := fnIntercept(
+			[]ActionInterceptor{&testActionInterceptor{
+				name: "1",
+				after: func(ctx *ActionContext) {
+					ctxCH <- ctx
+				},
+			}},
+			func(rt Runtime) Return {
+				return rt.Reply(base.ErrStream)
+			},
+		)
+		_, err := ParseResponseStream(stream)
+		assert(err.GetCode()).Equal(base.ErrStream.GetCode())
+		assert((<-ctxCH).GetError()).Equal(err)
+	})
+
+	t.Run("interceptor after panic", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errCH := make(chan *base.Error, 1)
+		subscription := base.SubscribePanic(func(e *base.Error) {
+			errCH <- e
+		})
+		defer subscription.Close()
+
+		stream := fnIntercept(
+			[]ActionInterceptor{&testActionInterceptor{
+				name: "1",
+				after: func(ctx *ActionContext) {
+					panic("error")
+				},
+			}},
+			func(rt Runtime) Return {
+				return rt.Reply(true)
+			},
+		)
+		assert(ParseResponseStream(stream)).Equal(true, nil)
+		err := <-errCH
+		assert(err.GetCode()).Equal(base.ErrActionPanic.GetCode())
+		assert(strings.HasPrefix(
+			err.GetMessage(),
+			base.ErrActionPanic.AddDebug("runtime error: error").GetMessage(),
+		)).IsTrue()
+	})
+
 	t.Run("return without runtime", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		stream, source := testReplyWithSource(true, nil, nil,
@@ -1359,4 +1974,114 @@ func TestRpcThread_Eval(t *testing.T) {
 			}),
 		).Equal(true, nil)
 	})
+
+	fnAuthorize := func(principal *Principal, roles ...string) *Stream {
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			principal:     principal,
+		}
+		processor := NewProcessor(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().On("Eval", func(rt Runtime) Return {
+					return rt.Reply(true)
+				}, roles...),
+				fileLine: "",
+				data:     nil,
+			}},
+			nil,
+			sessionHub,
+		)
+		defer processor.Close()
+		stream, _ := MakeInternalRequestStream(true, 0, "#.test:Eval", "@")
+		stream.SetGatewayID(1234)
+		stream.SetSessionID(5678)
+		processor.PutStream(stream)
+		return <-sessionHub.streamCH
+	}
+
+	t.Run("action without roles", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(fnAuthorize(nil))).Equal(true, nil)
+	})
+
+	t.Run("action forbidden without principal", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(fnAuthorize(nil, "admin"))).Equal(
+			nil,
+			base.ErrActionForbidden.
+				AddDebug("rpc-call: #.test:Eval is forbidden").
+				Standardize(),
+		)
+	})
+
+	t.Run("action forbidden without roles", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			fnAuthorize(NewPrincipal("kitty", "user"), "admin", "root"),
+		)).Equal(
+			nil,
+			base.ErrActionForbidden.
+				AddDebug("rpc-call: #.test:Eval is forbidden").
+				Standardize(),
+		)
+	})
+
+	t.Run("action authorized", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		assert(ParseResponseStream(
+			fnAuthorize(NewPrincipal("kitty", "user", "root"), "admin", "root"),
+		)).Equal(true, nil)
+	})
+
+	t.Run("forbidden call does not reach the interceptors", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		numOfCalls := int64(0)
+		sessionHub := &testSessionHub{
+			TestStreamHub: NewTestStreamHub(),
+			principal:     NewPrincipal("kitty", "user"),
+		}
+		processor := NewProcessor(
+			1,
+			16,
+			16,
+			2048,
+			nil,
+			3*time.Second,
+			[]*ServiceMeta{{
+				name: "test",
+				service: NewService().
+					AddInterceptor(&testActionInterceptor{
+						name: "1",
+						before: func(_ Runtime, _ *ActionContext) *base.Error {
+							atomic.AddInt64(&numOfCalls, 1)
+							return base.ErrStream
+						},
+					}).
+					On("Eval", func(rt Runtime) Return {
+						return rt.Reply(true)
+					}, "admin"),
+				fileLine: "",
+				data:     nil,
+			}},
+			nil,
+			sessionHub,
+		)
+		defer processor.Close()
+		stream, _ := MakeInternalRequestStream(true, 0, "#.test:Eval", "@")
+		processor.PutStream(stream)
+		assert(ParseResponseStream(<-sessionHub.streamCH)).Equal(
+			nil,
+			base.ErrActionForbidden.
+				AddDebug("rpc-call: #.test:Eval is forbidden").
+				Standardize(),
+		)
+		assert(atomic.LoadInt64(&numOfCalls)).Equal(int64(0))
+	})
 }
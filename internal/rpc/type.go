@@ -2,41 +2,55 @@ package rpc
 
 import (
 	"reflect"
+	"time"
 	"unsafe"
 )
 
 const (
 	sizeOfPosRecord = int(unsafe.Sizeof(posRecord(0)))
 
-	vkBool    = 'B'
-	vkInt64   = 'I'
-	vkUint64  = 'U'
-	vkFloat64 = 'F'
-	vkString  = 'S'
-	vkBytes   = 'X'
-	vkArray   = 'A'
-	vkMap     = 'M'
-	vkRTValue = 'V'
-	vkRTArray = 'Y'
-	vkRTMap   = 'Z'
+	vkBool     = 'B'
+	vkInt64    = 'I'
+	vkUint64   = 'U'
+	vkFloat64  = 'F'
+	vkString   = 'S'
+	vkBytes    = 'X'
+	vkArray    = 'A'
+	vkMap      = 'M'
+	vkRTValue  = 'V'
+	vkRTArray  = 'Y'
+	vkRTMap    = 'Z'
+	vkReflect  = 'R'
+	vkTime     = 'C'
+	vkDuration = 'D'
+
+	vkInt64Array   = 'i'
+	vkFloat64Array = 'f'
+	vkStringArray  = 's'
 )
 
 var (
 	emptyReturn = &ReturnObject{}
 
-	runtimeType = reflect.ValueOf(Runtime{}).Type()
-	returnType  = reflect.ValueOf(emptyReturn).Type()
-	boolType    = reflect.ValueOf(true).Type()
-	int64Type   = reflect.ValueOf(int64(0)).Type()
-	uint64Type  = reflect.ValueOf(uint64(0)).Type()
-	float64Type = reflect.ValueOf(float64(0)).Type()
-	stringType  = reflect.ValueOf("").Type()
-	bytesType   = reflect.ValueOf(Bytes{}).Type()
-	arrayType   = reflect.ValueOf(Array{}).Type()
-	mapType     = reflect.ValueOf(Map{}).Type()
-	rtValueType = reflect.ValueOf(RTValue{}).Type()
-	rtArrayType = reflect.ValueOf(RTArray{}).Type()
-	rtMapType   = reflect.ValueOf(RTMap{}).Type()
+	runtimeType  = reflect.ValueOf(Runtime{}).Type()
+	returnType   = reflect.ValueOf(emptyReturn).Type()
+	boolType     = reflect.ValueOf(true).Type()
+	int64Type    = reflect.ValueOf(int64(0)).Type()
+	uint64Type   = reflect.ValueOf(uint64(0)).Type()
+	float64Type  = reflect.ValueOf(float64(0)).Type()
+	stringType   = reflect.ValueOf("").Type()
+	bytesType    = reflect.ValueOf(Bytes{}).Type()
+	arrayType    = reflect.ValueOf(Array{}).Type()
+	mapType      = reflect.ValueOf(Map{}).Type()
+	rtValueType  = reflect.ValueOf(RTValue{}).Type()
+	rtArrayType  = reflect.ValueOf(RTArray{}).Type()
+	rtMapType    = reflect.ValueOf(RTMap{}).Type()
+	timeType     = reflect.ValueOf(time.Time{}).Type()
+	durationType = reflect.ValueOf(time.Duration(0)).Type()
+
+	int64ArrayType   = reflect.ValueOf([]int64{}).Type()
+	float64ArrayType = reflect.ValueOf([]float64{}).Type()
+	stringArrayType  = reflect.ValueOf([]string{}).Type()
 )
 
 // ActionCache ...
@@ -69,6 +83,12 @@ type String = string
 // Bytes ...
 type Bytes = []byte
 
+// Time ...
+type Time = time.Time
+
+// Duration ...
+type Duration = time.Duration
+
 // Array ...
 type Array = []interface{}
 
@@ -41,6 +41,7 @@ func testWithRPCBenchmark(
 				fileLine: "",
 				data:     serviceData,
 			}},
+			nil,
 			&blackHoleStreamHub{},
 		); processor != nil {
 			b.ResetTimer()
@@ -36,6 +36,9 @@ type Server struct {
 	actionCache      rpc.ActionCache
 	closeTimeout     time.Duration
 	mountServices    []*rpc.ServiceMeta
+	interceptors     []rpc.ActionInterceptor
+	remoteHub        rpc.IRemoteHub
+	remoteLock       sync.Mutex
 	logHub           rpc.IStreamHub
 	sync.Mutex
 }
@@ -53,6 +56,8 @@ func NewServer() *Server {
 		actionCache:      nil,
 		closeTimeout:     defaultCloseTimeout,
 		mountServices:    make([]*rpc.ServiceMeta, 0),
+		interceptors:     make([]rpc.ActionInterceptor, 0),
+		remoteHub:        nil,
 		logHub:           rpc.NewLogToScreenErrorStreamHub("Server"),
 	}
 
@@ -145,6 +150,57 @@ func (p *Server) SetActionCache(actionCache rpc.ActionCache) *Server {
 	return p
 }
 
+// SetAuthenticator sets the authenticator that checks the credentials of the
+// clients when they connect
+func (p *Server) SetAuthenticator(authenticator gateway.Authenticator) *Server {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrServerAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.gateway.SetAuthenticator(authenticator)
+	}
+
+	return p
+}
+
+// SetCompression sets the codec that the streams to the clients are
+// compressed with, like rpc.CompressionGzip. The streams whose bodies are not
+// longer than threshold are not compressed
+func (p *Server) SetCompression(compression string, threshold int) *Server {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrServerAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.gateway.SetCompression(compression, threshold)
+	}
+
+	return p
+}
+
+// SetSessionStore ...
+func (p *Server) SetSessionStore(store gateway.SessionStore) *Server {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrServerAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.gateway.SetSessionStore(store)
+	}
+
+	return p
+}
+
 // SetLogHub ...
 func (p *Server) SetLogHub(logHub rpc.IStreamHub) *Server {
 	p.Lock()
@@ -186,6 +242,42 @@ func (p *Server) AddService(
 	return p
 }
 
+// AddInterceptor adds an interceptor that runs around all the actions of
+// the server
+func (p *Server) AddInterceptor(interceptor rpc.ActionInterceptor) *Server {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrServerAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else if interceptor != nil {
+		p.interceptors = append(p.interceptors, interceptor)
+	}
+
+	return p
+}
+
+// SetRemoteHub sets the hub that forwards the calls of Runtime.Call to the
+// actions that are mounted on the other server nodes
+func (p *Server) SetRemoteHub(remoteHub rpc.IRemoteHub) *Server {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.isRunning {
+		p.OnReceiveStream(rpc.MakeSystemErrorStream(
+			base.ErrServerAlreadyRunning.AddDebug(base.GetFileLine(1)),
+		))
+	} else {
+		p.remoteLock.Lock()
+		p.remoteHub = remoteHub
+		p.remoteLock.Unlock()
+	}
+
+	return p
+}
+
 // BuildReplyCache ...
 func (p *Server) BuildReplyCache() *Server {
 	p.Lock()
@@ -202,6 +294,7 @@ func (p *Server) BuildReplyCache() *Server {
 		nil,
 		time.Second,
 		p.mountServices,
+		nil,
 		rpc.NewTestStreamHub(),
 	)
 	defer processor.Close()
@@ -216,16 +309,107 @@ func (p *Server) BuildReplyCache() *Server {
 	return p
 }
 
+// BuildClientStub writes a client package to the stub directory beside the
+// caller's file. The package has a typed method for each action, so the
+// callers do not need to pass the action paths and the untyped arguments
+func (p *Server) BuildClientStub() *Server {
+	p.Lock()
+	defer p.Unlock()
+
+	_, file, _, _ := runtime.Caller(1)
+	buildDir := path.Dir(file)
+
+	processor := rpc.NewProcessor(
+		1,
+		64,
+		64,
+		1024,
+		nil,
+		time.Second,
+		p.mountServices,
+		nil,
+		rpc.NewTestStreamHub(),
+	)
+	defer processor.Close()
+
+	if err := processor.BuildClientStub(
+		"stub",
+		path.Join(buildDir, "stub", "rpc_client_stub.go"),
+	); err != nil {
+		p.OnReceiveStream(rpc.MakeSystemErrorStream(err))
+	}
+
+	return p
+}
+
+// GetPrincipal ...
+func (p *Server) GetPrincipal(_ uint64, sessionID uint64) *rpc.Principal {
+	// the server has only one gateway
+	return p.gateway.GetPrincipal(sessionID)
+}
+
+// GetSessionAttribute ...
+func (p *Server) GetSessionAttribute(
+	_ uint64,
+	sessionID uint64,
+	key string,
+) (rpc.Any, bool) {
+	return p.gateway.GetSessionAttribute(sessionID, key)
+}
+
+// SetSessionAttribute ...
+func (p *Server) SetSessionAttribute(
+	_ uint64,
+	sessionID uint64,
+	key string,
+	value rpc.Any,
+) bool {
+	return p.gateway.SetSessionAttribute(sessionID, key, value)
+}
+
+// CallRemote ...
+func (p *Server) CallRemote(
+	target string,
+	stream *rpc.Stream,
+	deadline int64,
+	cancelCH <-chan bool,
+) *rpc.Stream {
+	// the server lock is held by Close while it waits for the threads, so the
+	// remote hub has its own lock
+	p.remoteLock.Lock()
+	remoteHub := p.remoteHub
+	p.remoteLock.Unlock()
+
+	if remoteHub != nil {
+		return remoteHub.CallRemote(target, stream, deadline, cancelCH)
+	}
+
+	return nil
+}
+
 // OnReceiveStream ...
 func (p *Server) OnReceiveStream(stream *rpc.Stream) {
 	if stream != nil {
 		switch stream.GetKind() {
 		case rpc.StreamKindRPCRequest:
 			p.processor.PutStream(stream)
+		case rpc.StreamKindRPCCancel:
+			p.processor.Cancel(
+				stream.GetGatewayID(),
+				stream.GetSessionID(),
+				stream.GetCallbackID(),
+			)
+			stream.Release()
+		case rpc.StreamKindSessionEvent:
+			p.processor.OnSessionEvent(stream)
+		case rpc.StreamKindTopicPublish:
+			p.gateway.Publish(stream)
 		case rpc.StreamKindRPCResponseOK:
 			fallthrough
 		case rpc.StreamKindRPCResponseError:
 			fallthrough
+		case rpc.StreamKindRPCResponseChunk:
+			fallthrough
 		case rpc.StreamKindRPCBoardCast:
 			p.gateway.OutStream(stream)
 		default:
@@ -259,6 +443,7 @@ func (p *Server) Open() bool {
 			p.actionCache,
 			p.closeTimeout,
 			p.mountServices,
+			p.interceptors,
 			p,
 		); processor == nil {
 			return false
@@ -2,10 +2,12 @@ package server
 
 import (
 	"github.com/rpccloud/rpc/internal/base"
+	"github.com/rpccloud/rpc/internal/gateway"
 	"github.com/rpccloud/rpc/internal/rpc"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -52,6 +54,8 @@ func TestNewServer(t *testing.T) {
 		assert(v.logHub).IsNotNil()
 		assert(len(v.mountServices)).Equal(0)
 		assert(cap(v.mountServices)).Equal(0)
+		assert(len(v.interceptors)).Equal(0)
+		assert(v.remoteHub).IsNil()
 	})
 
 	t.Run("numOfThreads > defaultMaxNumOfThreads", func(t *testing.T) {
@@ -76,6 +80,8 @@ func TestNewServer(t *testing.T) {
 		assert(v.logHub).IsNotNil()
 		assert(len(v.mountServices)).Equal(0)
 		assert(cap(v.mountServices)).Equal(0)
+		assert(len(v.interceptors)).Equal(0)
+		assert(v.remoteHub).IsNil()
 	})
 }
 
@@ -237,6 +243,195 @@ func TestServer_AddService(t *testing.T) {
 	})
 }
 
+type testActionInterceptor struct{}
+
+func (p *testActionInterceptor) Before(
+	_ rpc.Runtime,
+	_ *rpc.ActionContext,
+) *base.Error {
+	return nil
+}
+
+func (p *testActionInterceptor) After(_ *rpc.ActionContext) {}
+
+func TestServer_AddInterceptor(t *testing.T) {
+	t.Run("server is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := NewServer()
+		v.logHub = errorHub
+		v.isRunning = true
+		interceptor := &testActionInterceptor{}
+		_, source := v.AddInterceptor(interceptor), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).Equal(
+			nil, base.ErrServerAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(len(v.interceptors)).Equal(0)
+	})
+
+	t.Run("interceptor is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.AddInterceptor(nil)).Equal(v)
+		assert(len(v.interceptors)).Equal(0)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		interceptor := &testActionInterceptor{}
+		v := NewServer()
+		assert(v.AddInterceptor(interceptor)).Equal(v)
+		assert(v.interceptors).Equal([]rpc.ActionInterceptor{interceptor})
+	})
+}
+
+func TestServer_SetAuthenticator(t *testing.T) {
+	authenticator := gateway.AuthenticatorFunc(
+		func(credentials rpc.Map) (*rpc.Principal, bool) {
+			return rpc.NewPrincipal("kitty"), true
+		},
+	)
+
+	t.Run("server is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := NewServer()
+		v.logHub = errorHub
+		v.isRunning = true
+		_, source := v.SetAuthenticator(authenticator), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).Equal(
+			nil, base.ErrServerAlreadyRunning.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.SetAuthenticator(authenticator)).Equal(v)
+	})
+}
+
+func TestServer_SetCompression(t *testing.T) {
+	t.Run("server is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := NewServer()
+		v.logHub = errorHub
+		v.isRunning = true
+		_, source := v.SetCompression(rpc.CompressionGzip, 1), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).Equal(
+			nil, base.ErrServerAlreadyRunning.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.SetCompression(rpc.CompressionGzip, 1024)).Equal(v)
+	})
+}
+
+func TestServer_GetPrincipal(t *testing.T) {
+	t.Run("session is not exist", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.GetPrincipal(0, 1)).IsNil()
+	})
+}
+
+func TestServer_SetSessionStore(t *testing.T) {
+	t.Run("server is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := NewServer()
+		v.logHub = errorHub
+		v.isRunning = true
+		_, source := v.SetSessionStore(nil), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).Equal(
+			nil, base.ErrServerAlreadyRunning.AddDebug(source).Standardize(),
+		)
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.SetSessionStore(nil)).Equal(v)
+	})
+}
+
+func TestServer_GetSessionAttribute(t *testing.T) {
+	t.Run("session is not exist", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.GetSessionAttribute(0, 1, "name")).Equal(nil, false)
+	})
+}
+
+func TestServer_SetSessionAttribute(t *testing.T) {
+	t.Run("session is not exist", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		assert(v.SetSessionAttribute(0, 1, "name", "kitty")).IsFalse()
+	})
+}
+
+type testRemoteHub struct{}
+
+func (p *testRemoteHub) CallRemote(
+	target string,
+	_ *rpc.Stream,
+	_ int64,
+	_ <-chan bool,
+) *rpc.Stream {
+	ret := rpc.NewStream()
+	ret.SetKind(rpc.StreamKindRPCResponseOK)
+	ret.WriteString(target)
+	return ret
+}
+
+func TestServer_SetRemoteHub(t *testing.T) {
+	t.Run("server is already running", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := NewServer()
+		v.logHub = errorHub
+		v.isRunning = true
+		_, source := v.SetRemoteHub(&testRemoteHub{}), base.GetFileLine(0)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).Equal(
+			nil, base.ErrServerAlreadyRunning.AddDebug(source).Standardize(),
+		)
+		assert(v.remoteHub).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		remoteHub := &testRemoteHub{}
+		v := NewServer()
+		v.SetRemoteHub(remoteHub)
+		assert(v.remoteHub).Equal(remoteHub)
+	})
+}
+
+func TestServer_CallRemote(t *testing.T) {
+	t.Run("remote hub is nil", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer()
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.user:Get", "")
+		defer stream.Release()
+		assert(v.CallRemote("#.user:Get", stream, 0, nil)).IsNil()
+	})
+
+	t.Run("test ok", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer().SetRemoteHub(&testRemoteHub{})
+		stream, _ := rpc.MakeInternalRequestStream(true, 0, "#.user:Get", "")
+		defer stream.Release()
+		backStream := v.CallRemote("#.user:Get", stream, 0, nil)
+		defer backStream.Release()
+		assert(rpc.ParseResponseStream(backStream)).Equal("#.user:Get", nil)
+	})
+}
+
 func TestServer_BuildReplyCache(t *testing.T) {
 	_, curFile, _, _ := runtime.Caller(0)
 	curDir := path.Dir(curFile)
@@ -268,6 +463,47 @@ func TestServer_BuildReplyCache(t *testing.T) {
 	})
 }
 
+func TestServer_BuildClientStub(t *testing.T) {
+	_, curFile, _, _ := runtime.Caller(0)
+	curDir := path.Dir(curFile)
+
+	t.Run("test ok", func(t *testing.T) {
+		defer func() {
+			_ = os.RemoveAll(path.Join(curDir, "stub"))
+		}()
+		assert := base.NewAssert(t)
+		v := NewServer().AddService("user", rpc.NewService().
+			On("SayHello", func(rt rpc.Runtime, name rpc.String) rpc.Return {
+				return rt.Reply("hello " + name)
+			}), nil)
+		assert(v.BuildClientStub()).Equal(v)
+		content, _ := base.ReadFromFile(
+			path.Join(curDir, "stub", "rpc_client_stub.go"),
+		)
+		assert(strings.Contains(
+			content,
+			"func (p *Client) UserSayHello(arg0 rpc.String) (rpc.Any, *rpc.Error) {",
+		)).IsTrue()
+	})
+
+	t.Run("output file exists", func(t *testing.T) {
+		defer func() {
+			_ = os.RemoveAll(path.Join(curDir, "stub"))
+		}()
+
+		_ = os.MkdirAll(path.Join(curDir, "stub"), 0555)
+		_ = os.MkdirAll(path.Join(curDir, "stub", "rpc_client_stub.go"), 0555)
+		assert := base.NewAssert(t)
+		errorHub := rpc.NewTestStreamHub()
+		v := NewServer()
+		v.logHub = errorHub
+		assert(v.BuildClientStub()).Equal(v)
+		assert(rpc.ParseResponseStream(errorHub.GetStream())).Equal(
+			nil, base.ErrCacheWriteFile.Standardize(),
+		)
+	})
+}
+
 func TestServer_OnReceiveStream(t *testing.T) {
 	t.Run("StreamKindRPCInternalRequest", func(t *testing.T) {
 		assert := base.NewAssert(t)
@@ -327,6 +563,102 @@ func TestServer_OnReceiveStream(t *testing.T) {
 			Equal(nil, base.ErrGateWaySessionNotFound)
 	})
 
+	t.Run("StreamKindRPCCancel", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		waitCH := make(chan bool)
+		v := NewServer().
+			SetNumOfThreads(1024).
+			Listen("tcp", "127.0.0.1:8888", nil).
+			AddService("test", rpc.NewService().
+				On("Wait", func(rt rpc.Runtime) rpc.Return {
+					waitCH <- true
+					<-rt.Done()
+					return rt.Reply(true)
+				}), nil)
+
+		errorHub := rpc.NewTestStreamHub()
+		v.logHub = errorHub
+		go func() {
+			v.Open()
+		}()
+
+		for !v.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		defer v.Close()
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCRequest)
+		stream.SetCallbackID(3)
+		stream.WriteString("#.test:Wait")
+		stream.WriteString("@")
+		v.OnReceiveStream(stream)
+		<-waitCH
+
+		cancelStream := rpc.NewStream()
+		cancelStream.SetKind(rpc.StreamKindRPCCancel)
+		cancelStream.SetCallbackID(3)
+		v.OnReceiveStream(cancelStream)
+
+		// the action returns, and the response can not find the session
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrGateWaySessionNotFound)
+	})
+
+	t.Run("StreamKindSessionEvent", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		waitCH := make(chan bool, 1)
+		v := NewServer().
+			SetNumOfThreads(1024).
+			Listen("tcp", "127.0.0.1:8888", nil).
+			AddService("test", rpc.NewService().
+				On("$onSessionOpen", func(rt rpc.Runtime) rpc.Return {
+					waitCH <- true
+					return rt.Reply(true)
+				}), nil)
+
+		go func() {
+			v.Open()
+		}()
+
+		for !v.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		defer v.Close()
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindSessionEvent)
+		stream.WriteString(rpc.SessionEventOpen)
+		v.OnReceiveStream(stream)
+		assert(<-waitCH).IsTrue()
+	})
+
+	t.Run("StreamKindTopicPublish", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer().
+			SetNumOfThreads(1024).
+			Listen("tcp", "127.0.0.1:8888", nil)
+
+		errorHub := rpc.NewTestStreamHub()
+		v.logHub = errorHub
+		go func() {
+			v.Open()
+		}()
+
+		// the topic can not be read, so the gateway reports the error
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindTopicPublish)
+		stream.WriteBool(true)
+
+		for !v.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		defer v.Close()
+		v.OnReceiveStream(stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrStream)
+	})
+
 	t.Run("StreamKindRPCResponseOK", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		v := NewServer().
@@ -378,6 +710,32 @@ func TestServer_OnReceiveStream(t *testing.T) {
 			Equal(nil, base.ErrGateWaySessionNotFound)
 	})
 
+	t.Run("StreamKindRPCResponseChunk", func(t *testing.T) {
+		assert := base.NewAssert(t)
+		v := NewServer().
+			SetNumOfThreads(1024).
+			Listen("tcp", "127.0.0.1:8888", nil)
+
+		errorHub := rpc.NewTestStreamHub()
+		v.logHub = errorHub
+		go func() {
+			v.Open()
+		}()
+
+		stream := rpc.NewStream()
+		stream.SetKind(rpc.StreamKindRPCResponseChunk)
+		stream.WriteUint64(0)
+		stream.Write(true)
+
+		for !v.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		defer v.Close()
+		v.OnReceiveStream(stream)
+		assert(rpc.ParseResponseStream(errorHub.WaitStream())).
+			Equal(nil, base.ErrGateWaySessionNotFound)
+	})
+
 	t.Run("StreamKindRPCBoardCast", func(t *testing.T) {
 		assert := base.NewAssert(t)
 		v := NewServer().